@@ -14,6 +14,7 @@ import (
 
 	"github.com/fileez/fileez/internal/api"
 	"github.com/fileez/fileez/internal/config"
+	"github.com/fileez/fileez/internal/crypto"
 	"github.com/fileez/fileez/internal/secure"
 	"github.com/fileez/fileez/internal/store"
 )
@@ -34,6 +35,10 @@ func main() {
 	log.Printf("  Max memory: %d MB", cfg.MaxMemory/(1024*1024))
 	log.Printf("  File expiry: %s", cfg.FileExpiry)
 	log.Printf("  Clipboard expiry: %s", cfg.ClipboardExpiry)
+	log.Printf("  Clipboard history: %d entries, %d MB", cfg.ClipboardHistory, cfg.ClipboardHistoryMaxBytes/(1024*1024))
+	if cfg.SealedOnly {
+		log.Printf("  Sealed-only mode: plaintext writes are rejected")
+	}
 
 	// Initialize decoy pool (creates noise in memory to confuse forensics)
 	// 100 decoys ranging from 1KB to 512KB (~25MB average total)
@@ -59,26 +64,119 @@ func main() {
 	// Initialize session manager
 	session := store.NewSessionManager()
 
+	// Change notifications for /api/events (metadata only)
+	events := store.NewEventBus()
+	session.SetEventBus(events)
+
+	// Device token lifetimes and inactivity auto-lock
+	tokenPolicy := store.TokenPolicy{
+		Lifetime:    cfg.TokenLifetime,
+		IdleTimeout: cfg.TokenIdleTimeout,
+		AutoLock:    cfg.AutoLockAfter,
+	}
+	session.SetTokenPolicy(tokenPolicy)
+
+	// Failed unlock tracking with backoff, lockout and optional wipe
+	unlockGuardConfig := store.UnlockGuardConfig{
+		MaxFailures: cfg.UnlockMaxFailures,
+		GlobalLimit: cfg.UnlockGlobalLimit,
+		Lockout:     cfg.UnlockLockout,
+		BackoffBase: cfg.UnlockBackoffBase,
+		BackoffMax:  cfg.UnlockBackoffMax,
+		WipeAfter:   cfg.UnlockWipeAfter,
+	}
+	unlockGuard := store.NewUnlockGuard(unlockGuardConfig)
+	session.SetUnlockGuard(unlockGuard)
+
+	// Lock scopes: files, clipboard text and clipboard image sealed on their own
+	scopes := store.NewLockScopes(session, tokenPolicy, unlockGuardConfig, events)
+
+	// KDF advertised to clients for new seals
+	kdf := crypto.KDFParams{Algorithm: cfg.KDFAlgorithm}
+	switch cfg.KDFAlgorithm {
+	case crypto.KDFArgon2id:
+		kdf.Version = crypto.Argon2Version
+		kdf.Time = cfg.Argon2Time
+		kdf.Memory = cfg.Argon2Memory
+		kdf.Parallelism = cfg.Argon2Parallelism
+	default:
+		kdf.Iterations = cfg.KDFIterations
+	}
+	if err := kdf.Validate(); err != nil {
+		log.Fatalf("Invalid KDF configuration: %v", err)
+	}
+
 	// Initialize stores
 	files := store.NewFileStore(session, memory, cfg.MaxFileSize, cfg.FileExpiry)
-	clipboard := store.NewClipboardStore(session, memory, cfg.ClipboardExpiry)
+	clipboard := store.NewClipboardStore(session, memory, cfg.ClipboardExpiry, cfg.ClipboardHistory, cfg.ClipboardHistoryMaxBytes)
+
+	// Named clipboard channels share a memory budget drawn from the global tracker
+	channelMemory, err := memory.Child(cfg.ChannelMaxMemory)
+	if err != nil {
+		log.Fatalf("Failed to create channel memory tracker: %v", err)
+	}
+	channels := store.NewChannelStore(session, channelMemory, cfg.MaxChannels, cfg.ClipboardExpiry, cfg.ClipboardHistory, cfg.ClipboardHistoryMaxBytes)
+
+	// Shared Wormhole document
+	document := store.NewDocumentStore(memory, cfg.DocumentHistory, cfg.ClipboardExpiry)
+
+	// View-once secret notes
+	notes := store.NewSecretNoteStore(memory, cfg.MaxSecretNotes, cfg.SecretNoteExpiry)
+
+	// Rooms: isolated sessions, each with a memory budget drawn from the global tracker
+	var rooms *store.RoomManager
+	if cfg.EnableRooms {
+		rooms = store.NewRoomManager(memory, store.RoomConfig{
+			MaxMemory:                cfg.RoomMaxMemory,
+			MaxFileSize:              cfg.MaxFileSize,
+			FileExpiry:               cfg.FileExpiry,
+			ClipboardExpiry:          cfg.ClipboardExpiry,
+			ClipboardHistory:         cfg.ClipboardHistory,
+			ClipboardHistoryMaxBytes: cfg.ClipboardHistoryMaxBytes,
+			MaxChannels:              cfg.MaxChannels,
+			ChannelMaxMemory:         cfg.ChannelMaxMemory,
+			DocumentHistory:          cfg.DocumentHistory,
+			TokenPolicy:              tokenPolicy,
+			UnlockGuard:              unlockGuardConfig,
+		}, cfg.MaxRooms, cfg.RoomExpiry)
+	}
+
+	files.SetEventBus(events)
+	clipboard.SetEventBus(events, "")
+	channels.SetEventBus(events)
+	document.SetEventBus(events)
 
 	// Register global intrusion callback - shred all data if debugger detected
 	tripwire.RegisterCallback(func() {
 		log.Println("[SECURITY] Intrusion detected - shredding all data")
 		files.ShredAll()
 		clipboard.ShredAll()
+		channels.Close()
+		document.Close()
+		notes.Close()
+		if rooms != nil {
+			rooms.Close()
+		}
+		scopes.Destroy()
 		session.Destroy()
 		os.Exit(1)
 	})
 
 	// Create API server
 	server := &api.Server{
-		Config:    cfg,
-		Session:   session,
-		Files:     files,
-		Clipboard: clipboard,
-		Memory:    memory,
+		Config:      cfg,
+		Session:     session,
+		Files:       files,
+		Clipboard:   clipboard,
+		Channels:    channels,
+		Document:    document,
+		Notes:       notes,
+		Rooms:       rooms,
+		Events:      events,
+		Memory:      memory,
+		UnlockGuard: unlockGuard,
+		Scopes:      scopes,
+		KDF:         kdf,
 	}
 
 	// Create router
@@ -93,6 +191,12 @@ func main() {
 		IdleTimeout:  2 * time.Minute,
 	}
 
+	// End event streams so graceful shutdown doesn't wait on them
+	httpServer.RegisterOnShutdown(events.Close)
+	if rooms != nil {
+		httpServer.RegisterOnShutdown(rooms.CloseEvents)
+	}
+
 	// Channel for shutdown signals
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
@@ -129,7 +233,29 @@ func main() {
 	clipboard.ShredAll()
 	log.Printf("  Shredded clipboard data")
 
-	// Destroy session
+	// Shred and remove channels
+	channelCount := channels.Count()
+	channels.Close()
+	log.Printf("  Shredded %d clipboard channels", channelCount)
+
+	// Shred shared document
+	document.Close()
+	log.Printf("  Shredded shared document")
+
+	// Shred secret notes
+	noteCount := notes.Count()
+	notes.Close()
+	log.Printf("  Shredded %d secret notes", noteCount)
+
+	// Shred all rooms
+	if rooms != nil {
+		roomCount := rooms.Count()
+		rooms.Close()
+		log.Printf("  Shredded %d rooms", roomCount)
+	}
+
+	// Destroy session and lock scopes
+	scopes.Destroy()
 	session.Destroy()
 	log.Printf("  Destroyed session")
 
@@ -36,11 +36,13 @@ function App() {
   const [showLockModal, setShowLockModal] = useState(false)
   const [showUnlockModal, setShowUnlockModal] = useState(false)
   const [hasExistingData, setHasExistingData] = useState(false)
+  const [sealedOnly, setSealedOnly] = useState(false) // Server rejects plaintext writes
 
   // E2EE: Local decryption key and decrypted data (never sent to server)
   // Key is stored in memory only - cleared on page refresh or lock removal
   const [encryptionKey, setEncryptionKey] = useState(null)
   const encryptionKeyRef = useRef(null) // Ref for use in callbacks
+  const sealedFilesRef = useRef(new Map()) // Decrypted sealed files by ID, with their version
 
   const clipboardSyncedRef = useRef(true)
   const clipboardCooldownRef = useRef(null)
@@ -59,6 +61,23 @@ function App() {
     }
   }, [])
 
+  // Sealed-only servers reject plaintext, so never send it to them
+  useEffect(() => {
+    fetchWithTimeout('/api/health')
+      .then(response => (response.ok ? response.json() : null))
+      .then(data => setSealedOnly(data?.mode === 'sealed-only'))
+      .catch(err => console.error('Failed to fetch server mode:', err))
+  }, [])
+
+  // Returns true if content must not be sent because it would be plaintext
+  const refusePlaintext = () => {
+    if (sealedOnly && !encryptionKeyRef.current) {
+      toast.error('This server only accepts encrypted data. Seal the session first.', { id: 'sealed-only' })
+      return true
+    }
+    return false
+  }
+
   // Keep encryption key ref in sync with state
   useEffect(() => {
     encryptionKeyRef.current = encryptionKey
@@ -157,6 +176,60 @@ function App() {
     }
   }, [sessionToken])
 
+  // E2EE: Fetch the raw ciphertext of one sealed item listed in the unlock manifest
+  const fetchSealed = async (path, token) => {
+    const response = await fetchWithTimeout(`/api/sealed/${path}`, {
+      headers: token ? { 'X-Session-Token': token } : {}
+    })
+    if (!response.ok) {
+      throw new Error(`Sealed fetch failed: ${response.status}`)
+    }
+    return new Uint8Array(await response.arrayBuffer())
+  }
+
+  // E2EE: Decrypt sealed files one at a time, fetching the content only
+  // for files that are new or changed since they were last decrypted
+  const decryptSealedFiles = async (entries, key, token) => {
+    const cache = sealedFilesRef.current
+    const decryptedFiles = []
+    for (const entry of entries) {
+      const cached = cache.get(entry.id)
+      if (cached && cached.version === entry.version) {
+        decryptedFiles.push(cached.file)
+        continue
+      }
+      try {
+        const encrypted = await fetchSealed(`files/${encodeURIComponent(entry.id)}`, token)
+        const decrypted = await crypto.decryptFile(
+          key,
+          entry.id,
+          encrypted,
+          crypto.fromBase64(entry.encryptedMetadata_b64)
+        )
+        const file = {
+          id: entry.id,
+          name: decrypted.name,
+          mimetype: decrypted.mimetype,
+          size: decrypted.size,
+          localDecryptedData: decrypted.bytes // Local only, never sent to server
+        }
+        cache.set(entry.id, { version: entry.version, file })
+        decryptedFiles.push(file)
+      } catch (err) {
+        console.error(`Failed to decrypt file ${entry.id}:`, err)
+      }
+    }
+
+    // Forget files that were deleted
+    const ids = new Set(entries.map(entry => entry.id))
+    for (const id of cache.keys()) {
+      if (!ids.has(id)) {
+        cache.delete(id)
+      }
+    }
+    return decryptedFiles
+  }
+
   const fetchFiles = async () => {
     try {
       const response = await fetchWithTimeout('/api/files', {
@@ -180,24 +253,8 @@ function App() {
         let processedFiles = data
 
         // If we have encryption key and files are encrypted, decrypt them
-        if (encryptionKeyRef.current && data.length > 0 && data[0].encrypted_b64) {
-          const decryptedFiles = []
-          for (const file of data) {
-            try {
-              const encryptedData = crypto.fromBase64(file.encrypted_b64)
-              const fileBytes = await crypto.decrypt(encryptionKeyRef.current, encryptedData)
-              decryptedFiles.push({
-                id: file.id,
-                name: file.name,
-                mimetype: file.mimetype,
-                size: file.size,
-                localDecryptedData: fileBytes
-              })
-            } catch (err) {
-              console.error(`Failed to decrypt file ${file.name}:`, err)
-            }
-          }
-          processedFiles = decryptedFiles
+        if (encryptionKeyRef.current && data.length > 0 && data[0].encryptedMetadata_b64) {
+          processedFiles = await decryptSealedFiles(data, encryptionKeyRef.current, sessionToken)
         }
 
         // Conflict detection: check if any recently uploaded files are missing
@@ -302,7 +359,7 @@ function App() {
       if (data.encrypted_b64 && encryptionKeyRef.current) {
         try {
           const encryptedData = crypto.fromBase64(data.encrypted_b64)
-          const imageBytes = await crypto.decrypt(encryptionKeyRef.current, encryptedData)
+          const imageBytes = await crypto.decrypt(encryptionKeyRef.current, encryptedData, crypto.ENVELOPE.CLIPBOARD_IMAGE)
           const base64Image = crypto.toBase64(imageBytes)
           setClipboardImageData({
             hasImage: true,
@@ -338,6 +395,12 @@ function App() {
 
     // Debounce: wait 500ms after last keystroke before saving
     clipboardDebounceRef.current = setTimeout(async () => {
+      if (refusePlaintext()) {
+        setClipboardSynced(true) // Nothing will be saved
+        clipboardSyncedRef.current = true
+        return
+      }
+
       try {
         let bodyData
 
@@ -406,6 +469,8 @@ function App() {
   }
 
   const saveClipboardImage = async (base64Data, mimetype) => {
+    if (refusePlaintext()) return
+
     try {
       let bodyData
 
@@ -413,7 +478,7 @@ function App() {
       if (encryptionKeyRef.current) {
         // Convert base64 to bytes, encrypt, then send as encrypted_b64
         const imageBytes = crypto.fromBase64(base64Data)
-        const encrypted = await crypto.encrypt(encryptionKeyRef.current, imageBytes)
+        const encrypted = await crypto.encrypt(encryptionKeyRef.current, imageBytes, crypto.ENVELOPE.CLIPBOARD_IMAGE)
         bodyData = {
           encrypted_b64: crypto.toBase64(encrypted),
           mimetype
@@ -460,6 +525,7 @@ function App() {
 
   const handleUpload = async (fileList) => {
     if (!fileList || fileList.length === 0) return
+    if (refusePlaintext()) return
 
     setUploading(true)
     setUploadProgress(0)
@@ -476,11 +542,14 @@ function App() {
           // Read file as bytes
           const fileBytes = new Uint8Array(await file.arrayBuffer())
 
-          // Encrypt the file
-          const encryptedData = await crypto.encrypt(encryptionKeyRef.current, fileBytes)
-
           // Generate a unique ID for the file
-          const fileId = `${Date.now()}-${Math.random().toString(36).substr(2, 9)}`
+          const fileId = crypto.generateFileId()
+
+          // Encrypt the file and its name, type and size, bound to its ID
+          const { encrypted, encryptedMetadata } = await crypto.encryptFile(encryptionKeyRef.current, fileId, fileBytes, {
+            name: file.name,
+            mimetype: file.type || 'application/octet-stream'
+          })
 
           // Send encrypted file data to server
           const response = await fetchWithTimeout('/api/upload/encrypted', {
@@ -488,10 +557,8 @@ function App() {
             headers: getHeaders('application/json'),
             body: JSON.stringify({
               id: fileId,
-              name: file.name,
-              mimetype: file.type || 'application/octet-stream',
-              size: file.size,
-              encrypted_b64: crypto.toBase64(encryptedData)
+              encryptedMetadata_b64: crypto.toBase64(encryptedMetadata),
+              encrypted_b64: crypto.toBase64(encrypted)
             })
           }, 120000)
 
@@ -637,7 +704,7 @@ function App() {
             if (imageResponse.ok) {
               const imageBlob = await imageResponse.blob()
               const imageBytes = new Uint8Array(await imageBlob.arrayBuffer())
-              encryptedImage = await crypto.encrypt(key, imageBytes)
+              encryptedImage = await crypto.encrypt(key, imageBytes, crypto.ENVELOPE.CLIPBOARD_IMAGE)
             }
           } catch (err) {
             console.warn('Failed to encrypt clipboard image:', err)
@@ -653,13 +720,14 @@ function App() {
             if (fileResponse.ok) {
               const fileBlob = await fileResponse.blob()
               const fileBytes = new Uint8Array(await fileBlob.arrayBuffer())
-              const encryptedData = await crypto.encrypt(key, fileBytes)
+              const { encrypted, encryptedMetadata } = await crypto.encryptFile(key, file.id, fileBytes, {
+                name: file.name,
+                mimetype: file.mimetype
+              })
               encryptedFiles.push({
                 id: file.id,
-                name: file.name,
-                mimetype: file.mimetype,
-                size: file.size,
-                encrypted_b64: crypto.toBase64(encryptedData)
+                encryptedMetadata_b64: crypto.toBase64(encryptedMetadata),
+                encrypted_b64: crypto.toBase64(encrypted)
               })
             }
           } catch (err) {
@@ -675,6 +743,7 @@ function App() {
         body: JSON.stringify({
           keyHash_b64: crypto.toBase64(keyHash),
           salt_b64: crypto.toBase64(salt),
+          kdf: crypto.DEFAULT_KDF,
           clearExisting,
           encryptedClipboard_b64: encryptedClipboard ? crypto.toBase64(encryptedClipboard) : null,
           encryptedImage_b64: encryptedImage ? crypto.toBase64(encryptedImage) : null,
@@ -705,6 +774,9 @@ function App() {
       key = null // Prevent finally from wiping our stored key
 
       toast.success('Session sealed!')
+
+      // Shown once by the lock modal; the server keeps only its hash
+      return data.recoveryCode
     } finally {
       // 6. Only wipe key if we didn't store it (error case)
       if (key) {
@@ -727,15 +799,15 @@ function App() {
       if (!saltResponse.ok) {
         throw new Error('Failed to get encryption salt')
       }
-      const { salt_b64 } = await saltResponse.json()
+      const { salt_b64, kdf } = await saltResponse.json()
       const salt = crypto.fromBase64(salt_b64)
 
-      // 2. Derive key and hash it client-side
-      key = await crypto.deriveKey(password, salt)
+      // 2. Derive key and hash it client-side, as recorded when sealing
+      key = await crypto.deriveKey(password, salt, kdf)
       const keyHash = await crypto.hashKey(key)
 
-      // 3. Verify with server and get encrypted data
-      // Note: Server stays locked, returns encrypted blobs only
+      // 3. Verify with server and get the manifest of encrypted items
+      // Note: Server stays locked, items are fetched as ciphertext only
       const response = await fetchWithTimeout('/api/unlock', {
         method: 'POST',
         headers: getHeaders('application/json'),
@@ -763,30 +835,32 @@ function App() {
 
       // 5. Store encryption key in memory for future decrypt/encrypt operations
       // This key NEVER leaves the client
+      const unlockKey = key
       setEncryptionKey(key)
       key = null // Prevent cleanup from wiping our stored key
 
-      // 6. Decrypt clipboard text locally (DO NOT send back to server!)
-      if (data.encryptedClipboard_b64) {
+      // 6. Fetch and decrypt the manifest items one at a time (DO NOT send back to server!)
+      const manifest = data.manifest || { files: [] }
+      if (manifest.clipboardText) {
         try {
-          const encryptedClipboard = crypto.fromBase64(data.encryptedClipboard_b64)
-          const text = await crypto.decryptText(encryptionKeyRef.current || await crypto.deriveKey(password, salt), encryptedClipboard)
+          const encryptedClipboard = await fetchSealed('clipboard/text', newToken)
+          const text = await crypto.decryptText(unlockKey, encryptedClipboard)
           setClipboardText(text)
         } catch (err) {
           console.error('Failed to decrypt clipboard:', err)
         }
       }
 
-      // 7. Decrypt clipboard image locally (DO NOT send back to server!)
-      if (data.encryptedImage_b64) {
+      // 7. Decrypt clipboard image locally
+      if (manifest.clipboardImage) {
         try {
-          const encryptedImage = crypto.fromBase64(data.encryptedImage_b64)
-          const imageBytes = await crypto.decrypt(encryptionKeyRef.current || await crypto.deriveKey(password, salt), encryptedImage)
+          const encryptedImage = await fetchSealed('clipboard/image', newToken)
+          const imageBytes = await crypto.decrypt(unlockKey, encryptedImage, crypto.ENVELOPE.CLIPBOARD_IMAGE)
           // Store decrypted image data locally for display
           const base64Image = crypto.toBase64(imageBytes)
           setClipboardImageData({
             hasImage: true,
-            mimeType: data.imageMimeType || 'image/png',
+            mimeType: manifest.clipboardImage.mimetype || 'image/png',
             localDecryptedData: base64Image // Local only, never sent to server
           })
         } catch (err) {
@@ -794,25 +868,10 @@ function App() {
         }
       }
 
-      // 8. Decrypt files locally (DO NOT send back to server!)
-      if (data.encryptedFiles && data.encryptedFiles.length > 0) {
-        const decryptedFiles = []
-        for (const encFile of data.encryptedFiles) {
-          try {
-            const encryptedData = crypto.fromBase64(encFile.encrypted_b64)
-            const fileBytes = await crypto.decrypt(encryptionKeyRef.current || await crypto.deriveKey(password, salt), encryptedData)
-            decryptedFiles.push({
-              id: encFile.id,
-              name: encFile.name,
-              mimetype: encFile.mimetype,
-              size: encFile.size,
-              localDecryptedData: fileBytes // Local only, never sent to server
-            })
-          } catch (err) {
-            console.error(`Failed to decrypt file ${encFile.name}:`, err)
-          }
-        }
-        setFiles(decryptedFiles)
+      // 8. Decrypt files locally
+      sealedFilesRef.current.clear()
+      if (manifest.files.length > 0) {
+        setFiles(await decryptSealedFiles(manifest.files, unlockKey, newToken))
       }
 
       // 9. Update state - session is still locked on server, but we have the key locally
@@ -829,15 +888,20 @@ function App() {
     }
   }
 
-  const handleForceUnlock = async () => {
+  // Breach with the recovery code, or remove the seal as an unlocked device
+  // (authorized by its session token if the server sets DEVICE_FORCE_UNLOCK)
+  const handleForceUnlock = async (recoveryCode) => {
     const response = await fetchWithTimeout('/api/lock/force-unlock', {
       method: 'POST',
       headers: getHeaders('application/json'),
-      body: JSON.stringify({ confirm: 'SHRED' })
+      body: JSON.stringify(recoveryCode ? { recoveryCode } : {})
     })
 
-    const data = await response.json()
-    if (!response.ok) throw new Error(data.error || 'Failed to force unlock')
+    if (!response.ok) {
+      // Errors are plain text (wrong recovery code, throttled)
+      const message = (await response.text()).trim()
+      throw new Error(message || 'Failed to force unlock')
+    }
 
     // Clear session and encryption key
     setSessionToken(null)
@@ -848,6 +912,7 @@ function App() {
       crypto.wipe(encryptionKey)
     }
     setEncryptionKey(null)
+    sealedFilesRef.current.clear()
 
     setIsLocked(false)
     prevLockedRef.current = false // Prevent "from another device" toast
@@ -2,7 +2,7 @@ import { useState, useRef, useEffect } from 'react'
 import { toast } from 'sonner'
 import { ConfirmModal } from '../ui/Modal'
 
-const MAX_IMAGE_SIZE = 1 * 1024 * 1024 // 1MB
+const MAX_IMAGE_SIZE = 5 * 1024 * 1024 // 5MB
 
 export function ClipboardImage({
   imageData,
@@ -42,7 +42,7 @@ export function ClipboardImage({
   const uploadImage = async (file) => {
     // Validate file size
     if (file.size > MAX_IMAGE_SIZE) {
-      toast.error('Image too large. Maximum size is 1MB')
+      toast.error('Image too large. Maximum size is 5MB')
       return
     }
 
@@ -7,6 +7,7 @@ export function LockModal({ isOpen, onClose, onLock, hasExistingData }) {
   const [clearExisting, setClearExisting] = useState(true)
   const [error, setError] = useState('')
   const [loading, setLoading] = useState(false)
+  const [recoveryCode, setRecoveryCode] = useState('')
 
   const handleLock = async () => {
     setError('')
@@ -23,11 +24,16 @@ export function LockModal({ isOpen, onClose, onLock, hasExistingData }) {
 
     setLoading(true)
     try {
-      await onLock(password, clearExisting)
+      const code = await onLock(password, clearExisting)
       setPassword('')
       setConfirmPassword('')
       setClearExisting(true)
-      onClose()
+      // The recovery code is shown once - keep the modal open until it is saved
+      if (code) {
+        setRecoveryCode(code)
+      } else {
+        onClose()
+      }
     } catch (err) {
       setError(err.message || 'Failed to lock session')
     } finally {
@@ -40,9 +46,42 @@ export function LockModal({ isOpen, onClose, onLock, hasExistingData }) {
     setConfirmPassword('')
     setError('')
     setClearExisting(true)
+    setRecoveryCode('')
     onClose()
   }
 
+  // Show the recovery code once after sealing
+  if (recoveryCode) {
+    return (
+      <Modal
+        isOpen={isOpen}
+        onClose={handleClose}
+        title="Recovery Code"
+        variant="default"
+        actions={
+          <button
+            onClick={handleClose}
+            className="min-h-[44px] px-4 py-2.5 font-display font-bold uppercase text-white
+                       rounded kurz-border bg-kurz-blue hover:bg-kurz-purple transition-colors
+                       focus:outline-none focus-visible:ring-2 focus-visible:ring-kurz-cyan focus-visible:ring-offset-2"
+          >
+            I Saved It
+          </button>
+        }
+      >
+        <div className="space-y-4">
+          <p className="text-sm text-kurz-dark">
+            This code is the only way to breach the seal without the password (all data is consumed).
+            It is shown <strong>once</strong> - store it somewhere safe.
+          </p>
+          <div className="bg-kurz-bg border-2 border-kurz-dark/30 rounded p-3">
+            <p className="font-mono text-sm text-kurz-dark break-all select-all">{recoveryCode}</p>
+          </div>
+        </div>
+      </Modal>
+    )
+  }
+
   return (
     <Modal
       isOpen={isOpen}
@@ -152,6 +191,7 @@ export function UnlockModal({ isOpen, onClose, onUnlock, onForceUnlock, isUnlock
   const [loading, setLoading] = useState(false)
   const [showForceUnlock, setShowForceUnlock] = useState(false)
   const [confirmShred, setConfirmShred] = useState('')
+  const [recoveryCode, setRecoveryCode] = useState('')
   const [showRemoveSeal, setShowRemoveSeal] = useState(false)
 
   const handleUnlock = async () => {
@@ -180,11 +220,17 @@ export function UnlockModal({ isOpen, onClose, onUnlock, onForceUnlock, isUnlock
       return
     }
 
+    if (!recoveryCode) {
+      setError('Recovery code is required')
+      return
+    }
+
     setLoading(true)
     try {
-      await onForceUnlock()
+      await onForceUnlock(recoveryCode)
       setPassword('')
       setConfirmShred('')
+      setRecoveryCode('')
       setShowForceUnlock(false)
       onClose()
     } catch (err) {
@@ -219,6 +265,7 @@ export function UnlockModal({ isOpen, onClose, onUnlock, onForceUnlock, isUnlock
     setShowForceUnlock(false)
     setShowRemoveSeal(false)
     setConfirmShred('')
+    setRecoveryCode('')
     onClose()
   }
 
@@ -372,7 +419,7 @@ export function UnlockModal({ isOpen, onClose, onUnlock, onForceUnlock, isUnlock
             </button>
             <button
               onClick={handleForceUnlock}
-              disabled={loading || confirmShred !== 'CONSUME'}
+              disabled={loading || confirmShred !== 'CONSUME' || !recoveryCode}
               className="min-h-[44px] px-4 py-2.5 font-display font-bold uppercase text-white
                          rounded kurz-border bg-kurz-pink hover:bg-kurz-orange transition-colors
                          focus:outline-none focus-visible:ring-2 focus-visible:ring-kurz-cyan focus-visible:ring-offset-2
@@ -397,6 +444,22 @@ export function UnlockModal({ isOpen, onClose, onUnlock, onForceUnlock, isUnlock
             </div>
           </div>
 
+          <div>
+            <label className="block text-sm font-display font-semibold text-kurz-dark mb-1">
+              Recovery code
+            </label>
+            <input
+              type="text"
+              value={recoveryCode}
+              onChange={(e) => setRecoveryCode(e.target.value)}
+              placeholder="Shown when the session was sealed"
+              className="w-full px-3 py-2.5 border-2 border-kurz-dark/30 rounded
+                         focus:border-kurz-pink focus:ring-2 focus:ring-kurz-pink/20 outline-none
+                         font-mono text-kurz-dark uppercase"
+              autoFocus
+            />
+          </div>
+
           <div>
             <label className="block text-sm font-display font-semibold text-kurz-dark mb-1">
               Type "CONSUME" to confirm
@@ -409,7 +472,6 @@ export function UnlockModal({ isOpen, onClose, onUnlock, onForceUnlock, isUnlock
               className="w-full px-3 py-2.5 border-2 border-kurz-dark/30 rounded
                          focus:border-kurz-pink focus:ring-2 focus:ring-kurz-pink/20 outline-none
                          font-mono text-kurz-dark uppercase"
-              autoFocus
             />
           </div>
 
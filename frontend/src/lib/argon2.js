@@ -0,0 +1,412 @@
+/**
+ * Argon2id (RFC 9106, version 0x13) and the BLAKE2b it is built on.
+ * Web Crypto has no Argon2, so sessions sealed with an Argon2id descriptor
+ * derive their key here. Must match golang.org/x/crypto/argon2 as used by
+ * internal/crypto/kdf.go.
+ *
+ * 64-bit words are kept as (low, high) pairs of 32-bit halves in
+ * Uint32Arrays: word i is at [2i] (low) and [2i + 1] (high).
+ */
+
+export const ARGON2_VERSION = 0x13
+
+const ARGON2ID = 2
+const SYNC_POINTS = 4
+const BLOCK_WORDS = 128 // 64-bit words in a 1 KiB block
+
+// BLAKE2b initialization vector, as 32-bit halves (low, high)
+const BLAKE2B_IV = new Uint32Array([
+  0xf3bcc908, 0x6a09e667, 0x84caa73b, 0xbb67ae85,
+  0xfe94f82b, 0x3c6ef372, 0x5f1d36f1, 0xa54ff53a,
+  0xade682d1, 0x510e527f, 0x2b3e6c1f, 0x9b05688c,
+  0xfb41bd6b, 0x1f83d9ab, 0x137e2179, 0x5be0cd19
+])
+
+const BLAKE2B_SIGMA = [
+  [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15],
+  [14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3],
+  [11, 8, 12, 0, 5, 2, 15, 13, 10, 14, 3, 6, 7, 1, 9, 4],
+  [7, 9, 3, 1, 13, 12, 11, 14, 2, 6, 5, 10, 4, 0, 15, 8],
+  [9, 0, 5, 7, 2, 4, 10, 15, 14, 1, 11, 12, 6, 8, 3, 13],
+  [2, 12, 6, 10, 0, 11, 8, 3, 4, 13, 7, 5, 15, 14, 1, 9],
+  [12, 5, 1, 15, 14, 13, 4, 10, 0, 7, 6, 3, 9, 2, 8, 11],
+  [13, 11, 7, 14, 12, 1, 3, 9, 5, 0, 15, 4, 8, 6, 2, 10],
+  [6, 15, 14, 9, 11, 3, 0, 8, 12, 2, 13, 7, 1, 4, 10, 5],
+  [10, 2, 8, 4, 7, 6, 1, 5, 15, 11, 9, 14, 3, 12, 13, 0],
+  [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15],
+  [14, 10, 4, 8, 9, 15, 13, 6, 1, 12, 0, 2, 11, 7, 5, 3]
+]
+
+/**
+ * v[a] += v[b] + m, with m given as its halves.
+ */
+function add64(v, a, b, mLow, mHigh) {
+  const low = v[2 * a] + v[2 * b] + mLow
+  v[2 * a + 1] = v[2 * a + 1] + v[2 * b + 1] + mHigh + Math.floor(low / 0x100000000)
+  v[2 * a] = low
+}
+
+/**
+ * v[a] = (v[a] ^ v[b]) rotated right by n bits (0 < n < 64).
+ */
+function xorRotr64(v, a, b, n) {
+  let low = v[2 * a] ^ v[2 * b]
+  let high = v[2 * a + 1] ^ v[2 * b + 1]
+  if (n >= 32) {
+    const t = low
+    low = high
+    high = t
+    n -= 32
+  }
+  if (n > 0) {
+    const t = (low >>> n) | (high << (32 - n))
+    high = (high >>> n) | (low << (32 - n))
+    low = t
+  }
+  v[2 * a] = low
+  v[2 * a + 1] = high
+}
+
+function blake2bG(v, m, a, b, c, d, x, y) {
+  add64(v, a, b, m[2 * x], m[2 * x + 1])
+  xorRotr64(v, d, a, 32)
+  add64(v, c, d, 0, 0)
+  xorRotr64(v, b, c, 24)
+  add64(v, a, b, m[2 * y], m[2 * y + 1])
+  xorRotr64(v, d, a, 16)
+  add64(v, c, d, 0, 0)
+  xorRotr64(v, b, c, 63)
+}
+
+/**
+ * Compress one 128-byte block into the state h.
+ * @param {Uint32Array} h - State (8 words)
+ * @param {Uint8Array} block - 128 bytes
+ * @param {number} length - Bytes hashed so far, including this block
+ * @param {boolean} last - Whether this is the final block
+ */
+function blake2bCompress(h, block, length, last) {
+  const v = new Uint32Array(32)
+  const m = new Uint32Array(32)
+  v.set(h)
+  v.set(BLAKE2B_IV, 16)
+  v[24] ^= length >>> 0
+  v[25] ^= Math.floor(length / 0x100000000)
+  if (last) {
+    v[28] = ~v[28]
+    v[29] = ~v[29]
+  }
+  for (let i = 0; i < 32; i++) {
+    m[i] = block[4 * i] | (block[4 * i + 1] << 8) | (block[4 * i + 2] << 16) | (block[4 * i + 3] << 24)
+  }
+
+  for (const s of BLAKE2B_SIGMA) {
+    blake2bG(v, m, 0, 4, 8, 12, s[0], s[1])
+    blake2bG(v, m, 1, 5, 9, 13, s[2], s[3])
+    blake2bG(v, m, 2, 6, 10, 14, s[4], s[5])
+    blake2bG(v, m, 3, 7, 11, 15, s[6], s[7])
+    blake2bG(v, m, 0, 5, 10, 15, s[8], s[9])
+    blake2bG(v, m, 1, 6, 11, 12, s[10], s[11])
+    blake2bG(v, m, 2, 7, 8, 13, s[12], s[13])
+    blake2bG(v, m, 3, 4, 9, 14, s[14], s[15])
+  }
+
+  for (let i = 0; i < 16; i++) {
+    h[i] ^= v[i] ^ v[i + 16]
+  }
+}
+
+/**
+ * Unkeyed BLAKE2b (RFC 7693).
+ * @param {number} outLength - Digest length in bytes (1-64)
+ * @param {Uint8Array} input - Data to hash
+ * @returns {Uint8Array} Digest
+ */
+export function blake2b(outLength, input) {
+  const h = new Uint32Array(BLAKE2B_IV)
+  h[0] ^= 0x01010000 ^ outLength
+
+  const block = new Uint8Array(128)
+  let offset = 0
+  while (input.length - offset > 128) {
+    blake2bCompress(h, input.subarray(offset, offset + 128), offset + 128, false)
+    offset += 128
+  }
+  block.set(input.subarray(offset))
+  blake2bCompress(h, block, input.length, true)
+
+  const out = new Uint8Array(outLength)
+  for (let i = 0; i < outLength; i++) {
+    out[i] = h[i >> 2] >>> (8 * (i & 3))
+  }
+  return out
+}
+
+/**
+ * Little-endian encoding of a 32-bit value.
+ */
+function le32(n) {
+  return new Uint8Array([n, n >>> 8, n >>> 16, n >>> 24])
+}
+
+function concat(...parts) {
+  const out = new Uint8Array(parts.reduce((n, part) => n + part.length, 0))
+  let offset = 0
+  for (const part of parts) {
+    out.set(part, offset)
+    offset += part.length
+  }
+  return out
+}
+
+/**
+ * Variable-length hash H' of RFC 9106, section 3.3.
+ */
+function blake2bLong(outLength, input) {
+  let v = blake2b(Math.min(outLength, 64), concat(le32(outLength), input))
+  if (outLength <= 64) {
+    return v
+  }
+
+  const out = new Uint8Array(outLength)
+  let offset = 0
+  while (outLength - offset > 64) {
+    out.set(v.subarray(0, 32), offset)
+    offset += 32
+    v = blake2b(Math.min(outLength - offset, 64), v)
+  }
+  out.set(v, offset)
+  return out
+}
+
+/**
+ * low and high halves of 2 * low(v[a]) * low(v[b]).
+ */
+let mulLow = 0
+let mulHigh = 0
+function mul2(v, a, b) {
+  const x = v[2 * a]
+  const y = v[2 * b]
+  const xl = x & 0xffff
+  const xh = x >>> 16
+  const yl = y & 0xffff
+  const yh = y >>> 16
+  const ll = xl * yl
+  const lh = xl * yh
+  const hl = xh * yl
+  const mid = (ll >>> 16) + (lh & 0xffff) + (hl & 0xffff)
+  const low = ((mid & 0xffff) << 16) | (ll & 0xffff)
+  const high = xh * yh + (lh >>> 16) + (hl >>> 16) + (mid >>> 16)
+  mulLow = (low << 1) >>> 0
+  mulHigh = ((high << 1) | (low >>> 31)) >>> 0
+}
+
+/**
+ * Argon2's BlaMka G: BLAKE2b's G with the additions replaced by
+ * x + y + 2 * low(x) * low(y).
+ */
+function blamkaG(v, a, b, c, d) {
+  mul2(v, a, b)
+  add64(v, a, b, mulLow, mulHigh)
+  xorRotr64(v, d, a, 32)
+  mul2(v, c, d)
+  add64(v, c, d, mulLow, mulHigh)
+  xorRotr64(v, b, c, 24)
+  mul2(v, a, b)
+  add64(v, a, b, mulLow, mulHigh)
+  xorRotr64(v, d, a, 16)
+  mul2(v, c, d)
+  add64(v, c, d, mulLow, mulHigh)
+  xorRotr64(v, b, c, 63)
+}
+
+function blamkaRound(v, w) {
+  blamkaG(v, w[0], w[4], w[8], w[12])
+  blamkaG(v, w[1], w[5], w[9], w[13])
+  blamkaG(v, w[2], w[6], w[10], w[14])
+  blamkaG(v, w[3], w[7], w[11], w[15])
+  blamkaG(v, w[0], w[5], w[10], w[15])
+  blamkaG(v, w[1], w[6], w[11], w[12])
+  blamkaG(v, w[2], w[7], w[8], w[13])
+  blamkaG(v, w[3], w[4], w[9], w[14])
+}
+
+// Word indices of the rows and columns the permutation P is applied to
+const ROWS = []
+const COLUMNS = []
+for (let i = 0; i < 8; i++) {
+  ROWS.push(Array.from({ length: 16 }, (_, j) => 16 * i + j))
+  COLUMNS.push(Array.from({ length: 16 }, (_, j) => 2 * i + 16 * (j >> 1) + (j & 1)))
+}
+
+const scratch = new Uint32Array(2 * BLOCK_WORDS)
+const input = new Uint32Array(2 * BLOCK_WORDS)
+
+/**
+ * Compression function G: out (^)= P(x ^ y) ^ x ^ y.
+ * Blocks are word offsets into their memory arrays.
+ */
+function compress(out, o, x, xo, y, yo, xor) {
+  for (let i = 0; i < 2 * BLOCK_WORDS; i++) {
+    input[i] = x[xo + i] ^ y[yo + i]
+  }
+  scratch.set(input)
+  for (const row of ROWS) {
+    blamkaRound(scratch, row)
+  }
+  for (const column of COLUMNS) {
+    blamkaRound(scratch, column)
+  }
+  for (let i = 0; i < 2 * BLOCK_WORDS; i++) {
+    out[o + i] = (xor ? out[o + i] : 0) ^ scratch[i] ^ input[i]
+  }
+}
+
+/**
+ * floor(x * y / 2^32) for 32-bit x and y.
+ */
+function mulHigh32(x, y) {
+  // Split x so that both partial products are exact doubles
+  const high = (x >>> 16) * y
+  const low = (x & 0xffff) * y
+  return Math.floor((high + Math.floor(low / 0x10000)) / 0x10000)
+}
+
+/**
+ * Argon2id key derivation.
+ * @param {Uint8Array} password
+ * @param {Uint8Array} salt
+ * @param {object} params - time (passes), memory (KiB), parallelism (lanes)
+ * @param {number} [keyLength] - Output length in bytes
+ * @returns {Uint8Array} Derived key
+ */
+export function argon2id(password, salt, { time, memory, parallelism }, keyLength = 32) {
+  const h0 = blake2b(64, concat(
+    le32(parallelism), le32(keyLength), le32(memory), le32(time),
+    le32(ARGON2_VERSION), le32(ARGON2ID),
+    le32(password.length), password,
+    le32(salt.length), salt,
+    le32(0), le32(0)
+  ))
+
+  let blocks = Math.floor(memory / (SYNC_POINTS * parallelism)) * SYNC_POINTS * parallelism
+  if (blocks < 2 * SYNC_POINTS * parallelism) {
+    blocks = 2 * SYNC_POINTS * parallelism
+  }
+  const laneLength = blocks / parallelism
+  const segmentLength = laneLength / SYNC_POINTS
+  const B = new Uint32Array(blocks * 2 * BLOCK_WORDS)
+
+  const loadBlock = (index, bytes) => {
+    const o = index * 2 * BLOCK_WORDS
+    for (let i = 0; i < 2 * BLOCK_WORDS; i++) {
+      B[o + i] = bytes[4 * i] | (bytes[4 * i + 1] << 8) | (bytes[4 * i + 2] << 16) | (bytes[4 * i + 3] << 24)
+    }
+  }
+  for (let lane = 0; lane < parallelism; lane++) {
+    loadBlock(lane * laneLength, blake2bLong(1024, concat(h0, le32(0), le32(lane))))
+    loadBlock(lane * laneLength + 1, blake2bLong(1024, concat(h0, le32(1), le32(lane))))
+  }
+
+  const zero = new Uint32Array(2 * BLOCK_WORDS)
+  const addresses = new Uint32Array(2 * BLOCK_WORDS)
+  const addressInput = new Uint32Array(2 * BLOCK_WORDS)
+  const nextAddresses = () => {
+    addressInput[12]++ // Counter (word 6)
+    compress(addresses, 0, addressInput, 0, zero, 0, false)
+    compress(addresses, 0, addresses, 0, zero, 0, false)
+  }
+
+  for (let pass = 0; pass < time; pass++) {
+    for (let slice = 0; slice < SYNC_POINTS; slice++) {
+      for (let lane = 0; lane < parallelism; lane++) {
+        // Argon2id: data-independent addressing in the first half of the first pass
+        const independent = pass === 0 && slice < SYNC_POINTS / 2
+        if (independent) {
+          addressInput.fill(0)
+          addressInput[0] = pass
+          addressInput[2] = lane
+          addressInput[4] = slice
+          addressInput[6] = blocks
+          addressInput[8] = time
+          addressInput[10] = ARGON2ID
+        }
+
+        let index = 0
+        if (pass === 0 && slice === 0) {
+          index = 2 // The first two blocks are already filled
+          if (independent) {
+            nextAddresses()
+          }
+        }
+
+        let offset = lane * laneLength + slice * segmentLength + index
+        for (; index < segmentLength; index++, offset++) {
+          let prev = offset - 1
+          if (index === 0 && slice === 0) {
+            prev += laneLength // Last block of the lane
+          }
+
+          let randLow, randHigh
+          if (independent) {
+            if (index % BLOCK_WORDS === 0) {
+              nextAddresses()
+            }
+            randLow = addresses[2 * (index % BLOCK_WORDS)]
+            randHigh = addresses[2 * (index % BLOCK_WORDS) + 1]
+          } else {
+            randLow = B[prev * 2 * BLOCK_WORDS]
+            randHigh = B[prev * 2 * BLOCK_WORDS + 1]
+          }
+
+          // Reference block (RFC 9106, section 3.4.1.2)
+          let refLane = randHigh % parallelism
+          if (pass === 0 && slice === 0) {
+            refLane = lane
+          }
+          let area = 3 * segmentLength
+          let start = ((slice + 1) % SYNC_POINTS) * segmentLength
+          if (lane === refLane) {
+            area += index
+          }
+          if (pass === 0) {
+            area = slice * segmentLength
+            start = 0
+            if (slice === 0 || lane === refLane) {
+              area += index
+            }
+          }
+          if (index === 0 || lane === refLane) {
+            area--
+          }
+          const x = mulHigh32(randLow, randLow)
+          const y = mulHigh32(area, x)
+          const ref = refLane * laneLength + (start + area - (y + 1)) % laneLength
+
+          compress(B, offset * 2 * BLOCK_WORDS, B, prev * 2 * BLOCK_WORDS, B, ref * 2 * BLOCK_WORDS, true)
+        }
+      }
+    }
+  }
+
+  // XOR the last block of every lane into the final block
+  const final = B.subarray((laneLength - 1) * 2 * BLOCK_WORDS, laneLength * 2 * BLOCK_WORDS)
+  for (let lane = 1; lane < parallelism; lane++) {
+    const o = (lane * laneLength + laneLength - 1) * 2 * BLOCK_WORDS
+    for (let i = 0; i < 2 * BLOCK_WORDS; i++) {
+      final[i] ^= B[o + i]
+    }
+  }
+  const finalBytes = new Uint8Array(1024)
+  for (let i = 0; i < 2 * BLOCK_WORDS; i++) {
+    finalBytes[4 * i] = final[i]
+    finalBytes[4 * i + 1] = final[i] >>> 8
+    finalBytes[4 * i + 2] = final[i] >>> 16
+    finalBytes[4 * i + 3] = final[i] >>> 24
+  }
+
+  const key = blake2bLong(keyLength, finalBytes)
+  B.fill(0)
+  finalBytes.fill(0)
+  return key
+}
@@ -1,6 +1,6 @@
 /**
  * Client-side cryptographic utilities for E2EE.
- * Uses Web Crypto API for all operations.
+ * Uses Web Crypto API for all operations except Argon2id (argon2.js).
  *
  * Security model:
  * - Password: Never sent to server, wiped after use
@@ -9,16 +9,44 @@
  * - Salt: Random 16 bytes, sent to server for key derivation on unlock
  */
 
-const PBKDF2_ITERATIONS = 600000 // OWASP 2023 recommendation for SHA-256
+import { argon2id, ARGON2_VERSION } from './argon2'
 
 /**
- * Derive an encryption key from password using PBKDF2.
+ * KDF descriptor used for new seals. Sealed sessions record their own
+ * descriptor, which the server returns with the salt.
+ */
+export const DEFAULT_KDF = {
+  algorithm: 'pbkdf2-sha256',
+  iterations: 600000 // OWASP 2023 recommendation for SHA-256
+}
+
+/**
+ * Derive an encryption key from password as described by a KDF descriptor:
+ * PBKDF2-SHA256 through Web Crypto, or Argon2id in JavaScript.
  * @param {string} password - User's password
  * @param {Uint8Array} salt - 16-byte salt
+ * @param {object} [kdf] - KDF descriptor from the server (defaults to DEFAULT_KDF)
  * @returns {Promise<Uint8Array>} 256-bit derived key
  */
-export async function deriveKey(password, salt) {
+export async function deriveKey(password, salt, kdf = DEFAULT_KDF) {
   const encoder = new TextEncoder()
+
+  if (kdf?.algorithm === 'argon2id') {
+    if (kdf.version !== ARGON2_VERSION) {
+      throw new Error(`Unsupported Argon2 version: ${kdf.version}`)
+    }
+    const passwordBytes = encoder.encode(password)
+    try {
+      return argon2id(passwordBytes, salt, kdf)
+    } finally {
+      passwordBytes.fill(0)
+    }
+  }
+
+  if (!kdf || kdf.algorithm !== 'pbkdf2-sha256') {
+    throw new Error(`Unsupported key derivation: ${kdf?.algorithm}`)
+  }
+
   const keyMaterial = await crypto.subtle.importKey(
     'raw',
     encoder.encode(password),
@@ -31,7 +59,7 @@ export async function deriveKey(password, salt) {
     {
       name: 'PBKDF2',
       salt: salt,
-      iterations: PBKDF2_ITERATIONS,
+      iterations: kdf.iterations,
       hash: 'SHA-256'
     },
     keyMaterial,
@@ -61,12 +89,70 @@ export function generateSalt() {
 }
 
 /**
- * Encrypt data using AES-256-GCM.
+ * Generate a random file ID in the server's format.
+ * @returns {string} 16 lowercase hex characters
+ */
+export function generateFileId() {
+  const bytes = crypto.getRandomValues(new Uint8Array(8))
+  return Array.from(bytes, (b) => b.toString(16).padStart(2, '0')).join('')
+}
+
+/**
+ * Envelope item types. Files are bound to their ID, other items to their
+ * type only. Must match internal/crypto/envelope.go.
+ */
+export const ENVELOPE = {
+  FILE: 1,
+  CLIPBOARD_TEXT: 2,
+  CLIPBOARD_IMAGE: 3,
+  FILE_METADATA: 4,
+  DOCUMENT_OP: 5,
+  DOCUMENT_SNAPSHOT: 6,
+  NOTE: 7
+}
+
+const ENVELOPE_MAGIC = [0x46, 0x5a, 0x45, 0x45] // "FZEE"
+const ENVELOPE_VERSION = 1
+const ENVELOPE_CIPHER_AES_256_GCM = 1
+const ENVELOPE_KDF_IDS = { 'pbkdf2-sha256': 1, argon2id: 2 }
+const ENVELOPE_FIXED_HEADER = 9
+const ENVELOPE_MAX_ID_LENGTH = 64
+
+/**
+ * Build the envelope header, which is authenticated as AES-GCM additional data.
+ * @param {number} type - ENVELOPE item type
+ * @param {string} id - Item ID ('' for clipboard entries)
+ * @returns {Uint8Array} magic || version || kdf || cipher || type || id length || id
+ */
+function envelopeHeader(type, id) {
+  const idBytes = new TextEncoder().encode(id)
+  if (idBytes.length > ENVELOPE_MAX_ID_LENGTH) {
+    throw new Error('Envelope ID too long')
+  }
+
+  const header = new Uint8Array(ENVELOPE_FIXED_HEADER + idBytes.length)
+  header.set(ENVELOPE_MAGIC)
+  header.set([
+    ENVELOPE_VERSION,
+    ENVELOPE_KDF_IDS[DEFAULT_KDF.algorithm],
+    ENVELOPE_CIPHER_AES_256_GCM,
+    type,
+    idBytes.length
+  ], ENVELOPE_MAGIC.length)
+  header.set(idBytes, ENVELOPE_FIXED_HEADER)
+  return header
+}
+
+/**
+ * Encrypt data using AES-256-GCM into a versioned envelope bound to an item.
  * @param {Uint8Array} key - 256-bit encryption key
  * @param {Uint8Array} plaintext - Data to encrypt
- * @returns {Promise<Uint8Array>} IV (12 bytes) || ciphertext || auth tag (16 bytes)
+ * @param {number} type - ENVELOPE item type
+ * @param {string} [id] - File ID ('' for clipboard entries)
+ * @returns {Promise<Uint8Array>} header || IV (12 bytes) || ciphertext || auth tag (16 bytes)
  */
-export async function encrypt(key, plaintext) {
+export async function encrypt(key, plaintext, type, id = '') {
+  const header = envelopeHeader(type, id)
   const iv = crypto.getRandomValues(new Uint8Array(12))
 
   const cryptoKey = await crypto.subtle.importKey(
@@ -78,29 +164,51 @@ export async function encrypt(key, plaintext) {
   )
 
   const ciphertext = await crypto.subtle.encrypt(
-    { name: 'AES-GCM', iv },
+    { name: 'AES-GCM', iv, additionalData: header },
     cryptoKey,
     plaintext
   )
 
-  // Format: IV (12 bytes) || ciphertext || auth tag (included by Web Crypto)
-  const result = new Uint8Array(12 + ciphertext.byteLength)
-  result.set(iv)
-  result.set(new Uint8Array(ciphertext), 12)
+  // Format: header || IV (12 bytes) || ciphertext || auth tag (included by Web Crypto)
+  const result = new Uint8Array(header.length + 12 + ciphertext.byteLength)
+  result.set(header)
+  result.set(iv, header.length)
+  result.set(new Uint8Array(ciphertext), header.length + 12)
 
   return result
 }
 
 /**
- * Decrypt data using AES-256-GCM.
+ * Decrypt an envelope produced by encrypt().
  * @param {Uint8Array} key - 256-bit encryption key
- * @param {Uint8Array} ciphertext - IV || ciphertext || auth tag
+ * @param {Uint8Array} envelope - header || IV || ciphertext || auth tag
+ * @param {number} type - Expected ENVELOPE item type
+ * @param {string} [id] - Expected file ID ('' for clipboard entries)
  * @returns {Promise<Uint8Array>} Decrypted plaintext
- * @throws {Error} If decryption fails (wrong key or tampered data)
+ * @throws {Error} If the envelope is malformed, belongs to another item, or
+ *   decryption fails (wrong key or tampered data)
  */
-export async function decrypt(key, ciphertext) {
-  const iv = ciphertext.slice(0, 12)
-  const data = ciphertext.slice(12)
+export async function decrypt(key, envelope, type, id = '') {
+  if (envelope.length < ENVELOPE_FIXED_HEADER ||
+      ENVELOPE_MAGIC.some((b, i) => envelope[i] !== b)) {
+    throw new Error('Invalid ciphertext envelope')
+  }
+  if (envelope[4] !== ENVELOPE_VERSION || envelope[6] !== ENVELOPE_CIPHER_AES_256_GCM) {
+    throw new Error('Unsupported ciphertext envelope')
+  }
+
+  const headerLength = ENVELOPE_FIXED_HEADER + envelope[8]
+  const header = envelope.slice(0, headerLength)
+  const envelopeId = new TextDecoder().decode(header.slice(ENVELOPE_FIXED_HEADER))
+  if (envelope[7] !== type || envelopeId !== id) {
+    throw new Error('Ciphertext envelope does not match item')
+  }
+  if (envelope.length < headerLength + 12 + 16) {
+    throw new Error('Invalid ciphertext envelope')
+  }
+
+  const iv = envelope.slice(headerLength, headerLength + 12)
+  const data = envelope.slice(headerLength + 12)
 
   const cryptoKey = await crypto.subtle.importKey(
     'raw',
@@ -111,7 +219,7 @@ export async function decrypt(key, ciphertext) {
   )
 
   const plaintext = await crypto.subtle.decrypt(
-    { name: 'AES-GCM', iv },
+    { name: 'AES-GCM', iv, additionalData: header },
     cryptoKey,
     data
   )
@@ -119,6 +227,64 @@ export async function decrypt(key, ciphertext) {
   return new Uint8Array(plaintext)
 }
 
+/**
+ * Round a size up to its Padmé bucket (at most about 12% more, at least 256
+ * bytes). Must match SizeBucket in internal/crypto/envelope.go.
+ * @param {number} n - Size in bytes
+ * @returns {number} Padded size
+ */
+export function sizeBucket(n) {
+  if (n <= 256) return 256
+  const e = 31 - Math.clz32(n) // floor(log2(n))
+  const s = 32 - Math.clz32(e) // floor(log2(e)) + 1
+  const step = 2 ** (e - s)
+  return Math.ceil(n / step) * step
+}
+
+/**
+ * Encrypt a sealed file: the content is padded to its size bucket, and the
+ * name, MIME type and real size go into a separate metadata envelope.
+ * @param {Uint8Array} key - Encryption key
+ * @param {string} id - File ID both envelopes are bound to
+ * @param {Uint8Array} bytes - File content
+ * @param {{name: string, mimetype: string}} meta - Plaintext metadata
+ * @returns {Promise<{encrypted: Uint8Array, encryptedMetadata: Uint8Array}>}
+ */
+export async function encryptFile(key, id, bytes, meta) {
+  const padded = new Uint8Array(sizeBucket(bytes.length))
+  padded.set(bytes)
+  const encrypted = await encrypt(key, padded, ENVELOPE.FILE, id)
+  wipe(padded)
+
+  const metadata = new TextEncoder().encode(JSON.stringify({
+    name: meta.name,
+    mimetype: meta.mimetype,
+    size: bytes.length
+  }))
+  const encryptedMetadata = await encrypt(key, metadata, ENVELOPE.FILE_METADATA, id)
+
+  return { encrypted, encryptedMetadata }
+}
+
+/**
+ * Decrypt a sealed file encrypted with encryptFile().
+ * @param {Uint8Array} key - Encryption key
+ * @param {string} id - File ID
+ * @param {Uint8Array} encrypted - Content envelope
+ * @param {Uint8Array} encryptedMetadata - Metadata envelope
+ * @returns {Promise<{name: string, mimetype: string, size: number, bytes: Uint8Array}>}
+ */
+export async function decryptFile(key, id, encrypted, encryptedMetadata) {
+  const metadata = await decrypt(key, encryptedMetadata, ENVELOPE.FILE_METADATA, id)
+  const meta = JSON.parse(new TextDecoder().decode(metadata))
+
+  const padded = await decrypt(key, encrypted, ENVELOPE.FILE, id)
+  const bytes = padded.slice(0, meta.size)
+  wipe(padded)
+
+  return { name: meta.name, mimetype: meta.mimetype, size: meta.size, bytes }
+}
+
 /**
  * Securely wipe a Uint8Array by overwriting with random data then zeros.
  * This is best-effort in JavaScript due to potential memory copies.
@@ -161,24 +327,24 @@ export function fromBase64(str) {
 }
 
 /**
- * Encrypt a string.
+ * Encrypt clipboard text.
  * @param {Uint8Array} key - Encryption key
  * @param {string} text - Text to encrypt
- * @returns {Promise<Uint8Array>} Encrypted data
+ * @returns {Promise<Uint8Array>} Envelope for clipboard text
  */
 export async function encryptText(key, text) {
   const encoder = new TextEncoder()
-  return encrypt(key, encoder.encode(text))
+  return encrypt(key, encoder.encode(text), ENVELOPE.CLIPBOARD_TEXT)
 }
 
 /**
- * Decrypt to a string.
+ * Decrypt clipboard text.
  * @param {Uint8Array} key - Encryption key
- * @param {Uint8Array} ciphertext - Encrypted data
+ * @param {Uint8Array} ciphertext - Envelope for clipboard text
  * @returns {Promise<string>} Decrypted text
  */
 export async function decryptText(key, ciphertext) {
   const decoder = new TextDecoder()
-  const plaintext = await decrypt(key, ciphertext)
+  const plaintext = await decrypt(key, ciphertext, ENVELOPE.CLIPBOARD_TEXT)
   return decoder.decode(plaintext)
 }
@@ -5,6 +5,7 @@ go 1.22
 require (
 	github.com/awnumar/memguard v0.22.5
 	github.com/go-chi/chi/v5 v5.1.0
+	github.com/gorilla/websocket v1.5.3
 	golang.org/x/crypto v0.28.0
 	golang.org/x/time v0.7.0
 )
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// ChannelsHandler handles named clipboard channel management.
+// Channel content is served by ClipboardHandler under /api/clipboard/{channel}.
+type ChannelsHandler struct {
+	channels *store.ChannelStore
+}
+
+// NewChannelsHandler creates a new channels handler.
+func NewChannelsHandler(channels *store.ChannelStore) *ChannelsHandler {
+	return &ChannelsHandler{
+		channels: channels,
+	}
+}
+
+// CreateChannelRequest is the request body for creating a channel.
+type CreateChannelRequest struct {
+	Name          string `json:"name"`
+	ImageSlot     bool   `json:"image_slot,omitempty"`     // Enable /api/clipboard/{channel}/image
+	ExpirySeconds int64  `json:"expiry_seconds,omitempty"` // Content expiry (0 = server default)
+}
+
+// ChannelListResponse is the response for listing channels.
+type ChannelListResponse struct {
+	Channels []store.ChannelInfo `json:"channels"`
+}
+
+// List handles GET /api/channels
+func (h *ChannelsHandler) List(w http.ResponseWriter, r *http.Request) {
+	resp := ChannelListResponse{Channels: h.channels.List()}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode channel list response: %v", err)
+	}
+}
+
+// Create handles POST /api/channels
+func (h *ChannelsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ExpirySeconds < 0 {
+		http.Error(w, "Invalid expiry", http.StatusBadRequest)
+		return
+	}
+
+	channel, err := h.channels.Create(req.Name, req.ImageSlot, time.Duration(req.ExpirySeconds)*time.Second)
+	if err != nil {
+		switch err {
+		case validate.ErrInvalidChannelName, validate.ErrReservedChannelName, store.ErrInvalidChannelExpiry:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case store.ErrChannelExists:
+			http.Error(w, "Channel already exists", http.StatusConflict)
+		case store.ErrTooManyChannels:
+			http.Error(w, "Too many channels", http.StatusInsufficientStorage)
+		default:
+			http.Error(w, "Failed to create channel", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(channel.Info()); err != nil {
+		log.Printf("Failed to encode channel response: %v", err)
+	}
+}
+
+// Delete handles DELETE /api/channels/{channel}
+// Shreds all channel content and removes the channel.
+func (h *ChannelsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "channel")
+
+	if err := h.channels.Delete(name); err != nil {
+		if err == store.ErrChannelNotFound {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete channel", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"deleted":  true,
+		"name":     name,
+		"shredded": true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode delete response: %v", err)
+	}
+}
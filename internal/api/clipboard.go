@@ -3,10 +3,18 @@ package api
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/secure"
 	"github.com/fileez/fileez/internal/store"
 	"github.com/fileez/fileez/internal/validate"
 )
@@ -17,40 +25,164 @@ func decodeBase64(s string) ([]byte, error) {
 }
 
 // ClipboardHandler handles clipboard operations.
+// Text and image handlers serve both the default clipboard and named
+// channels (routes with a {channel} URL parameter).
 type ClipboardHandler struct {
 	clipboard *store.ClipboardStore
-	session   *store.SessionManager
+	channels  *store.ChannelStore
+
+	// Seals guarding clipboard text (and channels) and the clipboard image:
+	// the session, or its clipboard text and image lock scopes
+	text  store.LockState
+	image store.LockState
+
+	// Whether raw uploads may store images (ENABLE_CLIPBOARD_IMAGE)
+	imageEnabled bool
+
+	// Bounds for per-entry ttl_seconds
+	minTTL time.Duration
+	maxTTL time.Duration
+
+	// Reject plaintext writes (SEALED_ONLY)
+	sealedOnly bool
+
+	// Accounts the secure buffers raw uploads are read into
+	memory *secure.MemoryTracker
 }
 
 // NewClipboardHandler creates a new clipboard handler.
-func NewClipboardHandler(clipboard *store.ClipboardStore, session *store.SessionManager) *ClipboardHandler {
+func NewClipboardHandler(clipboard *store.ClipboardStore, channels *store.ChannelStore, text, image store.LockState, imageEnabled bool, minTTL, maxTTL time.Duration, sealedOnly bool, memory *secure.MemoryTracker) *ClipboardHandler {
 	return &ClipboardHandler{
-		clipboard: clipboard,
-		session:   session,
+		clipboard:    clipboard,
+		channels:     channels,
+		text:         text,
+		image:        image,
+		imageEnabled: imageEnabled,
+		minTTL:       minTTL,
+		maxTTL:       maxTTL,
+		sealedOnly:   sealedOnly,
+		memory:       memory,
+	}
+}
+
+// entryOptions validates the lifetime requested for a new clipboard entry.
+// Writes a 400 response and returns false if the TTL is out of bounds.
+func (h *ClipboardHandler) entryOptions(w http.ResponseWriter, ttlSeconds int64, pinned bool) (store.EntryOptions, bool) {
+	ttl, err := validate.TTL(ttlSeconds, h.minTTL, h.maxTTL)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("ttl_seconds must be between %d and %d",
+			int64(h.minTTL/time.Second), int64(h.maxTTL/time.Second)), http.StatusBadRequest)
+		return store.EntryOptions{}, false
+	}
+	return store.EntryOptions{TTL: ttl, Pinned: pinned}, true
+}
+
+// lockFor returns the seal guarding content of contentType addressed by the
+// request. Channels, including their image slots, follow the text seal.
+func (h *ClipboardHandler) lockFor(r *http.Request, contentType store.ClipboardType) store.LockState {
+	if contentType == store.ClipboardTypeImage && chi.URLParam(r, "channel") == "" {
+		return h.image
+	}
+	return h.text
+}
+
+// authorizedFor checks that the request may access content of contentType,
+// which may be sealed separately from the route's seal. Writes the locked
+// response and returns nil otherwise.
+func (h *ClipboardHandler) authorizedFor(w http.ResponseWriter, r *http.Request, contentType store.ClipboardType) store.LockState {
+	lock := h.lockFor(r, contentType)
+	if !middleware.TokenAuthorized(lock, r) {
+		middleware.WriteLocked(w)
+		return nil
+	}
+	return lock
+}
+
+// clipboardFor resolves the clipboard addressed by the request: the named
+// channel if the route has a {channel} parameter, otherwise the default.
+// Writes an error response and returns nil if the channel does not exist
+// or (for image requests) has no image slot.
+func (h *ClipboardHandler) clipboardFor(w http.ResponseWriter, r *http.Request, image bool) *store.ClipboardStore {
+	name := chi.URLParam(r, "channel")
+	if name == "" {
+		return h.clipboard
+	}
+
+	if h.channels == nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return nil
+	}
+
+	channel, err := h.channels.Get(name)
+	if err != nil {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return nil
+	}
+
+	if image && !channel.ImageSlot {
+		http.Error(w, "Channel has no image slot", http.StatusNotFound)
+		return nil
 	}
+
+	return channel.Clipboard
 }
 
 // ClipboardTextRequest is the request body for setting text clipboard.
-// When session is locked, client sends encrypted_b64 instead of text.
+// When session is locked, client sends encrypted_b64 instead of text
+// (representations are then part of the client-encrypted blob).
 type ClipboardTextRequest struct {
-	Text         string `json:"text,omitempty"`
-	EncryptedB64 string `json:"encrypted_b64,omitempty"` // E2EE: encrypted text when locked
+	Text            string                    `json:"text,omitempty"`
+	Representations []ClipboardRepresentation `json:"representations,omitempty"` // Additional MIME flavours (HTML, URL list, file refs)
+	EncryptedB64    string                    `json:"encrypted_b64,omitempty"`   // E2EE: encrypted text when locked
+	TTLSeconds      int64                     `json:"ttl_seconds,omitempty"`     // Lifetime of the entry (default CLIPBOARD_EXPIRY)
+	Pinned          bool                      `json:"pinned,omitempty"`          // Never expires; still deletable
+}
+
+// ClipboardRepresentation is one MIME representation of clipboard text.
+type ClipboardRepresentation struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
 }
 
 // ClipboardTextResponse is the response for text clipboard.
 type ClipboardTextResponse struct {
-	Text         string `json:"text,omitempty"`
-	EncryptedB64 string `json:"encrypted_b64,omitempty"` // E2EE: encrypted text when locked
-	HasContent   bool   `json:"has_content"`
-	Size         int    `json:"size,omitempty"`
+	Text            string   `json:"text,omitempty"`
+	EncryptedB64    string   `json:"encrypted_b64,omitempty"` // E2EE: encrypted text when locked
+	HasContent      bool     `json:"has_content"`
+	Size            int      `json:"size,omitempty"`
+	Version         uint64   `json:"version,omitempty"`         // Also sent as ETag
+	Representations []string `json:"representations,omitempty"` // MIME types available via Accept
+	Pinned          bool     `json:"pinned,omitempty"`
+	ExpiresIn       int64    `json:"expires_in,omitempty"` // Seconds until expiry (omitted while pinned)
 }
 
-// GetText handles GET /api/clipboard
+// GetText handles GET /api/clipboard and GET /api/clipboard/{channel}
+// Returns JSON unless the Accept header prefers one of the entry's
+// representations (text/plain, text/html, ...), which is then sent raw.
 // E2EE: When session is locked, returns encrypted_b64 instead of text.
 func (h *ClipboardHandler) GetText(w http.ResponseWriter, r *http.Request) {
+	clipboard := h.clipboardFor(w, r, false)
+	if clipboard == nil {
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	// Read the version before the content so a concurrent write yields a stale ETag
+	version := clipboard.TextVersion()
+	if notModified(w, r, version) {
+		return
+	}
+
 	// E2EE: If session is locked, return encrypted data for client-side decryption
-	if h.session.IsLocked() {
-		encrypted := h.clipboard.GetEncryptedText()
+	if h.text.IsLocked() {
+		if negotiateContentType(r, []string{"application/json"}) == "" {
+			http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+			return
+		}
+		setETag(w, version)
+
+		encrypted := clipboard.GetEncryptedText()
 		if encrypted == nil {
 			resp := ClipboardTextResponse{HasContent: false}
 			w.Header().Set("Content-Type", "application/json")
@@ -60,10 +192,14 @@ func (h *ClipboardHandler) GetText(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		info := clipboard.TextInfo()
 		resp := ClipboardTextResponse{
 			EncryptedB64: base64.StdEncoding.EncodeToString(encrypted),
 			HasContent:   true,
 			Size:         len(encrypted),
+			Version:      version,
+			Pinned:       info.Pinned,
+			ExpiresIn:    info.ExpiresIn,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -74,7 +210,26 @@ func (h *ClipboardHandler) GetText(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Normal plaintext mode
-	content, err := h.clipboard.GetText()
+	info := clipboard.TextInfo()
+	contentType := negotiateContentType(r, append([]string{"application/json"}, info.Representations...))
+	if contentType == "" {
+		http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+		return
+	}
+	setETag(w, version)
+
+	if contentType != "application/json" {
+		content, err := clipboard.GetTextRepresentation(contentType)
+		if err != nil {
+			// Changed since the metadata was read
+			http.Error(w, "Representation not available", http.StatusNotAcceptable)
+			return
+		}
+		writeRepresentation(w, contentType, content)
+		return
+	}
+
+	content, err := clipboard.GetText()
 	if err != nil {
 		if err == store.ErrClipboardEmpty || err == store.ErrClipboardExpired {
 			resp := ClipboardTextResponse{HasContent: false}
@@ -89,9 +244,13 @@ func (h *ClipboardHandler) GetText(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := ClipboardTextResponse{
-		Text:       string(content),
-		HasContent: true,
-		Size:       len(content),
+		Text:            string(content),
+		HasContent:      true,
+		Size:            len(content),
+		Version:         version,
+		Representations: info.Representations,
+		Pinned:          info.Pinned,
+		ExpiresIn:       info.ExpiresIn,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -100,21 +259,38 @@ func (h *ClipboardHandler) GetText(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// SetText handles POST /api/clipboard
+// SetText handles POST /api/clipboard and POST /api/clipboard/{channel}
 // E2EE: When session is locked, accepts encrypted_b64 instead of text.
 // Client encrypts locally, sends ciphertext. Server stores without decrypting.
 func (h *ClipboardHandler) SetText(w http.ResponseWriter, r *http.Request) {
+	clipboard := h.clipboardFor(w, r, false)
+	if clipboard == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, clipboard.TextVersion())
+	if !ok {
+		return
+	}
+
 	var req ClipboardTextRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	opts, ok := h.entryOptions(w, req.TTLSeconds, req.Pinned)
+	if !ok {
+		return
+	}
+
 	var content []byte
 	var size int
+	var version uint64
+	var representations []string
 
 	// E2EE: If session is locked and encrypted data provided, store as encrypted
-	if h.session.IsLocked() && req.EncryptedB64 != "" {
+	if h.text.IsLocked() && req.EncryptedB64 != "" {
 		// Decode encrypted data
 		encrypted, err := decodeBase64(req.EncryptedB64)
 		if err != nil {
@@ -123,36 +299,108 @@ func (h *ClipboardHandler) SetText(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Store encrypted text (server cannot decrypt)
-		h.clipboard.SetEncryptedText(encrypted)
+		version, err = clipboard.SetEncryptedText(encrypted, ifVersion, opts)
+		if err == store.ErrClipboardClosed {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+		if err == store.ErrVersionMismatch {
+			writeVersionConflict(w, version)
+			return
+		}
+		if err == store.ErrPinLimit {
+			writePinLimit(w)
+			return
+		}
+		if crypto.IsEnvelopeError(err) {
+			http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to set clipboard", http.StatusInsufficientStorage)
+			return
+		}
 		size = len(encrypted)
+	} else if h.sealedOnly {
+		rejectPlaintext(w)
+		return
 	} else {
 		// Normal plaintext mode
-		if req.Text == "" && req.EncryptedB64 == "" {
+		if req.Text == "" && req.EncryptedB64 == "" && len(req.Representations) == 0 {
 			http.Error(w, "No content provided", http.StatusBadRequest)
 			return
 		}
 
-		// Validate content
-		text, err := validate.ClipboardContent(req.Text)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		content = []byte(text)
-		size = len(text)
+		if len(req.Representations) > 0 {
+			// Multiple representations, each validated and stored separately
+			reps, err := clipboardRepresentations(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, rep := range reps {
+				size += len(rep.Data)
+				representations = append(representations, rep.MimeType)
+			}
 
-		// Store content
-		if err := h.clipboard.SetText(content); err != nil {
-			http.Error(w, "Failed to set clipboard", http.StatusInternalServerError)
-			return
+			version, err = clipboard.SetRepresentations(reps, ifVersion, opts)
+			if err == store.ErrClipboardClosed {
+				http.Error(w, "Channel not found", http.StatusNotFound)
+				return
+			}
+			if err == store.ErrVersionMismatch {
+				writeVersionConflict(w, version)
+				return
+			}
+			if err == store.ErrPinLimit {
+				writePinLimit(w)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to set clipboard", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			// Validate content
+			text, err := validate.ClipboardContent(req.Text)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content = []byte(text)
+			size = len(text)
+
+			// Store content
+			version, err = clipboard.SetText(content, ifVersion, opts)
+			if err == store.ErrClipboardClosed {
+				http.Error(w, "Channel not found", http.StatusNotFound)
+				return
+			}
+			if err == store.ErrVersionMismatch {
+				writeVersionConflict(w, version)
+				return
+			}
+			if err == store.ErrPinLimit {
+				writePinLimit(w)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to set clipboard", http.StatusInternalServerError)
+				return
+			}
 		}
 	}
 
 	resp := ClipboardTextResponse{
-		HasContent: true,
-		Size:       size,
+		HasContent:      true,
+		Size:            size,
+		Version:         version,
+		Representations: representations,
+		Pinned:          opts.Pinned,
+		ExpiresIn:       clipboard.TextInfo().ExpiresIn,
 	}
 
+	setETag(w, version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -160,11 +408,89 @@ func (h *ClipboardHandler) SetText(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// DeleteText handles DELETE /api/clipboard
+// clipboardRepresentations validates the representations of a text request.
+// req.Text, if set, is the text/plain representation; otherwise one must be listed.
+func clipboardRepresentations(req ClipboardTextRequest) ([]store.Representation, error) {
+	items := req.Representations
+	if req.Text != "" {
+		items = append([]ClipboardRepresentation{{MimeType: validate.MIMETextPlain, Data: req.Text}}, items...)
+	}
+
+	reps := make([]store.Representation, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	total := 0
+
+	for _, item := range items {
+		mimeType, err := validate.RepresentationMIMEType(item.MimeType)
+		if err != nil {
+			return nil, err
+		}
+		if seen[mimeType] {
+			return nil, validate.ErrRepresentationDuplicate
+		}
+		seen[mimeType] = true
+
+		content, err := validate.Representation(mimeType, item.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		total += len(content)
+		if total > validate.MaxClipboardSize {
+			return nil, validate.ErrClipboardTooLarge
+		}
+
+		reps = append(reps, store.Representation{MimeType: mimeType, Data: []byte(content)})
+	}
+
+	if !seen[validate.MIMETextPlain] {
+		return nil, validate.ErrRepresentationMissingText
+	}
+
+	return reps, nil
+}
+
+// writeRepresentation sends a raw clipboard representation and shreds it.
+func writeRepresentation(w http.ResponseWriter, mimeType string, content []byte) {
+	defer secure.Shred(content)
+
+	contentType := mimeType
+	if strings.HasPrefix(mimeType, "text/") {
+		contentType += "; charset=utf-8"
+	}
+
+	if mimeType == validate.MIMETextHTML {
+		// Shared HTML is untrusted - never render it in the app's origin
+		w.Header().Set("Content-Security-Policy", "sandbox; default-src 'none'")
+		w.Header().Set("Content-Disposition", "attachment")
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(content)
+}
+
+// DeleteText handles DELETE /api/clipboard and DELETE /api/clipboard/{channel}
 func (h *ClipboardHandler) DeleteText(w http.ResponseWriter, r *http.Request) {
-	h.clipboard.DeleteText()
+	clipboard := h.clipboardFor(w, r, false)
+	if clipboard == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, clipboard.TextVersion())
+	if !ok {
+		return
+	}
+
+	version, err := clipboard.DeleteText(ifVersion)
+	if err == store.ErrVersionMismatch {
+		writeVersionConflict(w, version)
+		return
+	}
 
 	resp := map[string]bool{"deleted": true}
+	setETag(w, version)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Printf("Failed to encode delete response: %v", err)
@@ -177,22 +503,38 @@ type ClipboardImageResponse struct {
 	MimeType     string `json:"mimeType,omitempty"`
 	Size         int    `json:"size,omitempty"`
 	EncryptedB64 string `json:"encrypted_b64,omitempty"` // E2EE: encrypted image when locked
+	Version      uint64 `json:"version,omitempty"`       // Also sent as ETag
+	Pinned       bool   `json:"pinned,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"` // Seconds until expiry (omitted while pinned)
 }
 
 // ClipboardImageRequest is the request body for setting image clipboard.
 // When session is locked, client sends encrypted_b64 instead of image.
 type ClipboardImageRequest struct {
-	Image        string `json:"image,omitempty"`        // Base64 encoded image data (plaintext mode)
-	MimeType     string `json:"mimetype"`               // MIME type of the image
+	Image        string `json:"image,omitempty"`         // Base64 encoded image data (plaintext mode)
+	MimeType     string `json:"mimetype"`                // MIME type of the image
 	EncryptedB64 string `json:"encrypted_b64,omitempty"` // E2EE: encrypted image when locked
+	TTLSeconds   int64  `json:"ttl_seconds,omitempty"`   // Lifetime of the entry (default CLIPBOARD_EXPIRY)
+	Pinned       bool   `json:"pinned,omitempty"`        // Never expires; still deletable
 }
 
-// GetImageInfo handles GET /api/clipboard-image
+// GetImageInfo handles GET /api/clipboard-image and GET /api/clipboard/{channel}/image
 // E2EE: When session is locked, returns encrypted_b64 instead of image data.
 func (h *ClipboardHandler) GetImageInfo(w http.ResponseWriter, r *http.Request) {
+	clipboard := h.clipboardFor(w, r, true)
+	if clipboard == nil {
+		return
+	}
+
+	version := clipboard.ImageVersion()
+	if notModified(w, r, version) {
+		return
+	}
+	setETag(w, version)
+
 	// E2EE: If session is locked, return encrypted data for client-side decryption
-	if h.session.IsLocked() {
-		encrypted, mimeType := h.clipboard.GetEncryptedImage()
+	if h.lockFor(r, store.ClipboardTypeImage).IsLocked() {
+		encrypted, mimeType := clipboard.GetEncryptedImage()
 		if encrypted == nil {
 			resp := ClipboardImageResponse{HasImage: false}
 			w.Header().Set("Content-Type", "application/json")
@@ -202,11 +544,15 @@ func (h *ClipboardHandler) GetImageInfo(w http.ResponseWriter, r *http.Request)
 			return
 		}
 
+		info := clipboard.ImageInfo()
 		resp := ClipboardImageResponse{
 			HasImage:     true,
 			MimeType:     mimeType,
 			Size:         len(encrypted),
 			EncryptedB64: base64.StdEncoding.EncodeToString(encrypted),
+			Version:      version,
+			Pinned:       info.Pinned,
+			ExpiresIn:    info.ExpiresIn,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -217,12 +563,15 @@ func (h *ClipboardHandler) GetImageInfo(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Normal plaintext mode
-	info := h.clipboard.ImageInfo()
+	info := clipboard.ImageInfo()
 
 	resp := ClipboardImageResponse{
-		HasImage: info.HasContent,
-		MimeType: info.MimeType,
-		Size:     info.Size,
+		HasImage:  info.HasContent,
+		MimeType:  info.MimeType,
+		Size:      info.Size,
+		Version:   version,
+		Pinned:    info.Pinned,
+		ExpiresIn: info.ExpiresIn,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -231,9 +580,19 @@ func (h *ClipboardHandler) GetImageInfo(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-// GetImageData handles GET /api/clipboard-image/data
+// GetImageData handles GET /api/clipboard-image/data and GET /api/clipboard/{channel}/image/data
 func (h *ClipboardHandler) GetImageData(w http.ResponseWriter, r *http.Request) {
-	data, mimeType, err := h.clipboard.GetImage()
+	clipboard := h.clipboardFor(w, r, true)
+	if clipboard == nil {
+		return
+	}
+
+	version := clipboard.ImageVersion()
+	if notModified(w, r, version) {
+		return
+	}
+
+	data, mimeType, err := clipboard.GetImage()
 	if err != nil {
 		if err == store.ErrClipboardEmpty || err == store.ErrClipboardExpired {
 			http.Error(w, "No image in clipboard", http.StatusNotFound)
@@ -243,22 +602,38 @@ func (h *ClipboardHandler) GetImageData(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	setETag(w, version)
 	w.Header().Set("Content-Type", mimeType)
 	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	w.Header().Set("X-Content-Type-Options", "nosniff")
 	w.Write(data)
 }
 
-// SetImage handles POST /api/clipboard-image
+// SetImage handles POST /api/clipboard-image and POST /api/clipboard/{channel}/image
 // E2EE: When session is locked, accepts encrypted_b64 instead of image.
 // Client encrypts locally, sends ciphertext. Server stores without decrypting.
 func (h *ClipboardHandler) SetImage(w http.ResponseWriter, r *http.Request) {
+	clipboard := h.clipboardFor(w, r, true)
+	if clipboard == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, clipboard.ImageVersion())
+	if !ok {
+		return
+	}
+
 	var req ClipboardImageRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	opts, ok := h.entryOptions(w, req.TTLSeconds, req.Pinned)
+	if !ok {
+		return
+	}
+
 	// Validate MIME type
 	if !validate.IsImageMIMEType(req.MimeType) {
 		http.Error(w, "Invalid image type", http.StatusBadRequest)
@@ -266,9 +641,10 @@ func (h *ClipboardHandler) SetImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var size int
+	var version uint64
 
 	// E2EE: If session is locked and encrypted data provided, store as encrypted
-	if h.session.IsLocked() && req.EncryptedB64 != "" {
+	if h.lockFor(r, store.ClipboardTypeImage).IsLocked() && req.EncryptedB64 != "" {
 		// Decode encrypted data
 		encrypted, err := decodeBase64(req.EncryptedB64)
 		if err != nil {
@@ -277,8 +653,31 @@ func (h *ClipboardHandler) SetImage(w http.ResponseWriter, r *http.Request) {
 		}
 
 		// Store encrypted image (server cannot decrypt)
-		h.clipboard.SetEncryptedImage(encrypted, req.MimeType)
+		version, err = clipboard.SetEncryptedImage(encrypted, req.MimeType, ifVersion, opts)
+		if err == store.ErrClipboardClosed {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+		if err == store.ErrVersionMismatch {
+			writeVersionConflict(w, version)
+			return
+		}
+		if err == store.ErrPinLimit {
+			writePinLimit(w)
+			return
+		}
+		if crypto.IsEnvelopeError(err) {
+			http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to store image", http.StatusInsufficientStorage)
+			return
+		}
 		size = len(encrypted)
+	} else if h.sealedOnly {
+		rejectPlaintext(w)
+		return
 	} else {
 		// Normal plaintext mode
 		if req.Image == "" {
@@ -293,13 +692,26 @@ func (h *ClipboardHandler) SetImage(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if len(data) > validate.MaxClipboardSize {
+		if len(data) > validate.MaxClipboardImageSize {
 			http.Error(w, "Image too large", http.StatusRequestEntityTooLarge)
 			return
 		}
 
 		// Store image
-		if err := h.clipboard.SetImage(data, req.MimeType); err != nil {
+		version, err = clipboard.SetImage(data, req.MimeType, ifVersion, opts)
+		if err == store.ErrClipboardClosed {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+		if err == store.ErrVersionMismatch {
+			writeVersionConflict(w, version)
+			return
+		}
+		if err == store.ErrPinLimit {
+			writePinLimit(w)
+			return
+		}
+		if err != nil {
 			http.Error(w, "Failed to store image", http.StatusInternalServerError)
 			return
 		}
@@ -307,11 +719,15 @@ func (h *ClipboardHandler) SetImage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := ClipboardImageResponse{
-		HasImage: true,
-		MimeType: req.MimeType,
-		Size:     size,
+		HasImage:  true,
+		MimeType:  req.MimeType,
+		Size:      size,
+		Version:   version,
+		Pinned:    opts.Pinned,
+		ExpiresIn: clipboard.ImageInfo().ExpiresIn,
 	}
 
+	setETag(w, version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
@@ -319,13 +735,243 @@ func (h *ClipboardHandler) SetImage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// DeleteImage handles DELETE /api/clipboard-image
+// DeleteImage handles DELETE /api/clipboard-image and DELETE /api/clipboard/{channel}/image
 func (h *ClipboardHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
-	h.clipboard.DeleteImage()
+	clipboard := h.clipboardFor(w, r, true)
+	if clipboard == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, clipboard.ImageVersion())
+	if !ok {
+		return
+	}
+
+	version, err := clipboard.DeleteImage(ifVersion)
+	if err == store.ErrVersionMismatch {
+		writeVersionConflict(w, version)
+		return
+	}
 
 	resp := map[string]bool{"deleted": true}
+	setETag(w, version)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.Printf("Failed to encode delete response: %v", err)
 	}
 }
+
+// ClipboardHistoryResponse is the response for listing clipboard history.
+type ClipboardHistoryResponse struct {
+	Entries []store.ClipboardInfo `json:"entries"`
+}
+
+// ClipboardEntryResponse is the response for a single clipboard history entry.
+// E2EE: When session is locked, encrypted_b64 is returned instead of content.
+type ClipboardEntryResponse struct {
+	store.ClipboardInfo
+	Text         string `json:"text,omitempty"`
+	ImageB64     string `json:"image_b64,omitempty"`
+	EncryptedB64 string `json:"encrypted_b64,omitempty"`
+}
+
+// ListHistory handles GET /api/clipboard/history
+// Returns metadata for all history entries (newest first), never content.
+// Entries of a type sealed by a lock scope are listed only with its token.
+func (h *ClipboardHandler) ListHistory(w http.ResponseWriter, r *http.Request) {
+	version := h.clipboard.Version()
+	if notModified(w, r, version) {
+		return
+	}
+
+	authorized := map[string]bool{}
+	for _, contentType := range []store.ClipboardType{store.ClipboardTypeText, store.ClipboardTypeImage} {
+		authorized[contentType.String()] = middleware.TokenAuthorized(h.lockFor(r, contentType), r)
+	}
+
+	resp := ClipboardHistoryResponse{Entries: []store.ClipboardInfo{}}
+	for _, info := range h.clipboard.History() {
+		if authorized[info.Kind] {
+			resp.Entries = append(resp.Entries, info)
+		}
+	}
+
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode clipboard history response: %v", err)
+	}
+}
+
+// GetHistoryEntry handles GET /api/clipboard/history/{id}
+// Text entries can be fetched raw in one of their representations via Accept.
+// E2EE: When session is locked, returns encrypted_b64 instead of content.
+func (h *ClipboardHandler) GetHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	lock := h.entryLock(w, r, id)
+	if lock == nil {
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	var resp ClipboardEntryResponse
+
+	if lock.IsLocked() {
+		if negotiateContentType(r, []string{"application/json"}) == "" {
+			http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+			return
+		}
+
+		info, encrypted, err := h.clipboard.GetEncryptedEntry(id)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		resp.ClipboardInfo = info
+		if notModified(w, r, info.Version) {
+			return
+		}
+		resp.EncryptedB64 = base64.StdEncoding.EncodeToString(encrypted)
+	} else {
+		info, content, err := h.clipboard.GetEntry(id)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		resp.ClipboardInfo = info
+		if notModified(w, r, info.Version) {
+			secure.Shred(content)
+			return
+		}
+
+		contentType := negotiateContentType(r, append([]string{"application/json"}, info.Representations...))
+		if contentType == "" {
+			secure.Shred(content)
+			http.Error(w, "Not acceptable", http.StatusNotAcceptable)
+			return
+		}
+		if contentType != "application/json" {
+			secure.Shred(content)
+			_, rep, err := h.clipboard.GetEntryRepresentation(id, contentType)
+			if err != nil {
+				writeHistoryError(w, err)
+				return
+			}
+			setETag(w, info.Version)
+			writeRepresentation(w, contentType, rep)
+			return
+		}
+
+		if info.Kind == store.ClipboardTypeImage.String() {
+			resp.ImageB64 = base64.StdEncoding.EncodeToString(content)
+		} else {
+			resp.Text = string(content)
+		}
+	}
+
+	setETag(w, resp.Version)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode clipboard entry response: %v", err)
+	}
+}
+
+// PinHistoryEntry handles POST /api/clipboard/history/{id}/pin
+// Pinned entries are kept when the history ring rotates and do not expire.
+func (h *ClipboardHandler) PinHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, true)
+}
+
+// UnpinHistoryEntry handles DELETE /api/clipboard/history/{id}/pin
+func (h *ClipboardHandler) UnpinHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	h.setPinned(w, r, false)
+}
+
+// setPinned updates the pin flag of a history entry and returns its metadata.
+func (h *ClipboardHandler) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	id := chi.URLParam(r, "id")
+
+	if h.entryLock(w, r, id) == nil {
+		return
+	}
+
+	info, err := h.clipboard.PinEntry(id, pinned)
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(info); err != nil {
+		log.Printf("Failed to encode clipboard entry response: %v", err)
+	}
+}
+
+// DeleteHistoryEntry handles DELETE /api/clipboard/history/{id}
+func (h *ClipboardHandler) DeleteHistoryEntry(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if h.entryLock(w, r, id) == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, store.AnyVersion)
+	if !ok {
+		return
+	}
+
+	version, err := h.clipboard.DeleteEntry(id, ifVersion)
+	if err == store.ErrVersionMismatch {
+		writeVersionConflict(w, version)
+		return
+	}
+	if err != nil {
+		writeHistoryError(w, err)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"deleted":  true,
+		"id":       id,
+		"shredded": true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode delete response: %v", err)
+	}
+}
+
+// entryLock returns the seal guarding the history entry id, after checking
+// that the request may access it. Writes the error response and returns nil
+// otherwise.
+func (h *ClipboardHandler) entryLock(w http.ResponseWriter, r *http.Request, id string) store.LockState {
+	contentType, err := h.clipboard.EntryType(id)
+	if err != nil {
+		writeHistoryError(w, err)
+		return nil
+	}
+	return h.authorizedFor(w, r, contentType)
+}
+
+// writeHistoryError maps clipboard history errors to HTTP responses.
+func writeHistoryError(w http.ResponseWriter, err error) {
+	switch err {
+	case store.ErrClipboardEntryNotFound:
+		http.Error(w, "Clipboard entry not found", http.StatusNotFound)
+	case store.ErrClipboardExpired:
+		http.Error(w, "Clipboard entry expired", http.StatusGone)
+	case store.ErrRepresentationNotFound:
+		http.Error(w, "Representation not available", http.StatusNotAcceptable)
+	case store.ErrPinLimit:
+		writePinLimit(w)
+	default:
+		http.Error(w, "Failed to access clipboard history", http.StatusInternalServerError)
+	}
+}
+
+// writePinLimit writes a 409 response for a pin that exceeds the history limits.
+func writePinLimit(w http.ResponseWriter) {
+	http.Error(w, "Too many pinned clipboard entries: unpin or delete one first", http.StatusConflict)
+}
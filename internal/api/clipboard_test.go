@@ -0,0 +1,41 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fileez/fileez/internal/validate"
+)
+
+func TestClipboardRepresentations(t *testing.T) {
+	html := ClipboardRepresentation{MimeType: "text/html; charset=utf-8", Data: "<b>hi</b>"}
+
+	reps, err := clipboardRepresentations(ClipboardTextRequest{Text: "hi", Representations: []ClipboardRepresentation{html}})
+	if err != nil {
+		t.Fatalf("text with HTML: %v", err)
+	}
+	if len(reps) != 2 || reps[0].MimeType != validate.MIMETextPlain || reps[1].MimeType != validate.MIMETextHTML {
+		t.Fatalf("got %+v, want text/plain then text/html", reps)
+	}
+
+	tests := []struct {
+		name string
+		req  ClipboardTextRequest
+		want error
+	}{
+		{"no text/plain", ClipboardTextRequest{Representations: []ClipboardRepresentation{html}}, validate.ErrRepresentationMissingText},
+		{"duplicate type", ClipboardTextRequest{Text: "hi", Representations: []ClipboardRepresentation{{MimeType: "TEXT/PLAIN", Data: "again"}}}, validate.ErrRepresentationDuplicate},
+		{"unsupported type", ClipboardTextRequest{Text: "hi", Representations: []ClipboardRepresentation{{MimeType: "image/svg+xml", Data: "<svg/>"}}}, validate.ErrRepresentationUnsupported},
+		{"invalid content", ClipboardTextRequest{Text: "hi", Representations: []ClipboardRepresentation{{MimeType: validate.MIMEURIList, Data: "javascript:alert(1)"}}}, validate.ErrURIScheme},
+		{"combined size over the limit", ClipboardTextRequest{
+			Text:            strings.Repeat("a", validate.MaxClipboardSize/2+1),
+			Representations: []ClipboardRepresentation{{MimeType: validate.MIMETextHTML, Data: strings.Repeat("b", validate.MaxClipboardSize/2+1)}},
+		}, validate.ErrClipboardTooLarge},
+	}
+
+	for _, tt := range tests {
+		if _, err := clipboardRepresentations(tt.req); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
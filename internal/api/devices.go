@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// DevicesHandler handles the devices holding tokens for a sealed session.
+type DevicesHandler struct {
+	session *store.SessionManager
+}
+
+// NewDevicesHandler creates a new devices handler.
+func NewDevicesHandler(session *store.SessionManager) *DevicesHandler {
+	return &DevicesHandler{
+		session: session,
+	}
+}
+
+// DeviceListResponse is the response for listing devices.
+type DeviceListResponse struct {
+	Devices []store.DeviceInfo `json:"devices"`
+}
+
+// List handles GET /api/devices
+// Lists devices that unlocked the sealed session. Empty when not sealed.
+func (h *DevicesHandler) List(w http.ResponseWriter, r *http.Request) {
+	devices := h.session.ListDevices()
+
+	if current, ok := h.session.DeviceForToken(middleware.GetSessionToken(r)); ok {
+		for i := range devices {
+			devices[i].Current = devices[i].ID == current
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(DeviceListResponse{Devices: devices}); err != nil {
+		log.Printf("Failed to encode device list response: %v", err)
+	}
+}
+
+// Revoke handles DELETE /api/devices/{id}
+// Invalidates the device's token. The device must unlock again to regain access.
+func (h *DevicesHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	id, err := validate.DeviceID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.session.RevokeDevice(id); err != nil {
+		if err == store.ErrDeviceNotFound {
+			http.Error(w, "Device not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to revoke device", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"revoked": true,
+		"id":      id,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode revoke response: %v", err)
+	}
+}
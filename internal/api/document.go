@@ -0,0 +1,314 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// maxDocumentRequestSize bounds document request bodies: a full document as
+// base64 ciphertext plus JSON framing.
+var maxDocumentRequestSize = int64(base64.StdEncoding.EncodedLen(validate.MaxClipboardSize) + 4096)
+
+// DocumentHandler handles the collaboratively edited Wormhole document.
+type DocumentHandler struct {
+	document *store.DocumentStore
+	lock     store.LockState
+
+	// Reject plaintext operations; ciphertext must be envelopes (SEALED_ONLY)
+	sealedOnly bool
+}
+
+// NewDocumentHandler creates a new document handler.
+func NewDocumentHandler(document *store.DocumentStore, lock store.LockState, sealedOnly bool) *DocumentHandler {
+	return &DocumentHandler{
+		document:   document,
+		lock:       lock,
+		sealedOnly: sealedOnly,
+	}
+}
+
+// DocumentResponse is the response for the document.
+// E2EE: When sealed, encrypted_b64 is the snapshot taken at snapshot_revision;
+// clients replay the operations after it to reach revision.
+type DocumentResponse struct {
+	Text             string `json:"text"`
+	Revision         uint64 `json:"revision"`
+	Sealed           bool   `json:"sealed,omitempty"`
+	EncryptedB64     string `json:"encrypted_b64,omitempty"`
+	SnapshotRevision uint64 `json:"snapshot_revision,omitempty"`
+}
+
+// DocumentOpRequest is the request body for submitting an operation.
+// When session is locked, client sends encrypted_b64 instead of op.
+type DocumentOpRequest struct {
+	Revision     uint64               `json:"revision"` // Revision the operation is based on
+	Op           *store.TextOperation `json:"op,omitempty"`
+	EncryptedB64 string               `json:"encrypted_b64,omitempty"` // E2EE: encrypted operation when locked
+}
+
+// DocumentOpResponse is a logged operation, or the result of submitting one.
+type DocumentOpResponse struct {
+	Revision     uint64               `json:"revision"`
+	Op           *store.TextOperation `json:"op,omitempty"`
+	EncryptedB64 string               `json:"encrypted_b64,omitempty"`
+}
+
+// DocumentOpsResponse is the response for fetching operations.
+type DocumentOpsResponse struct {
+	Revision uint64               `json:"revision"`
+	Ops      []DocumentOpResponse `json:"ops"`
+}
+
+// DocumentSnapshotRequest is the request body for storing a sealed snapshot.
+type DocumentSnapshotRequest struct {
+	Revision     uint64 `json:"revision"`
+	EncryptedB64 string `json:"encrypted_b64"`
+}
+
+// RevisionResponse reports the current revision with a 409 or 410 error.
+type RevisionResponse struct {
+	Error    string `json:"error"`
+	Revision uint64 `json:"revision"`
+}
+
+// Get handles GET /api/doc
+// E2EE: When session is locked, returns the encrypted snapshot instead of text.
+func (h *DocumentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	var resp DocumentResponse
+
+	if h.lock.IsLocked() {
+		snapshot, snapshotRevision, revision, err := h.document.GetEncrypted()
+		if err != nil {
+			writeDocumentError(w, err, h.document.Revision())
+			return
+		}
+		resp = DocumentResponse{
+			Revision:         revision,
+			Sealed:           true,
+			SnapshotRevision: snapshotRevision,
+		}
+		if snapshot != nil {
+			resp.EncryptedB64 = base64.StdEncoding.EncodeToString(snapshot)
+		}
+	} else {
+		content, revision, err := h.document.Get()
+		if err != nil {
+			writeDocumentError(w, err, h.document.Revision())
+			return
+		}
+		resp = DocumentResponse{
+			Text:     string(content),
+			Revision: revision,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode document response: %v", err)
+	}
+}
+
+// Ops handles GET /api/doc/ops?since={revision}
+// Returns the operations after since. 410 means the client is too far behind
+// and must reload the document.
+func (h *DocumentHandler) Ops(w http.ResponseWriter, r *http.Request) {
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	ops, revision, err := h.document.Ops(since)
+	if err != nil {
+		writeDocumentError(w, err, revision)
+		return
+	}
+
+	resp := DocumentOpsResponse{
+		Revision: revision,
+		Ops:      make([]DocumentOpResponse, 0, len(ops)),
+	}
+	for _, op := range ops {
+		item := DocumentOpResponse{Revision: op.Revision, Op: op.Op}
+		if op.Encrypted != nil {
+			item.EncryptedB64 = base64.StdEncoding.EncodeToString(op.Encrypted)
+		}
+		resp.Ops = append(resp.Ops, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode document ops response: %v", err)
+	}
+}
+
+// SubmitOp handles POST /api/doc/ops
+// Unsealed: the operation is transformed against concurrent edits and merged;
+// the response carries the operation as applied.
+// E2EE: When session is locked, accepts encrypted_b64 and only orders it -
+// 409 means the client must fetch newer operations and rebase.
+func (h *DocumentHandler) SubmitOp(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentRequestSize)
+
+	var req DocumentOpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var resp DocumentOpResponse
+
+	if h.lock.IsLocked() {
+		encrypted, err := decodeBase64(req.EncryptedB64)
+		if err != nil || len(encrypted) == 0 {
+			http.Error(w, "Invalid encrypted data", http.StatusBadRequest)
+			return
+		}
+		if !h.checkEnvelope(w, encrypted, crypto.EnvelopeDocumentOp) {
+			return
+		}
+
+		revision, err := h.document.AppendEncrypted(req.Revision, encrypted)
+		if err != nil {
+			writeDocumentError(w, err, revision)
+			return
+		}
+		resp.Revision = revision
+	} else if h.sealedOnly {
+		rejectPlaintext(w)
+		return
+	} else {
+		if req.Op == nil {
+			http.Error(w, "No operation provided", http.StatusBadRequest)
+			return
+		}
+
+		revision, applied, err := h.document.Apply(req.Revision, *req.Op)
+		if err != nil {
+			writeDocumentError(w, err, revision)
+			return
+		}
+		resp = DocumentOpResponse{Revision: revision, Op: &applied}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode document op response: %v", err)
+	}
+}
+
+// SetSnapshot handles PUT /api/doc/snapshot
+// E2EE: Stores a client-encrypted snapshot taken at revision and compacts the log.
+func (h *DocumentHandler) SetSnapshot(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDocumentRequestSize)
+
+	if !h.lock.IsLocked() {
+		http.Error(w, "Session not locked", http.StatusConflict)
+		return
+	}
+
+	var req DocumentSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	encrypted, err := decodeBase64(req.EncryptedB64)
+	if err != nil || len(encrypted) == 0 {
+		http.Error(w, "Invalid encrypted data", http.StatusBadRequest)
+		return
+	}
+	if !h.checkEnvelope(w, encrypted, crypto.EnvelopeDocumentSnapshot) {
+		return
+	}
+
+	if err := h.document.SetEncryptedSnapshot(req.Revision, encrypted); err != nil {
+		writeDocumentError(w, err, h.document.Revision())
+		return
+	}
+
+	resp := map[string]interface{}{
+		"stored":   true,
+		"revision": req.Revision,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode snapshot response: %v", err)
+	}
+}
+
+// checkEnvelope rejects ciphertext that is not an envelope of type typ.
+// Only sealed-only servers check; otherwise the format is up to the clients.
+func (h *DocumentHandler) checkEnvelope(w http.ResponseWriter, encrypted []byte, typ crypto.EnvelopeType) bool {
+	if !h.sealedOnly {
+		return true
+	}
+	if err := crypto.CheckEnvelope(encrypted, typ, ""); err != nil {
+		http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Delete handles DELETE /api/doc
+func (h *DocumentHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	locked := h.lock.IsLocked()
+
+	h.document.ShredAll()
+	if locked {
+		// Stay sealed so clients keep sending encrypted operations
+		h.document.Seal(nil)
+	}
+
+	resp := map[string]interface{}{
+		"deleted":  true,
+		"revision": h.document.Revision(),
+		"shredded": true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode delete response: %v", err)
+	}
+}
+
+// writeDocumentError maps document errors to HTTP responses.
+func writeDocumentError(w http.ResponseWriter, err error, revision uint64) {
+	switch err {
+	case store.ErrRevisionConflict:
+		writeRevisionError(w, http.StatusConflict, "Revision conflict", revision)
+	case store.ErrRevisionTooOld:
+		writeRevisionError(w, http.StatusGone, "Revision too old, reload the document", revision)
+	case store.ErrInvalidRevision:
+		writeRevisionError(w, http.StatusBadRequest, "Invalid revision", revision)
+	case store.ErrInvalidOperation, store.ErrOperationLength:
+		http.Error(w, "Invalid operation", http.StatusBadRequest)
+	case store.ErrDocumentTooLarge:
+		http.Error(w, "Document too large", http.StatusRequestEntityTooLarge)
+	case store.ErrStorageFull:
+		http.Error(w, "Storage full", http.StatusInsufficientStorage)
+	case store.ErrDocumentSealed, store.ErrDocumentNotSealed:
+		http.Error(w, "Document seal state changed, reload the document", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to update document", http.StatusInternalServerError)
+	}
+}
+
+// writeRevisionError writes an error response carrying the current revision.
+func writeRevisionError(w http.ResponseWriter, status int, message string, revision uint64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(RevisionResponse{
+		Error:    message,
+		Revision: revision,
+	}); err != nil {
+		log.Printf("Failed to encode revision response: %v", err)
+	}
+}
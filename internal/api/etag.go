@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fileez/fileez/internal/store"
+)
+
+// VersionConflictResponse is sent with 412 when If-Match does not match.
+type VersionConflictResponse struct {
+	Error   string `json:"error"`
+	Version uint64 `json:"version"` // Current version (also sent as ETag)
+}
+
+// formatETag formats a store version as a strong ETag.
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// parseETag parses a strong ETag produced by formatETag.
+func parseETag(tag string) (uint64, bool) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return version, true
+}
+
+// setETag sets the ETag header for a version (nothing for version 0, which
+// means the resource has never been written).
+func setETag(w http.ResponseWriter, version uint64) {
+	if version != store.AnyVersion {
+		w.Header().Set("ETag", formatETag(version))
+	}
+}
+
+// ifMatchVersion returns the version required by the If-Match header.
+// Returns store.AnyVersion if the header is absent or "*".
+// Returns false for a header that cannot match any version (weak or
+// malformed tags, or more than one tag); the request should fail with 412.
+func ifMatchVersion(r *http.Request) (uint64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return store.AnyVersion, true
+	}
+
+	version, ok := parseETag(header)
+	if !ok || version == store.AnyVersion {
+		return 0, false
+	}
+	return version, true
+}
+
+// checkIfMatch resolves If-Match and writes a 412 response if it is unusable.
+// Returns false if the request has been answered.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current uint64) (uint64, bool) {
+	version, ok := ifMatchVersion(r)
+	if !ok {
+		writeVersionConflict(w, current)
+		return 0, false
+	}
+	return version, true
+}
+
+// notModified handles If-None-Match for GET requests.
+// Writes 304 and returns true if the client already has this version.
+func notModified(w http.ResponseWriter, r *http.Request, version uint64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || version == store.AnyVersion {
+		return false
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		// If-None-Match uses weak comparison
+		tag = strings.TrimPrefix(tag, "W/")
+
+		if v, ok := parseETag(tag); tag == "*" || (ok && v == version) {
+			setETag(w, version)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeVersionConflict writes a 412 response with the current version.
+func writeVersionConflict(w http.ResponseWriter, current uint64) {
+	setETag(w, current)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	if err := json.NewEncoder(w).Encode(VersionConflictResponse{
+		Error:   "Version mismatch",
+		Version: current,
+	}); err != nil {
+		log.Printf("Failed to encode version conflict response: %v", err)
+	}
+}
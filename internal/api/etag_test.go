@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fileez/fileez/internal/store"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   uint64
+		wantOK bool
+	}{
+		{"", store.AnyVersion, true},
+		{"*", store.AnyVersion, true},
+		{`"7"`, 7, true},
+		{`W/"7"`, 0, false},
+		{`"7", "8"`, 0, false},
+		{`"0"`, 0, false},
+		{"7", 0, false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("If-Match", tt.header)
+		}
+		version, ok := ifMatchVersion(req)
+		if version != tt.want || ok != tt.wantOK {
+			t.Errorf("If-Match %q: got %d, %v, want %d, %v", tt.header, version, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"6"`, false},
+		{`"7"`, true},
+		{`W/"7"`, true},
+		{`"6", "7"`, true},
+		{"*", true},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("If-None-Match", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		if got := notModified(rec, req, 7); got != tt.want {
+			t.Errorf("If-None-Match %q: got %v, want %v", tt.header, got, tt.want)
+		}
+		if tt.want && rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %q: status %d, want %d", tt.header, rec.Code, http.StatusNotModified)
+		}
+	}
+}
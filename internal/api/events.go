@@ -0,0 +1,143 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+)
+
+// EventHeartbeatInterval is how often an idle event stream sends a keep-alive comment.
+const EventHeartbeatInterval = 25 * time.Second
+
+// EventsHandler streams store change events to clients.
+type EventsHandler struct {
+	events  *store.EventBus
+	session *store.SessionManager
+
+	// Seals guarding files, clipboard text and the clipboard image
+	files store.LockState
+	text  store.LockState
+	image store.LockState
+}
+
+// NewEventsHandler creates a new events handler.
+func NewEventsHandler(events *store.EventBus, session *store.SessionManager, files, text, image store.LockState) *EventsHandler {
+	return &EventsHandler{
+		events:  events,
+		session: session,
+		files:   files,
+		text:    text,
+		image:   image,
+	}
+}
+
+// Stream handles GET /api/events
+// Server-Sent Events stream of change notifications (metadata only).
+// Authorization is re-checked for every event: if the session is locked and the
+// client's token does not match, the stream ends after the lock event. Events
+// about data sealed by a lock scope are skipped without its token.
+func (h *EventsHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	// Streams outlive the server write timeout
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	// Tell clients how long to wait before reconnecting
+	fmt.Fprintf(w, "retry: %d\n\n", 3000)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(EventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-heartbeat.C:
+			if !middleware.StreamAuthorized(h.session, r) {
+				return
+			}
+			fmt.Fprint(w, ": heartbeat\n\n")
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+		case event, ok := <-events:
+			if !ok {
+				// Dropped for falling behind - client reconnects and resyncs
+				return
+			}
+
+			// Lock state is public (see /api/lock/status); everything else
+			// requires the stream to still be authorized.
+			public := strings.HasPrefix(string(event.Type), "session.")
+			authorized := middleware.StreamAuthorized(h.session, r)
+			if !authorized && !public {
+				return
+			}
+			if authorized && !public && !middleware.StreamAuthorized(h.lockFor(event), r) {
+				continue
+			}
+
+			if err := writeEvent(w, event); err != nil {
+				log.Printf("Failed to encode event: %v", err)
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+
+			if !authorized {
+				return
+			}
+		}
+	}
+}
+
+// lockFor returns the seal guarding the data an event is about.
+func (h *EventsHandler) lockFor(event store.Event) store.LockState {
+	switch {
+	case strings.HasPrefix(string(event.Type), "file."):
+		return h.files
+	case strings.HasPrefix(string(event.Type), "clipboard."):
+		if event.Kind == store.ClipboardTypeImage.String() && event.Channel == "" {
+			return h.image
+		}
+		return h.text
+	case strings.HasPrefix(string(event.Type), "document."):
+		return h.text
+	}
+	return h.session
+}
+
+// writeEvent writes a single event in SSE wire format.
+func writeEvent(w http.ResponseWriter, event store.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+	return err
+}
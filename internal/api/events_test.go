@@ -0,0 +1,99 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+)
+
+// openEventStream connects to an event stream and waits until it is subscribed.
+func openEventStream(t *testing.T, url, token string) *bufio.Reader {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("X-Session-Token", token)
+	}
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	// The retry preamble is written after subscribing
+	reader := bufio.NewReader(resp.Body)
+	if line, err := reader.ReadString('\n'); err != nil || !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("stream preamble: got %q, %v", line, err)
+	}
+	reader.ReadString('\n')
+	return reader
+}
+
+// nextEvent returns the type of the next event on the stream, or "" once the
+// stream has ended.
+func nextEvent(reader *bufio.Reader) string {
+	var eventType string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return ""
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" && eventType != "" {
+			return eventType
+		}
+		if value, ok := strings.CutPrefix(line, "event: "); ok {
+			eventType = value
+		}
+	}
+}
+
+func TestEventStreamRequiresTokenWhenSealed(t *testing.T) {
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	events := store.NewEventBus()
+	t.Cleanup(events.Close)
+	session.SetEventBus(events)
+
+	h := NewEventsHandler(events, session, session, session, session)
+	server := httptest.NewServer(middleware.SessionExtractor(http.HandlerFunc(h.Stream)))
+	t.Cleanup(server.Close)
+
+	stream := openEventStream(t, server.URL, "")
+	events.Publish(store.Event{Type: store.EventClipboardSet, Kind: "text"})
+	if got := nextEvent(stream); got != string(store.EventClipboardSet) {
+		t.Fatalf("unsealed stream: got %q, want %q", got, store.EventClipboardSet)
+	}
+
+	// Sealing is announced, then a stream without the token ends
+	token, _, err := session.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if got := nextEvent(stream); got != string(store.EventSessionLocked) {
+		t.Fatalf("after sealing: got %q, want %q", got, store.EventSessionLocked)
+	}
+	events.Publish(store.Event{Type: store.EventClipboardSet, Kind: "text"})
+	if got := nextEvent(stream); got != "" {
+		t.Errorf("stream without the token received %q after sealing", got)
+	}
+
+	// The session token keeps a stream authorized
+	authorized := openEventStream(t, server.URL, token)
+	events.Publish(store.Event{Type: store.EventFileAdded})
+	if got := nextEvent(authorized); got != string(store.EventFileAdded) {
+		t.Errorf("stream with the token: got %q, want %q", got, store.EventFileAdded)
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/fileez/fileez/internal/crypto"
 	"github.com/fileez/fileez/internal/store"
 	"github.com/fileez/fileez/internal/validate"
 )
@@ -22,16 +23,18 @@ func decodeBase64Files(s string) ([]byte, error) {
 // FilesHandler handles file operations.
 type FilesHandler struct {
 	files       *store.FileStore
-	session     *store.SessionManager
+	lock        store.LockState
 	maxFileSize int64
+	sealedOnly  bool // Reject plaintext uploads (SEALED_ONLY)
 }
 
 // NewFilesHandler creates a new files handler.
-func NewFilesHandler(files *store.FileStore, session *store.SessionManager, maxFileSize int64) *FilesHandler {
+func NewFilesHandler(files *store.FileStore, lock store.LockState, maxFileSize int64, sealedOnly bool) *FilesHandler {
 	return &FilesHandler{
 		files:       files,
-		session:     session,
+		lock:        lock,
 		maxFileSize: maxFileSize,
+		sealedOnly:  sealedOnly,
 	}
 }
 
@@ -44,23 +47,41 @@ type FileResponse struct {
 	UploadedAt   string `json:"uploadedAt,omitempty"`
 	ExpiresAt    string `json:"expiresAt,omitempty"`
 	EncryptedB64 string `json:"encrypted_b64,omitempty"` // E2EE: encrypted file data when locked
+	Version      uint64 `json:"version,omitempty"`       // Also sent as ETag on single-file responses
+}
+
+// SealedFileResponse is the response for a single file while sealed.
+// E2EE: The name, MIME type and exact size are only in the encrypted metadata.
+type SealedFileResponse struct {
+	ID                   string `json:"id"`
+	EncryptedMetadataB64 string `json:"encryptedMetadata_b64"`   // Name, MIME type and size, encrypted by the client
+	SizeBucket           int64  `json:"sizeBucket"`              // Padded size
+	EncryptedB64         string `json:"encrypted_b64,omitempty"` // E2EE: encrypted file data
+	Version              uint64 `json:"version,omitempty"`
 }
 
 // List handles GET /api/files
-// E2EE: When session is locked, returns encrypted_b64 for each file.
+// E2EE: When session is locked, returns the encrypted metadata of each file;
+// the content is fetched from /api/sealed/files/{id}.
 func (h *FilesHandler) List(w http.ResponseWriter, r *http.Request) {
-	// E2EE: If session is locked, return encrypted files for client-side decryption
-	if h.session.IsLocked() {
-		encryptedFiles := h.files.GetEncryptedFiles()
-		resp := make([]FileResponse, 0, len(encryptedFiles))
-
-		for _, f := range encryptedFiles {
-			resp = append(resp, FileResponse{
-				ID:           f.ID,
-				Name:         f.Name,
-				MimeType:     f.MimeType,
-				Size:         f.Size,
-				EncryptedB64: f.EncryptedB64,
+	// Read the version before the list so a concurrent change yields a stale ETag
+	version := h.files.Version()
+	if notModified(w, r, version) {
+		return
+	}
+	setETag(w, version)
+
+	// E2EE: If session is locked, list encrypted files for client-side decryption
+	if h.lock.IsLocked() {
+		sealedFiles := h.files.SealedFiles()
+		resp := make([]SealedFileResponse, 0, len(sealedFiles))
+
+		for _, f := range sealedFiles {
+			resp = append(resp, SealedFileResponse{
+				ID:                   f.ID,
+				EncryptedMetadataB64: f.EncryptedMetadataB64,
+				SizeBucket:           f.SizeBucket,
+				Version:              f.Version,
 			})
 		}
 
@@ -82,6 +103,7 @@ func (h *FilesHandler) List(w http.ResponseWriter, r *http.Request) {
 			Size:       f.Size,
 			UploadedAt: f.CreatedAt.Format("2006-01-02T15:04:05Z"),
 			ExpiresAt:  f.ExpiresAt.Format("2006-01-02T15:04:05Z"),
+			Version:    f.Version,
 		})
 	}
 
@@ -90,7 +112,13 @@ func (h *FilesHandler) List(w http.ResponseWriter, r *http.Request) {
 }
 
 // Upload handles POST /api/upload
+// Sealed-only servers accept only /api/upload/encrypted.
 func (h *FilesHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	if h.sealedOnly {
+		rejectPlaintext(w)
+		return
+	}
+
 	// Limit request size
 	r.Body = http.MaxBytesReader(w, r.Body, h.maxFileSize)
 
@@ -158,28 +186,28 @@ func (h *FilesHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		Size:       metadata.Size,
 		UploadedAt: metadata.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		ExpiresAt:  metadata.ExpiresAt.Format("2006-01-02T15:04:05Z"),
+		Version:    metadata.Version,
 	}
 
+	setETag(w, metadata.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(resp)
 }
 
 // EncryptedUploadRequest is the request for uploading encrypted files.
-// E2EE: Client encrypts file locally and sends ciphertext.
+// E2EE: Client encrypts file and metadata locally and sends ciphertext.
 type EncryptedUploadRequest struct {
-	ID           string `json:"id"`           // Client-generated file ID
-	Name         string `json:"name"`         // Original filename
-	MimeType     string `json:"mimetype"`     // Original MIME type
-	Size         int64  `json:"size"`         // Original unencrypted size
-	EncryptedB64 string `json:"encrypted_b64"` // Base64-encoded encrypted data
+	ID                   string `json:"id"`                    // Client-generated file ID
+	EncryptedMetadataB64 string `json:"encryptedMetadata_b64"` // Encrypted name, MIME type and size
+	EncryptedB64         string `json:"encrypted_b64"`         // Base64-encoded encrypted data, padded to its size bucket
 }
 
 // UploadEncrypted handles POST /api/upload/encrypted
 // E2EE: Receives encrypted file data from client. Server cannot decrypt.
 func (h *FilesHandler) UploadEncrypted(w http.ResponseWriter, r *http.Request) {
 	// Only allow when session is locked
-	if !h.session.IsLocked() {
+	if !h.lock.IsLocked() {
 		http.Error(w, "Session must be locked for encrypted uploads", http.StatusBadRequest)
 		return
 	}
@@ -190,54 +218,71 @@ func (h *FilesHandler) UploadEncrypted(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate filename
-	filename, err := validate.Filename(req.Name)
-	if err != nil {
-		http.Error(w, "Invalid filename", http.StatusBadRequest)
+	// Encrypted metadata is required: the server keeps no plaintext name or type
+	if req.EncryptedMetadataB64 == "" {
+		http.Error(w, "Missing encrypted metadata", http.StatusBadRequest)
 		return
 	}
 
-	// Validate MIME type
-	mimeType := validate.MIMETypeOrDefault(req.MimeType, "application/octet-stream")
-
 	// Decode encrypted data
 	encrypted, err := decodeBase64Files(req.EncryptedB64)
 	if err != nil {
 		http.Error(w, "Invalid encrypted data", http.StatusBadRequest)
 		return
 	}
+	envelope, err := crypto.ParseEnvelope(encrypted)
+	if err != nil {
+		http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	// Check size limits (encrypted data will be slightly larger than original)
-	if int64(len(encrypted)) > h.maxFileSize+1024 { // Allow for encryption overhead
+	// Check size limits (content is padded to its size bucket, plus encryption overhead)
+	if int64(len(encrypted)) > crypto.SizeBucket(h.maxFileSize)+1024 {
 		http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
 		return
 	}
 
-	// Store as encrypted file
+	// Store as encrypted file, under the file ID bound into the envelope
 	id := req.ID
 	if id == "" {
-		id = fmt.Sprintf("%d-%s", r.Context().Value("timestamp"), filename)
+		id = envelope.ID
+	}
+	if normalized, err := validate.FileID(id); err != nil || normalized != id {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
 	}
 
 	// Create encrypted file info and add to store
 	encryptedFile := store.EncryptedFileInfo{
-		ID:           id,
-		Name:         filename,
-		MimeType:     mimeType,
-		Size:         req.Size,
-		EncryptedB64: req.EncryptedB64,
+		ID:                   id,
+		EncryptedMetadataB64: req.EncryptedMetadataB64,
+		EncryptedB64:         req.EncryptedB64,
 	}
 
 	// Add to encrypted files list
-	h.files.AddEncryptedFile(encryptedFile)
+	version, err := h.files.AddEncryptedFile(encryptedFile)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateFile:
+			http.Error(w, "File already exists", http.StatusConflict)
+		case store.ErrFileTooLarge:
+			http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+		case store.ErrStorageFull:
+			http.Error(w, "Storage full", http.StatusInsufficientStorage)
+		default:
+			http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
 
-	resp := FileResponse{
-		ID:       id,
-		Name:     filename,
-		MimeType: mimeType,
-		Size:     req.Size,
+	resp := SealedFileResponse{
+		ID:                   id,
+		EncryptedMetadataB64: req.EncryptedMetadataB64,
+		SizeBucket:           crypto.SizeBucket(int64(envelope.PlaintextSize())),
+		Version:              version,
 	}
 
+	setETag(w, version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(resp)
@@ -254,6 +299,11 @@ func (h *FilesHandler) Download(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Files are immutable, so a matching version skips copying the content
+	if metadata, err := h.files.GetMetadata(id); err == nil && notModified(w, r, metadata.Version) {
+		return
+	}
+
 	// Get file
 	file, content, err := h.files.Get(id)
 	if err != nil {
@@ -269,6 +319,7 @@ func (h *FilesHandler) Download(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Set headers for download
+	setETag(w, file.Version)
 	w.Header().Set("Content-Type", file.MimeType)
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.Filename))
 	w.Header().Set("Content-Length", strconv.FormatInt(int64(len(content)), 10))
@@ -301,6 +352,23 @@ func (h *FilesHandler) GetMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if notModified(w, r, file.Version) {
+		return
+	}
+	setETag(w, file.Version)
+	w.Header().Set("Content-Type", "application/json")
+
+	// E2EE: Sealed files have only encrypted metadata and a size bucket
+	if metadata := file.EncryptedMetadata(); metadata != nil {
+		json.NewEncoder(w).Encode(SealedFileResponse{
+			ID:                   file.ID,
+			EncryptedMetadataB64: base64.StdEncoding.EncodeToString(metadata),
+			SizeBucket:           file.Size,
+			Version:              file.Version,
+		})
+		return
+	}
+
 	resp := FileResponse{
 		ID:         file.ID,
 		Name:       file.Filename,
@@ -308,9 +376,9 @@ func (h *FilesHandler) GetMetadata(w http.ResponseWriter, r *http.Request) {
 		Size:       file.Size,
 		UploadedAt: file.CreatedAt.Format("2006-01-02T15:04:05Z"),
 		ExpiresAt:  file.ExpiresAt.Format("2006-01-02T15:04:05Z"),
+		Version:    file.Version,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
@@ -325,12 +393,21 @@ func (h *FilesHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ifVersion, ok := checkIfMatch(w, r, store.AnyVersion)
+	if !ok {
+		return
+	}
+
 	// Delete file (secure shred)
-	if err := h.files.Delete(id); err != nil {
+	if version, err := h.files.Delete(id, ifVersion); err != nil {
 		if err == store.ErrFileNotFound {
 			http.Error(w, "File not found", http.StatusNotFound)
 			return
 		}
+		if err == store.ErrVersionMismatch {
+			writeVersionConflict(w, version)
+			return
+		}
 		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
 		return
 	}
@@ -14,29 +14,40 @@ type HealthHandler struct {
 	memory  *secure.MemoryTracker
 	files   *store.FileStore
 	session *store.SessionManager
+	guard   *store.UnlockGuard
+	mode    string // ModeStandard or ModeSealedOnly
 }
 
 // NewHealthHandler creates a new health handler.
-func NewHealthHandler(memory *secure.MemoryTracker, files *store.FileStore, session *store.SessionManager) *HealthHandler {
+func NewHealthHandler(memory *secure.MemoryTracker, files *store.FileStore, session *store.SessionManager, guard *store.UnlockGuard, sealedOnly bool) *HealthHandler {
+	mode := ModeStandard
+	if sealedOnly {
+		mode = ModeSealedOnly
+	}
 	return &HealthHandler{
 		memory:  memory,
 		files:   files,
 		session: session,
+		guard:   guard,
+		mode:    mode,
 	}
 }
 
 // HealthResponse is the response for health check.
 type HealthResponse struct {
-	Status  string              `json:"status"`
-	Memory  *secure.MemoryStats `json:"memory,omitempty"`
-	Files   *store.FileStoreStats `json:"files,omitempty"`
-	Session *store.SessionStatus  `json:"session,omitempty"`
+	Status  string                  `json:"status"`
+	Mode    string                  `json:"mode"` // "sealed-only": clients must never send plaintext
+	Memory  *secure.MemoryStats     `json:"memory,omitempty"`
+	Files   *store.FileStoreStats   `json:"files,omitempty"`
+	Session *store.SessionStatus    `json:"session,omitempty"`
+	Unlock  *store.UnlockGuardStats `json:"unlock,omitempty"` // Failed unlock counters
 }
 
 // Health handles GET /api/health
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 	resp := HealthResponse{
 		Status: "ok",
+		Mode:   h.mode,
 	}
 
 	// Include stats if requested
@@ -53,6 +64,10 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 			status := h.session.Status()
 			resp.Session = &status
 		}
+		if h.guard != nil {
+			stats := h.guard.Stats()
+			resp.Unlock = &stats
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
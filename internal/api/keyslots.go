@@ -0,0 +1,187 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+)
+
+// KeySlotRequest is a key slot sent by the client.
+// The client encrypts the data with a random content key and wraps that key
+// with a key derived from each slot's password (or device secret).
+type KeySlotRequest struct {
+	Label         string            `json:"label,omitempty"`
+	Kind          string            `json:"kind,omitempty"` // "password" (default) or "device"
+	SaltB64       string            `json:"salt_b64"`
+	KDF           *crypto.KDFParams `json:"kdf,omitempty"` // Default PBKDF2-SHA256, 600,000 iterations
+	KeyHashB64    string            `json:"keyHash_b64"`   // SHA-256 of the slot key
+	WrappedKeyB64 string            `json:"wrappedKey_b64"`
+}
+
+// AddKeySlotRequest is the request body for adding a key slot.
+type AddKeySlotRequest struct {
+	KeyHashB64 string         `json:"keyHash_b64"` // Proves an existing slot
+	Slot       KeySlotRequest `json:"slot"`
+}
+
+// RemoveKeySlotRequest is the request body for removing a key slot.
+type RemoveKeySlotRequest struct {
+	KeyHashB64 string `json:"keyHash_b64"` // Proves an existing slot
+}
+
+// AddKeySlot handles POST /api/lock/slots
+// Adds a key slot wrapping the same content key, proven by an existing slot.
+// The sealed data is not re-encrypted.
+func (h *LockHandler) AddKeySlot(w http.ResponseWriter, r *http.Request) {
+	// SECURITY: Proving a slot counts like an unlock attempt
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
+	var req AddKeySlotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	keyHash, err := base64.StdEncoding.DecodeString(req.KeyHashB64)
+	if err != nil || len(keyHash) != 32 { // SHA-256 = 32 bytes
+		http.Error(w, "Invalid keyHash", http.StatusBadRequest)
+		return
+	}
+
+	slots, msg := h.decodeKeySlots([]KeySlotRequest{req.Slot})
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	info, err := h.session.AddKeySlot(keyHash, slots[0])
+	if err != nil {
+		h.keySlotError(w, ip, err)
+		return
+	}
+
+	if h.guard != nil {
+		h.guard.Success(ip)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(info)
+}
+
+// RemoveKeySlot handles DELETE /api/lock/slots/{id}
+// Removes a key slot, proven by any slot (including the one removed).
+// The last slot cannot be removed.
+func (h *LockHandler) RemoveKeySlot(w http.ResponseWriter, r *http.Request) {
+	// SECURITY: Proving a slot counts like an unlock attempt
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
+	var req RemoveKeySlotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	keyHash, err := base64.StdEncoding.DecodeString(req.KeyHashB64)
+	if err != nil || len(keyHash) != 32 { // SHA-256 = 32 bytes
+		http.Error(w, "Invalid keyHash", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.session.RemoveKeySlot(keyHash, chi.URLParam(r, "id")); err != nil {
+		h.keySlotError(w, ip, err)
+		return
+	}
+
+	if h.guard != nil {
+		h.guard.Success(ip)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// keySlotError writes the response for a failed key slot change.
+func (h *LockHandler) keySlotError(w http.ResponseWriter, ip string, err error) {
+	switch err {
+	case store.ErrSessionNotLocked:
+		http.Error(w, "Session not locked", http.StatusConflict)
+	case store.ErrKeySlotsNotEnabled, store.ErrPAKERequired:
+		http.Error(w, "Session was not sealed with key slots", http.StatusConflict)
+	case store.ErrInvalidPassword:
+		h.unlockFailed(w, ip)
+	case store.ErrKeySlotNotFound:
+		http.Error(w, "Key slot not found", http.StatusNotFound)
+	case store.ErrTooManyKeySlots:
+		http.Error(w, "Too many key slots", http.StatusConflict)
+	case store.ErrLastKeySlot:
+		http.Error(w, "Cannot remove the last key slot", http.StatusConflict)
+	case store.ErrInvalidKeySlot:
+		http.Error(w, "Invalid key slot", http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to update key slots", http.StatusInternalServerError)
+	}
+}
+
+// decodeKeySlots decodes the key slots of a seal.
+// On failure it returns the message for a 400 response.
+func (h *LockHandler) decodeKeySlots(reqs []KeySlotRequest) ([]store.KeySlot, string) {
+	if h.requirePAKE {
+		return nil, "SRP verifier required"
+	}
+	if len(reqs) > store.MaxKeySlots {
+		return nil, "Too many key slots"
+	}
+
+	slots := make([]store.KeySlot, 0, len(reqs))
+	for _, req := range reqs {
+		keyHash, err := base64.StdEncoding.DecodeString(req.KeyHashB64)
+		if err != nil || len(keyHash) != 32 { // SHA-256 = 32 bytes
+			return nil, "Invalid key slot keyHash"
+		}
+		salt, err := base64.StdEncoding.DecodeString(req.SaltB64)
+		if err != nil || len(salt) < 16 { // Salt must be at least 16 bytes
+			return nil, "Invalid key slot salt"
+		}
+		wrapped, err := base64.StdEncoding.DecodeString(req.WrappedKeyB64)
+		if err != nil || len(wrapped) == 0 || len(wrapped) > store.MaxWrappedKeySize {
+			return nil, "Invalid key slot wrappedKey"
+		}
+		if req.Kind != "" && req.Kind != store.KeySlotPassword && req.Kind != store.KeySlotDevice {
+			return nil, "Invalid key slot kind"
+		}
+
+		// Clients without a KDF descriptor use the original PBKDF2 parameters
+		kdf := crypto.LegacyKDFParams()
+		if req.KDF != nil {
+			kdf = *req.KDF
+			if err := kdf.Validate(); err != nil {
+				return nil, "Invalid kdf: " + err.Error()
+			}
+		}
+
+		slots = append(slots, store.KeySlot{
+			Label:      req.Label,
+			Kind:       req.Kind,
+			Salt:       salt,
+			KDF:        kdf,
+			KeyHash:    keyHash,
+			WrappedKey: wrapped,
+		})
+	}
+
+	return slots, ""
+}
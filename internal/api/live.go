@@ -0,0 +1,318 @@
+package api
+
+import (
+	"encoding/base64"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+const (
+	// liveWriteWait is the time allowed to write a message to the peer.
+	liveWriteWait = 10 * time.Second
+	// livePongWait is the time allowed between pongs before the peer is considered gone.
+	livePongWait = 60 * time.Second
+	// livePingInterval must be shorter than livePongWait.
+	livePingInterval = 25 * time.Second
+	// liveMessageOverhead is the allowance for JSON framing around clipboard content.
+	liveMessageOverhead = 4096
+)
+
+// liveMaxMessageSize is the largest inbound message accepted.
+// Sized for a full clipboard as base64 ciphertext (the larger of the two forms).
+var liveMaxMessageSize = int64(base64.StdEncoding.EncodedLen(validate.MaxClipboardSize) + liveMessageOverhead)
+
+// LiveMessage is a message on the live clipboard WebSocket.
+//
+// Client to server:
+//   - "set": store text (or encrypted_b64 when the session is locked);
+//     a non-zero version makes the update conditional, like If-Match
+//
+// Server to client:
+//   - "text": current clipboard text, sent on connect and after every change
+//   - "ack": a "set" was stored (id is the new entry ID)
+//   - "error": a "set" was rejected
+//   - "conflict": a conditional "set" was stale (version is the current version)
+//   - "locked": the session was locked and this connection is no longer authorized
+//   - "deleted": the channel was deleted; the connection is closed
+type LiveMessage struct {
+	Type         string `json:"type"`
+	Text         string `json:"text,omitempty"`
+	EncryptedB64 string `json:"encrypted_b64,omitempty"` // E2EE: base64 encrypted content
+	HasContent   bool   `json:"has_content,omitempty"`
+	Size         int    `json:"size,omitempty"`
+	ID           string `json:"id,omitempty"`
+	Version      uint64 `json:"version,omitempty"` // Text version (same value as the HTTP ETag)
+	Message      string `json:"message,omitempty"`
+}
+
+// LiveHandler handles live clipboard sync over WebSocket.
+type LiveHandler struct {
+	clipboard *store.ClipboardStore
+	channels  *store.ChannelStore
+	lock      store.LockState
+	events    *store.EventBus
+	upgrader  websocket.Upgrader
+
+	// Reject plaintext "set" messages (SEALED_ONLY)
+	sealedOnly bool
+}
+
+// NewLiveHandler creates a new live sync handler.
+// allowedOrigins is checked on upgrade with the same rules as OriginValidation.
+func NewLiveHandler(clipboard *store.ClipboardStore, channels *store.ChannelStore, lock store.LockState, events *store.EventBus, allowedOrigins []string, sealedOnly bool) *LiveHandler {
+	return &LiveHandler{
+		clipboard:  clipboard,
+		channels:   channels,
+		lock:       lock,
+		events:     events,
+		sealedOnly: sealedOnly,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// Never echo the token subprotocol back
+			Subprotocols: []string{middleware.WebSocketProtocol},
+			CheckOrigin: func(r *http.Request) bool {
+				return middleware.OriginAllowed(allowedOrigins, r)
+			},
+		},
+	}
+}
+
+// Clipboard handles GET /api/clipboard/live (WebSocket upgrade)
+// Optional ?channel= selects a named channel instead of the default clipboard.
+// Backpressure: changes are coalesced, so a slow client receives only the
+// latest text rather than a queue of intermediate states.
+// The session token is re-checked for every message in both directions; browsers
+// send it as the "fileez.token.<token>" subprotocol alongside "fileez".
+func (h *LiveHandler) Clipboard(w http.ResponseWriter, r *http.Request) {
+	// Subscribe before looking up the channel so its deletion is never missed
+	events, unsubscribe := h.events.Subscribe()
+	defer unsubscribe()
+
+	channelName := ""
+	clipboard := h.clipboard
+	if name := r.URL.Query().Get("channel"); name != "" {
+		channel, err := h.channels.Get(name)
+		if err != nil {
+			http.Error(w, "Channel not found", http.StatusNotFound)
+			return
+		}
+		channelName = channel.Name
+		clipboard = channel.Clipboard
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrader has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	conn.SetReadLimit(liveMaxMessageSize)
+
+	// Single-slot signal: any number of changes collapse into one pending push
+	dirty := make(chan struct{}, 1)
+	markDirty := func() {
+		select {
+		case dirty <- struct{}{}:
+		default:
+		}
+	}
+	markDirty() // Initial snapshot
+
+	replies := make(chan LiveMessage)
+	readDone := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		defer close(readDone)
+		h.readLoop(conn, r, clipboard, replies, stop)
+	}()
+
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-readDone:
+			return
+
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			switch event.Type {
+			case store.EventSessionLocked, store.EventSessionUnlocked, store.EventSessionForceUnlocked,
+				store.EventSessionDeviceRevoked, store.EventSessionDeviceExpired, store.EventSessionAutoLocked,
+				store.EventSessionRekeyed:
+				markDirty()
+			case store.EventClipboardSet, store.EventClipboardDeleted, store.EventClipboardExpired:
+				if event.Kind == store.ClipboardTypeText.String() && event.Channel == channelName {
+					markDirty()
+				}
+			case store.EventChannelDeleted:
+				if channelName != "" && event.Channel == channelName {
+					h.write(conn, LiveMessage{Type: "deleted", Message: "Channel deleted"})
+					return
+				}
+			}
+
+		case <-dirty:
+			if !middleware.StreamAuthorized(h.lock, r) {
+				h.write(conn, LiveMessage{Type: "locked", Message: "Session is locked"})
+				return
+			}
+			if err := h.write(conn, h.snapshot(clipboard)); err != nil {
+				return
+			}
+
+		case msg := <-replies:
+			if err := h.write(conn, msg); err != nil || msg.Type == "locked" {
+				return
+			}
+
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// readLoop reads client messages until the connection fails or the session
+// locks out this client. Replies are handed to the writer via replies.
+func (h *LiveHandler) readLoop(conn *websocket.Conn, r *http.Request, clipboard *store.ClipboardStore, replies chan<- LiveMessage, stop <-chan struct{}) {
+	conn.SetReadDeadline(time.Now().Add(livePongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(livePongWait))
+	})
+
+	reply := func(msg LiveMessage) bool {
+		select {
+		case replies <- msg:
+			return true
+		case <-stop:
+			return false
+		}
+	}
+
+	for {
+		var msg LiveMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		if !middleware.TokenAuthorized(h.lock, r) {
+			reply(LiveMessage{Type: "locked", Message: "Session is locked"})
+			return
+		}
+
+		switch msg.Type {
+		case "set":
+			if !reply(h.set(clipboard, msg)) {
+				return
+			}
+		default:
+			if !reply(LiveMessage{Type: "error", Message: "Unknown message type"}) {
+				return
+			}
+		}
+	}
+}
+
+// set stores text from a "set" message.
+// E2EE: When session is locked, stores encrypted_b64 without decrypting.
+func (h *LiveHandler) set(clipboard *store.ClipboardStore, msg LiveMessage) LiveMessage {
+	if h.lock.IsLocked() && msg.EncryptedB64 != "" {
+		encrypted, err := decodeBase64(msg.EncryptedB64)
+		if err != nil {
+			return LiveMessage{Type: "error", Message: "Invalid encrypted data"}
+		}
+		if err := validate.ClipboardBytes(encrypted); err != nil {
+			return LiveMessage{Type: "error", Message: err.Error()}
+		}
+		version, err := clipboard.SetEncryptedText(encrypted, msg.Version, store.EntryOptions{})
+		if err == store.ErrVersionMismatch {
+			return LiveMessage{Type: "conflict", Version: version}
+		}
+		if crypto.IsEnvelopeError(err) {
+			return LiveMessage{Type: "error", Message: "Invalid encrypted data: " + err.Error()}
+		}
+		if err != nil {
+			return LiveMessage{Type: "error", Message: "Failed to set clipboard"}
+		}
+		return LiveMessage{Type: "ack", ID: clipboard.TextInfo().ID, Size: len(encrypted), Version: version}
+	}
+
+	if h.sealedOnly {
+		return LiveMessage{Type: "error", Message: "Plaintext writes are disabled: this server is sealed-only"}
+	}
+
+	if msg.Text == "" {
+		return LiveMessage{Type: "error", Message: "No content provided"}
+	}
+
+	text, err := validate.ClipboardContent(msg.Text)
+	if err != nil {
+		return LiveMessage{Type: "error", Message: err.Error()}
+	}
+
+	version, err := clipboard.SetText([]byte(text), msg.Version, store.EntryOptions{})
+	if err == store.ErrVersionMismatch {
+		return LiveMessage{Type: "conflict", Version: version}
+	}
+	if err != nil {
+		return LiveMessage{Type: "error", Message: "Failed to set clipboard"}
+	}
+
+	return LiveMessage{Type: "ack", ID: clipboard.TextInfo().ID, Size: len(text), Version: version}
+}
+
+// snapshot returns the current clipboard text as a "text" message.
+// E2EE: When session is locked, returns encrypted_b64 instead of text.
+func (h *LiveHandler) snapshot(clipboard *store.ClipboardStore) LiveMessage {
+	// Read the version before the content so a concurrent write yields a stale version
+	msg := LiveMessage{Type: "text", Version: clipboard.TextVersion()}
+
+	if h.lock.IsLocked() {
+		encrypted := clipboard.GetEncryptedText()
+		if encrypted != nil {
+			msg.EncryptedB64 = base64.StdEncoding.EncodeToString(encrypted)
+			msg.HasContent = true
+			msg.Size = len(encrypted)
+			msg.ID = clipboard.TextInfo().ID
+		}
+		return msg
+	}
+
+	content, err := clipboard.GetText()
+	if err != nil {
+		return msg
+	}
+
+	msg.Text = string(content)
+	msg.HasContent = true
+	msg.Size = len(content)
+	msg.ID = clipboard.TextInfo().ID
+	return msg
+}
+
+// write sends a JSON message with a write deadline.
+func (h *LiveHandler) write(conn *websocket.Conn, msg LiveMessage) error {
+	conn.SetWriteDeadline(time.Now().Add(liveWriteWait))
+	if err := conn.WriteJSON(msg); err != nil {
+		log.Printf("Failed to write live message: %v", err)
+		return err
+	}
+	return nil
+}
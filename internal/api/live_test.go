@@ -0,0 +1,186 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+)
+
+const liveTestOrigin = "http://fileez.test"
+
+// liveTest is a live clipboard socket server for a fresh session.
+type liveTest struct {
+	server    *httptest.Server
+	session   *store.SessionManager
+	clipboard *store.ClipboardStore
+	channels  *store.ChannelStore
+	events    *store.EventBus
+}
+
+func newLiveTest(t *testing.T) *liveTest {
+	t.Helper()
+
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	events := store.NewEventBus()
+	t.Cleanup(events.Close)
+	session.SetEventBus(events)
+
+	clipboard := store.NewClipboardStore(session, nil, 0, 0, 0)
+	t.Cleanup(clipboard.Close)
+	clipboard.SetEventBus(events, "")
+	channels := store.NewChannelStore(session, nil, 0, 0, 0, 0)
+	t.Cleanup(channels.Close)
+	channels.SetEventBus(events)
+
+	h := NewLiveHandler(clipboard, channels, session, events, []string{liveTestOrigin}, false)
+	server := httptest.NewServer(middleware.SessionExtractor(http.HandlerFunc(h.Clipboard)))
+	t.Cleanup(server.Close)
+
+	return &liveTest{server: server, session: session, clipboard: clipboard, channels: channels, events: events}
+}
+
+// dial opens a live socket; query is appended to the URL and protocols
+// are offered as WebSocket subprotocols.
+func (lt *liveTest) dial(t *testing.T, query string, protocols ...string) *websocket.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(lt.server.URL, "http") + query
+	dialer := websocket.Dialer{Subprotocols: protocols}
+	conn, resp, err := dialer.Dial(url, http.Header{"Origin": {liveTestOrigin}})
+	if err != nil {
+		if resp != nil {
+			t.Fatalf("dial %s: %v (HTTP %d)", url, err, resp.StatusCode)
+		}
+		t.Fatalf("dial %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(15 * time.Second))
+	return conn
+}
+
+func readLive(t *testing.T, conn *websocket.Conn) LiveMessage {
+	t.Helper()
+
+	var msg LiveMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON: %v", err)
+	}
+	return msg
+}
+
+func TestLiveSocketRequiresTokenWhenSealed(t *testing.T) {
+	lt := newLiveTest(t)
+
+	if _, err := lt.clipboard.SetText([]byte("hello"), store.AnyVersion, store.EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	conn := lt.dial(t, "")
+	if msg := readLive(t, conn); msg.Type != "text" || msg.Text != "hello" {
+		t.Fatalf("initial snapshot: got %+v", msg)
+	}
+
+	// Sealing ends the unauthorized connection
+	token, _, err := lt.session.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if msg := readLive(t, conn); msg.Type != "locked" {
+		t.Fatalf("after sealing: got %+v, want a locked message", msg)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("connection still open after sealing")
+	}
+
+	// Without the token nothing is sent but the locked message
+	if msg := readLive(t, lt.dial(t, "")); msg.Type != "locked" {
+		t.Errorf("connect without the token: got %+v, want a locked message", msg)
+	}
+
+	// With the token the sealed snapshot is sent
+	if msg := readLive(t, lt.dial(t, "", middleware.WebSocketProtocol, middleware.WebSocketTokenPrefix+token)); msg.Type != "text" || msg.Text != "" {
+		t.Errorf("connect with the token: got %+v, want a text message without plaintext", msg)
+	}
+}
+
+func TestLiveSocketCoalescesChangesForSlowClients(t *testing.T) {
+	lt := newLiveTest(t)
+	if _, err := lt.clipboard.SetText(bytes.Repeat([]byte{'a'}, 512<<10), store.AnyVersion, store.EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	conn := lt.dial(t, "")
+
+	// Large snapshots fill the socket buffers while the client does not read;
+	// changes announced meanwhile collapse into one push of the latest text
+	const changes = 50
+	for i := 0; i < changes; i++ {
+		lt.events.Publish(store.Event{Type: store.EventClipboardSet, Kind: store.ClipboardTypeText.String()})
+	}
+	if _, err := lt.clipboard.SetText([]byte("latest"), store.AnyVersion, store.EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+
+	received := 0
+	for {
+		msg := readLive(t, conn)
+		if msg.Type != "text" {
+			t.Fatalf("got %+v, want text messages", msg)
+		}
+		received++
+		if msg.Text == "latest" {
+			break
+		}
+	}
+
+	// One snapshot on connect and one per change without coalescing
+	if received >= changes+2 {
+		t.Errorf("received %d snapshots for %d changes, want them coalesced", received, changes+1)
+	}
+}
+
+func TestLiveSocketLimitsMessageSize(t *testing.T) {
+	lt := newLiveTest(t)
+	conn := lt.dial(t, "")
+	readLive(t, conn) // Initial snapshot
+
+	oversized := `{"type":"set","text":"` + strings.Repeat("a", int(liveMaxMessageSize)) + `"}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(oversized)); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	_, _, err := conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseMessageTooBig) {
+		t.Errorf("oversized message: got %v, want close %d", err, websocket.CloseMessageTooBig)
+	}
+	if lt.clipboard.HasText() {
+		t.Error("oversized message was stored")
+	}
+}
+
+func TestLiveSocketEndsWhenChannelIsDeleted(t *testing.T) {
+	lt := newLiveTest(t)
+	if _, err := lt.channels.Create("notes", false, 0); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	conn := lt.dial(t, "?channel=notes")
+	readLive(t, conn) // Initial snapshot
+
+	if err := lt.channels.Delete("notes"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if msg := readLive(t, conn); msg.Type != "deleted" {
+		t.Fatalf("after deleting the channel: got %+v, want a deleted message", msg)
+	}
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("connection still open after its channel was deleted")
+	}
+}
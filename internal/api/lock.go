@@ -3,10 +3,17 @@ package api
 import (
 	"encoding/base64"
 	"encoding/json"
+	"io"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
 	"github.com/fileez/fileez/internal/secure"
 	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
 )
 
 // LockHandler handles session lock/unlock operations.
@@ -14,60 +21,212 @@ type LockHandler struct {
 	session   *store.SessionManager
 	files     *store.FileStore
 	clipboard *store.ClipboardStore
+	channels  *store.ChannelStore
+	document  *store.DocumentStore
+	notes     *store.SecretNoteStore
+	guard     *store.UnlockGuard // Failed unlock tracking (nil = unlimited)
+
+	// Reject keyHash seals; only SRP verifiers are accepted
+	requirePAKE bool
+
+	// KDF parameters advertised for new seals
+	kdf crypto.KDFParams
+
+	// Operator token that authorizes force-unlock ("" = disabled)
+	adminToken string
+	// Device tokens also authorize force-unlock (DEVICE_FORCE_UNLOCK)
+	deviceForceUnlock bool
+
+	// Document snapshots must be envelopes (SEALED_ONLY)
+	sealedOnly bool
+
+	// Lock scope sealed by this handler; the stores above are those of the scope
+	scope store.LockScope
+	// Scope sessions, sealed exclusively of the whole session (nil in rooms)
+	scopes *store.LockScopes
+}
+
+// LockHandlerConfig holds the stores and settings of a LockHandler.
+type LockHandlerConfig struct {
+	Session   *store.SessionManager
+	Files     *store.FileStore
+	Clipboard *store.ClipboardStore
+	Channels  *store.ChannelStore
+	Document  *store.DocumentStore
+	Notes     *store.SecretNoteStore
+	Guard     *store.UnlockGuard // Failed unlock tracking (nil = unlimited)
+
+	RequirePAKE       bool              // Reject keyHash seals; only SRP verifiers are accepted
+	KDF               crypto.KDFParams  // KDF parameters advertised for new seals
+	AdminToken        string            // Operator token that authorizes force-unlock ("" = disabled)
+	DeviceForceUnlock bool              // Device tokens also authorize force-unlock
+	SealedOnly        bool              // Document snapshots must be envelopes
+	Scopes            *store.LockScopes // Lock scope sessions (nil in rooms)
 }
 
 // NewLockHandler creates a new lock handler.
-func NewLockHandler(session *store.SessionManager, files *store.FileStore, clipboard *store.ClipboardStore) *LockHandler {
+func NewLockHandler(config LockHandlerConfig) *LockHandler {
 	return &LockHandler{
-		session:   session,
-		files:     files,
-		clipboard: clipboard,
+		session:           config.Session,
+		files:             config.Files,
+		clipboard:         config.Clipboard,
+		channels:          config.Channels,
+		document:          config.Document,
+		notes:             config.Notes,
+		guard:             config.Guard,
+		requirePAKE:       config.RequirePAKE,
+		kdf:               config.KDF,
+		adminToken:        config.AdminToken,
+		deviceForceUnlock: config.DeviceForceUnlock,
+		sealedOnly:        config.SealedOnly,
+		scopes:            config.Scopes,
 	}
 }
 
+// NewScopedLockHandler creates the lock handler of one lock scope.
+// It seals only the stores in the scope, with the scope's own session and
+// unlock guard.
+func NewScopedLockHandler(config LockHandlerConfig, scope store.LockScope) *LockHandler {
+	h := NewLockHandler(LockHandlerConfig{
+		Session:           config.Scopes.Session(scope),
+		Guard:             config.Scopes.Guard(scope),
+		RequirePAKE:       config.RequirePAKE,
+		KDF:               config.KDF,
+		AdminToken:        config.AdminToken,
+		DeviceForceUnlock: config.DeviceForceUnlock,
+		SealedOnly:        config.SealedOnly,
+		Scopes:            config.Scopes,
+	})
+	h.scope = scope
+
+	switch scope {
+	case store.ScopeFiles:
+		h.files = config.Files
+	case store.ScopeClipboardText:
+		h.clipboard = config.Clipboard
+		h.channels = config.Channels
+		h.document = config.Document
+	case store.ScopeClipboardImage:
+		h.clipboard = config.Clipboard
+	}
+
+	return h
+}
+
 // LockRequest is the request body for E2EE lock operations.
 // Client derives key from password, encrypts data, and sends only keyHash for verification.
 type LockRequest struct {
 	// E2EE fields - client-side encryption
-	KeyHashB64  string `json:"keyHash_b64"`  // SHA-256 hash of derived key
-	SaltB64     string `json:"salt_b64"`     // PBKDF2 salt
-	ClearExisting bool `json:"clearExisting"` // If true, shred all data before locking
+	KeyHashB64    string            `json:"keyHash_b64"`            // SHA-256 hash of derived key
+	VerifierB64   string            `json:"verifier_b64,omitempty"` // SRP verifier, sent instead of keyHash
+	SaltB64       string            `json:"salt_b64"`               // KDF salt (also the SRP salt)
+	KDF           *crypto.KDFParams `json:"kdf,omitempty"`          // How the key was derived (default PBKDF2-SHA256, 600,000 iterations)
+	ClearExisting bool              `json:"clearExisting"`          // If true, shred all data before locking
+	DeviceName    string            `json:"deviceName,omitempty"`   // Shown in the device list
+
+	// Key slots, sent instead of keyHash and salt: each wraps the content key
+	KeySlots []KeySlotRequest `json:"keySlots,omitempty"`
 
 	// Encrypted data from client (server cannot decrypt)
 	EncryptedClipboardB64 string                    `json:"encryptedClipboard_b64,omitempty"`
 	EncryptedImageB64     string                    `json:"encryptedImage_b64,omitempty"`
 	ImageMimeType         string                    `json:"imageMimeType,omitempty"`
 	EncryptedFiles        []store.EncryptedFileInfo `json:"encryptedFiles,omitempty"`
+	EncryptedDocumentB64  string                    `json:"encryptedDocument_b64,omitempty"`
 }
 
 // UnlockRequest is the request body for E2EE unlock operations.
 type UnlockRequest struct {
-	KeyHashB64 string `json:"keyHash_b64"` // SHA-256 hash of derived key
+	KeyHashB64 string `json:"keyHash_b64"`          // SHA-256 hash of derived key
+	DeviceName string `json:"deviceName,omitempty"` // Shown in the device list
+	SlotID     string `json:"slotId,omitempty"`     // Key slot to check (default: any slot)
 }
 
-// UnlockResponse contains encrypted data for client-side decryption.
-type UnlockResponse struct {
-	Token                 string                    `json:"token"`
-	Locked                bool                      `json:"locked"`
-	HasSession            bool                      `json:"hasSession"`
+// RekeyRequest is the request body for changing the password of a sealed session.
+// The current password is proven like an unlock: keyHash, or a handshake started
+// with /api/unlock/srp/start and its M1 for SRP seals.
+type RekeyRequest struct {
+	// Proof of the current password
+	KeyHashB64     string `json:"keyHash_b64,omitempty"`
+	HandshakeID    string `json:"handshakeId,omitempty"`
+	ClientProofB64 string `json:"M1_b64,omitempty"`
+
+	// New credentials, as for /api/lock
+	NewKeyHashB64  string            `json:"newKeyHash_b64,omitempty"`
+	NewVerifierB64 string            `json:"newVerifier_b64,omitempty"`
+	NewSaltB64     string            `json:"newSalt_b64"`
+	NewKDF         *crypto.KDFParams `json:"newKdf,omitempty"`
+	DeviceName     string            `json:"deviceName,omitempty"`
+
+	// All sealed data, re-encrypted under the new key
 	EncryptedClipboardB64 string                    `json:"encryptedClipboard_b64,omitempty"`
 	EncryptedImageB64     string                    `json:"encryptedImage_b64,omitempty"`
 	ImageMimeType         string                    `json:"imageMimeType,omitempty"`
-	EncryptedFiles        []store.EncryptedFileInfo `json:"encryptedFiles,omitempty"`
+	EncryptedFiles        []store.EncryptedFileInfo `json:"encryptedFiles,omitempty"`   // Exactly the sealed files, by ID
+	EncryptedHistory      []EncryptedClipboardEntry `json:"encryptedHistory,omitempty"` // Older encrypted clipboard entries, by ID
+	EncryptedDocumentB64  string                    `json:"encryptedDocument_b64,omitempty"`
+	DocumentRevision      uint64                    `json:"documentRevision"` // Current document revision the snapshot was taken at
+}
+
+// EncryptedClipboardEntry is a clipboard history entry re-encrypted for a re-key.
+type EncryptedClipboardEntry struct {
+	ID           string `json:"id"`
+	EncryptedB64 string `json:"encrypted_b64"`
+}
+
+// UnlockResponse lists the encrypted data for client-side decryption.
+type UnlockResponse struct {
+	Token          string         `json:"token"`    // Token of this device
+	DeviceID       string         `json:"deviceId"` // For revoking this device
+	Locked         bool           `json:"locked"`
+	HasSession     bool           `json:"hasSession"`
+	Manifest       SealedManifest `json:"manifest"`                 // Sealed items, fetched from /api/sealed
+	ServerProofB64 string         `json:"M2_b64,omitempty"`         // SRP server proof
+	SlotID         string         `json:"slotId,omitempty"`         // Key slot that matched
+	WrappedKeyB64  string         `json:"wrappedKey_b64,omitempty"` // Content key wrapped by that slot's key
 }
 
 // LockStatusResponse is the response for lock status.
 type LockStatusResponse struct {
-	Locked     bool   `json:"locked"`
-	HasSession bool   `json:"hasSession"`
-	HasData    bool   `json:"hasData"`
-	Token      string `json:"token,omitempty"`
+	Locked       bool              `json:"locked"`
+	HasSession   bool              `json:"hasSession"`
+	HasData      bool              `json:"hasData"`
+	Token        string            `json:"token,omitempty"`
+	DeviceID     string            `json:"deviceId,omitempty"`
+	AuthMode     string            `json:"authMode,omitempty"`     // "keyhash" or "srp" when locked
+	KDF          *crypto.KDFParams `json:"kdf,omitempty"`          // The seal's KDF when locked, else the one to use for sealing
+	RecoveryCode string            `json:"recoveryCode,omitempty"` // Authorizes a force-unlock; returned only when sealing
+	Scopes       []store.LockScope `json:"scopes,omitempty"`       // Lock scopes sealed on their own (whole session status only)
+}
+
+// AdminTokenHeader carries the operator token for force-unlock.
+const AdminTokenHeader = "X-Admin-Token"
+
+// ForceUnlockRequest is the request body for force-unlock.
+// Operators send the admin token in the X-Admin-Token header instead.
+type ForceUnlockRequest struct {
+	RecoveryCode string `json:"recoveryCode,omitempty"` // Shown once when the session was sealed
 }
 
 // Status handles GET /api/lock/status
 func (h *LockHandler) Status(w http.ResponseWriter, r *http.Request) {
-	// Check if there's any data
-	hasData := h.files.Count() > 0 || h.clipboard.HasText() || h.clipboard.HasImage()
+	// Check if there's any data in the handler's scope
+	hasData := h.files != nil && h.files.Count() > 0
+	if h.clipboard != nil {
+		if (h.scope.Covers(store.ClipboardTypeText) && h.clipboard.HasText()) ||
+			(h.scope.Covers(store.ClipboardTypeImage) && h.clipboard.HasImage()) {
+			hasData = true
+		}
+	}
+	if h.channels != nil && h.channels.HasData() {
+		hasData = true
+	}
+	if h.document != nil && h.document.HasData() {
+		hasData = true
+	}
+	if h.notes != nil && h.notes.HasData() {
+		hasData = true
+	}
 
 	// Check if session exists
 	session := h.session.GetSession()
@@ -77,6 +236,18 @@ func (h *LockHandler) Status(w http.ResponseWriter, r *http.Request) {
 		Locked:     h.session.IsLocked(),
 		HasSession: hasSession,
 		HasData:    hasData,
+		AuthMode:   h.session.AuthMode(),
+	}
+	if kdf, locked := h.session.GetKDF(); locked {
+		// Key slot seals have no seal-wide KDF; each slot has its own
+		if kdf.Algorithm != "" {
+			resp.KDF = &kdf
+		}
+	} else {
+		resp.KDF = &h.kdf
+	}
+	if h.scope == store.ScopeSession && h.scopes != nil {
+		resp.Scopes = h.scopes.Sealed()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -94,83 +265,154 @@ func (h *LockHandler) Lock(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validate required E2EE fields
-	if req.KeyHashB64 == "" || req.SaltB64 == "" {
-		http.Error(w, "Missing keyHash or salt", http.StatusBadRequest)
+	// Validate required E2EE fields: keyHash or SRP verifier, salt and KDF,
+	// or key slots
+	var keyHash, verifier, salt []byte
+	var kdf crypto.KDFParams
+	var slots []store.KeySlot
+	var msg string
+	if len(req.KeySlots) > 0 {
+		slots, msg = h.decodeKeySlots(req.KeySlots)
+	} else {
+		keyHash, verifier, salt, kdf, msg = h.sealCredentials(req.KeyHashB64, req.VerifierB64, req.SaltB64, req.KDF)
+	}
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
 		return
 	}
 
-	// Decode keyHash and salt
-	keyHash, err := base64.StdEncoding.DecodeString(req.KeyHashB64)
-	if err != nil || len(keyHash) != 32 { // SHA-256 = 32 bytes
-		http.Error(w, "Invalid keyHash", http.StatusBadRequest)
+	// Every blob must decode before anything is touched.
+	// With clearExisting all existing data is shredded instead.
+	var text, image, document []byte
+	var files []store.EncryptedFileInfo
+	if !req.ClearExisting {
+		if name := h.outOfScope(req.EncryptedFiles, req.EncryptedClipboardB64, req.EncryptedImageB64, req.EncryptedDocumentB64); name != "" {
+			http.Error(w, name+" is outside the lock scope", http.StatusBadRequest)
+			return
+		}
+		for _, blob := range []struct {
+			b64  string
+			dst  *[]byte
+			name string
+		}{
+			{req.EncryptedClipboardB64, &text, "encryptedClipboard_b64"},
+			{req.EncryptedImageB64, &image, "encryptedImage_b64"},
+			{req.EncryptedDocumentB64, &document, "encryptedDocument_b64"},
+		} {
+			if blob.b64 == "" {
+				continue
+			}
+			var err error
+			if *blob.dst, err = base64.StdEncoding.DecodeString(blob.b64); err != nil {
+				http.Error(w, "Invalid "+blob.name, http.StatusBadRequest)
+				return
+			}
+		}
+		files = req.EncryptedFiles
+	}
+	if !h.checkDocumentSnapshot(w, document) {
 		return
 	}
 
-	salt, err := base64.StdEncoding.DecodeString(req.SaltB64)
-	if err != nil || len(salt) < 16 { // Salt must be at least 16 bytes
-		http.Error(w, "Invalid salt", http.StatusBadRequest)
+	// The recovery code is shown once and stored with the seal; the server
+	// keeps only its hash. A seal is never left without one.
+	recoveryCode, err := crypto.GenerateRecoveryCode()
+	if err != nil {
+		http.Error(w, "Failed to issue recovery code", http.StatusInternalServerError)
 		return
 	}
 
-	// Handle existing data based on clearExisting flag
-	if req.ClearExisting {
-		// Shred all existing data before locking
-		if h.files != nil {
-			h.files.ShredAll()
+	// Lock session with keyHash or verifier and salt (server cannot derive key)
+	// The encrypted data is swapped in together with the lock; if anything is
+	// rejected, nothing changes. The locking device gets the first device token.
+	// Channel content is not part of the lock payload; rather than shred it
+	// unasked, the seal is refused until the channels are cleared
+	var txs []store.SealTx
+	seal := func() error {
+		if !req.ClearExisting && h.channels != nil && h.channels.HasData() {
+			return store.ErrChannelsHaveData
 		}
-		if h.clipboard != nil {
-			h.clipboard.ShredAll()
+		var err error
+		txs, err = h.sealEncrypted(files, text, image, req.ImageMimeType, document)
+		return err
+	}
+	var token string
+	var device store.DeviceInfo
+	lock := func() error {
+		var err error
+		if slots != nil {
+			token, device, err = h.session.LockWithKeySlots(slots, req.DeviceName, recoveryCode, seal)
+		} else if verifier != nil {
+			token, device, err = h.session.LockWithVerifier(salt, verifier, kdf, req.DeviceName, recoveryCode, seal)
+		} else {
+			token, device, err = h.session.Lock(keyHash, salt, kdf, req.DeviceName, recoveryCode, seal)
 		}
+		return err
+	}
+	if h.scopes != nil {
+		// The whole session and single scopes are never sealed together
+		err = h.scopes.Seal(h.scope, lock)
 	} else {
-		// Store encrypted blobs from client (server cannot decrypt)
-		if h.clipboard != nil {
-			// Shred plaintext first
-			h.clipboard.ShredAll()
-
-			// Store encrypted clipboard text
-			if req.EncryptedClipboardB64 != "" {
-				encrypted, err := base64.StdEncoding.DecodeString(req.EncryptedClipboardB64)
-				if err == nil {
-					h.clipboard.SetEncryptedText(encrypted)
-				}
-			}
-
-			// Store encrypted clipboard image
-			if req.EncryptedImageB64 != "" {
-				encrypted, err := base64.StdEncoding.DecodeString(req.EncryptedImageB64)
-				if err == nil {
-					h.clipboard.SetEncryptedImage(encrypted, req.ImageMimeType)
-				}
-			}
+		err = lock()
+	}
+	if err != nil {
+		switch err {
+		case store.ErrSessionLocked:
+			http.Error(w, "Session already locked", http.StatusConflict)
+		case store.ErrScopeConflict:
+			http.Error(w, "The whole session and lock scopes cannot be sealed together", http.StatusConflict)
+		case store.ErrChannelsHaveData:
+			http.Error(w, "Clear the clipboard channels before sealing, or seal with clearExisting", http.StatusConflict)
+		case crypto.ErrInvalidEnvelope, crypto.ErrEnvelopeUnsupported, crypto.ErrEnvelopeMismatch:
+			http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		case validate.ErrInvalidFileID:
+			http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		case store.ErrDuplicateFile:
+			http.Error(w, "Duplicate file ID", http.StatusBadRequest)
+		case store.ErrFileTooLarge:
+			http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+		case store.ErrDocumentTooLarge:
+			http.Error(w, "Document too large", http.StatusRequestEntityTooLarge)
+		case store.ErrStorageFull:
+			http.Error(w, "Storage full", http.StatusInsufficientStorage)
+		default:
+			http.Error(w, "Failed to lock session", http.StatusInternalServerError)
 		}
+		return
+	}
 
-		// Store encrypted files
-		if h.files != nil && len(req.EncryptedFiles) > 0 {
-			h.files.SetEncryptedFiles(req.EncryptedFiles)
-		} else if h.files != nil {
-			// No encrypted files provided, shred existing
-			h.files.ShredAll()
-		}
+	// Sealed - only now shred the plaintext that was swapped out
+	for _, tx := range txs {
+		tx.Shred()
 	}
 
-	// Lock session with keyHash and salt (server cannot derive key)
-	if err := h.session.Lock(keyHash, salt); err != nil {
-		if err == store.ErrSessionLocked {
-			http.Error(w, "Session already locked", http.StatusConflict)
-			return
+	// Channels were empty unless all data is cleared. They stay usable with
+	// client-encrypted content.
+	if h.channels != nil && req.ClearExisting {
+		h.channels.ShredAll()
+	}
+
+	// Plaintext secret notes are shredded; client-encrypted notes stay
+	// retrievable by their recipients unless all data is cleared
+	if h.notes != nil {
+		if req.ClearExisting {
+			h.notes.ShredAll()
+		} else {
+			h.notes.ShredPlaintext()
 		}
-		http.Error(w, "Failed to lock session", http.StatusInternalServerError)
-		return
 	}
 
-	// Get token for client
-	token := h.session.GetToken()
+	// Failed attempts against an earlier seal no longer count
+	if h.guard != nil {
+		h.guard.Reset()
+	}
 
 	resp := LockStatusResponse{
-		Locked:     true,
-		HasSession: true,
-		Token:      token,
+		Locked:       true,
+		HasSession:   true,
+		Token:        token,
+		DeviceID:     device.ID,
+		RecoveryCode: recoveryCode,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
@@ -181,6 +423,13 @@ func (h *LockHandler) Lock(w http.ResponseWriter, r *http.Request) {
 // IMPORTANT: Session STAYS LOCKED. Data STAYS ENCRYPTED on server.
 // Client decrypts locally for display only. Each device must verify password.
 func (h *LockHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	// SECURITY: Back off and lock out after failed attempts
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
 	// Parse request
 	var req UnlockRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -202,43 +451,408 @@ func (h *LockHandler) Unlock(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// SECURITY: Verify keyHash using constant-time comparison
-	if err := h.session.VerifyKeyHash(keyHash); err != nil {
+	// For key slot seals this also finds the slot and its wrapped content key
+	slot, err := h.session.MatchKeyHash(keyHash, req.SlotID)
+	if err != nil {
 		switch err {
 		case store.ErrSessionNotLocked:
 			http.Error(w, "Session not locked", http.StatusConflict)
+		case store.ErrPAKERequired:
+			http.Error(w, "Session requires SRP unlock", http.StatusConflict)
+		case store.ErrKeySlotNotFound:
+			http.Error(w, "Key slot not found", http.StatusNotFound)
 		case store.ErrInvalidPassword:
-			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			h.unlockFailed(w, ip)
 		default:
 			http.Error(w, "Failed to verify password", http.StatusInternalServerError)
 		}
 		return
 	}
 
-	// KeyHash is correct - return encrypted blobs for client-side decryption
-	// IMPORTANT: Session stays locked, data stays encrypted on server
-	resp := UnlockResponse{
-		Token:      h.session.GetToken(),
-		Locked:     true, // Session STAYS locked
+	if h.guard != nil {
+		h.guard.Success(ip)
+	}
+
+	// KeyHash is correct
+	h.completeUnlock(w, r, req.DeviceName, nil, slot)
+}
+
+// Rekey handles POST /api/lock/rekey
+// Changes the password of the sealed session. The client proves the current
+// password and sends the new keyHash (or verifier), salt and all sealed data
+// re-encrypted under the new key. Everything is swapped in one transaction or
+// the whole request is rejected. Other devices must unlock with the new password.
+func (h *LockHandler) Rekey(w http.ResponseWriter, r *http.Request) {
+	// SECURITY: Proving the current password counts like an unlock attempt
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
+	var req RekeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	params := store.RekeyParams{
+		HandshakeID:   req.HandshakeID,
+		DeviceName:    req.DeviceName,
+		PreviousToken: middleware.GetSessionToken(r),
+	}
+
+	// Decode the proof of the current password
+	var err error
+	if req.HandshakeID != "" {
+		params.ClientProof, err = base64.StdEncoding.DecodeString(req.ClientProofB64)
+		if err != nil || len(params.ClientProof) == 0 {
+			http.Error(w, "Invalid M1", http.StatusBadRequest)
+			return
+		}
+	} else {
+		params.KeyHash, err = base64.StdEncoding.DecodeString(req.KeyHashB64)
+		if err != nil || len(params.KeyHash) != 32 { // SHA-256 = 32 bytes
+			http.Error(w, "Invalid keyHash", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Decode the new credentials
+	var msg string
+	params.NewKeyHash, params.NewVerifier, params.NewSalt, params.NewKDF, msg = h.sealCredentials(req.NewKeyHashB64, req.NewVerifierB64, req.NewSaltB64, req.NewKDF)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if name := h.outOfScope(req.EncryptedFiles, req.EncryptedClipboardB64, req.EncryptedImageB64, req.EncryptedDocumentB64); name != "" {
+		http.Error(w, name+" is outside the lock scope", http.StatusBadRequest)
+		return
+	}
+	if len(req.EncryptedHistory) > 0 && (h.clipboard == nil || len(h.scope.ClipboardTypes()) == 0) {
+		http.Error(w, "encryptedHistory is outside the lock scope", http.StatusBadRequest)
+		return
+	}
+
+	// Every blob must decode before anything is swapped
+	var text, image, document []byte
+	history := make(map[string][]byte, len(req.EncryptedHistory))
+	for _, entry := range req.EncryptedHistory {
+		encrypted, err := base64.StdEncoding.DecodeString(entry.EncryptedB64)
+		if _, dup := history[entry.ID]; err != nil || len(encrypted) == 0 || dup {
+			http.Error(w, "Invalid encryptedHistory entry "+entry.ID, http.StatusBadRequest)
+			return
+		}
+		history[entry.ID] = encrypted
+	}
+	for _, blob := range []struct {
+		b64  string
+		dst  *[]byte
+		name string
+	}{
+		{req.EncryptedClipboardB64, &text, "encryptedClipboard_b64"},
+		{req.EncryptedImageB64, &image, "encryptedImage_b64"},
+		{req.EncryptedDocumentB64, &document, "encryptedDocument_b64"},
+	} {
+		if blob.b64 == "" {
+			continue
+		}
+		if *blob.dst, err = base64.StdEncoding.DecodeString(blob.b64); err != nil {
+			http.Error(w, "Invalid "+blob.name, http.StatusBadRequest)
+			return
+		}
+	}
+	if !h.checkDocumentSnapshot(w, document) {
+		return
+	}
+
+	result, err := h.session.Rekey(params, func() error {
+		return h.swapEncrypted(req.EncryptedFiles, text, image, req.ImageMimeType, history, req.DocumentRevision, document)
+	})
+	if err != nil {
+		switch err {
+		case store.ErrSessionNotLocked:
+			http.Error(w, "Session not locked", http.StatusConflict)
+		case store.ErrPAKERequired:
+			http.Error(w, "Session requires SRP proof", http.StatusConflict)
+		case store.ErrPAKENotEnabled:
+			http.Error(w, "Session was not sealed for SRP unlock", http.StatusConflict)
+		case store.ErrHandshakeNotFound:
+			http.Error(w, "Handshake not found or expired", http.StatusNotFound)
+		case store.ErrInvalidPassword:
+			h.unlockFailed(w, ip)
+		case store.ErrRekeyIncomplete:
+			http.Error(w, "Re-encrypted data does not match the sealed data", http.StatusConflict)
+		case store.ErrChannelsHaveData:
+			http.Error(w, "Clear the clipboard channels before changing the password", http.StatusConflict)
+		case store.ErrRekeyKeySlots:
+			http.Error(w, "Remove the other key slots before changing the password", http.StatusConflict)
+		case store.ErrDocumentNotSealed:
+			http.Error(w, "Document is not sealed", http.StatusConflict)
+		case crypto.ErrInvalidEnvelope, crypto.ErrEnvelopeUnsupported, crypto.ErrEnvelopeMismatch:
+			http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		case validate.ErrInvalidFileID:
+			http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		case store.ErrRevisionConflict:
+			http.Error(w, "Document changed, reload and retry", http.StatusConflict)
+		case store.ErrDocumentTooLarge:
+			http.Error(w, "Document too large", http.StatusRequestEntityTooLarge)
+		case store.ErrStorageFull:
+			http.Error(w, "Storage full", http.StatusInsufficientStorage)
+		default:
+			http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if h.guard != nil {
+		h.guard.Success(ip)
+	}
+
+	resp := LockStatusResponse{
+		Locked:     true,
 		HasSession: true,
+		Token:      result.Token,
+		DeviceID:   result.Device.ID,
+		AuthMode:   h.session.AuthMode(),
+		KDF:        &params.NewKDF,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// swapEncrypted replaces the sealed data with its re-encrypted version.
+// Every store stages its part first, holding its lock; only if all of them
+// accept are they committed. Called by SessionManager.Rekey.
+func (h *LockHandler) swapEncrypted(files []store.EncryptedFileInfo, text, image []byte, imageMimeType string, history map[string][]byte, documentRevision uint64, document []byte) error {
+	var txs []store.RekeyTx
+	abort := func(err error) error {
+		for i := len(txs) - 1; i >= 0; i-- {
+			txs[i].Abort()
+		}
+		return err
+	}
+
+	// Channel content is encrypted with the old key and not part of the payload
+	if h.channels != nil && h.channels.HasData() {
+		return store.ErrChannelsHaveData
 	}
 
-	// Get encrypted clipboard text
+	if h.files != nil {
+		tx, err := h.files.BeginRekey(files)
+		if err != nil {
+			return abort(err)
+		}
+		txs = append(txs, tx)
+	}
 	if h.clipboard != nil {
-		if encryptedText := h.clipboard.GetEncryptedText(); encryptedText != nil {
-			resp.EncryptedClipboardB64 = base64.StdEncoding.EncodeToString(encryptedText)
+		tx, err := h.clipboard.BeginRekey(h.scope.ClipboardTypes(), text, image, imageMimeType, history)
+		if err != nil {
+			return abort(err)
 		}
+		txs = append(txs, tx)
+	}
+	if h.document != nil {
+		tx, err := h.document.BeginRekey(documentRevision, document)
+		if err != nil {
+			return abort(err)
+		}
+		txs = append(txs, tx)
+	}
+
+	for _, tx := range txs {
+		tx.Commit()
+	}
 
-		// Get encrypted clipboard image
-		if encryptedImage, mimeType := h.clipboard.GetEncryptedImage(); encryptedImage != nil {
-			resp.EncryptedImageB64 = base64.StdEncoding.EncodeToString(encryptedImage)
-			resp.ImageMimeType = mimeType
+	return nil
+}
+
+// sealEncrypted replaces all data with the encrypted data of a lock.
+// Every store stages its part first, holding its lock; only if all of them
+// accept are they committed. The returned transactions shred the replaced
+// plaintext. Called by SessionManager.Lock before the lock takes effect.
+func (h *LockHandler) sealEncrypted(files []store.EncryptedFileInfo, text, image []byte, imageMimeType string, document []byte) ([]store.SealTx, error) {
+	var txs []store.SealTx
+	abort := func(err error) ([]store.SealTx, error) {
+		for i := len(txs) - 1; i >= 0; i-- {
+			txs[i].Abort()
 		}
+		return nil, err
 	}
 
-	// Get encrypted files
 	if h.files != nil {
-		resp.EncryptedFiles = h.files.GetEncryptedFiles()
+		tx, err := h.files.BeginSeal(files)
+		if err != nil {
+			return abort(err)
+		}
+		txs = append(txs, tx)
+	}
+	if h.clipboard != nil {
+		tx, err := h.clipboard.BeginSeal(h.scope.ClipboardTypes(), text, image, imageMimeType)
+		if err != nil {
+			return abort(err)
+		}
+		txs = append(txs, tx)
+	}
+	if h.document != nil {
+		tx, err := h.document.BeginSeal(document)
+		if err != nil {
+			return abort(err)
+		}
+		txs = append(txs, tx)
+	}
+
+	for _, tx := range txs {
+		tx.Commit()
+	}
+
+	return txs, nil
+}
+
+// outOfScope returns the name of a blob sent for data outside the handler's
+// lock scope, or "" if there is none.
+func (h *LockHandler) outOfScope(files []store.EncryptedFileInfo, textB64, imageB64, documentB64 string) string {
+	switch {
+	case len(files) > 0 && h.files == nil:
+		return "encryptedFiles"
+	case textB64 != "" && !h.scope.Covers(store.ClipboardTypeText):
+		return "encryptedClipboard_b64"
+	case imageB64 != "" && !h.scope.Covers(store.ClipboardTypeImage):
+		return "encryptedImage_b64"
+	case documentB64 != "" && h.document == nil:
+		return "encryptedDocument_b64"
+	}
+	return ""
+}
+
+// checkDocumentSnapshot rejects a document snapshot that is not an envelope.
+// Only sealed-only servers check; otherwise the format is up to the clients.
+func (h *LockHandler) checkDocumentSnapshot(w http.ResponseWriter, document []byte) bool {
+	if !h.sealedOnly || len(document) == 0 {
+		return true
+	}
+	if err := crypto.CheckEnvelope(document, crypto.EnvelopeDocumentSnapshot, ""); err != nil {
+		http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// sealCredentials decodes the keyHash or SRP verifier, salt and KDF descriptor
+// of a seal. On failure it returns the message for a 400 response.
+func (h *LockHandler) sealCredentials(keyHashB64, verifierB64, saltB64 string, descriptor *crypto.KDFParams) ([]byte, []byte, []byte, crypto.KDFParams, string) {
+	if (keyHashB64 == "" && verifierB64 == "") || saltB64 == "" {
+		return nil, nil, nil, crypto.KDFParams{}, "Missing keyHash or salt"
+	}
+	if verifierB64 == "" && h.requirePAKE {
+		return nil, nil, nil, crypto.KDFParams{}, "SRP verifier required"
+	}
+
+	// Decode keyHash or verifier, and salt
+	var keyHash, verifier []byte
+	var err error
+	if verifierB64 != "" {
+		verifier, err = base64.StdEncoding.DecodeString(verifierB64)
+		if err != nil || crypto.CheckSRPVerifier(verifier) != nil {
+			return nil, nil, nil, crypto.KDFParams{}, "Invalid verifier"
+		}
+	} else {
+		keyHash, err = base64.StdEncoding.DecodeString(keyHashB64)
+		if err != nil || len(keyHash) != 32 { // SHA-256 = 32 bytes
+			return nil, nil, nil, crypto.KDFParams{}, "Invalid keyHash"
+		}
+	}
+
+	salt, err := base64.StdEncoding.DecodeString(saltB64)
+	if err != nil || len(salt) < 16 { // Salt must be at least 16 bytes
+		return nil, nil, nil, crypto.KDFParams{}, "Invalid salt"
+	}
+
+	// Clients without a KDF descriptor use the original PBKDF2 parameters
+	kdf := crypto.LegacyKDFParams()
+	if descriptor != nil {
+		kdf = *descriptor
+		if err := kdf.Validate(); err != nil {
+			return nil, nil, nil, crypto.KDFParams{}, "Invalid kdf: " + err.Error()
+		}
+	}
+
+	return keyHash, verifier, salt, kdf, ""
+}
+
+// throttled rejects the request if ip has to wait after failed unlocks.
+// An admitted attempt must be ended with release.
+func (h *LockHandler) throttled(w http.ResponseWriter, ip string) bool {
+	if h.guard == nil {
+		return false
+	}
+	wait, ok := h.guard.Allow(ip)
+	if ok {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many failed attempts, try again later", http.StatusTooManyRequests)
+	return true
+}
+
+// release ends an unlock attempt admitted by throttled.
+func (h *LockHandler) release(ip string) {
+	if h.guard != nil {
+		h.guard.Release(ip)
+	}
+}
+
+// unlockFailed records a wrong password from ip and applies the wipe policy.
+func (h *LockHandler) unlockFailed(w http.ResponseWriter, ip string) {
+	if h.guard != nil && h.guard.Failure(ip) {
+		// Wipe policy: too many failures in total, shred as in ForceUnlock
+		log.Printf("Unlock failure limit reached, shredding session data")
+		if err := h.shredAndUnlock(); err == nil || err == store.ErrSessionNotLocked {
+			http.Error(w, "Too many failed attempts: all data has been shredded", http.StatusGone)
+			return
+		}
 	}
+	http.Error(w, "Invalid password", http.StatusUnauthorized)
+}
+
+// completeUnlock issues a token for a device that proved the password and
+// returns the encrypted blobs. serverProof is the SRP M2 and slot the matching
+// key slot, if any.
+func (h *LockHandler) completeUnlock(w http.ResponseWriter, r *http.Request, deviceName string, serverProof []byte, slot *store.KeySlotMatch) {
+	// Issue a token for this device
+	// A device re-verifying with its current token gets that token rotated
+	token, device, err := h.session.IssueDeviceToken(deviceName, middleware.GetSessionToken(r))
+	if err != nil {
+		switch err {
+		case store.ErrSessionNotLocked:
+			http.Error(w, "Session not locked", http.StatusConflict)
+		case store.ErrTooManyDevices:
+			http.Error(w, "Too many devices, revoke one first", http.StatusConflict)
+		default:
+			http.Error(w, "Failed to issue device token", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Return the manifest of encrypted blobs for client-side decryption
+	// IMPORTANT: Session stays locked, data stays encrypted on server
+	resp := UnlockResponse{
+		Token:      token,
+		DeviceID:   device.ID,
+		Locked:     true, // Session STAYS locked
+		HasSession: true,
+	}
+	if serverProof != nil {
+		resp.ServerProofB64 = base64.StdEncoding.EncodeToString(serverProof)
+	}
+	if slot != nil {
+		resp.SlotID = slot.Slot.ID
+		resp.WrappedKeyB64 = base64.StdEncoding.EncodeToString(slot.WrappedKey)
+	}
+
+	// List the sealed items; clients fetch each one from /api/sealed
+	resp.Manifest = sealedManifest(h.files, h.clipboard, h.document, h.scope)
 
 	// DO NOT unlock session - data stays encrypted on server
 	// DO NOT clear encrypted data - it's the only copy
@@ -249,21 +863,36 @@ func (h *LockHandler) Unlock(w http.ResponseWriter, r *http.Request) {
 }
 
 // GetSalt handles GET /api/lock/salt
-// Returns the PBKDF2 salt for client-side key derivation during unlock.
+// Returns the salt and KDF descriptor for client-side key derivation during unlock,
+// or the salt and KDF of every key slot.
 func (h *LockHandler) GetSalt(w http.ResponseWriter, r *http.Request) {
 	if !h.session.IsLocked() {
 		http.Error(w, "Session not locked", http.StatusBadRequest)
 		return
 	}
 
+	if slots := h.session.KeySlots(); slots != nil {
+		resp := map[string]interface{}{
+			"authMode": store.AuthModeKeySlots,
+			"keySlots": slots,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
 	salt := h.session.GetSalt()
 	if salt == nil {
 		http.Error(w, "No salt available", http.StatusInternalServerError)
 		return
 	}
 
-	resp := map[string]string{
+	kdf, _ := h.session.GetKDF()
+	resp := map[string]interface{}{
 		"salt_b64": base64.StdEncoding.EncodeToString(salt),
+		"authMode": h.session.AuthMode(),
+		"kdf":      kdf,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -272,7 +901,75 @@ func (h *LockHandler) GetSalt(w http.ResponseWriter, r *http.Request) {
 
 // ForceUnlock handles POST /api/lock/force-unlock
 // This shreds all data and unlocks without requiring the password.
+// SECURITY: Requires the admin token, the recovery code issued when sealing,
+// or the token of a device that has unlocked the session.
 func (h *LockHandler) ForceUnlock(w http.ResponseWriter, r *http.Request) {
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
+	if !h.session.IsLocked() {
+		http.Error(w, "Session not locked", http.StatusConflict)
+		return
+	}
+
+	// The body is optional: operators authorize with the header only
+	var req ForceUnlockRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var authorizedBy string
+	switch {
+	case h.adminToken != "" && r.Header.Get(AdminTokenHeader) != "":
+		if crypto.ConstantTimeCompare([]byte(r.Header.Get(AdminTokenHeader)), []byte(h.adminToken)) {
+			authorizedBy = "admin token"
+		}
+	case req.RecoveryCode != "":
+		if h.session.CheckRecoveryCode(req.RecoveryCode) {
+			authorizedBy = "recovery code"
+		}
+	case h.deviceForceUnlock:
+		// Off by default: a stolen device token would otherwise wipe the session
+		if h.session.CheckToken(middleware.GetSessionToken(r)) {
+			authorizedBy = "device token"
+		}
+	}
+	if authorizedBy == "" {
+		log.Printf("Force-unlock rejected from %s", ip)
+		if h.guard != nil {
+			h.guard.ForceUnlockFailure(ip)
+		}
+		http.Error(w, "Recovery code or admin token required", http.StatusUnauthorized)
+		return
+	}
+	log.Printf("Force-unlock from %s authorized by %s", ip, authorizedBy)
+
+	if err := h.shredAndUnlock(); err != nil {
+		if err == store.ErrSessionNotLocked {
+			http.Error(w, "Session not locked", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to force unlock", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"locked":   false,
+		"shredded": true,
+		"message":  "All data has been securely shredded",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// shredAndUnlock shreds all session data and unlocks without the password.
+// Used by ForceUnlock and by the unlock wipe policy.
+func (h *LockHandler) shredAndUnlock() error {
 	// Shred callback
 	shredCallback := func() {
 		// Shred all files
@@ -282,30 +979,37 @@ func (h *LockHandler) ForceUnlock(w http.ResponseWriter, r *http.Request) {
 
 		// Shred clipboard
 		if h.clipboard != nil {
-			h.clipboard.ShredAll()
+			h.clipboard.ShredTypes(h.scope.ClipboardTypes()...)
+		}
+
+		// Shred channel content
+		if h.channels != nil {
+			h.channels.ShredAll()
+		}
+
+		// Shred the shared document
+		if h.document != nil {
+			h.document.ShredAll()
+		}
+
+		// Shred secret notes
+		if h.notes != nil {
+			h.notes.ShredAll()
 		}
 	}
 
 	// Force unlock
 	if err := h.session.ForceUnlock(shredCallback); err != nil {
-		if err == store.ErrSessionNotLocked {
-			http.Error(w, "Session not locked", http.StatusConflict)
-			return
-		}
-		http.Error(w, "Failed to force unlock", http.StatusInternalServerError)
-		return
+		return err
 	}
 
 	// Clear any remaining data using secure shredder
 	shredder := secure.NewShredder(nil)
 	shredder.ShredAll()
 
-	resp := map[string]interface{}{
-		"locked":   false,
-		"shredded": true,
-		"message":  "All data has been securely shredded",
+	if h.guard != nil {
+		h.guard.Reset()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	return nil
 }
@@ -0,0 +1,247 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+)
+
+// sealedLockHandler returns a lock handler for a session sealed with keyHash.
+func sealedLockHandler(t *testing.T, keyHash []byte, guard *store.UnlockGuard) *LockHandler {
+	t.Helper()
+
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+
+	salt := bytes.Repeat([]byte{1}, 16)
+	if _, _, err := session.Lock(keyHash, salt, crypto.LegacyKDFParams(), "test", "", nil); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	return NewLockHandler(LockHandlerConfig{Session: session, Guard: guard, KDF: crypto.LegacyKDFParams()})
+}
+
+func TestUnlockBackoffIgnoresSpoofedForwardingHeaders(t *testing.T) {
+	guard := store.NewUnlockGuard(store.UnlockGuardConfig{BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(guard.Close)
+
+	h := sealedLockHandler(t, bytes.Repeat([]byte{1}, 32), guard)
+	handler := middleware.ClientIPResolver(nil)(http.HandlerFunc(h.Unlock))
+
+	unlock := func(remoteAddr, forwardedFor string) int {
+		body, _ := json.Marshal(UnlockRequest{KeyHashB64: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 32))})
+		req := httptest.NewRequest(http.MethodPost, "/api/unlock", bytes.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := unlock("203.0.113.7:4242", ""); code != http.StatusUnauthorized {
+		t.Fatalf("first wrong password: got %d, want %d", code, http.StatusUnauthorized)
+	}
+
+	// A new forwarded address on every request must not escape the backoff
+	for _, spoofed := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3"} {
+		if code := unlock("203.0.113.7:4242", spoofed); code != http.StatusTooManyRequests {
+			t.Errorf("retry with X-Forwarded-For %s: got %d, want %d", spoofed, code, http.StatusTooManyRequests)
+		}
+	}
+
+	// Nor may it lock out the address it names
+	if code := unlock("198.51.100.1:4242", ""); code != http.StatusUnauthorized {
+		t.Errorf("other client: got %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestUnlockReleasesRejectedAttempts(t *testing.T) {
+	guard := store.NewUnlockGuard(store.UnlockGuardConfig{BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(guard.Close)
+
+	h := sealedLockHandler(t, bytes.Repeat([]byte{1}, 32), guard)
+
+	// Requests rejected before the password is checked free their attempt
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/unlock", strings.NewReader("{"))
+		rec := httptest.NewRecorder()
+		h.Unlock(rec, req)
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("malformed request %d: got %d, want %d", i, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestForceUnlockIgnoresDeviceTokensByDefault(t *testing.T) {
+	for _, deviceForceUnlock := range []bool{false, true} {
+		session := store.NewSessionManager()
+		t.Cleanup(session.Destroy)
+
+		token, _, err := session.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil)
+		if err != nil {
+			t.Fatalf("Lock: %v", err)
+		}
+
+		h := NewLockHandler(LockHandlerConfig{Session: session, KDF: crypto.LegacyKDFParams(), DeviceForceUnlock: deviceForceUnlock})
+		req := httptest.NewRequest(http.MethodPost, "/api/lock/force-unlock", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.SessionTokenKey, token))
+		rec := httptest.NewRecorder()
+		h.ForceUnlock(rec, req)
+
+		want := http.StatusUnauthorized
+		if deviceForceUnlock {
+			want = http.StatusOK
+		}
+		if rec.Code != want {
+			t.Errorf("force-unlock with a device token (DEVICE_FORCE_UNLOCK=%v): got %d, want %d", deviceForceUnlock, rec.Code, want)
+		}
+		if session.IsLocked() == deviceForceUnlock {
+			t.Errorf("session locked = %v after force-unlock (DEVICE_FORCE_UNLOCK=%v)", session.IsLocked(), deviceForceUnlock)
+		}
+	}
+}
+
+func TestLockRecordsKDFDescriptor(t *testing.T) {
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	h := NewLockHandler(LockHandlerConfig{Session: session, KDF: crypto.LegacyKDFParams()})
+
+	lock := func(kdf crypto.KDFParams) int {
+		body, _ := json.Marshal(LockRequest{
+			KeyHashB64: base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+			SaltB64:    base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)),
+			KDF:        &kdf,
+		})
+		rec := httptest.NewRecorder()
+		h.Lock(rec, httptest.NewRequest(http.MethodPost, "/api/lock", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	weak := crypto.DefaultArgon2idParams()
+	weak.Memory = crypto.MinArgon2Memory / 2
+	if code := lock(weak); code != http.StatusBadRequest {
+		t.Errorf("seal with weak Argon2id parameters: got %d, want %d", code, http.StatusBadRequest)
+	}
+
+	// The descriptor sent when sealing comes back with the salt
+	kdf := crypto.DefaultArgon2idParams()
+	kdf.Time = 4
+	if code := lock(kdf); code != http.StatusOK {
+		t.Fatalf("seal: got %d, want %d", code, http.StatusOK)
+	}
+
+	rec := httptest.NewRecorder()
+	h.GetSalt(rec, httptest.NewRequest(http.MethodGet, "/api/lock/salt", nil))
+	var resp struct {
+		KDF crypto.KDFParams `json:"kdf"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode salt response: %v", err)
+	}
+	if resp.KDF != kdf {
+		t.Errorf("kdf = %+v, want %+v", resp.KDF, kdf)
+	}
+}
+
+func TestForceUnlockAuthorization(t *testing.T) {
+	const adminToken = "operator-secret"
+	recoveryCode, err := crypto.GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		adminToken   string
+		recoveryCode string
+		want         int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong admin token", "guess", "", http.StatusUnauthorized},
+		{"wrong recovery code", "", "0000-0000-0000-0000", http.StatusUnauthorized},
+		{"wrong admin token with the recovery code", "guess", recoveryCode, http.StatusUnauthorized},
+		{"admin token", adminToken, "", http.StatusOK},
+		{"recovery code", "", recoveryCode, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		session := store.NewSessionManager()
+		t.Cleanup(session.Destroy)
+		if _, _, err := session.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", recoveryCode, nil); err != nil {
+			t.Fatalf("Lock: %v", err)
+		}
+
+		h := NewLockHandler(LockHandlerConfig{Session: session, KDF: crypto.LegacyKDFParams(), AdminToken: adminToken})
+		body, _ := json.Marshal(ForceUnlockRequest{RecoveryCode: tt.recoveryCode})
+		req := httptest.NewRequest(http.MethodPost, "/api/lock/force-unlock", bytes.NewReader(body))
+		if tt.adminToken != "" {
+			req.Header.Set(AdminTokenHeader, tt.adminToken)
+		}
+		rec := httptest.NewRecorder()
+		h.ForceUnlock(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if session.IsLocked() != (tt.want != http.StatusOK) {
+			t.Errorf("%s: locked = %v after force-unlock", tt.name, session.IsLocked())
+		}
+	}
+}
+
+func TestLockRefusedWhileChannelsHoldContent(t *testing.T) {
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	channels := store.NewChannelStore(session, nil, 0, 0, 0, 0)
+	t.Cleanup(channels.Close)
+
+	channel, err := channels.Create("notes", false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := channel.Clipboard.SetText([]byte("plaintext"), store.AnyVersion, store.EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+
+	h := NewLockHandler(LockHandlerConfig{Session: session, Channels: channels, KDF: crypto.LegacyKDFParams()})
+	lock := func(clearExisting bool) int {
+		body, _ := json.Marshal(LockRequest{
+			KeyHashB64:    base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)),
+			SaltB64:       base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16)),
+			ClearExisting: clearExisting,
+		})
+		rec := httptest.NewRecorder()
+		h.Lock(rec, httptest.NewRequest(http.MethodPost, "/api/lock", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	if code := lock(false); code != http.StatusConflict {
+		t.Fatalf("lock with channel content: got %d, want %d", code, http.StatusConflict)
+	}
+	if session.IsLocked() {
+		t.Fatal("session was sealed")
+	}
+	if !channels.HasData() {
+		t.Fatal("channel content was shredded by a refused lock")
+	}
+
+	// clearExisting shreds the channels along with everything else
+	if code := lock(true); code != http.StatusOK {
+		t.Fatalf("lock with clearExisting: got %d, want %d", code, http.StatusOK)
+	}
+	if channels.HasData() {
+		t.Error("channel content survived a lock with clearExisting")
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is one media range of an Accept header.
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// negotiateContentType picks the offer that best matches the Accept header.
+// Offers are listed in server preference order, which breaks ties; an absent
+// Accept header selects the first offer. Returns "" if nothing is acceptable.
+func negotiateContentType(r *http.Request, offers []string) string {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	ranges := parseAccept(header)
+
+	best := ""
+	bestQuality := 0.0
+	for _, offer := range offers {
+		if quality := acceptQuality(ranges, offer); quality > bestQuality {
+			best = offer
+			bestQuality = quality
+		}
+	}
+
+	return best
+}
+
+// parseAccept parses the media ranges of an Accept header.
+// Malformed ranges are skipped.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			quality, err = strconv.ParseFloat(q, 64)
+			if err != nil || quality < 0 || quality > 1 {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+
+	return ranges
+}
+
+// acceptQuality returns the quality of the most specific range matching mediaType.
+func acceptQuality(ranges []acceptRange, mediaType string) float64 {
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	quality := 0.0
+	specificity := -1
+	for _, ar := range ranges {
+		var s int
+		switch {
+		case ar.mediaType == mediaType:
+			s = 2
+		case ar.mediaType == mainType+"/*":
+			s = 1
+		case ar.mediaType == "*/*":
+			s = 0
+		default:
+			continue
+		}
+
+		if s > specificity {
+			specificity = s
+			quality = ar.quality
+		}
+	}
+
+	return quality
+}
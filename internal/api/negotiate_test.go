@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"text/plain", "text/html", "text/uri-list"}
+
+	tests := []struct {
+		name   string
+		accept string
+		offers []string
+		want   string
+	}{
+		{"no Accept header", "", offers, "text/plain"},
+		{"exact match", "text/html", offers, "text/html"},
+		{"highest quality wins", "text/plain;q=0.5, text/uri-list;q=0.8", offers, "text/uri-list"},
+		{"ties keep server preference", "text/html, text/plain", offers, "text/plain"},
+		{"subtype wildcard", "text/*", offers, "text/plain"},
+		{"specific range overrides wildcard", "text/*;q=0.9, text/plain;q=0.1", offers, "text/html"},
+		{"q=0 refuses a type", "text/plain;q=0, */*", offers, "text/html"},
+		{"malformed ranges are skipped", "text/html;q=2, ;;, text/uri-list", offers, "text/uri-list"},
+		{"nothing acceptable", "image/png", offers, ""},
+		{"no offers", "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/api/clipboard", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		if got := negotiateContentType(req, tt.offers); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// maxNoteRequestSize bounds note request bodies: a note as base64 ciphertext plus JSON framing.
+var maxNoteRequestSize = int64(base64.StdEncoding.EncodedLen(validate.MaxClipboardSize+1024) + 4096)
+
+// NotesHandler handles view-once secret notes.
+// Recipients and creators authenticate with the note's tokens, not the session,
+// so opening and status checks work for people outside a locked session.
+type NotesHandler struct {
+	notes   *store.SecretNoteStore
+	session *store.SessionManager
+
+	// Reject plaintext notes; encrypted notes must be envelopes (SEALED_ONLY)
+	sealedOnly bool
+}
+
+// NewNotesHandler creates a new secret notes handler.
+func NewNotesHandler(notes *store.SecretNoteStore, session *store.SessionManager, sealedOnly bool) *NotesHandler {
+	return &NotesHandler{
+		notes:      notes,
+		session:    session,
+		sealedOnly: sealedOnly,
+	}
+}
+
+// CreateNoteRequest is the request body for creating a note.
+// For client-side encryption, send encrypted_b64 and keep the key in the
+// link's URL fragment, which browsers never send to the server.
+type CreateNoteRequest struct {
+	Text         string `json:"text,omitempty"`
+	EncryptedB64 string `json:"encrypted_b64,omitempty"` // Client-encrypted note
+	TTLSeconds   int64  `json:"ttl_seconds,omitempty"`   // Lifetime of the unread note (default SECRET_NOTE_EXPIRY)
+}
+
+// CreateNoteResponse is the response for creating a note.
+// The tokens are only ever returned here.
+type CreateNoteResponse struct {
+	Token       string    `json:"token"`        // Retrieval token for the recipient
+	StatusToken string    `json:"status_token"` // Lets the creator check or burn the note
+	Encrypted   bool      `json:"encrypted"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// NoteTokenRequest is the request body for opening a note.
+// Tokens travel in the body so they never appear in URLs or access logs.
+type NoteTokenRequest struct {
+	Token string `json:"token"`
+}
+
+// NoteStatusRequest is the request body for checking or burning a note.
+type NoteStatusRequest struct {
+	StatusToken string `json:"status_token"`
+}
+
+// OpenNoteResponse is the response for opening a note.
+type OpenNoteResponse struct {
+	Text         string `json:"text,omitempty"`
+	EncryptedB64 string `json:"encrypted_b64,omitempty"`
+	Encrypted    bool   `json:"encrypted"`
+}
+
+// Create handles POST /api/notes
+// E2EE: When session is locked, only client-encrypted notes are accepted.
+func (h *NotesHandler) Create(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxNoteRequestSize)
+
+	var req CreateNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.TTLSeconds < 0 {
+		http.Error(w, "Invalid ttl_seconds", http.StatusBadRequest)
+		return
+	}
+	expiry := time.Duration(req.TTLSeconds) * time.Second
+
+	var tokens store.NoteTokens
+	var err error
+	encrypted := req.EncryptedB64 != ""
+
+	if encrypted {
+		data, decodeErr := decodeBase64(req.EncryptedB64)
+		if decodeErr != nil || len(data) == 0 {
+			http.Error(w, "Invalid encrypted data", http.StatusBadRequest)
+			return
+		}
+		if h.sealedOnly {
+			if envelopeErr := crypto.CheckEnvelope(data, crypto.EnvelopeNote, ""); envelopeErr != nil {
+				http.Error(w, "Invalid encrypted data: "+envelopeErr.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		tokens, err = h.notes.CreateEncrypted(data, expiry)
+	} else {
+		if h.sealedOnly {
+			rejectPlaintext(w)
+			return
+		}
+		if h.session.IsLocked() {
+			http.Error(w, "Session is locked: notes must be client-encrypted", http.StatusBadRequest)
+			return
+		}
+
+		text, validateErr := validate.ClipboardContent(req.Text)
+		if validateErr == nil {
+			text, validateErr = validate.NonEmpty(text)
+		}
+		if validateErr != nil {
+			http.Error(w, validateErr.Error(), http.StatusBadRequest)
+			return
+		}
+		tokens, err = h.notes.Create([]byte(text), expiry)
+	}
+	if err != nil {
+		switch err {
+		case store.ErrInvalidNoteExpiry:
+			http.Error(w, "ttl_seconds out of range", http.StatusBadRequest)
+		case validate.ErrClipboardTooLarge, validate.ErrEmptyInput:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case store.ErrTooManyNotes:
+			http.Error(w, "Too many notes", http.StatusInsufficientStorage)
+		default:
+			http.Error(w, "Failed to store note", http.StatusInsufficientStorage)
+		}
+		return
+	}
+
+	resp := CreateNoteResponse{
+		Token:       tokens.Token,
+		StatusToken: tokens.StatusToken,
+		Encrypted:   encrypted,
+		ExpiresAt:   tokens.ExpiresAt,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode note response: %v", err)
+	}
+}
+
+// Open handles POST /api/notes/open
+// Returns the note and shreds it. POST keeps link previewers and prefetchers
+// from burning the note. Unknown, read and expired notes all return 404.
+func (h *NotesHandler) Open(w http.ResponseWriter, r *http.Request) {
+	var req NoteTokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	token, err := validate.SessionToken(req.Token)
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+
+	content, encrypted, err := h.notes.Open(token)
+	if err != nil {
+		if err == store.ErrNoteNotFound {
+			http.Error(w, "Note not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to open note", http.StatusInternalServerError)
+		return
+	}
+	defer secure.Shred(content)
+
+	resp := OpenNoteResponse{Encrypted: encrypted}
+	if encrypted {
+		resp.EncryptedB64 = base64.StdEncoding.EncodeToString(content)
+	} else {
+		resp.Text = string(content)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode note response: %v", err)
+	}
+}
+
+// Status handles POST /api/notes/status
+// Tells the creator whether the note was read, without its content.
+func (h *NotesHandler) Status(w http.ResponseWriter, r *http.Request) {
+	h.withStatus(w, r, h.notes.Status)
+}
+
+// Burn handles POST /api/notes/burn
+// Shreds an unread note on behalf of its creator.
+func (h *NotesHandler) Burn(w http.ResponseWriter, r *http.Request) {
+	h.withStatus(w, r, h.notes.Burn)
+}
+
+// withStatus decodes a status token, applies fn and writes the resulting status.
+func (h *NotesHandler) withStatus(w http.ResponseWriter, r *http.Request, fn func(string) (store.NoteStatus, error)) {
+	var req NoteStatusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	statusToken, err := validate.SessionToken(req.StatusToken)
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+
+	status, err := fn(statusToken)
+	if err != nil {
+		http.Error(w, "Note not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		log.Printf("Failed to encode note status response: %v", err)
+	}
+}
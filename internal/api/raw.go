@@ -0,0 +1,361 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// GetRaw handles GET /api/clipboard/raw and GET /api/clipboard/{channel}/raw
+// Returns the current text as text/plain, or the current image bytes with
+// ?kind=image (or an Accept header preferring image/*).
+// E2EE: When session is locked, returns the ciphertext as application/octet-stream.
+func (h *ClipboardHandler) GetRaw(w http.ResponseWriter, r *http.Request) {
+	image := rawWantsImage(r)
+	if image && !h.imageEnabled {
+		http.Error(w, "Image clipboard disabled", http.StatusNotFound)
+		return
+	}
+
+	contentType := store.ClipboardTypeText
+	if image {
+		contentType = store.ClipboardTypeImage
+	}
+	lock := h.authorizedFor(w, r, contentType)
+	if lock == nil {
+		return
+	}
+
+	clipboard := h.clipboardFor(w, r, image)
+	if clipboard == nil {
+		return
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	version := clipboard.TextVersion()
+	if image {
+		version = clipboard.ImageVersion()
+	}
+	if notModified(w, r, version) {
+		return
+	}
+
+	var data []byte
+	var mediaType string
+
+	// E2EE: If session is locked, return encrypted data for client-side decryption
+	if lock.IsLocked() {
+		if image {
+			data, _ = clipboard.GetEncryptedImage()
+		} else {
+			data = clipboard.GetEncryptedText()
+		}
+		if data == nil {
+			http.Error(w, "Clipboard is empty", http.StatusNotFound)
+			return
+		}
+		mediaType = "application/octet-stream"
+	} else {
+		var err error
+		if image {
+			data, mediaType, err = clipboard.GetImage()
+		} else {
+			data, err = clipboard.GetText()
+			mediaType = "text/plain; charset=utf-8"
+		}
+		if err != nil {
+			if err == store.ErrClipboardEmpty || err == store.ErrClipboardExpired {
+				http.Error(w, "Clipboard is empty", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "Failed to get clipboard", http.StatusInternalServerError)
+			return
+		}
+	}
+	defer secure.Shred(data)
+
+	setETag(w, version)
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
+
+// SetRaw handles PUT and POST /api/clipboard/raw and /api/clipboard/{channel}/raw
+// The body is the content itself (e.g. curl -T file). An image Content-Type
+// stores an image; otherwise images are recognised by their bytes and
+// everything else must be UTF-8 text. The body is read straight into secure
+// memory, counted against the memory limit, and limited to the image size
+// limit with an image Content-Type or the text limit otherwise.
+// ?ttl_seconds= and ?pinned=true set the entry lifetime as in the JSON
+// endpoints.
+// E2EE: When session is locked, the body is the client-encrypted blob; an
+// image Content-Type (the type of the plaintext) selects the image slot.
+// While only one of text and image is sealed, images are recognised by their
+// Content-Type alone.
+func (h *ClipboardHandler) SetRaw(w http.ResponseWriter, r *http.Request) {
+	declared, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	contentType := store.ClipboardTypeText
+	if validate.IsImageMIMEType(declared) {
+		contentType = store.ClipboardTypeImage
+	}
+	lock := h.authorizedFor(w, r, contentType)
+	if lock == nil {
+		return
+	}
+	locked := lock.IsLocked()
+	if !locked && h.sealedOnly {
+		rejectPlaintext(w)
+		return
+	}
+
+	query := r.URL.Query()
+	var ttlSeconds int64
+	if v := query.Get("ttl_seconds"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid ttl_seconds", http.StatusBadRequest)
+			return
+		}
+		ttlSeconds = parsed
+	}
+	pinned, _ := strconv.ParseBool(query.Get("pinned"))
+
+	opts, ok := h.entryOptions(w, ttlSeconds, pinned)
+	if !ok {
+		return
+	}
+
+	// Images may be larger than text; a body without an image Content-Type
+	// is read under the text limit even if its bytes turn out to be an image
+	limit := validate.MaxClipboardSize
+	if contentType == store.ClipboardTypeImage {
+		limit = validate.MaxClipboardImageSize
+	}
+	if r.ContentLength > int64(limit) {
+		http.Error(w, "Clipboard content too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if r.ContentLength == 0 {
+		http.Error(w, "No content provided", http.StatusBadRequest)
+		return
+	}
+	// A declared length sizes the buffer; chunked bodies get the whole limit
+	if r.ContentLength > 0 {
+		limit = int(r.ContentLength)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(limit))
+
+	// The secure buffer is locked memory: count it against the memory limit
+	// until it is destroyed, so concurrent uploads cannot exhaust it
+	if h.memory != nil {
+		if err := h.memory.Allocate(int64(limit) + 1); err != nil {
+			http.Error(w, "Storage full", http.StatusInsufficientStorage)
+			return
+		}
+		defer h.memory.Free(int64(limit) + 1)
+	}
+
+	buf, n, err := secure.ReadSecure(r.Body, limit)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case err == secure.ErrStreamTooLarge, errors.As(err, &maxBytesErr):
+			http.Error(w, "Clipboard content too large", http.StatusRequestEntityTooLarge)
+		case err == secure.ErrBufferEmpty:
+			http.Error(w, "No content provided", http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to read clipboard content", http.StatusBadRequest)
+		}
+		return
+	}
+	defer buf.Destroy()
+
+	// The content never leaves secure memory: stores copy it into their own buffers
+	buf.MutableUse(func(data []byte) error {
+		content := data[:n]
+
+		mimeType := ""
+		switch {
+		case validate.IsImageMIMEType(declared):
+			mimeType = declared
+		case !locked && !h.lockFor(r, store.ClipboardTypeImage).IsLocked():
+			if detected := http.DetectContentType(content); validate.IsImageMIMEType(detected) {
+				mimeType = detected
+			}
+		}
+
+		if mimeType != "" {
+			h.setRawImage(w, r, content, mimeType, locked, opts)
+		} else {
+			h.setRawText(w, r, content, locked, opts)
+		}
+		return nil
+	})
+}
+
+// setRawText stores a raw text body.
+func (h *ClipboardHandler) setRawText(w http.ResponseWriter, r *http.Request, content []byte, locked bool, opts store.EntryOptions) {
+	clipboard := h.clipboardFor(w, r, false)
+	if clipboard == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, clipboard.TextVersion())
+	if !ok {
+		return
+	}
+
+	var version uint64
+	var err error
+
+	if locked {
+		// Store encrypted text (server cannot decrypt)
+		version, err = clipboard.SetEncryptedText(content, ifVersion, opts)
+	} else {
+		// Same rules as the JSON endpoint: UTF-8, surrounding whitespace trimmed
+		if !utf8.Valid(content) {
+			http.Error(w, "Unsupported content: expected UTF-8 text or an image", http.StatusUnsupportedMediaType)
+			return
+		}
+		content = bytes.TrimSpace(content)
+		if len(content) == 0 {
+			http.Error(w, "No content provided", http.StatusBadRequest)
+			return
+		}
+
+		version, err = clipboard.SetText(content, ifVersion, opts)
+	}
+	if err == store.ErrClipboardClosed {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err == store.ErrVersionMismatch {
+		writeVersionConflict(w, version)
+		return
+	}
+	if err == store.ErrPinLimit {
+		writePinLimit(w)
+		return
+	}
+	if crypto.IsEnvelopeError(err) {
+		http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to set clipboard", http.StatusInsufficientStorage)
+		return
+	}
+
+	resp := ClipboardTextResponse{
+		HasContent: true,
+		Size:       len(content),
+		Version:    version,
+		Pinned:     opts.Pinned,
+		ExpiresIn:  clipboard.TextInfo().ExpiresIn,
+	}
+
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode clipboard response: %v", err)
+	}
+}
+
+// setRawImage stores a raw image body.
+func (h *ClipboardHandler) setRawImage(w http.ResponseWriter, r *http.Request, content []byte, mimeType string, locked bool, opts store.EntryOptions) {
+	if !h.imageEnabled {
+		http.Error(w, "Image clipboard disabled", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	clipboard := h.clipboardFor(w, r, true)
+	if clipboard == nil {
+		return
+	}
+
+	ifVersion, ok := checkIfMatch(w, r, clipboard.ImageVersion())
+	if !ok {
+		return
+	}
+
+	var version uint64
+	var err error
+
+	if locked {
+		// Store encrypted image (server cannot decrypt)
+		version, err = clipboard.SetEncryptedImage(content, mimeType, ifVersion, opts)
+	} else {
+		version, err = clipboard.SetImage(content, mimeType, ifVersion, opts)
+	}
+	if err == store.ErrClipboardClosed {
+		http.Error(w, "Channel not found", http.StatusNotFound)
+		return
+	}
+	if err == store.ErrVersionMismatch {
+		writeVersionConflict(w, version)
+		return
+	}
+	if err == store.ErrPinLimit {
+		writePinLimit(w)
+		return
+	}
+	if crypto.IsEnvelopeError(err) {
+		http.Error(w, "Invalid encrypted data: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to store image", http.StatusInsufficientStorage)
+		return
+	}
+
+	resp := ClipboardImageResponse{
+		HasImage:  true,
+		MimeType:  mimeType,
+		Size:      len(content),
+		Version:   version,
+		Pinned:    opts.Pinned,
+		ExpiresIn: clipboard.ImageInfo().ExpiresIn,
+	}
+
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode image response: %v", err)
+	}
+}
+
+// rawWantsImage returns whether a raw read addresses the image slot:
+// ?kind=image, or an Accept header that prefers any image over text.
+func rawWantsImage(r *http.Request) bool {
+	switch r.URL.Query().Get("kind") {
+	case "image":
+		return true
+	case "text":
+		return false
+	}
+
+	ranges := parseAccept(r.Header.Get("Accept"))
+
+	imageQuality := 0.0
+	for _, ar := range ranges {
+		if strings.HasPrefix(ar.mediaType, "image/") && ar.quality > imageQuality {
+			imageQuality = ar.quality
+		}
+	}
+	return imageQuality > acceptQuality(ranges, "text/plain")
+}
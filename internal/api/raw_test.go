@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// rawClipboardHandler returns a clipboard handler for an unsealed session
+// whose raw uploads are accounted against memory.
+func rawClipboardHandler(t *testing.T, memory *secure.MemoryTracker) *ClipboardHandler {
+	t.Helper()
+
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	clipboard := store.NewClipboardStore(session, nil, 0, 0, 0)
+	t.Cleanup(clipboard.Close)
+
+	return NewClipboardHandler(clipboard, nil, session, session, true, time.Second, time.Hour, false, memory)
+}
+
+func TestSetRawLimitsByContentType(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(64 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	h := rawClipboardHandler(t, memory)
+
+	tests := []struct {
+		name        string
+		contentType string
+		size        int
+		chunked     bool
+		want        int
+	}{
+		{"text", "text/plain", 16, false, http.StatusCreated},
+		{"text over the text limit", "text/plain", validate.MaxClipboardSize + 1, false, http.StatusRequestEntityTooLarge},
+		{"chunked text over the text limit", "text/plain", validate.MaxClipboardSize + 1, true, http.StatusRequestEntityTooLarge},
+		{"image over the text limit", "image/png", validate.MaxClipboardSize + 1, false, http.StatusCreated},
+		{"image over the image limit", "image/png", validate.MaxClipboardImageSize + 1, false, http.StatusRequestEntityTooLarge},
+		{"chunked image over the image limit", "image/png", validate.MaxClipboardImageSize + 1, true, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		var body io.Reader = bytes.NewReader(bytes.Repeat([]byte{'a'}, tt.size))
+		if tt.chunked {
+			// Hide the length so the request is read without a Content-Length
+			body = io.MultiReader(body)
+		}
+		req := httptest.NewRequest(http.MethodPut, "/api/clipboard/raw", body)
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		h.SetRaw(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+		if n := memory.Allocated(); n != 0 {
+			t.Errorf("%s: %d bytes still accounted after the request, want 0", tt.name, n)
+		}
+	}
+}
+
+func TestSetRawCountsAgainstMemoryLimit(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(secure.MinMemoryLimit)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	h := rawClipboardHandler(t, memory)
+
+	// Another upload holds nearly all of the limit
+	if err := memory.Allocate(secure.MinMemoryLimit - 8); err != nil {
+		t.Fatalf("Allocate: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/api/clipboard/raw", bytes.NewReader([]byte("over the remaining budget")))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	h.SetRaw(rec, req)
+
+	if rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("upload past the memory limit: got %d, want %d", rec.Code, http.StatusInsufficientStorage)
+	}
+	if n := memory.Allocated(); n != secure.MinMemoryLimit-8 {
+		t.Errorf("allocated = %d after a refused upload, want %d", n, secure.MinMemoryLimit-8)
+	}
+}
+
+func TestRawRoundTrip(t *testing.T) {
+	h := rawClipboardHandler(t, nil)
+
+	put := func(contentType string, body []byte) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/clipboard/raw", bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.SetRaw(rec, req)
+		return rec.Code
+	}
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.GetRaw(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	if code := put("", []byte("  hello\n")); code != http.StatusCreated {
+		t.Fatalf("PUT text: got %d, want %d", code, http.StatusCreated)
+	}
+	rec := get("/api/clipboard/raw")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Errorf("GET text: got %d %q, want %d %q", rec.Code, rec.Body.String(), http.StatusOK, "hello")
+	}
+
+	// Images are recognised by their bytes without a Content-Type
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 32)...)
+	if code := put("", png); code != http.StatusCreated {
+		t.Fatalf("PUT image: got %d, want %d", code, http.StatusCreated)
+	}
+	rec = get("/api/clipboard/raw?kind=image")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" || !bytes.Equal(rec.Body.Bytes(), png) {
+		t.Errorf("GET image: got %d %s, want %d image/png with the uploaded bytes", rec.Code, rec.Header().Get("Content-Type"), http.StatusOK)
+	}
+
+	// The image did not replace the text
+	if rec := get("/api/clipboard/raw"); rec.Body.String() != "hello" {
+		t.Errorf("GET text after an image upload: got %q, want %q", rec.Body.String(), "hello")
+	}
+
+	if code := put("", []byte{0xff, 0xfe, 0x00}); code != http.StatusUnsupportedMediaType {
+		t.Errorf("PUT binary non-image: got %d, want %d", code, http.StatusUnsupportedMediaType)
+	}
+	if code := put("", []byte(" \n")); code != http.StatusBadRequest {
+		t.Errorf("PUT whitespace: got %d, want %d", code, http.StatusBadRequest)
+	}
+}
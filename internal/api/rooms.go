@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/fileez/fileez/internal/store"
+)
+
+// RoomsHandler handles room creation and dispatches room requests.
+// Each room gets its own router bound to the room's stores, built on first
+// use and dropped when the room is removed.
+type RoomsHandler struct {
+	rooms *store.RoomManager
+
+	// Builds the router for a room (set by NewRouter)
+	build func(room *store.Room) http.Handler
+
+	mu      sync.Mutex
+	routers map[string]http.Handler
+}
+
+// NewRoomsHandler creates a new rooms handler.
+func NewRoomsHandler(rooms *store.RoomManager) *RoomsHandler {
+	h := &RoomsHandler{
+		rooms:   rooms,
+		routers: make(map[string]http.Handler),
+	}
+	rooms.OnRemove(h.forget)
+	return h
+}
+
+// CreateRoomResponse is the response for creating a room.
+type CreateRoomResponse struct {
+	store.RoomInfo
+	Path string `json:"path"` // API base path of the room
+}
+
+// Create handles POST /api/rooms
+// Returns the room code; anyone with the code can join until the room is sealed.
+func (h *RoomsHandler) Create(w http.ResponseWriter, r *http.Request) {
+	room, err := h.rooms.Create()
+	if err != nil {
+		if err == store.ErrTooManyRooms {
+			http.Error(w, "Too many rooms", http.StatusInsufficientStorage)
+			return
+		}
+		http.Error(w, "Failed to create room", http.StatusInsufficientStorage)
+		return
+	}
+
+	resp := CreateRoomResponse{
+		RoomInfo: room.Info(),
+		Path:     "/api/rooms/" + room.Code,
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode room response: %v", err)
+	}
+}
+
+// Serve handles /api/rooms/{room}/*
+// Routes the request to the room's own handlers.
+func (h *RoomsHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	room, err := h.rooms.Get(chi.URLParam(r, "room"))
+	if err != nil {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	router := h.routerFor(room)
+
+	// Continue routing with the part of the path after the room code
+	rctx := chi.RouteContext(r.Context())
+	rctx.RoutePath = "/" + chi.URLParam(r, "*")
+
+	router.ServeHTTP(w, r)
+}
+
+// Info handles GET /api/rooms/{room}
+func (h *RoomsHandler) Info(w http.ResponseWriter, r *http.Request) {
+	room, err := h.rooms.Get(chi.URLParam(r, "room"))
+	if err != nil {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(room.Info()); err != nil {
+		log.Printf("Failed to encode room response: %v", err)
+	}
+}
+
+// Delete handles DELETE /api/rooms/{room}
+// Shreds all room data and removes the room. Requires the room token when sealed.
+func (h *RoomsHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.rooms.Delete(chi.URLParam(r, "room")); err != nil {
+		if err == store.ErrRoomNotFound {
+			http.Error(w, "Room not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to delete room", http.StatusInternalServerError)
+		return
+	}
+
+	resp := map[string]interface{}{
+		"deleted":  true,
+		"shredded": true,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode delete response: %v", err)
+	}
+}
+
+// routerFor returns the room's router, building it on first use.
+func (h *RoomsHandler) routerFor(room *store.Room) http.Handler {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if router, exists := h.routers[room.Code]; exists {
+		return router
+	}
+
+	// A room removed meanwhile has already been forgotten; caching its router
+	// would keep the destroyed room's stores alive
+	router := h.build(room)
+	if current, err := h.rooms.Get(room.Code); err == nil && current == room {
+		h.routers[room.Code] = router
+	}
+	return router
+}
+
+// forget drops the router of a removed room.
+func (h *RoomsHandler) forget(code string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.routers, code)
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/store"
+)
+
+func TestRoomRouterNotCachedAfterRemoval(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(64 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	rooms := store.NewRoomManager(memory, store.RoomConfig{}, 4, 0)
+	t.Cleanup(rooms.Close)
+
+	h := NewRoomsHandler(rooms)
+	h.build = func(room *store.Room) http.Handler { return http.NotFoundHandler() }
+
+	room, err := rooms.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// A request that resolved the room before it was deleted
+	if err := rooms.Delete(room.Code); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	h.routerFor(room)
+
+	if n := len(h.routers); n != 0 {
+		t.Errorf("%d routers cached after the room was removed, want 0", n)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/fileez/fileez/internal/config"
+	"github.com/fileez/fileez/internal/crypto"
 	"github.com/fileez/fileez/internal/middleware"
 	"github.com/fileez/fileez/internal/secure"
 	"github.com/fileez/fileez/internal/store"
@@ -20,7 +21,21 @@ type Server struct {
 	Session   *store.SessionManager
 	Files     *store.FileStore
 	Clipboard *store.ClipboardStore
+	Channels  *store.ChannelStore
+	Document  *store.DocumentStore
+	Notes     *store.SecretNoteStore
+	Rooms     *store.RoomManager
+	Events    *store.EventBus
 	Memory    *secure.MemoryTracker
+
+	// Failed unlock tracking for Session
+	UnlockGuard *store.UnlockGuard
+
+	// Lock scopes of Session, sealed on their own (nil in rooms)
+	Scopes *store.LockScopes
+
+	// KDF parameters advertised for new seals
+	KDF crypto.KDFParams
 }
 
 // NewRouter creates and configures the HTTP router.
@@ -28,6 +43,7 @@ func NewRouter(s *Server) *chi.Mux {
 	r := chi.NewRouter()
 
 	// Global middleware
+	r.Use(middleware.ClientIPResolver(s.Config.TrustedProxies))
 	r.Use(middleware.Recovery)
 	r.Use(middleware.Logging)
 	r.Use(middleware.SecurityHeaders)
@@ -50,13 +66,8 @@ func NewRouter(s *Server) *chi.Mux {
 	r.Use(middleware.SessionExtractor)
 
 	// Create handlers
-	healthHandler := NewHealthHandler(s.Memory, s.Files, s.Session)
-	lockHandler := NewLockHandler(s.Session, s.Files, s.Clipboard)
-	clipboardHandler := NewClipboardHandler(s.Clipboard, s.Session)
-	filesHandler := NewFilesHandler(s.Files, s.Session, s.Config.MaxFileSize)
-
-	// Session lock middleware - requires valid token when session is locked
-	requireSessionWhenLocked := middleware.RequireSessionWhenLocked(s.Session)
+	healthHandler := NewHealthHandler(s.Memory, s.Files, s.Session, s.UnlockGuard, s.Config.SealedOnly)
+	notesHandler := NewNotesHandler(s.Notes, s.Session, s.Config.SealedOnly)
 
 	// Determine frontend directory
 	frontendDir := s.Config.FrontendDir
@@ -73,48 +84,29 @@ func NewRouter(s *Server) *chi.Mux {
 		r.Get("/health", healthHandler.Health)
 		r.Get("/ping", healthHandler.Ping)
 
-		// Lock/unlock endpoints
-		r.Get("/lock/status", lockHandler.Status)
-		r.Get("/lock/salt", lockHandler.GetSalt) // E2EE: Get salt for client-side key derivation
-		r.Post("/lock", lockHandler.Lock)
-		r.Post("/unlock", lockHandler.Unlock)
-		r.Post("/lock/force-unlock", lockHandler.ForceUnlock)
-
-		// Protected data routes - require session token when locked
-		r.Group(func(r chi.Router) {
-			r.Use(requireSessionWhenLocked)
-
-			// Clipboard endpoints
-			if s.Config.EnableClipboard {
-				r.Get("/clipboard", clipboardHandler.GetText)
-				r.Post("/clipboard", clipboardHandler.SetText)
-				r.Delete("/clipboard", clipboardHandler.DeleteText)
-			}
+		// Secret notes - opened by recipients outside the session, so the
+		// note tokens (sent in the body) are the only credential
+		if s.Config.EnableSecretNotes {
+			r.Post("/notes/open", notesHandler.Open)
+			r.Post("/notes/status", notesHandler.Status)
+			r.Post("/notes/burn", notesHandler.Burn)
+		}
 
-			// Clipboard image endpoints
-			if s.Config.EnableClipboardImage {
-				r.Get("/clipboard-image", clipboardHandler.GetImageInfo)
-				r.Get("/clipboard-image/data", clipboardHandler.GetImageData)
-				r.Post("/clipboard-image", clipboardHandler.SetImage)
-				r.Delete("/clipboard-image", clipboardHandler.DeleteImage)
+		// Rooms - isolated sessions under /api/rooms/{room}/...
+		if s.Rooms != nil {
+			roomsHandler := NewRoomsHandler(s.Rooms)
+			roomsHandler.build = func(room *store.Room) http.Handler {
+				return roomRouter(s, room, roomsHandler, rateLimiter)
 			}
 
-			// File endpoints
-			if s.Config.EnableFileSharing {
-				r.Get("/files", filesHandler.List)
-
-				// Upload with stricter rate limiting
-				r.Group(func(r chi.Router) {
-					r.Use(rateLimiter.Upload())
-					r.Post("/upload", filesHandler.Upload)
-					r.Post("/upload/encrypted", filesHandler.UploadEncrypted) // E2EE: encrypted file upload
-				})
+			r.Post("/rooms", roomsHandler.Create)
+			r.Route("/rooms/{room}", func(r chi.Router) {
+				r.HandleFunc("/*", roomsHandler.Serve)
+			})
+		}
 
-				r.Get("/files/{id}", filesHandler.GetMetadata)
-				r.Get("/files/{id}/download", filesHandler.Download)
-				r.Delete("/files/{id}", filesHandler.Delete)
-			}
-		})
+		// Default session
+		sessionRoutes(r, s, rateLimiter)
 	})
 
 	// Serve root path
@@ -148,6 +140,231 @@ func NewRouter(s *Server) *chi.Mux {
 	return r
 }
 
+// sessionRoutes registers the data routes of one session: lock state,
+// clipboard, channels, document, events and files.
+// They serve the default session under /api and each room under /api/rooms/{room}.
+func sessionRoutes(r chi.Router, s *Server, rateLimiter *middleware.RateLimitMiddleware) {
+	// Seals guarding each part of the session's data: the whole session, or
+	// its lock scopes where the session has them
+	var filesLock, textLock, imageLock store.LockState = s.Session, s.Session, s.Session
+	if s.Scopes != nil {
+		filesLock = s.Scopes.Lock(store.ScopeFiles)
+		textLock = s.Scopes.Lock(store.ScopeClipboardText)
+		imageLock = s.Scopes.Lock(store.ScopeClipboardImage)
+	}
+
+	lockConfig := LockHandlerConfig{
+		Session:           s.Session,
+		Files:             s.Files,
+		Clipboard:         s.Clipboard,
+		Channels:          s.Channels,
+		Document:          s.Document,
+		Notes:             s.Notes,
+		Guard:             s.UnlockGuard,
+		RequirePAKE:       s.Config.RequirePAKE,
+		KDF:               s.KDF,
+		AdminToken:        s.Config.AdminToken,
+		DeviceForceUnlock: s.Config.DeviceForceUnlock,
+		SealedOnly:        s.Config.SealedOnly,
+		Scopes:            s.Scopes,
+	}
+	lockHandler := NewLockHandler(lockConfig)
+	clipboardHandler := NewClipboardHandler(s.Clipboard, s.Channels, textLock, imageLock, s.Config.EnableClipboardImage, s.Config.ClipboardMinTTL, s.Config.ClipboardMaxTTL, s.Config.SealedOnly, s.Memory)
+	channelsHandler := NewChannelsHandler(s.Channels)
+	documentHandler := NewDocumentHandler(s.Document, textLock, s.Config.SealedOnly)
+	notesHandler := NewNotesHandler(s.Notes, s.Session, s.Config.SealedOnly)
+	filesHandler := NewFilesHandler(s.Files, filesLock, s.Config.MaxFileSize, s.Config.SealedOnly)
+	eventsHandler := NewEventsHandler(s.Events, s.Session, filesLock, textLock, imageLock)
+	liveHandler := NewLiveHandler(s.Clipboard, s.Channels, textLock, s.Events, s.Config.AllowedOrigins, s.Config.SealedOnly)
+	devicesHandler := NewDevicesHandler(s.Session)
+	sealedHandler := NewSealedHandler(s.Files, s.Clipboard, s.Document, filesLock, textLock, imageLock)
+
+	// Lock/unlock endpoints
+	lockRoutes(r, lockHandler)
+
+	// Lock scopes - sealed on their own under /api/scopes/{scope}/...
+	if s.Scopes != nil {
+		for _, scope := range store.AllLockScopes {
+			switch {
+			case scope == store.ScopeFiles && !s.Config.EnableFileSharing,
+				scope == store.ScopeClipboardText && !s.Config.EnableClipboard,
+				scope == store.ScopeClipboardImage && !s.Config.EnableClipboardImage:
+				continue
+			}
+
+			scopeHandler := NewScopedLockHandler(lockConfig, scope)
+			scopeDevices := NewDevicesHandler(s.Scopes.Session(scope))
+			r.Route("/scopes/"+string(scope), func(r chi.Router) {
+				lockRoutes(r, scopeHandler)
+
+				// Devices holding tokens for the sealed scope
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RequireSessionWhenLocked(s.Scopes.Session(scope)))
+					r.Get("/devices", scopeDevices.List)
+					r.Delete("/devices/{id}", scopeDevices.Revoke)
+				})
+			})
+		}
+	}
+
+	// Protected routes - require session token when locked. Handlers check
+	// lock scopes per item where a route serves several of them.
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.RequireSessionWhenLocked(s.Session))
+
+		// Change notifications (Server-Sent Events)
+		r.Get("/events", eventsHandler.Stream)
+
+		// Devices holding tokens for the sealed session
+		r.Get("/devices", devicesHandler.List)
+		r.Delete("/devices/{id}", devicesHandler.Revoke)
+
+		if s.Config.EnableClipboard {
+			// Raw clipboard content for shell use (curl -T file, curl > out)
+			r.Get("/clipboard/raw", clipboardHandler.GetRaw)
+			r.Put("/clipboard/raw", clipboardHandler.SetRaw)
+			r.Post("/clipboard/raw", clipboardHandler.SetRaw)
+			r.Get("/clipboard/{channel}/raw", clipboardHandler.GetRaw)
+			r.Put("/clipboard/{channel}/raw", clipboardHandler.SetRaw)
+			r.Post("/clipboard/{channel}/raw", clipboardHandler.SetRaw)
+
+			// Clipboard history (text and image entries)
+			r.Get("/clipboard/history", clipboardHandler.ListHistory)
+			r.Get("/clipboard/history/{id}", clipboardHandler.GetHistoryEntry)
+			r.Delete("/clipboard/history/{id}", clipboardHandler.DeleteHistoryEntry)
+			r.Post("/clipboard/history/{id}/pin", clipboardHandler.PinHistoryEntry)
+			r.Delete("/clipboard/history/{id}/pin", clipboardHandler.UnpinHistoryEntry)
+		}
+
+		// Creating secret notes (default session only)
+		if s.Config.EnableSecretNotes && s.Notes != nil {
+			r.Post("/notes", notesHandler.Create)
+		}
+	})
+
+	// Clipboard text, channels and document - require a token when the
+	// session or the clipboard text scope is locked
+	if s.Config.EnableClipboard {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireSessionWhenLocked(textLock))
+
+			// Ciphertext of the items in the unlock manifest, one per request (Range supported)
+			r.Get("/sealed/clipboard/text", sealedHandler.ClipboardText)
+			r.Get("/sealed/document", sealedHandler.Document)
+
+			// Clipboard endpoints
+			r.Get("/clipboard", clipboardHandler.GetText)
+			r.Post("/clipboard", clipboardHandler.SetText)
+			r.Delete("/clipboard", clipboardHandler.DeleteText)
+
+			// Live clipboard sync (WebSocket, optional ?channel=)
+			r.Get("/clipboard/live", liveHandler.Clipboard)
+
+			// Named clipboard channels
+			r.Get("/channels", channelsHandler.List)
+			r.Post("/channels", channelsHandler.Create)
+			r.Delete("/channels/{channel}", channelsHandler.Delete)
+
+			// Shared Wormhole document (collaborative editing)
+			r.Get("/doc", documentHandler.Get)
+			r.Delete("/doc", documentHandler.Delete)
+			r.Get("/doc/ops", documentHandler.Ops)
+			r.Post("/doc/ops", documentHandler.SubmitOp)
+			r.Put("/doc/snapshot", documentHandler.SetSnapshot)
+
+			r.Get("/clipboard/{channel}", clipboardHandler.GetText)
+			r.Post("/clipboard/{channel}", clipboardHandler.SetText)
+			r.Delete("/clipboard/{channel}", clipboardHandler.DeleteText)
+
+			// Optional per-channel image slot
+			if s.Config.EnableClipboardImage {
+				r.Get("/clipboard/{channel}/image", clipboardHandler.GetImageInfo)
+				r.Get("/clipboard/{channel}/image/data", clipboardHandler.GetImageData)
+				r.Post("/clipboard/{channel}/image", clipboardHandler.SetImage)
+				r.Delete("/clipboard/{channel}/image", clipboardHandler.DeleteImage)
+			}
+		})
+	}
+
+	// Clipboard image endpoints
+	if s.Config.EnableClipboardImage {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireSessionWhenLocked(imageLock))
+
+			r.Get("/sealed/clipboard/image", sealedHandler.ClipboardImage)
+
+			r.Get("/clipboard-image", clipboardHandler.GetImageInfo)
+			r.Get("/clipboard-image/data", clipboardHandler.GetImageData)
+			r.Post("/clipboard-image", clipboardHandler.SetImage)
+			r.Delete("/clipboard-image", clipboardHandler.DeleteImage)
+		})
+	}
+
+	// File endpoints
+	if s.Config.EnableFileSharing {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireSessionWhenLocked(filesLock))
+
+			r.Get("/sealed/files/{id}", sealedHandler.File)
+
+			r.Get("/files", filesHandler.List)
+
+			// Upload with stricter rate limiting
+			r.Group(func(r chi.Router) {
+				r.Use(rateLimiter.Upload())
+				r.Post("/upload", filesHandler.Upload)
+				r.Post("/upload/encrypted", filesHandler.UploadEncrypted) // E2EE: encrypted file upload
+			})
+
+			r.Get("/files/{id}", filesHandler.GetMetadata)
+			r.Get("/files/{id}/download", filesHandler.Download)
+			r.Delete("/files/{id}", filesHandler.Delete)
+		})
+	}
+}
+
+// lockRoutes registers the lock/unlock endpoints of h.
+func lockRoutes(r chi.Router, h *LockHandler) {
+	r.Get("/lock/status", h.Status)
+	r.Get("/lock/salt", h.GetSalt) // E2EE: Get salt for client-side key derivation
+	r.Post("/lock", h.Lock)
+	r.Post("/unlock", h.Unlock)
+	r.Post("/unlock/srp/start", h.SRPStart) // SRP-6a unlock for verifier seals
+	r.Post("/unlock/srp/verify", h.SRPVerify)
+	r.Post("/lock/rekey", h.Rekey)      // Password change: swaps all sealed data atomically
+	r.Post("/lock/slots", h.AddKeySlot) // Key slots: listed by /lock/salt
+	r.Delete("/lock/slots/{id}", h.RemoveKeySlot)
+	r.Post("/lock/force-unlock", h.ForceUnlock)
+}
+
+// roomRouter builds the routes of one room, bound to the room's own stores.
+// Requests reach it through RoomsHandler.Serve with the /api/rooms/{room}
+// prefix already consumed.
+func roomRouter(s *Server, room *store.Room, rooms *RoomsHandler, rateLimiter *middleware.RateLimitMiddleware) http.Handler {
+	roomServer := &Server{
+		Config:      s.Config,
+		Session:     room.Session,
+		Files:       room.Files,
+		Clipboard:   room.Clipboard,
+		Channels:    room.Channels,
+		Document:    room.Document,
+		Events:      room.Events,
+		Memory:      room.Memory,
+		UnlockGuard: room.Guard,
+		KDF:         s.KDF,
+	}
+
+	r := chi.NewRouter()
+
+	// Room metadata and removal
+	r.Get("/", rooms.Info)
+	r.With(middleware.RequireSessionWhenLocked(room.Session)).Delete("/", rooms.Delete)
+
+	sessionRoutes(r, roomServer, rateLimiter)
+
+	return r
+}
+
 // serveIndexHTML serves the index.html file for SPA routing.
 func serveIndexHTML(w http.ResponseWriter, r *http.Request, dir string) {
 	indexPath := filepath.Join(dir, "index.html")
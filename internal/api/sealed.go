@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/store"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// SealedHandler serves the ciphertext of sealed items one at a time.
+// E2EE: Clients verify the password with /api/unlock, which returns a
+// manifest, then fetch each item here and decrypt it locally.
+type SealedHandler struct {
+	files     *store.FileStore
+	clipboard *store.ClipboardStore
+	document  *store.DocumentStore
+
+	// Seals guarding files, clipboard text (and the document) and the clipboard image
+	filesLock store.LockState
+	textLock  store.LockState
+	imageLock store.LockState
+}
+
+// NewSealedHandler creates a new sealed item handler.
+func NewSealedHandler(files *store.FileStore, clipboard *store.ClipboardStore, document *store.DocumentStore, filesLock, textLock, imageLock store.LockState) *SealedHandler {
+	return &SealedHandler{
+		files:     files,
+		clipboard: clipboard,
+		document:  document,
+		filesLock: filesLock,
+		textLock:  textLock,
+		imageLock: imageLock,
+	}
+}
+
+// SealedManifest lists the sealed items returned by /api/unlock.
+// Each item's ciphertext is fetched from /api/sealed.
+type SealedManifest struct {
+	Files          []store.SealedFileInfo `json:"files"`
+	ClipboardText  *SealedItem            `json:"clipboardText,omitempty"`
+	ClipboardImage *SealedItem            `json:"clipboardImage,omitempty"`
+	Document       *SealedItem            `json:"document,omitempty"`
+}
+
+// SealedItem describes one sealed clipboard entry or document snapshot.
+type SealedItem struct {
+	Size     int    `json:"size"`    // Ciphertext length
+	Version  uint64 `json:"version"` // Sent as ETag when fetching the item
+	MimeType string `json:"mimetype,omitempty"`
+	Revision uint64 `json:"revision,omitempty"` // Revision of the document snapshot
+}
+
+// sealedManifest lists the sealed items without copying their ciphertext.
+// Of the clipboard, only the entry types in scope are listed.
+func sealedManifest(files *store.FileStore, clipboard *store.ClipboardStore, document *store.DocumentStore, scope store.LockScope) SealedManifest {
+	manifest := SealedManifest{Files: []store.SealedFileInfo{}}
+
+	if files != nil {
+		manifest.Files = files.SealedFiles()
+	}
+
+	if clipboard != nil {
+		if info := clipboard.TextInfo(); scope.Covers(store.ClipboardTypeText) && info.HasContent && info.Encrypted {
+			manifest.ClipboardText = &SealedItem{Size: info.Size, Version: info.Version}
+		}
+		if info := clipboard.ImageInfo(); scope.Covers(store.ClipboardTypeImage) && info.HasContent && info.Encrypted {
+			manifest.ClipboardImage = &SealedItem{Size: info.Size, Version: info.Version, MimeType: info.MimeType}
+		}
+	}
+
+	// Newer document operations come from /api/doc/ops
+	if document != nil {
+		if size, revision, err := document.EncryptedSnapshotInfo(); err == nil && size > 0 {
+			manifest.Document = &SealedItem{Size: size, Version: revision, Revision: revision}
+		}
+	}
+
+	return manifest
+}
+
+// File handles GET /api/sealed/files/{id}
+// Streams the raw ciphertext of one sealed file.
+func (h *SealedHandler) File(w http.ResponseWriter, r *http.Request) {
+	if !sealed(w, h.filesLock) {
+		return
+	}
+
+	id, err := validate.FileID(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid file ID", http.StatusBadRequest)
+		return
+	}
+
+	encrypted, version, err := h.files.GetEncryptedFile(id)
+	if err != nil {
+		switch err {
+		case store.ErrFileNotFound:
+			http.Error(w, "File not found", http.StatusNotFound)
+		case store.ErrFileExpired:
+			http.Error(w, "File expired", http.StatusGone)
+		default:
+			http.Error(w, "Failed to get file", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	serveCiphertext(w, r, encrypted, version)
+}
+
+// ClipboardText handles GET /api/sealed/clipboard/text
+// Streams the raw ciphertext of the current clipboard text.
+func (h *SealedHandler) ClipboardText(w http.ResponseWriter, r *http.Request) {
+	h.clipboardItem(w, r, store.ClipboardTypeText)
+}
+
+// ClipboardImage handles GET /api/sealed/clipboard/image
+// Streams the raw ciphertext of the current clipboard image.
+func (h *SealedHandler) ClipboardImage(w http.ResponseWriter, r *http.Request) {
+	h.clipboardItem(w, r, store.ClipboardTypeImage)
+}
+
+func (h *SealedHandler) clipboardItem(w http.ResponseWriter, r *http.Request, contentType store.ClipboardType) {
+	lock := h.textLock
+	if contentType == store.ClipboardTypeImage {
+		lock = h.imageLock
+	}
+	if !sealed(w, lock) {
+		return
+	}
+
+	encrypted, _, version := h.clipboard.GetEncryptedCurrent(contentType)
+	if encrypted == nil {
+		http.Error(w, "No sealed clipboard content", http.StatusNotFound)
+		return
+	}
+
+	serveCiphertext(w, r, encrypted, version)
+}
+
+// Document handles GET /api/sealed/document
+// Streams the raw ciphertext of the document snapshot; the ETag is its revision.
+func (h *SealedHandler) Document(w http.ResponseWriter, r *http.Request) {
+	if !sealed(w, h.textLock) {
+		return
+	}
+
+	snapshot, snapshotRevision, _, err := h.document.GetEncrypted()
+	if err != nil || snapshot == nil {
+		http.Error(w, "No sealed document snapshot", http.StatusNotFound)
+		return
+	}
+
+	serveCiphertext(w, r, snapshot, snapshotRevision)
+}
+
+// sealed rejects the request unless lock is sealed.
+func sealed(w http.ResponseWriter, lock store.LockState) bool {
+	if !lock.IsLocked() {
+		http.Error(w, "Session not locked", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// serveCiphertext writes one item's ciphertext as raw bytes, honouring
+// Range, If-Range and If-None-Match, then shreds the copy.
+func serveCiphertext(w http.ResponseWriter, r *http.Request, encrypted []byte, version uint64) {
+	defer secure.Shred(encrypted)
+
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(encrypted))
+}
+
+// Server modes reported by /api/health.
+const (
+	// ModeStandard accepts plaintext while unsealed and ciphertext while sealed.
+	ModeStandard = "standard"
+	// ModeSealedOnly accepts only client-encrypted envelopes (SEALED_ONLY).
+	ModeSealedOnly = "sealed-only"
+)
+
+// rejectPlaintext writes the response for a plaintext write in sealed-only mode.
+func rejectPlaintext(w http.ResponseWriter) {
+	http.Error(w, "Plaintext writes are disabled: this server is sealed-only", http.StatusForbidden)
+}
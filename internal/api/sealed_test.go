@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/store"
+)
+
+// jsonRequest returns a POST request with v as its JSON body.
+func jsonRequest(t *testing.T, target string, v interface{}) *http.Request {
+	t.Helper()
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encode request: %v", err)
+	}
+	return httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+}
+
+func TestSealedOnlyRejectsPlaintextWrites(t *testing.T) {
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	clipboard := store.NewClipboardStore(session, nil, 0, 0, 0)
+	t.Cleanup(clipboard.Close)
+	files := store.NewFileStore(session, nil, 1<<20, time.Hour)
+	t.Cleanup(files.Close)
+	document := store.NewDocumentStore(nil, 0, 0)
+	t.Cleanup(document.Close)
+	notes := store.NewSecretNoteStore(nil, 0, 0)
+	t.Cleanup(notes.Close)
+
+	clipboardHandler := NewClipboardHandler(clipboard, nil, session, session, true, time.Second, time.Hour, true, nil)
+	filesHandler := NewFilesHandler(files, session, 1<<20, true)
+	documentHandler := NewDocumentHandler(document, session, true)
+	notesHandler := NewNotesHandler(notes, session, true)
+
+	var op store.TextOperation
+	if err := json.Unmarshal([]byte(`["hello"]`), &op); err != nil {
+		t.Fatalf("decode operation: %v", err)
+	}
+	raw := httptest.NewRequest(http.MethodPut, "/api/clipboard/raw", bytes.NewReader([]byte("hello")))
+	upload := httptest.NewRequest(http.MethodPost, "/api/upload", bytes.NewReader([]byte("hello")))
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{"clipboard text", clipboardHandler.SetText, jsonRequest(t, "/api/clipboard", ClipboardTextRequest{Text: "hello"})},
+		{"clipboard image", clipboardHandler.SetImage, jsonRequest(t, "/api/clipboard-image", ClipboardImageRequest{Image: base64.StdEncoding.EncodeToString([]byte("png")), MimeType: "image/png"})},
+		{"raw clipboard", clipboardHandler.SetRaw, raw},
+		{"upload", filesHandler.Upload, upload},
+		{"document operation", documentHandler.SubmitOp, jsonRequest(t, "/api/doc/ops", DocumentOpRequest{Op: &op})},
+		{"secret note", notesHandler.Create, jsonRequest(t, "/api/notes", CreateNoteRequest{Text: "hello"})},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.handler(rec, tt.req)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	if clipboard.HasText() || clipboard.HasImage() || files.Count() != 0 || document.HasData() || notes.HasData() {
+		t.Error("plaintext was stored on a sealed-only server")
+	}
+}
+
+func TestSealedOnlyAcceptsEnvelopes(t *testing.T) {
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	clipboard := store.NewClipboardStore(session, nil, 0, 0, 0)
+	t.Cleanup(clipboard.Close)
+
+	token, _, err := session.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	key, err := secure.NewSecureKey(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewSecureKey: %v", err)
+	}
+	t.Cleanup(key.Destroy)
+	envelope, err := crypto.SealEnvelope(key, crypto.EnvelopeHeader{Type: crypto.EnvelopeClipboardText}, []byte("hello"))
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+
+	h := NewClipboardHandler(clipboard, nil, session, session, true, time.Second, time.Hour, true, nil)
+	send := func(encrypted []byte) int {
+		req := jsonRequest(t, "/api/clipboard", ClipboardTextRequest{EncryptedB64: base64.StdEncoding.EncodeToString(encrypted)})
+		req = req.WithContext(context.WithValue(req.Context(), middleware.SessionTokenKey, token))
+		rec := httptest.NewRecorder()
+		h.SetText(rec, req)
+		return rec.Code
+	}
+
+	if code := send([]byte("not an envelope")); code != http.StatusBadRequest {
+		t.Errorf("ciphertext without an envelope: got %d, want %d", code, http.StatusBadRequest)
+	}
+	if code := send(envelope); code != http.StatusCreated {
+		t.Errorf("clipboard text envelope: got %d, want %d", code, http.StatusCreated)
+	}
+	if !clipboard.HasText() {
+		t.Error("envelope was not stored")
+	}
+}
+
+// sealedTextHandler returns a sealed-item handler for a sealed session whose
+// clipboard text is the returned envelope.
+func sealedTextHandler(t *testing.T) (*SealedHandler, *store.ClipboardStore, []byte) {
+	t.Helper()
+
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	clipboard := store.NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(clipboard.Close)
+
+	if _, _, err := session.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	key, err := secure.NewSecureKey(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewSecureKey: %v", err)
+	}
+	defer key.Destroy()
+	envelope, err := crypto.SealEnvelope(key, crypto.EnvelopeHeader{Type: crypto.EnvelopeClipboardText}, bytes.Repeat([]byte("sealed text "), 100))
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	if _, err := clipboard.SetEncryptedText(envelope, store.AnyVersion, store.EntryOptions{}); err != nil {
+		t.Fatalf("SetEncryptedText: %v", err)
+	}
+
+	return NewSealedHandler(nil, clipboard, nil, session, session, session), clipboard, envelope
+}
+
+func TestSealedManifestListsItemsWithoutCiphertext(t *testing.T) {
+	_, clipboard, envelope := sealedTextHandler(t)
+
+	manifest := sealedManifest(nil, clipboard, nil, store.ScopeSession)
+	if manifest.Files == nil || len(manifest.Files) != 0 {
+		t.Errorf("files = %v, want an empty list", manifest.Files)
+	}
+	if manifest.ClipboardImage != nil || manifest.Document != nil {
+		t.Errorf("manifest lists items that do not exist: %+v", manifest)
+	}
+
+	item := manifest.ClipboardText
+	if item == nil {
+		t.Fatal("manifest does not list the sealed clipboard text")
+	}
+	if item.Size != len(envelope) || item.Version != clipboard.TextVersion() {
+		t.Errorf("clipboard text = %+v, want size %d and version %d", item, len(envelope), clipboard.TextVersion())
+	}
+}
+
+func TestSealedItemSupportsRangeAndIfNoneMatch(t *testing.T) {
+	h, clipboard, envelope := sealedTextHandler(t)
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/sealed/clipboard/text", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		h.ClipboardText(rec, req)
+		return rec
+	}
+
+	rec := get("", "")
+	if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), envelope) {
+		t.Fatalf("full fetch: got %d with %d bytes, want %d with the envelope", rec.Code, rec.Body.Len(), http.StatusOK)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag != formatETag(clipboard.TextVersion()) {
+		t.Errorf("ETag = %q, want the clipboard text version", etag)
+	}
+
+	// A resumed download gets the rest of the ciphertext
+	rec = get("Range", "bytes=100-")
+	if rec.Code != http.StatusPartialContent || !bytes.Equal(rec.Body.Bytes(), envelope[100:]) {
+		t.Errorf("range fetch: got %d with %d bytes, want %d with %d bytes", rec.Code, rec.Body.Len(), http.StatusPartialContent, len(envelope)-100)
+	}
+
+	rec = get("If-None-Match", etag)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("fetch with a matching ETag: got %d with %d bytes, want %d", rec.Code, rec.Body.Len(), http.StatusNotModified)
+	}
+	if rec = get("If-None-Match", `"0"`); rec.Code != http.StatusOK {
+		t.Errorf("fetch with a stale ETag: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestSealedItemsRequireSealedSession(t *testing.T) {
+	session := store.NewSessionManager()
+	t.Cleanup(session.Destroy)
+	clipboard := store.NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(clipboard.Close)
+	h := NewSealedHandler(nil, clipboard, nil, session, session, session)
+
+	rec := httptest.NewRecorder()
+	h.ClipboardText(rec, httptest.NewRequest(http.MethodGet, "/api/sealed/clipboard/text", nil))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("unsealed session: got %d, want %d", rec.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/middleware"
+	"github.com/fileez/fileez/internal/store"
+)
+
+// SRPStartRequest is the request body for starting an SRP unlock.
+type SRPStartRequest struct {
+	ClientPublicB64 string `json:"A_b64"` // Client public value A
+}
+
+// SRPStartResponse is the response for starting an SRP unlock.
+type SRPStartResponse struct {
+	HandshakeID     string `json:"handshakeId"`
+	SaltB64         string `json:"salt_b64"`
+	ServerPublicB64 string `json:"B_b64"` // Server public value B
+}
+
+// SRPVerifyRequest is the request body for finishing an SRP unlock.
+type SRPVerifyRequest struct {
+	HandshakeID    string `json:"handshakeId"`
+	ClientProofB64 string `json:"M1_b64"`               // Client proof M1
+	DeviceName     string `json:"deviceName,omitempty"` // Shown in the device list
+}
+
+// SRPStart handles POST /api/unlock/srp/start
+// First step of an SRP unlock: the client sends A and receives the salt and B.
+func (h *LockHandler) SRPStart(w http.ResponseWriter, r *http.Request) {
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
+	var req SRPStartRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	clientPublic, err := base64.StdEncoding.DecodeString(req.ClientPublicB64)
+	if err != nil || len(clientPublic) == 0 {
+		http.Error(w, "Invalid A", http.StatusBadRequest)
+		return
+	}
+
+	id, salt, serverPublic, err := h.session.BeginSRP(clientPublic, ip)
+	if err != nil {
+		switch err {
+		case store.ErrSessionNotLocked:
+			http.Error(w, "Session not locked", http.StatusConflict)
+		case store.ErrPAKENotEnabled:
+			http.Error(w, "Session was not sealed for SRP unlock", http.StatusConflict)
+		case store.ErrTooManyHandshakes:
+			http.Error(w, "Too many pending unlocks, try again later", http.StatusTooManyRequests)
+		case store.ErrVerifierChanged:
+			http.Error(w, "Session was re-keyed, start again", http.StatusConflict)
+		case crypto.ErrSRPInvalidPublic:
+			http.Error(w, "Invalid A", http.StatusBadRequest)
+		default:
+			http.Error(w, "Failed to start unlock", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	resp := SRPStartResponse{
+		HandshakeID:     id,
+		SaltB64:         base64.StdEncoding.EncodeToString(salt),
+		ServerPublicB64: base64.StdEncoding.EncodeToString(serverPublic),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to encode SRP start response: %v", err)
+	}
+}
+
+// SRPVerify handles POST /api/unlock/srp/verify
+// Second step of an SRP unlock: checks the client proof M1 and, if it matches,
+// responds like /api/unlock plus the server proof M2 for the client to check.
+func (h *LockHandler) SRPVerify(w http.ResponseWriter, r *http.Request) {
+	ip := middleware.ClientIP(r)
+	if h.throttled(w, ip) {
+		return
+	}
+	defer h.release(ip)
+
+	var req SRPVerifyRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	clientProof, err := base64.StdEncoding.DecodeString(req.ClientProofB64)
+	if err != nil || len(clientProof) == 0 {
+		http.Error(w, "Invalid M1", http.StatusBadRequest)
+		return
+	}
+
+	serverProof, err := h.session.FinishSRP(req.HandshakeID, clientProof)
+	if err != nil {
+		switch err {
+		case store.ErrSessionNotLocked:
+			http.Error(w, "Session not locked", http.StatusConflict)
+		case store.ErrHandshakeNotFound:
+			http.Error(w, "Handshake not found or expired", http.StatusNotFound)
+		case store.ErrInvalidPassword:
+			h.unlockFailed(w, ip)
+		default:
+			http.Error(w, "Failed to verify password", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if h.guard != nil {
+		h.guard.Success(ip)
+	}
+
+	h.completeUnlock(w, r, req.DeviceName, serverProof, nil)
+}
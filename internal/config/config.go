@@ -4,6 +4,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,19 +18,68 @@ type Config struct {
 	ShutdownTimeout time.Duration
 
 	// Security settings
-	MaxFileSize        int64         // Maximum file size in bytes
-	MaxMemory          int64         // Maximum secure memory in bytes
-	FileExpiry         time.Duration // Time until files auto-expire
-	ClipboardExpiry    time.Duration // Time until clipboard auto-expires
-	RateLimit          int           // Requests per minute (general)
-	UploadRateLimit    int           // Requests per minute (uploads)
-	EnableCORS         bool          // Enable CORS headers
-	AllowedOrigins     []string      // CORS allowed origins
+	MaxFileSize     int64         // Maximum file size in bytes
+	MaxMemory       int64         // Maximum secure memory in bytes
+	FileExpiry      time.Duration // Time until files auto-expire
+	ClipboardExpiry time.Duration // Time until clipboard auto-expires
+	ClipboardMinTTL time.Duration // Shortest per-entry clipboard TTL a client may request
+	ClipboardMaxTTL time.Duration // Longest per-entry clipboard TTL a client may request
+	RateLimit       int           // Requests per minute (general)
+	UploadRateLimit int           // Requests per minute (uploads)
+	EnableCORS      bool          // Enable CORS headers
+	AllowedOrigins  []string      // CORS allowed origins
+	TrustedProxies  []string      // Proxy IPs or CIDRs whose X-Forwarded-For and X-Real-IP are honoured
+
+	// Clipboard history
+	ClipboardHistory         int   // Maximum number of clipboard history entries
+	ClipboardHistoryMaxBytes int64 // Maximum total size of clipboard history in bytes
+
+	// Clipboard channels
+	MaxChannels      int   // Maximum number of named clipboard channels
+	ChannelMaxMemory int64 // Maximum secure memory shared by all channels in bytes
+
+	// Shared document
+	DocumentHistory int // Operations kept for clients catching up on the shared document
+
+	// Secret notes
+	MaxSecretNotes   int           // Maximum number of unread secret notes
+	SecretNoteExpiry time.Duration // Default and longest lifetime of a secret note
+
+	// Rooms
+	MaxRooms      int           // Maximum number of rooms
+	RoomMaxMemory int64         // Secure memory budget per room in bytes (0 = MaxMemory / (2 * MaxRooms))
+	RoomExpiry    time.Duration // Idle time after which a room is shredded
+
+	// Session tokens (0 disables a limit)
+	TokenLifetime    time.Duration // Absolute lifetime of a device token
+	TokenIdleTimeout time.Duration // Unused time after which a device token expires
+	AutoLockAfter    time.Duration // Session inactivity after which all device tokens are invalidated
+
+	// Unlock brute-force protection
+	UnlockMaxFailures int           // Failed unlocks from one IP before it is locked out
+	UnlockGlobalLimit int           // Failed unlocks from all IPs together before every IP is delayed
+	UnlockLockout     time.Duration // How long a locked-out IP must wait
+	UnlockBackoffBase time.Duration // Delay after the first failed unlock, doubled per failure
+	UnlockBackoffMax  time.Duration // Longest backoff delay
+	UnlockWipeAfter   int           // Shred the session after this many failed unlocks in total (0 = never)
+	RequirePAKE       bool          // Only accept seals with an SRP verifier, never a keyHash
+	SealedOnly        bool          // Reject plaintext writes; only client-encrypted envelopes are stored
+	AdminToken        string        // Operator token that authorizes force-unlock ("" = recovery code only)
+	DeviceForceUnlock bool          // Also let any unlocked device's token authorize force-unlock
+
+	// Key derivation advertised to clients for new seals
+	KDFAlgorithm      string // "pbkdf2-sha256" or "argon2id"
+	KDFIterations     int    // PBKDF2 iterations
+	Argon2Time        uint32 // Argon2id passes
+	Argon2Memory      uint32 // Argon2id memory in KiB
+	Argon2Parallelism uint8  // Argon2id lanes
 
 	// Feature flags
 	EnableClipboard      bool
 	EnableClipboardImage bool
 	EnableFileSharing    bool
+	EnableSecretNotes    bool
+	EnableRooms          bool
 
 	// Frontend
 	FrontendDir string // Directory containing built frontend files
@@ -46,19 +96,66 @@ func DefaultConfig() *Config {
 		ShutdownTimeout: 30 * time.Second,
 
 		// Security
-		MaxFileSize:      100 * 1024 * 1024, // 100MB
-		MaxMemory:        512 * 1024 * 1024, // 512MB
-		FileExpiry:       24 * time.Hour,
-		ClipboardExpiry:  1 * time.Hour,
-		RateLimit:        600,  // 600/min = 10/sec
-		UploadRateLimit:  20,   // 20/min
-		EnableCORS:       true,
-		AllowedOrigins:   []string{"*"}, // Restricted in production
+		MaxFileSize:     100 * 1024 * 1024, // 100MB
+		MaxMemory:       512 * 1024 * 1024, // 512MB
+		FileExpiry:      24 * time.Hour,
+		ClipboardExpiry: 1 * time.Hour,
+		ClipboardMinTTL: 10 * time.Second,
+		ClipboardMaxTTL: 24 * time.Hour,
+		RateLimit:       600, // 600/min = 10/sec
+		UploadRateLimit: 20,  // 20/min
+		EnableCORS:      true,
+		AllowedOrigins:  []string{"*"}, // Restricted in production
+
+		// Clipboard history
+		ClipboardHistory:         20,
+		ClipboardHistoryMaxBytes: 16 * 1024 * 1024, // 16MB
+
+		// Clipboard channels
+		MaxChannels:      16,
+		ChannelMaxMemory: 64 * 1024 * 1024, // 64MB
+
+		// Shared document
+		DocumentHistory: 256,
+
+		// Secret notes
+		MaxSecretNotes:   100,
+		SecretNoteExpiry: 24 * time.Hour,
+
+		// Rooms
+		MaxRooms:      32,
+		RoomMaxMemory: 0, // MaxMemory / (2 * MaxRooms)
+		RoomExpiry:    24 * time.Hour,
+
+		// Session tokens
+		TokenLifetime:    24 * time.Hour,
+		TokenIdleTimeout: 2 * time.Hour,
+		AutoLockAfter:    0, // Disabled
+
+		// Unlock brute-force protection
+		UnlockMaxFailures: 5,
+		UnlockGlobalLimit: 50,
+		UnlockLockout:     15 * time.Minute,
+		UnlockBackoffBase: 1 * time.Second,
+		UnlockBackoffMax:  1 * time.Minute,
+		UnlockWipeAfter:   0, // Disabled
+		RequirePAKE:       false,
+		SealedOnly:        false,
+		DeviceForceUnlock: false,
+
+		// Key derivation
+		KDFAlgorithm:      "pbkdf2-sha256",
+		KDFIterations:     600000,
+		Argon2Time:        3,
+		Argon2Memory:      64 * 1024, // 64MB
+		Argon2Parallelism: 4,
 
 		// Features
 		EnableClipboard:      true,
 		EnableClipboardImage: true,
 		EnableFileSharing:    true,
+		EnableSecretNotes:    true,
+		EnableRooms:          true,
 
 		// Frontend
 		FrontendDir: "./frontend/dist",
@@ -124,6 +221,184 @@ func LoadFromEnv() *Config {
 		}
 	}
 
+	if v := os.Getenv("CLIPBOARD_MIN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ClipboardMinTTL = d
+		}
+	}
+
+	if v := os.Getenv("CLIPBOARD_MAX_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.ClipboardMaxTTL = d
+		}
+	}
+
+	// Keep the TTL bounds consistent
+	if cfg.ClipboardMaxTTL < cfg.ClipboardMinTTL {
+		cfg.ClipboardMaxTTL = cfg.ClipboardMinTTL
+	}
+
+	if v := os.Getenv("CLIPBOARD_HISTORY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.ClipboardHistory = n
+		}
+	}
+
+	if v := os.Getenv("CLIPBOARD_HISTORY_MAX_BYTES"); v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil && size > 0 {
+			cfg.ClipboardHistoryMaxBytes = size
+		}
+	}
+
+	if v := os.Getenv("MAX_CHANNELS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxChannels = n
+		}
+	}
+
+	if v := os.Getenv("CHANNEL_MAX_MEMORY"); v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil && size > 0 {
+			cfg.ChannelMaxMemory = size
+		}
+	}
+
+	if v := os.Getenv("DOCUMENT_HISTORY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.DocumentHistory = n
+		}
+	}
+
+	if v := os.Getenv("MAX_SECRET_NOTES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxSecretNotes = n
+		}
+	}
+
+	if v := os.Getenv("SECRET_NOTE_EXPIRY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= time.Minute {
+			cfg.SecretNoteExpiry = d
+		}
+	}
+
+	if v := os.Getenv("MAX_ROOMS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.MaxRooms = n
+		}
+	}
+
+	if v := os.Getenv("ROOM_MAX_MEMORY"); v != "" {
+		if size, err := strconv.ParseInt(v, 10, 64); err == nil && size > 0 {
+			cfg.RoomMaxMemory = size
+		}
+	}
+
+	if v := os.Getenv("ROOM_EXPIRY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.RoomExpiry = d
+		}
+	}
+
+	// Session tokens ("0" disables the limit)
+	if v := os.Getenv("TOKEN_LIFETIME"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.TokenLifetime = d
+		}
+	}
+
+	if v := os.Getenv("TOKEN_IDLE_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.TokenIdleTimeout = d
+		}
+	}
+
+	if v := os.Getenv("AUTO_LOCK_AFTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			cfg.AutoLockAfter = d
+		}
+	}
+
+	// Unlock brute-force protection
+	if v := os.Getenv("UNLOCK_MAX_FAILURES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.UnlockMaxFailures = n
+		}
+	}
+
+	if v := os.Getenv("UNLOCK_GLOBAL_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.UnlockGlobalLimit = n
+		}
+	}
+
+	if v := os.Getenv("UNLOCK_LOCKOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.UnlockLockout = d
+		}
+	}
+
+	if v := os.Getenv("UNLOCK_BACKOFF_BASE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.UnlockBackoffBase = d
+		}
+	}
+
+	if v := os.Getenv("UNLOCK_BACKOFF_MAX"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			cfg.UnlockBackoffMax = d
+		}
+	}
+
+	if v := os.Getenv("UNLOCK_WIPE_AFTER"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.UnlockWipeAfter = n
+		}
+	}
+
+	if v := os.Getenv("REQUIRE_PAKE"); v != "" {
+		cfg.RequirePAKE = v == "true" || v == "1" || v == "yes"
+	}
+
+	if v := os.Getenv("SEALED_ONLY"); v != "" {
+		cfg.SealedOnly = v == "true" || v == "1" || v == "yes"
+	}
+
+	if v := os.Getenv("ADMIN_TOKEN"); v != "" {
+		cfg.AdminToken = v
+	}
+
+	if v := os.Getenv("DEVICE_FORCE_UNLOCK"); v != "" {
+		cfg.DeviceForceUnlock = v == "true" || v == "1" || v == "yes"
+	}
+
+	// Key derivation
+	if v := os.Getenv("KDF_ALGORITHM"); v != "" {
+		cfg.KDFAlgorithm = v
+	}
+
+	if v := os.Getenv("KDF_ITERATIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.KDFIterations = n
+		}
+	}
+
+	if v := os.Getenv("ARGON2_TIME"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 32); err == nil && n > 0 {
+			cfg.Argon2Time = uint32(n)
+		}
+	}
+
+	if v := os.Getenv("ARGON2_MEMORY"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 32); err == nil && n > 0 {
+			cfg.Argon2Memory = uint32(n)
+		}
+	}
+
+	if v := os.Getenv("ARGON2_PARALLELISM"); v != "" {
+		if n, err := strconv.ParseUint(v, 10, 8); err == nil && n > 0 {
+			cfg.Argon2Parallelism = uint8(n)
+		}
+	}
+
 	if v := os.Getenv("RATE_LIMIT"); v != "" {
 		if limit, err := strconv.Atoi(v); err == nil && limit > 0 {
 			cfg.RateLimit = limit
@@ -144,6 +419,10 @@ func LoadFromEnv() *Config {
 		cfg.AllowedOrigins = []string{v}
 	}
 
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		cfg.TrustedProxies = strings.Split(v, ",")
+	}
+
 	// Feature flags
 	if v := os.Getenv("ENABLE_CLIPBOARD"); v != "" {
 		cfg.EnableClipboard = v == "true" || v == "1" || v == "yes"
@@ -156,6 +435,12 @@ func LoadFromEnv() *Config {
 	if v := os.Getenv("ENABLE_FILE_SHARING"); v != "" {
 		cfg.EnableFileSharing = v == "true" || v == "1" || v == "yes"
 	}
+	if v := os.Getenv("ENABLE_SECRET_NOTES"); v != "" {
+		cfg.EnableSecretNotes = v == "true" || v == "1" || v == "yes"
+	}
+	if v := os.Getenv("ENABLE_ROOMS"); v != "" {
+		cfg.EnableRooms = v == "true" || v == "1" || v == "yes"
+	}
 
 	// Frontend
 	if v := os.Getenv("FRONTEND_DIR"); v != "" {
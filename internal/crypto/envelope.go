@@ -0,0 +1,310 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"math/bits"
+
+	"github.com/fileez/fileez/internal/secure"
+)
+
+// Envelope format, version 1 (all integers single bytes):
+//
+//	magic "FZEE" (4) || version (1) || kdf (1) || cipher (1) || type (1) ||
+//	id length (1) || id (0-64) || nonce (12) || ciphertext || auth tag (16)
+//
+// Everything before the nonce is the header. It is authenticated as the
+// AES-GCM additional data, so an envelope cannot be moved to another item,
+// item type, or algorithm without failing to decrypt. The server cannot
+// decrypt envelopes; it checks their structure and binding on ingest.
+const (
+	// EnvelopeMagic starts every envelope.
+	EnvelopeMagic = "FZEE"
+	// EnvelopeVersion is the envelope format version written by SealEnvelope.
+	EnvelopeVersion = 1
+	// MaxEnvelopeIDLength bounds the item ID bound into an envelope.
+	MaxEnvelopeIDLength = 64
+	// MinSizeBucket is the smallest padded size reported for sealed content.
+	MinSizeBucket = 256
+
+	// envelopeFixedHeader is the header size without the item ID.
+	envelopeFixedHeader = len(EnvelopeMagic) + 5
+	// gcmTagBytes is the AES-GCM authentication tag size.
+	gcmTagBytes = 16
+)
+
+// EnvelopeKDF identifies how the envelope key was derived.
+type EnvelopeKDF uint8
+
+// Envelope KDF IDs.
+const (
+	// EnvelopeKDFNone is a random key, e.g. a content key wrapped by key slots.
+	EnvelopeKDFNone EnvelopeKDF = 0
+	// EnvelopeKDFPBKDF2SHA256 is a key derived with PBKDF2-SHA256.
+	EnvelopeKDFPBKDF2SHA256 EnvelopeKDF = 1
+	// EnvelopeKDFArgon2id is a key derived with Argon2id.
+	EnvelopeKDFArgon2id EnvelopeKDF = 2
+)
+
+// EnvelopeCipher identifies the envelope cipher.
+type EnvelopeCipher uint8
+
+// Envelope cipher IDs.
+const (
+	// EnvelopeCipherAES256GCM is AES-256-GCM with a 12-byte nonce.
+	EnvelopeCipherAES256GCM EnvelopeCipher = 1
+)
+
+// EnvelopeType identifies the kind of item an envelope belongs to.
+type EnvelopeType uint8
+
+// Envelope item types.
+const (
+	// EnvelopeFile is a file; the envelope ID is the file ID.
+	EnvelopeFile EnvelopeType = 1
+	// EnvelopeClipboardText is clipboard text; the envelope ID is empty.
+	EnvelopeClipboardText EnvelopeType = 2
+	// EnvelopeClipboardImage is a clipboard image; the envelope ID is empty.
+	EnvelopeClipboardImage EnvelopeType = 3
+	// EnvelopeFileMetadata is a file's name, MIME type and size; the envelope
+	// ID is the file ID.
+	EnvelopeFileMetadata EnvelopeType = 4
+	// EnvelopeDocumentOp is an operation on the shared document; the envelope ID is empty.
+	EnvelopeDocumentOp EnvelopeType = 5
+	// EnvelopeDocumentSnapshot is a snapshot of the shared document; the envelope ID is empty.
+	EnvelopeDocumentSnapshot EnvelopeType = 6
+	// EnvelopeNote is a secret note, encrypted with the key in its link; the
+	// envelope ID is empty.
+	EnvelopeNote EnvelopeType = 7
+)
+
+var (
+	// ErrInvalidEnvelope indicates a malformed or truncated envelope.
+	ErrInvalidEnvelope = errors.New("invalid ciphertext envelope")
+	// ErrEnvelopeUnsupported indicates an unknown envelope version, KDF or cipher.
+	ErrEnvelopeUnsupported = errors.New("unsupported ciphertext envelope")
+	// ErrEnvelopeMismatch indicates an envelope bound to another item or item type.
+	ErrEnvelopeMismatch = errors.New("ciphertext envelope does not match item")
+)
+
+// EnvelopeHeader is the authenticated header of an envelope.
+type EnvelopeHeader struct {
+	Version uint8
+	KDF     EnvelopeKDF
+	Cipher  EnvelopeCipher
+	Type    EnvelopeType
+	ID      string // Item ID ("" for clipboard entries)
+}
+
+// Envelope is a parsed envelope. Nonce and Ciphertext alias the parsed bytes.
+type Envelope struct {
+	EnvelopeHeader
+	Nonce      []byte
+	Ciphertext []byte // Ciphertext and auth tag
+}
+
+// EnvelopeKDFFor returns the envelope KDF ID for a KDF descriptor.
+func EnvelopeKDFFor(params KDFParams) (EnvelopeKDF, error) {
+	switch params.Algorithm {
+	case KDFPBKDF2SHA256:
+		return EnvelopeKDFPBKDF2SHA256, nil
+	case KDFArgon2id:
+		return EnvelopeKDFArgon2id, nil
+	case "":
+		return EnvelopeKDFNone, nil
+	}
+	return 0, ErrKDFUnsupported
+}
+
+// AAD returns the header bytes authenticated as AES-GCM additional data.
+func (h EnvelopeHeader) AAD() []byte {
+	aad := make([]byte, 0, envelopeFixedHeader+len(h.ID))
+	aad = append(aad, EnvelopeMagic...)
+	aad = append(aad, h.Version, byte(h.KDF), byte(h.Cipher), byte(h.Type), byte(len(h.ID)))
+	return append(aad, h.ID...)
+}
+
+// validate checks the header fields.
+func (h EnvelopeHeader) validate() error {
+	if h.Version != EnvelopeVersion || h.Cipher != EnvelopeCipherAES256GCM || h.KDF > EnvelopeKDFArgon2id {
+		return ErrEnvelopeUnsupported
+	}
+	if h.Type < EnvelopeFile || h.Type > EnvelopeNote || len(h.ID) > MaxEnvelopeIDLength {
+		return ErrInvalidEnvelope
+	}
+	// Files are bound to their ID; other items only to their type
+	fileBound := h.Type == EnvelopeFile || h.Type == EnvelopeFileMetadata
+	if fileBound != (h.ID != "") {
+		return ErrInvalidEnvelope
+	}
+	return nil
+}
+
+// PlaintextSize returns the size of the encrypted plaintext.
+func (e *Envelope) PlaintextSize() int {
+	return len(e.Ciphertext) - gcmTagBytes
+}
+
+// Marshal encodes the envelope.
+func (e *Envelope) Marshal() []byte {
+	aad := e.AAD()
+	out := make([]byte, 0, len(aad)+len(e.Nonce)+len(e.Ciphertext))
+	out = append(out, aad...)
+	out = append(out, e.Nonce...)
+	return append(out, e.Ciphertext...)
+}
+
+// ParseEnvelope decodes an envelope and checks its structure and lengths.
+// It does not decrypt; the returned slices alias data.
+func ParseEnvelope(data []byte) (*Envelope, error) {
+	if len(data) < envelopeFixedHeader || string(data[:len(EnvelopeMagic)]) != EnvelopeMagic {
+		return nil, ErrInvalidEnvelope
+	}
+
+	fixed := data[len(EnvelopeMagic):envelopeFixedHeader]
+	idLen := int(fixed[4])
+	if idLen > MaxEnvelopeIDLength || len(data) < envelopeFixedHeader+idLen {
+		return nil, ErrInvalidEnvelope
+	}
+
+	e := &Envelope{
+		EnvelopeHeader: EnvelopeHeader{
+			Version: fixed[0],
+			KDF:     EnvelopeKDF(fixed[1]),
+			Cipher:  EnvelopeCipher(fixed[2]),
+			Type:    EnvelopeType(fixed[3]),
+			ID:      string(data[envelopeFixedHeader : envelopeFixedHeader+idLen]),
+		},
+	}
+	if err := e.validate(); err != nil {
+		return nil, err
+	}
+
+	// AES-256-GCM: nonce and at least the auth tag
+	body := data[envelopeFixedHeader+idLen:]
+	if len(body) < NonceBytes+gcmTagBytes {
+		return nil, ErrInvalidEnvelope
+	}
+	e.Nonce = body[:NonceBytes]
+	e.Ciphertext = body[NonceBytes:]
+
+	return e, nil
+}
+
+// CheckEnvelope checks that data is a well-formed envelope for the item
+// typ/id. This is what the server can verify without the key.
+func CheckEnvelope(data []byte, typ EnvelopeType, id string) error {
+	_, err := parseEnvelopeFor(data, typ, id)
+	return err
+}
+
+// parseEnvelopeFor parses data and checks its binding to the item typ/id.
+func parseEnvelopeFor(data []byte, typ EnvelopeType, id string) (*Envelope, error) {
+	e, err := ParseEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	if e.Type != typ || e.ID != id {
+		return nil, ErrEnvelopeMismatch
+	}
+	return e, nil
+}
+
+// SealEnvelope encrypts plaintext with AES-256-GCM into an envelope for the
+// item described by h. Version and Cipher are set by SealEnvelope.
+func SealEnvelope(key *secure.SecureKey, h EnvelopeHeader, plaintext []byte) ([]byte, error) {
+	if key == nil || key.IsDestroyed() {
+		return nil, secure.ErrKeyDestroyed
+	}
+
+	h.Version = EnvelopeVersion
+	h.Cipher = EnvelopeCipherAES256GCM
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+
+	nonce, err := RandomBytesRaw(NonceBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed []byte
+	err = key.Use(func(keyBytes []byte) error {
+		gcm, err := newGCM(keyBytes)
+		if err != nil {
+			return err
+		}
+		e := &Envelope{EnvelopeHeader: h, Nonce: nonce}
+		e.Ciphertext = gcm.Seal(nil, nonce, plaintext, h.AAD())
+		sealed = e.Marshal()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sealed, nil
+}
+
+// OpenEnvelope checks that data is an envelope for the item typ/id and decrypts it.
+func OpenEnvelope(key *secure.SecureKey, data []byte, typ EnvelopeType, id string) ([]byte, error) {
+	if key == nil || key.IsDestroyed() {
+		return nil, secure.ErrKeyDestroyed
+	}
+
+	e, err := parseEnvelopeFor(data, typ, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext []byte
+	err = key.Use(func(keyBytes []byte) error {
+		gcm, err := newGCM(keyBytes)
+		if err != nil {
+			return err
+		}
+		plaintext, err = gcm.Open(nil, e.Nonce, e.Ciphertext, e.AAD())
+		if err != nil {
+			return ErrDecryptionFailed
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return plaintext, nil
+}
+
+// newGCM returns AES-256-GCM for keyBytes.
+func newGCM(keyBytes []byte) (cipher.AEAD, error) {
+	if len(keyBytes) != AES256KeySize {
+		return nil, ErrInvalidKeySize
+	}
+
+	block, err := aes.NewCipher(keyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// IsEnvelopeError reports whether err is one of the envelope errors.
+func IsEnvelopeError(err error) bool {
+	return err == ErrInvalidEnvelope || err == ErrEnvelopeUnsupported || err == ErrEnvelopeMismatch
+}
+
+// SizeBucket rounds n up to its Padmé bucket: at most about 12% more, and
+// at least MinSizeBucket. Clients pad content to SizeBucket(size) before
+// encrypting, so the ciphertext length reveals only the bucket.
+func SizeBucket(n int64) int64 {
+	if n <= MinSizeBucket {
+		return MinSizeBucket
+	}
+	e := bits.Len64(uint64(n)) - 1 // floor(log2(n))
+	s := bits.Len64(uint64(e))     // floor(log2(e)) + 1
+	mask := int64(1)<<(e-s) - 1
+	return (n + mask) &^ mask
+}
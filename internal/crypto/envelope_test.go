@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fileez/fileez/internal/secure"
+)
+
+// envelopeKey returns a fixed test key.
+func envelopeKey(t *testing.T, b byte) *secure.SecureKey {
+	t.Helper()
+
+	key, err := secure.NewSecureKey(bytes.Repeat([]byte{b}, AES256KeySize))
+	if err != nil {
+		t.Fatalf("NewSecureKey: %v", err)
+	}
+	t.Cleanup(key.Destroy)
+	return key
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	key := envelopeKey(t, 1)
+	h := EnvelopeHeader{KDF: EnvelopeKDFArgon2id, Type: EnvelopeFile, ID: "0123456789abcdef"}
+
+	sealed, err := SealEnvelope(key, h, []byte("content"))
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+
+	e, err := ParseEnvelope(sealed)
+	if err != nil {
+		t.Fatalf("ParseEnvelope: %v", err)
+	}
+	if e.Version != EnvelopeVersion || e.Cipher != EnvelopeCipherAES256GCM || e.KDF != h.KDF || e.Type != h.Type || e.ID != h.ID {
+		t.Errorf("parsed header %+v, want %+v", e.EnvelopeHeader, h)
+	}
+	if e.PlaintextSize() != len("content") {
+		t.Errorf("plaintext size %d, want %d", e.PlaintextSize(), len("content"))
+	}
+
+	plaintext, err := OpenEnvelope(key, sealed, EnvelopeFile, h.ID)
+	if err != nil {
+		t.Fatalf("OpenEnvelope: %v", err)
+	}
+	if string(plaintext) != "content" {
+		t.Errorf("opened %q, want %q", plaintext, "content")
+	}
+
+	if _, err := OpenEnvelope(envelopeKey(t, 2), sealed, EnvelopeFile, h.ID); err != ErrDecryptionFailed {
+		t.Errorf("open with another key: got %v, want %v", err, ErrDecryptionFailed)
+	}
+}
+
+func TestEnvelopeBinding(t *testing.T) {
+	key := envelopeKey(t, 1)
+	sealed, err := SealEnvelope(key, EnvelopeHeader{Type: EnvelopeFile, ID: "0123456789abcdef"}, []byte("content"))
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+
+	if err := CheckEnvelope(sealed, EnvelopeFile, "fedcba9876543210"); err != ErrEnvelopeMismatch {
+		t.Errorf("another file ID: got %v, want %v", err, ErrEnvelopeMismatch)
+	}
+	if err := CheckEnvelope(sealed, EnvelopeFileMetadata, "0123456789abcdef"); err != ErrEnvelopeMismatch {
+		t.Errorf("another item type: got %v, want %v", err, ErrEnvelopeMismatch)
+	}
+
+	// Rewriting the header to rebind the envelope fails to decrypt
+	rebound := bytes.Clone(sealed)
+	rebound[len(EnvelopeMagic)+3] = byte(EnvelopeFileMetadata)
+	if _, err := OpenEnvelope(key, rebound, EnvelopeFileMetadata, "0123456789abcdef"); err != ErrDecryptionFailed {
+		t.Errorf("rebound envelope: got %v, want %v", err, ErrDecryptionFailed)
+	}
+}
+
+func TestParseEnvelopeRejectsMalformed(t *testing.T) {
+	sealed, err := SealEnvelope(envelopeKey(t, 1), EnvelopeHeader{Type: EnvelopeClipboardText}, []byte("text"))
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	with := func(offset int, b byte) []byte {
+		data := bytes.Clone(sealed)
+		data[offset] = b
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrInvalidEnvelope},
+		{"bad magic", with(0, 'X'), ErrInvalidEnvelope},
+		{"truncated header", sealed[:envelopeFixedHeader-1], ErrInvalidEnvelope},
+		{"truncated body", sealed[:envelopeFixedHeader+NonceBytes+gcmTagBytes-1], ErrInvalidEnvelope},
+		{"unknown version", with(len(EnvelopeMagic), EnvelopeVersion+1), ErrEnvelopeUnsupported},
+		{"unknown KDF", with(len(EnvelopeMagic)+1, byte(EnvelopeKDFArgon2id)+1), ErrEnvelopeUnsupported},
+		{"unknown cipher", with(len(EnvelopeMagic)+2, 2), ErrEnvelopeUnsupported},
+		{"unknown type", with(len(EnvelopeMagic)+3, byte(EnvelopeNote)+1), ErrInvalidEnvelope},
+		{"ID length past the end", with(len(EnvelopeMagic)+4, 255), ErrInvalidEnvelope},
+		{"file type without an ID", with(len(EnvelopeMagic)+3, byte(EnvelopeFile)), ErrInvalidEnvelope},
+	}
+
+	for _, tt := range tests {
+		if _, err := ParseEnvelope(tt.data); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSizeBucket(t *testing.T) {
+	tests := []struct {
+		n, want int64
+	}{
+		{0, MinSizeBucket},
+		{MinSizeBucket, MinSizeBucket},
+		{1000, 1024},
+		{1 << 20, 1 << 20},
+		{1<<20 + 1, 1<<20 + 1<<15},
+	}
+
+	for _, tt := range tests {
+		if got := SizeBucket(tt.n); got != tt.want {
+			t.Errorf("SizeBucket(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+	}
+
+	// Buckets grow by at most about 12%
+	for n := int64(MinSizeBucket); n < 1<<24; n = n*3/2 + 7 {
+		if b := SizeBucket(n); b < n || float64(b) > float64(n)*1.125 {
+			t.Errorf("SizeBucket(%d) = %d, outside [n, 1.125n]", n, b)
+		}
+	}
+}
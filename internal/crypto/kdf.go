@@ -3,7 +3,9 @@ package crypto
 import (
 	"crypto/sha256"
 	"errors"
+	"fmt"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/pbkdf2"
 
 	"github.com/fileez/fileez/internal/secure"
@@ -18,13 +20,158 @@ const (
 	AES256KeySize = 32
 )
 
+// KDF algorithms a sealed session can record.
+const (
+	// KDFPBKDF2SHA256 is PBKDF2 with HMAC-SHA256.
+	KDFPBKDF2SHA256 = "pbkdf2-sha256"
+	// KDFArgon2id is Argon2id (RFC 9106).
+	KDFArgon2id = "argon2id"
+)
+
+const (
+	// Argon2Version is the Argon2 version implemented (0x13).
+	Argon2Version = argon2.Version
+
+	// Default Argon2id parameters (RFC 9106 second recommended option).
+	DefaultArgon2Time        = 3
+	DefaultArgon2Memory      = 64 * 1024 // KiB
+	DefaultArgon2Parallelism = 4
+
+	// Bounds for descriptors supplied by clients, so a seal can neither be
+	// weaker than the OWASP minimums nor impossible for other devices to derive.
+	MaxPBKDF2Iterations    = 10000000
+	MinArgon2Memory        = 19 * 1024 // KiB
+	MaxArgon2Memory        = 1024 * 1024
+	MaxArgon2Time          = 16
+	MaxArgon2Parallelism   = 16
+	minArgon2TimeLowMemory = 2 // Time cost required below 46 MiB
+	argon2LowMemory        = 46 * 1024
+)
+
 var (
 	// ErrPasswordEmpty indicates an empty password was provided.
 	ErrPasswordEmpty = errors.New("password cannot be empty")
 	// ErrSaltInvalid indicates an invalid salt was provided.
 	ErrSaltInvalid = errors.New("salt must be at least 16 bytes")
+	// ErrKDFUnsupported indicates an unknown KDF algorithm or version.
+	ErrKDFUnsupported = errors.New("unsupported KDF")
 )
 
+// KDFParams describes how clients derive the key from the password.
+// It is recorded when a session is sealed and returned with the salt, so
+// parameters can be raised for new seals without breaking sealed sessions.
+type KDFParams struct {
+	Algorithm   string `json:"algorithm"`
+	Iterations  int    `json:"iterations,omitempty"`  // PBKDF2
+	Version     int    `json:"version,omitempty"`     // Argon2id
+	Time        uint32 `json:"time,omitempty"`        // Argon2id passes
+	Memory      uint32 `json:"memory,omitempty"`      // Argon2id memory in KiB
+	Parallelism uint8  `json:"parallelism,omitempty"` // Argon2id lanes
+}
+
+// LegacyKDFParams returns the parameters of seals that predate KDF descriptors.
+func LegacyKDFParams() KDFParams {
+	return KDFParams{
+		Algorithm:  KDFPBKDF2SHA256,
+		Iterations: PBKDF2Iterations,
+	}
+}
+
+// DefaultArgon2idParams returns the default Argon2id parameters.
+func DefaultArgon2idParams() KDFParams {
+	return KDFParams{
+		Algorithm:   KDFArgon2id,
+		Version:     Argon2Version,
+		Time:        DefaultArgon2Time,
+		Memory:      DefaultArgon2Memory,
+		Parallelism: DefaultArgon2Parallelism,
+	}
+}
+
+// Validate checks that the parameters are supported and within bounds.
+func (p KDFParams) Validate() error {
+	switch p.Algorithm {
+	case KDFPBKDF2SHA256:
+		if p.Iterations < PBKDF2Iterations || p.Iterations > MaxPBKDF2Iterations {
+			return fmt.Errorf("pbkdf2 iterations must be between %d and %d", PBKDF2Iterations, MaxPBKDF2Iterations)
+		}
+	case KDFArgon2id:
+		if p.Version != Argon2Version {
+			return ErrKDFUnsupported
+		}
+		if p.Memory < MinArgon2Memory || p.Memory > MaxArgon2Memory {
+			return fmt.Errorf("argon2id memory must be between %d and %d KiB", MinArgon2Memory, MaxArgon2Memory)
+		}
+		minTime := uint32(1)
+		if p.Memory < argon2LowMemory {
+			minTime = minArgon2TimeLowMemory
+		}
+		if p.Time < minTime || p.Time > MaxArgon2Time {
+			return fmt.Errorf("argon2id time must be between %d and %d", minTime, MaxArgon2Time)
+		}
+		if p.Parallelism < 1 || p.Parallelism > MaxArgon2Parallelism {
+			return fmt.Errorf("argon2id parallelism must be between 1 and %d", MaxArgon2Parallelism)
+		}
+	default:
+		return ErrKDFUnsupported
+	}
+	return nil
+}
+
+// DeriveKeyWithParams derives an encryption key as described by params.
+// This is the reference for clients; the server itself never derives keys
+// for sealed sessions.
+// IMPORTANT: Caller must call Destroy() on the returned key.
+func DeriveKeyWithParams(password *secure.SecureBuffer, salt []byte, params KDFParams) (*secure.SecureKey, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch params.Algorithm {
+	case KDFArgon2id:
+		return DeriveKeyArgon2id(password, salt, params.Time, params.Memory, params.Parallelism)
+	default:
+		return DeriveKeyWithIterations(password, salt, params.Iterations)
+	}
+}
+
+// DeriveKeyArgon2id derives an encryption key from a password using Argon2id.
+// memory is in KiB.
+//
+// Returns a SecureKey that is encrypted in memory.
+// IMPORTANT: Caller must call Destroy() on the returned key.
+func DeriveKeyArgon2id(password *secure.SecureBuffer, salt []byte, time, memory uint32, parallelism uint8) (*secure.SecureKey, error) {
+	if password == nil || password.Size() == 0 {
+		return nil, ErrPasswordEmpty
+	}
+	if len(salt) < SaltBytes {
+		return nil, ErrSaltInvalid
+	}
+	if time < 1 || memory < 8*uint32(parallelism) || parallelism < 1 {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+
+	var derivedKey []byte
+
+	err := password.Use(func(passwordBytes []byte) error {
+		derivedKey = argon2.IDKey(passwordBytes, salt, time, memory, parallelism, AES256KeySize)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := secure.NewSecureKey(derivedKey)
+	secure.Shred(derivedKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
 // DeriveKey derives an encryption key from a password using PBKDF2-SHA256.
 // Uses 600,000 iterations per OWASP 2024 recommendations.
 //
@@ -0,0 +1,69 @@
+package crypto
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestKDFParamsValidate(t *testing.T) {
+	argon2id := func(time, memory uint32, parallelism uint8) KDFParams {
+		p := DefaultArgon2idParams()
+		p.Time, p.Memory, p.Parallelism = time, memory, parallelism
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		params KDFParams
+		valid  bool
+	}{
+		{"legacy PBKDF2", LegacyKDFParams(), true},
+		{"PBKDF2 below the minimum", KDFParams{Algorithm: KDFPBKDF2SHA256, Iterations: 100000}, false},
+		{"PBKDF2 above the maximum", KDFParams{Algorithm: KDFPBKDF2SHA256, Iterations: MaxPBKDF2Iterations + 1}, false},
+		{"default Argon2id", DefaultArgon2idParams(), true},
+		{"Argon2id 0x10", KDFParams{Algorithm: KDFArgon2id, Version: 0x10, Time: 3, Memory: 65536, Parallelism: 4}, false},
+		{"Argon2id OWASP minimum", argon2id(2, MinArgon2Memory, 1), true},
+		{"Argon2id one pass with little memory", argon2id(1, MinArgon2Memory, 1), false},
+		{"Argon2id one pass with 46 MiB", argon2id(1, 46*1024, 1), true},
+		{"Argon2id memory below the minimum", argon2id(3, MinArgon2Memory-1, 1), false},
+		{"Argon2id memory above the maximum", argon2id(3, MaxArgon2Memory+1, 1), false},
+		{"Argon2id too many passes", argon2id(MaxArgon2Time+1, 65536, 4), false},
+		{"Argon2id no lanes", argon2id(3, 65536, 0), false},
+		{"Argon2id too many lanes", argon2id(3, 65536, MaxArgon2Parallelism+1), false},
+		{"unknown algorithm", KDFParams{Algorithm: "scrypt"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.params.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestKDFParamsJSON(t *testing.T) {
+	tests := []struct {
+		params KDFParams
+		want   string
+	}{
+		{LegacyKDFParams(), `{"algorithm":"pbkdf2-sha256","iterations":600000}`},
+		{DefaultArgon2idParams(), `{"algorithm":"argon2id","version":19,"time":3,"memory":65536,"parallelism":4}`},
+	}
+
+	for _, tt := range tests {
+		encoded, err := json.Marshal(tt.params)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if string(encoded) != tt.want {
+			t.Errorf("encoded %s, want %s", encoded, tt.want)
+		}
+
+		var decoded KDFParams
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("decode %s: %v", encoded, err)
+		}
+		if decoded != tt.params {
+			t.Errorf("decoded %+v, want %+v", decoded, tt.params)
+		}
+	}
+}
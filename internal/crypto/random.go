@@ -4,9 +4,11 @@ package crypto
 import (
 	"crypto/rand"
 	"crypto/subtle"
+	"encoding/base32"
 	"encoding/hex"
 	"errors"
 	"io"
+	"strings"
 
 	"github.com/fileez/fileez/internal/secure"
 )
@@ -14,12 +16,16 @@ import (
 const (
 	// FileIDBytes is the number of bytes in a file ID (64 bits = 8 bytes = 16 hex chars).
 	FileIDBytes = 8
+	// RoomCodeBytes is the number of bytes in a room code (64 bits = 8 bytes = 16 hex chars).
+	RoomCodeBytes = 8
 	// SessionTokenBytes is the number of bytes in a session token (256 bits = 32 bytes = 64 hex chars).
 	SessionTokenBytes = 32
 	// NonceBytes is the standard nonce size for AES-GCM (96 bits = 12 bytes).
 	NonceBytes = 12
 	// SaltBytes is the standard salt size for PBKDF2 (128 bits = 16 bytes).
 	SaltBytes = 16
+	// RecoveryCodeBytes is the number of bytes in a recovery code (160 bits = 20 bytes = 32 base32 chars).
+	RecoveryCodeBytes = 20
 )
 
 var (
@@ -82,6 +88,19 @@ func GenerateFileID() (string, error) {
 	return id, nil
 }
 
+// GenerateRoomCode generates a new random room code.
+// Returns a 16-character hex string (64 bits of entropy).
+func GenerateRoomCode() (string, error) {
+	data, err := RandomBytesRaw(RoomCodeBytes)
+	if err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(data)
+	// Zero the raw bytes
+	secure.Shred(data)
+	return code, nil
+}
+
 // GenerateSessionToken generates a new random session token.
 // Returns a SecureBuffer containing a 64-character hex string (256 bits of entropy).
 // IMPORTANT: Caller must call Destroy() on the returned buffer.
@@ -114,6 +133,35 @@ func GenerateSessionTokenString() (string, error) {
 	return token, nil
 }
 
+// GenerateRecoveryCode generates a new random recovery code.
+// Returns 32 base32 characters in groups of four separated by dashes (160 bits of entropy).
+func GenerateRecoveryCode() (string, error) {
+	data, err := RandomBytesRaw(RecoveryCodeBytes)
+	if err != nil {
+		return "", err
+	}
+	encoded := base32.StdEncoding.EncodeToString(data)
+	// Zero the raw bytes
+	secure.Shred(data)
+
+	groups := make([]string, 0, len(encoded)/4)
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// NormalizeRecoveryCode strips separators and case from a typed recovery code.
+func NormalizeRecoveryCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if r != '-' && r != ' ' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
 // GenerateNonce generates a random nonce for AES-GCM.
 // Returns a SecureBuffer containing 12 bytes.
 // IMPORTANT: Caller must call Destroy() on the returned buffer.
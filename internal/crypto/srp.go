@@ -0,0 +1,327 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"math/big"
+
+	"github.com/fileez/fileez/internal/secure"
+)
+
+// SRP-6a (RFC 5054) with the 3072-bit group and SHA-256.
+//
+// The server stores only the verifier v = g^x, where x is derived from the
+// salt and the client's password input. Unlocking proves knowledge of the
+// password interactively: neither the verifier nor a recorded handshake lets
+// anyone unlock, and a handshake transcript gives no offline guessing oracle.
+
+const (
+	// SRPIdentity is the fixed SRP username; a session has a single password.
+	SRPIdentity = "fileez"
+	// SRPGroupBytes is the size of the group modulus and of padded SRP values.
+	SRPGroupBytes = 384
+	// srpEphemeralBytes is the size of the secret ephemeral exponents a and b.
+	srpEphemeralBytes = 32
+)
+
+var (
+	// ErrSRPInvalidPublic indicates a public value that is zero modulo N or out of range.
+	ErrSRPInvalidPublic = errors.New("invalid SRP public value")
+	// ErrSRPInvalidVerifier indicates a verifier that is not a valid group element.
+	ErrSRPInvalidVerifier = errors.New("invalid SRP verifier")
+	// ErrSRPProofMismatch indicates the peer's proof does not match: wrong password.
+	ErrSRPProofMismatch = errors.New("SRP proof mismatch")
+)
+
+// srpN is the RFC 5054 3072-bit group modulus (RFC 3526 MODP group 15).
+var srpN, _ = new(big.Int).SetString(
+	"FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74"+
+		"020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F1437"+
+		"4FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7ED"+
+		"EE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF05"+
+		"98DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB"+
+		"9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3B"+
+		"E39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF695581718"+
+		"3995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33"+
+		"A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7"+
+		"ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864"+
+		"D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E2"+
+		"08E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF", 16)
+
+// srpGroup is an SRP group, hash and identity. Only srpDefault is used by
+// the server; other groups exist for the RFC 5054 test vectors.
+type srpGroup struct {
+	N        *big.Int
+	g        *big.Int
+	k        *big.Int // SRP-6a multiplier k = H(N | PAD(g))
+	size     int      // Bytes of N, the size of padded values
+	hash     func() hash.Hash
+	identity string
+}
+
+// newSRPGroup returns the group N, g with the given hash and identity.
+func newSRPGroup(N *big.Int, g int64, h func() hash.Hash, identity string) *srpGroup {
+	grp := &srpGroup{N: N, g: big.NewInt(g), size: (N.BitLen() + 7) / 8, hash: h, identity: identity}
+	grp.k = grp.hashInt(N.Bytes(), grp.pad(grp.g))
+	return grp
+}
+
+// srpDefault is the 3072-bit group with generator 5, SHA-256 and SRPIdentity.
+var srpDefault = newSRPGroup(srpN, 5, sha256.New, SRPIdentity)
+
+// SRPVerifier computes the verifier v = g^x for the given salt and password input.
+// Clients send it when sealing; the server never sees the password input.
+func SRPVerifier(salt, password []byte) []byte {
+	return srpDefault.verifier(salt, password)
+}
+
+// verifier computes v = g^x.
+func (grp *srpGroup) verifier(salt, password []byte) []byte {
+	x := grp.x(salt, password)
+	v := new(big.Int).Exp(grp.g, x, grp.N)
+	x.SetInt64(0)
+	return grp.pad(v)
+}
+
+// CheckSRPVerifier reports whether verifier is a usable group element.
+func CheckSRPVerifier(verifier []byte) error {
+	return srpDefault.checkVerifier(verifier)
+}
+
+// checkVerifier reports whether verifier is a usable group element.
+func (grp *srpGroup) checkVerifier(verifier []byte) error {
+	v := new(big.Int).SetBytes(verifier)
+	if len(verifier) > grp.size || v.Sign() == 0 || v.Cmp(grp.N) >= 0 {
+		return ErrSRPInvalidVerifier
+	}
+	return nil
+}
+
+// SRPServer is the server side of one SRP handshake.
+// It is single-use: create one per unlock attempt.
+type SRPServer struct {
+	b *big.Int
+	B []byte
+
+	// Expected client proof and the server proof sent back on success
+	m1 []byte
+	m2 []byte
+}
+
+// NewSRPServer starts a handshake for the client's public value A.
+// Everything needed to check the client's proof is computed up front.
+func NewSRPServer(salt, verifier, clientPublic []byte) (*SRPServer, error) {
+	b, err := srpEphemeral()
+	if err != nil {
+		return nil, err
+	}
+	return srpDefault.newServer(salt, verifier, clientPublic, b)
+}
+
+// newServer starts a handshake with the secret ephemeral b.
+func (grp *srpGroup) newServer(salt, verifier, clientPublic []byte, b *big.Int) (*SRPServer, error) {
+	if err := grp.checkVerifier(verifier); err != nil {
+		return nil, err
+	}
+	v := new(big.Int).SetBytes(verifier)
+
+	A := new(big.Int).SetBytes(clientPublic)
+	if len(clientPublic) > grp.size || new(big.Int).Mod(A, grp.N).Sign() == 0 {
+		return nil, ErrSRPInvalidPublic
+	}
+
+	// B = k*v + g^b mod N
+	B := new(big.Int).Mul(grp.k, v)
+	B.Add(B, new(big.Int).Exp(grp.g, b, grp.N))
+	B.Mod(B, grp.N)
+
+	u := grp.hashInt(grp.pad(A), grp.pad(B))
+	if u.Sign() == 0 {
+		return nil, ErrSRPInvalidPublic
+	}
+
+	// S = (A * v^u)^b mod N
+	S := new(big.Int).Exp(v, u, grp.N)
+	S.Mul(S, A)
+	S.Mod(S, grp.N)
+	S.Exp(S, b, grp.N)
+
+	key := grp.hashBytes(grp.pad(S))
+	S.SetInt64(0)
+	defer secure.Shred(key)
+
+	s := &SRPServer{
+		b: b,
+		B: grp.pad(B),
+	}
+	s.m1 = grp.clientProof(salt, grp.pad(A), s.B, key)
+	s.m2 = grp.hashBytes(grp.pad(A), s.m1, key)
+
+	return s, nil
+}
+
+// PublicKey returns the server's public value B for the client.
+func (s *SRPServer) PublicKey() []byte {
+	return s.B
+}
+
+// Verify checks the client's proof M1 in constant time.
+// On success it returns the server proof M2, which lets the client check the server.
+func (s *SRPServer) Verify(clientProof []byte) ([]byte, error) {
+	if !ConstantTimeCompare(s.m1, clientProof) {
+		return nil, ErrSRPProofMismatch
+	}
+	m2 := make([]byte, len(s.m2))
+	copy(m2, s.m2)
+	return m2, nil
+}
+
+// Destroy wipes the handshake secrets.
+func (s *SRPServer) Destroy() {
+	if s.b != nil {
+		s.b.SetInt64(0)
+	}
+	secure.Shred(s.m1)
+	secure.Shred(s.m2)
+}
+
+// SRPClient is a reference client for one SRP handshake.
+// Browsers implement the same computation; this one is used by tools and tests.
+type SRPClient struct {
+	group    *srpGroup
+	password []byte
+	a        *big.Int
+	A        []byte
+
+	// Expected server proof
+	m2 []byte
+}
+
+// NewSRPClient starts a handshake with the given password input.
+func NewSRPClient(password []byte) (*SRPClient, error) {
+	a, err := srpEphemeral()
+	if err != nil {
+		return nil, err
+	}
+	return srpDefault.newClient(password, a), nil
+}
+
+// newClient starts a handshake with the secret ephemeral a.
+func (grp *srpGroup) newClient(password []byte, a *big.Int) *SRPClient {
+	p := make([]byte, len(password))
+	copy(p, password)
+
+	return &SRPClient{
+		group:    grp,
+		password: p,
+		a:        a,
+		A:        grp.pad(new(big.Int).Exp(grp.g, a, grp.N)),
+	}
+}
+
+// PublicKey returns the client's public value A for the server.
+func (c *SRPClient) PublicKey() []byte {
+	return c.A
+}
+
+// Proof computes the client proof M1 from the salt and the server's public value B.
+func (c *SRPClient) Proof(salt, serverPublic []byte) ([]byte, error) {
+	grp := c.group
+	B := new(big.Int).SetBytes(serverPublic)
+	if len(serverPublic) > grp.size || new(big.Int).Mod(B, grp.N).Sign() == 0 {
+		return nil, ErrSRPInvalidPublic
+	}
+
+	u := grp.hashInt(c.A, grp.pad(B))
+	if u.Sign() == 0 {
+		return nil, ErrSRPInvalidPublic
+	}
+
+	S := c.premasterSecret(salt, B, u)
+	key := grp.hashBytes(grp.pad(S))
+	S.SetInt64(0)
+	defer secure.Shred(key)
+
+	m1 := grp.clientProof(salt, c.A, grp.pad(B), key)
+	c.m2 = grp.hashBytes(c.A, m1, key)
+
+	return m1, nil
+}
+
+// premasterSecret computes S = (B - k*g^x)^(a + u*x) mod N.
+func (c *SRPClient) premasterSecret(salt []byte, B, u *big.Int) *big.Int {
+	grp := c.group
+
+	x := grp.x(salt, c.password)
+	defer x.SetInt64(0)
+
+	base := new(big.Int).Exp(grp.g, x, grp.N)
+	base.Mul(base, grp.k)
+	base.Sub(B, base)
+	base.Mod(base, grp.N)
+
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, c.a)
+	defer exp.SetInt64(0)
+
+	return base.Exp(base, exp, grp.N)
+}
+
+// VerifyServer checks the server proof M2 in constant time.
+func (c *SRPClient) VerifyServer(serverProof []byte) bool {
+	return c.m2 != nil && ConstantTimeCompare(c.m2, serverProof)
+}
+
+// Destroy wipes the client secrets.
+func (c *SRPClient) Destroy() {
+	secure.Shred(c.password)
+	c.a.SetInt64(0)
+	secure.Shred(c.m2)
+}
+
+// x computes x = H(salt | H(I ":" password)).
+func (grp *srpGroup) x(salt, password []byte) *big.Int {
+	inner := grp.hashBytes([]byte(grp.identity+":"), password)
+	defer secure.Shred(inner)
+	return grp.hashInt(salt, inner)
+}
+
+// clientProof computes M1 = H(H(N) xor H(g) | H(I) | salt | A | B | K).
+func (grp *srpGroup) clientProof(salt, A, B, key []byte) []byte {
+	hn := grp.hashBytes(grp.N.Bytes())
+	hg := grp.hashBytes(grp.pad(grp.g))
+	for i := range hn {
+		hn[i] ^= hg[i]
+	}
+	return grp.hashBytes(hn, grp.hashBytes([]byte(grp.identity)), salt, A, B, key)
+}
+
+// srpEphemeral returns a random secret exponent.
+func srpEphemeral() (*big.Int, error) {
+	data, err := RandomBytesRaw(srpEphemeralBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer secure.Shred(data)
+	return new(big.Int).SetBytes(data), nil
+}
+
+// pad returns n as a big-endian value padded to the group size.
+func (grp *srpGroup) pad(n *big.Int) []byte {
+	return n.FillBytes(make([]byte, grp.size))
+}
+
+// hashBytes returns the group hash over the concatenated parts.
+func (grp *srpGroup) hashBytes(parts ...[]byte) []byte {
+	h := grp.hash()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// hashInt returns the group hash over the concatenated parts as an integer.
+func (grp *srpGroup) hashInt(parts ...[]byte) *big.Int {
+	return new(big.Int).SetBytes(grp.hashBytes(parts...))
+}
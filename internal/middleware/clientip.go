@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPKey is the context key for the client IP resolved by ClientIPResolver.
+const ClientIPKey ContextKey = "clientIP"
+
+// ClientIPResolver creates middleware that resolves the client IP once per
+// request for rate limiting, unlock backoff and logging.
+// Forwarding headers are honoured only from a trusted proxy: trusted lists
+// proxy IPs or CIDRs (TRUSTED_PROXIES). Invalid entries are logged and ignored.
+// Without trusted proxies the client IP is the connection's remote address,
+// which a client cannot choose.
+func ClientIPResolver(trusted []string) func(http.Handler) http.Handler {
+	proxies := parseTrustedProxies(trusted)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := forwardedIP(r, proxies)
+			ctx := context.WithValue(r.Context(), ClientIPKey, ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the client IP of the request: the one resolved by
+// ClientIPResolver, or the remote address.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(ClientIPKey).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// forwardedIP returns the client IP, following forwarding headers through
+// trusted proxies only.
+func forwardedIP(r *http.Request, proxies []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !isTrustedProxy(ip, proxies) {
+		return ip
+	}
+
+	// The nearest hop not run by us is the client; hops further left were
+	// added by whoever sent the request and prove nothing
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			ip = hop
+			if !isTrustedProxy(hop, proxies) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(xri) != nil {
+		return xri
+	}
+
+	return ip
+}
+
+// remoteIP returns the IP of the connection's remote address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return strings.Trim(r.RemoteAddr, "[]")
+	}
+	return host
+}
+
+// isTrustedProxy reports whether ip is one of the trusted proxies.
+func isTrustedProxy(ip string, proxies []*net.IPNet) bool {
+	if len(proxies) == 0 {
+		return false
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range proxies {
+		if proxy.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses proxy IPs and CIDRs.
+func parseTrustedProxies(trusted []string) []*net.IPNet {
+	var proxies []*net.IPNet
+	for _, entry := range trusted {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				log.Printf("Ignoring invalid trusted proxy %q", entry)
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted proxy %q", entry)
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies
+}
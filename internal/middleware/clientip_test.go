@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		xff        string
+		xri        string
+		want       string
+	}{
+		{"remote address", nil, "203.0.113.7:4242", "", "", "203.0.113.7"},
+		{"IPv6 remote address", nil, "[2001:db8::1]:4242", "", "", "2001:db8::1"},
+		{"spoofed X-Forwarded-For without trusted proxies", nil, "203.0.113.7:4242", "198.51.100.1", "", "203.0.113.7"},
+		{"spoofed X-Real-IP without trusted proxies", nil, "203.0.113.7:4242", "", "198.51.100.1", "203.0.113.7"},
+		{"X-Forwarded-For from an untrusted address", []string{"10.0.0.1"}, "203.0.113.7:4242", "198.51.100.1", "", "203.0.113.7"},
+		{"X-Forwarded-For from a trusted proxy", []string{"10.0.0.1"}, "10.0.0.1:4242", "198.51.100.1", "", "198.51.100.1"},
+		{"X-Real-IP from a trusted proxy", []string{"10.0.0.0/8"}, "10.1.2.3:4242", "", "198.51.100.1", "198.51.100.1"},
+		{"hops added by the client are ignored", []string{"10.0.0.1"}, "10.0.0.1:4242", "192.0.2.9, 198.51.100.1", "", "198.51.100.1"},
+		{"chained trusted proxies", []string{"10.0.0.0/8"}, "10.0.0.1:4242", "198.51.100.1, 10.0.0.2", "", "198.51.100.1"},
+		{"invalid hop", []string{"10.0.0.1"}, "10.0.0.1:4242", "junk", "", "10.0.0.1"},
+		{"invalid trusted proxies are ignored", []string{"not-an-ip"}, "203.0.113.7:4242", "198.51.100.1", "", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ClientIPResolver(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xri != "" {
+				req.Header.Set("X-Real-IP", tt.xri)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package middleware
 
 import (
+	"bufio"
 	"log"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -37,6 +39,17 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap returns the underlying ResponseWriter so http.ResponseController
+// can reach Flush and SetWriteDeadline (needed for streaming responses).
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack lets WebSocket upgrades take over the connection.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
 // Logging logs HTTP requests in a secure manner.
 // Only logs errors (4xx, 5xx) and slow requests (>5s) to reduce noise.
 // It does NOT log:
@@ -60,7 +73,7 @@ func Logging(next http.Handler) http.Handler {
 		// Only log errors (4xx, 5xx) or slow requests (>5s)
 		if wrapped.status >= 400 || duration > 5*time.Second {
 			// Get client IP (check X-Forwarded-For for reverse proxy)
-			clientIP := getClientIP(r)
+			clientIP := ClientIP(r)
 
 			// Log in a structured format
 			// SECURITY: Only log safe fields
@@ -78,35 +91,6 @@ func Logging(next http.Handler) http.Handler {
 	})
 }
 
-// getClientIP extracts the client IP address from the request.
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For (first IP in the list)
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		if len(parts) > 0 {
-			ip := strings.TrimSpace(parts[0])
-			if ip != "" {
-				return ip
-			}
-		}
-	}
-
-	// Check X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr (strip port)
-	ip := r.RemoteAddr
-	if idx := strings.LastIndex(ip, ":"); idx > 0 {
-		ip = ip[:idx]
-	}
-	// Handle IPv6 in brackets
-	ip = strings.Trim(ip, "[]")
-
-	return ip
-}
-
 // sanitizePath removes potentially sensitive information from the path.
 func sanitizePath(path string) string {
 	// Truncate very long paths
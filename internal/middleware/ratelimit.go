@@ -89,7 +89,7 @@ func (rl *RateLimiter) Allow(ip string) bool {
 // Middleware returns an HTTP middleware that applies rate limiting.
 func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getClientIP(r)
+		ip := ClientIP(r)
 
 		if !rl.Allow(ip) {
 			w.Header().Set("Retry-After", "60")
@@ -85,9 +85,9 @@ func CORS(allowedOrigins []string) func(http.Handler) http.Handler {
 				w.Header().Set("Access-Control-Allow-Origin", origin)
 				w.Header().Set("Access-Control-Allow-Credentials", "true")
 				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-Token")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Session-Token, If-Match, If-None-Match")
 				w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
-				w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+				w.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type, ETag")
 			}
 
 			// Handle preflight requests
@@ -155,36 +155,15 @@ func OriginValidation(allowedOrigins []string) func(http.Handler) http.Handler {
 				return
 			}
 
-			// Get origin from Origin header or fall back to Referer
-			origin := r.Header.Get("Origin")
-			if origin == "" {
-				// For same-origin requests, browsers may not send Origin
-				// Fall back to Referer header
-				referer := r.Header.Get("Referer")
-				if referer != "" {
-					if refURL, err := url.Parse(referer); err == nil {
-						origin = refURL.Scheme + "://" + refURL.Host
-					}
-				}
-			}
-
 			// If no origin can be determined, reject the request
 			// This handles malicious requests that strip both headers
-			if origin == "" {
+			if requestOrigin(r) == "" {
 				http.Error(w, "Origin validation failed: missing Origin header", http.StatusForbidden)
 				return
 			}
 
 			// Validate origin against allowed list
-			allowed := false
-			for _, o := range allowedOrigins {
-				if o == origin {
-					allowed = true
-					break
-				}
-			}
-
-			if !allowed {
+			if !OriginAllowed(allowedOrigins, r) {
 				http.Error(w, "Origin validation failed: origin not allowed", http.StatusForbidden)
 				return
 			}
@@ -193,3 +172,37 @@ func OriginValidation(allowedOrigins []string) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+// OriginAllowed reports whether the request's origin is in the allowed list.
+// It applies the same rules as OriginValidation (wildcard allows everything,
+// Referer is used when Origin is absent, no origin is rejected) and is used
+// directly by handlers that must check GET requests, such as WebSocket upgrades.
+func OriginAllowed(allowedOrigins []string, r *http.Request) bool {
+	origin := requestOrigin(r)
+
+	for _, o := range allowedOrigins {
+		if o == "*" {
+			return true
+		}
+		if origin != "" && o == origin {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestOrigin returns the Origin header or, for same-origin requests where
+// browsers may not send Origin, the scheme and host of the Referer header.
+func requestOrigin(r *http.Request) string {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer != "" {
+			if refURL, err := url.Parse(referer); err == nil {
+				origin = refURL.Scheme + "://" + refURL.Host
+			}
+		}
+	}
+	return origin
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/fileez/fileez/internal/validate"
 )
@@ -12,7 +13,8 @@ import (
 // This allows the middleware to check lock status without importing the store package.
 type SessionChecker interface {
 	IsLocked() bool
-	GetToken() string
+	ValidateToken(token string) bool // Counts as activity of the token's device
+	CheckToken(token string) bool    // Does not count as activity
 }
 
 // ContextKey is a type for context keys to avoid collisions.
@@ -23,11 +25,22 @@ const (
 	SessionTokenKey ContextKey = "sessionToken"
 )
 
+const (
+	// WebSocketProtocol is the subprotocol the server selects for WebSocket connections.
+	WebSocketProtocol = "fileez"
+	// WebSocketTokenPrefix marks the subprotocol carrying the session token,
+	// since browsers cannot set headers on WebSocket requests.
+	WebSocketTokenPrefix = "fileez.token."
+)
+
 // SessionExtractor extracts and validates the session token from requests.
 // The token can be provided in:
 // 1. X-Session-Token header
 // 2. Authorization header (Bearer token)
-// 3. session_token query parameter
+// 3. Sec-WebSocket-Protocol header as "fileez.token.<token>" (WebSocket upgrades)
+//
+// Tokens are never read from the URL, where they would end up in browser
+// history and proxy logs.
 //
 // The validated token is stored in the request context.
 func SessionExtractor(next http.Handler) http.Handler {
@@ -45,9 +58,9 @@ func SessionExtractor(next http.Handler) http.Handler {
 			}
 		}
 
-		// Try query parameter (least preferred)
+		// Try WebSocket subprotocols
 		if token == "" {
-			token = r.URL.Query().Get("session_token")
+			token = webSocketToken(r)
 		}
 
 		// Validate token if present
@@ -65,6 +78,19 @@ func SessionExtractor(next http.Handler) http.Handler {
 	})
 }
 
+// webSocketToken returns the token offered as a WebSocket subprotocol, if any.
+func webSocketToken(r *http.Request) string {
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, protocol := range strings.Split(header, ",") {
+			protocol = strings.TrimSpace(protocol)
+			if strings.HasPrefix(protocol, WebSocketTokenPrefix) {
+				return strings.TrimPrefix(protocol, WebSocketTokenPrefix)
+			}
+		}
+	}
+	return ""
+}
+
 // GetSessionToken retrieves the session token from the request context.
 // Returns empty string if no valid token is present.
 func GetSessionToken(r *http.Request) string {
@@ -100,31 +126,53 @@ type LockedResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// WriteLocked writes the 401 response for a request without a valid token
+// for locked data.
+func WriteLocked(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(LockedResponse{
+		Locked:  true,
+		Message: "Session is locked. Please provide a valid session token.",
+	})
+}
+
+// TokenAuthorized reports whether the request may access protected data.
+// Unlocked sessions allow all requests; locked sessions require the token of
+// a device that has not been revoked.
+// Each call counts as activity for the token's idle timeout and auto-lock.
+func TokenAuthorized(checker SessionChecker, r *http.Request) bool {
+	if !checker.IsLocked() {
+		return true
+	}
+
+	providedToken := GetSessionToken(r)
+	return providedToken != "" && checker.ValidateToken(providedToken)
+}
+
+// StreamAuthorized is like TokenAuthorized but does not count as activity.
+// Long-lived streams should call this whenever the lock state may have changed,
+// so an open stream alone never keeps a token from expiring.
+func StreamAuthorized(checker SessionChecker, r *http.Request) bool {
+	if !checker.IsLocked() {
+		return true
+	}
+
+	providedToken := GetSessionToken(r)
+	return providedToken != "" && checker.CheckToken(providedToken)
+}
+
 // RequireSessionWhenLocked creates middleware that requires a valid session token when locked.
-// If the session is not locked, requests pass through freely.
-// If the session is locked, the request must have a valid session token that matches.
+// checker is the seal guarding the route's data: the whole session, or the
+// lock scope the route belongs to.
+// If it is not locked, requests pass through freely.
+// If it is locked, the request must carry a device token of that seal.
 func RequireSessionWhenLocked(checker SessionChecker) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Check if session is locked
-			if !checker.IsLocked() {
-				// Not locked - allow all requests
-				next.ServeHTTP(w, r)
-				return
-			}
-
-			// Session is locked - require valid token
-			providedToken := GetSessionToken(r)
-			expectedToken := checker.GetToken()
-
-			if providedToken == "" || providedToken != expectedToken {
+			if !TokenAuthorized(checker, r) {
 				// No token or wrong token - return locked response
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnauthorized)
-				json.NewEncoder(w).Encode(LockedResponse{
-					Locked:  true,
-					Message: "Session is locked. Please provide a valid session token.",
-				})
+				WriteLocked(w)
 				return
 			}
 
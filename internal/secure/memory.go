@@ -23,9 +23,12 @@ var (
 // MemoryTracker tracks secure memory allocations and enforces limits.
 // It provides visibility into how much secure memory is being used
 // and prevents unbounded growth.
+// A tracker created with Child also draws every allocation from its parent,
+// so a sub-budget can never exceed the global limit.
 type MemoryTracker struct {
 	allocated int64
 	limit     int64
+	parent    *MemoryTracker
 	mu        sync.RWMutex
 }
 
@@ -44,8 +47,25 @@ func NewMemoryTracker(limit int64) (*MemoryTracker, error) {
 	}, nil
 }
 
+// Child creates a tracker with its own limit that also allocates from m.
+// Use 0 for limit to share the parent's limit.
+func (m *MemoryTracker) Child(limit int64) (*MemoryTracker, error) {
+	if limit == 0 {
+		limit = m.Limit()
+	}
+	if limit < MinMemoryLimit {
+		return nil, ErrInvalidMemoryLimit
+	}
+
+	return &MemoryTracker{
+		limit:  limit,
+		parent: m,
+	}, nil
+}
+
 // Allocate attempts to reserve the given number of bytes.
-// Returns an error if the allocation would exceed the limit.
+// Returns an error if the allocation would exceed the limit
+// (or the limit of any parent tracker).
 func (m *MemoryTracker) Allocate(size int64) error {
 	if size <= 0 {
 		return nil
@@ -58,6 +78,12 @@ func (m *MemoryTracker) Allocate(size int64) error {
 		return ErrMemoryLimitExceeded
 	}
 
+	if m.parent != nil {
+		if err := m.parent.Allocate(size); err != nil {
+			return err
+		}
+	}
+
 	m.allocated += size
 	return nil
 }
@@ -72,9 +98,14 @@ func (m *MemoryTracker) Free(size int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// Only return to the parent what was actually allocated here
+	if size > m.allocated {
+		size = m.allocated
+	}
 	m.allocated -= size
-	if m.allocated < 0 {
-		m.allocated = 0
+
+	if m.parent != nil {
+		m.parent.Free(size)
 	}
 }
 
@@ -108,9 +139,14 @@ func (m *MemoryTracker) UsagePercent() float64 {
 }
 
 // Reset clears all allocations (use with caution).
+// A child tracker also returns its allocations to the parent.
 func (m *MemoryTracker) Reset() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+
+	if m.parent != nil {
+		m.parent.Free(m.allocated)
+	}
 	m.allocated = 0
 }
 
@@ -0,0 +1,50 @@
+package secure
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrStreamTooLarge indicates a stream exceeded the read limit.
+var ErrStreamTooLarge = errors.New("stream exceeds size limit")
+
+// ReadSecure reads r directly into a new SecureBuffer so the data never
+// passes through unprotected heap memory. At most limit bytes are accepted.
+// Returns the buffer and the number of bytes read (the rest of the buffer is
+// zero). The caller must Destroy the buffer.
+func ReadSecure(r io.Reader, limit int) (*SecureBuffer, int, error) {
+	if limit <= 0 || limit >= MaxBufferSize {
+		return nil, 0, ErrBufferTooLarge
+	}
+
+	// One spare byte detects streams over the limit
+	buf, err := NewSecureBuffer(limit + 1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n := 0
+	err = buf.MutableUse(func(data []byte) error {
+		var readErr error
+		n, readErr = io.ReadFull(r, data)
+		switch readErr {
+		case nil:
+			return ErrStreamTooLarge
+		case io.EOF, io.ErrUnexpectedEOF:
+			return nil
+		default:
+			return readErr
+		}
+	})
+	if err != nil {
+		buf.Destroy()
+		return nil, 0, err
+	}
+
+	if n == 0 {
+		buf.Destroy()
+		return nil, 0, ErrBufferEmpty
+	}
+
+	return buf, n, nil
+}
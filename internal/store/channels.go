@@ -0,0 +1,269 @@
+package store
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+var (
+	// ErrChannelNotFound indicates the clipboard channel does not exist.
+	ErrChannelNotFound = errors.New("channel not found")
+	// ErrChannelExists indicates a clipboard channel with that name already exists.
+	ErrChannelExists = errors.New("channel already exists")
+	// ErrTooManyChannels indicates the channel limit has been reached.
+	ErrTooManyChannels = errors.New("too many channels")
+	// ErrInvalidChannelExpiry indicates the requested channel expiry is out of range.
+	ErrInvalidChannelExpiry = errors.New("channel expiry out of range")
+	// ErrChannelsHaveData indicates a seal or re-key while channels hold content,
+	// which is not part of the lock payload.
+	ErrChannelsHaveData = errors.New("channels hold content")
+)
+
+const (
+	// DefaultMaxChannels is the default maximum number of clipboard channels.
+	DefaultMaxChannels = 16
+	// MinChannelExpiry is the shortest content expiry a channel may use.
+	MinChannelExpiry = 1 * time.Minute
+	// MaxChannelExpiry is the longest content expiry a channel may use.
+	MaxChannelExpiry = 24 * time.Hour
+)
+
+// Channel is a named clipboard with its own content, expiry and optional image slot.
+type Channel struct {
+	Name      string
+	ImageSlot bool
+	Expiry    time.Duration
+	CreatedAt time.Time
+	Clipboard *ClipboardStore
+}
+
+// ChannelInfo contains channel metadata for API responses.
+type ChannelInfo struct {
+	Name          string         `json:"name"`
+	ImageSlot     bool           `json:"image_slot"`
+	ExpirySeconds int64          `json:"expiry_seconds"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Text          ClipboardInfo  `json:"text"`
+	Image         *ClipboardInfo `json:"image,omitempty"`
+}
+
+// ChannelStore manages named clipboard channels.
+// All channels share a memory budget that is itself drawn from the global tracker.
+type ChannelStore struct {
+	mu sync.RWMutex
+
+	channels map[string]*Channel
+
+	// Configuration
+	maxChannels     int
+	expiry          time.Duration
+	historySize     int
+	historyMaxBytes int64
+
+	// Session manager for encryption key
+	session *SessionManager
+
+	// Memory tracker shared by all channels
+	memory *secure.MemoryTracker
+
+	// Change notifications
+	events *EventBus
+}
+
+// NewChannelStore creates a new channel store.
+// memory should be a child tracker so channels are capped as a whole.
+// expiry is the default content expiry for new channels.
+func NewChannelStore(session *SessionManager, memory *secure.MemoryTracker, maxChannels int, expiry time.Duration, historySize int, historyMaxBytes int64) *ChannelStore {
+	if maxChannels <= 0 {
+		maxChannels = DefaultMaxChannels
+	}
+	if expiry == 0 {
+		expiry = 1 * time.Hour
+	}
+
+	return &ChannelStore{
+		channels:        make(map[string]*Channel),
+		maxChannels:     maxChannels,
+		expiry:          expiry,
+		historySize:     historySize,
+		historyMaxBytes: historyMaxBytes,
+		session:         session,
+		memory:          memory,
+	}
+}
+
+// SetEventBus sets the bus that receives change events for all channels.
+func (cs *ChannelStore) SetEventBus(events *EventBus) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.events = events
+	for name, channel := range cs.channels {
+		channel.Clipboard.SetEventBus(events, name)
+	}
+}
+
+// Create creates a new channel.
+// A zero expiry uses the store default; otherwise it must be within
+// MinChannelExpiry and MaxChannelExpiry.
+func (cs *ChannelStore) Create(name string, imageSlot bool, expiry time.Duration) (*Channel, error) {
+	name, err := validate.ChannelName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiry == 0 {
+		expiry = cs.expiry
+	} else if expiry < MinChannelExpiry || expiry > MaxChannelExpiry {
+		return nil, ErrInvalidChannelExpiry
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, exists := cs.channels[name]; exists {
+		return nil, ErrChannelExists
+	}
+
+	if len(cs.channels) >= cs.maxChannels {
+		return nil, ErrTooManyChannels
+	}
+
+	channel := &Channel{
+		Name:      name,
+		ImageSlot: imageSlot,
+		Expiry:    expiry,
+		CreatedAt: time.Now(),
+		Clipboard: NewClipboardStore(cs.session, cs.memory, expiry, cs.historySize, cs.historyMaxBytes),
+	}
+	channel.Clipboard.SetEventBus(cs.events, name)
+	cs.channels[name] = channel
+
+	return channel, nil
+}
+
+// Get returns the channel with the given name.
+func (cs *ChannelStore) Get(name string) (*Channel, error) {
+	name, err := validate.ChannelName(name)
+	if err != nil {
+		return nil, ErrChannelNotFound
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	channel, exists := cs.channels[name]
+	if !exists {
+		return nil, ErrChannelNotFound
+	}
+
+	return channel, nil
+}
+
+// Delete shreds a channel's content and removes it.
+func (cs *ChannelStore) Delete(name string) error {
+	name, err := validate.ChannelName(name)
+	if err != nil {
+		return ErrChannelNotFound
+	}
+
+	cs.mu.Lock()
+	channel, exists := cs.channels[name]
+	if !exists {
+		cs.mu.Unlock()
+		return ErrChannelNotFound
+	}
+	delete(cs.channels, name)
+	events := cs.events
+	cs.mu.Unlock()
+
+	// Stops the expiry loop and shreds all content
+	channel.Clipboard.Close()
+
+	events.Publish(Event{Type: EventChannelDeleted, Channel: name})
+
+	return nil
+}
+
+// List returns metadata for all channels, sorted by name.
+func (cs *ChannelStore) List() []ChannelInfo {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	channels := make([]ChannelInfo, 0, len(cs.channels))
+	for _, channel := range cs.channels {
+		channels = append(channels, channel.Info())
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return channels
+}
+
+// Info returns metadata about the channel and its current content.
+func (c *Channel) Info() ChannelInfo {
+	info := ChannelInfo{
+		Name:          c.Name,
+		ImageSlot:     c.ImageSlot,
+		ExpirySeconds: int64(c.Expiry / time.Second),
+		CreatedAt:     c.CreatedAt,
+		Text:          c.Clipboard.TextInfo(),
+	}
+
+	if c.ImageSlot {
+		image := c.Clipboard.ImageInfo()
+		info.Image = &image
+	}
+
+	return info
+}
+
+// Count returns the number of channels.
+func (cs *ChannelStore) Count() int {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return len(cs.channels)
+}
+
+// HasData returns whether any channel holds live content.
+func (cs *ChannelStore) HasData() bool {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for _, channel := range cs.channels {
+		if channel.Clipboard.HasText() || channel.Clipboard.HasImage() {
+			return true
+		}
+	}
+	return false
+}
+
+// ShredAll securely destroys the content of every channel.
+// The channels themselves are kept so clients can continue using them.
+func (cs *ChannelStore) ShredAll() {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for _, channel := range cs.channels {
+		channel.Clipboard.ShredAll()
+	}
+}
+
+// Close shreds and removes all channels.
+// Should be called on application shutdown.
+func (cs *ChannelStore) Close() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for name, channel := range cs.channels {
+		channel.Clipboard.Close()
+		delete(cs.channels, name)
+	}
+}
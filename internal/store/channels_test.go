@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/secure"
+)
+
+func TestChannelCreateRules(t *testing.T) {
+	cs := NewChannelStore(nil, nil, 2, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.Create("notes", false, 0); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := cs.Create("notes", false, 0); err != ErrChannelExists {
+		t.Errorf("duplicate name: got %v, want %v", err, ErrChannelExists)
+	}
+	if _, err := cs.Create("short", false, time.Second); err != ErrInvalidChannelExpiry {
+		t.Errorf("expiry below the minimum: got %v, want %v", err, ErrInvalidChannelExpiry)
+	}
+	if _, err := cs.Create("long", false, MaxChannelExpiry+time.Hour); err != ErrInvalidChannelExpiry {
+		t.Errorf("expiry above the maximum: got %v, want %v", err, ErrInvalidChannelExpiry)
+	}
+	if _, err := cs.Create("../etc", false, 0); err == nil {
+		t.Error("invalid name accepted")
+	}
+	if _, err := cs.Create("links", true, 0); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := cs.Create("third", false, 0); err != ErrTooManyChannels {
+		t.Errorf("channel over the limit: got %v, want %v", err, ErrTooManyChannels)
+	}
+}
+
+func TestChannelsAreIsolated(t *testing.T) {
+	cs := NewChannelStore(nil, nil, 0, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	notes, err := cs.Create("notes", false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	links, err := cs.Create("links", false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := notes.Clipboard.SetText([]byte("note"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if links.Clipboard.HasText() {
+		t.Error("text written to one channel is visible in another")
+	}
+
+	if err := cs.Delete("notes"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := cs.Get("notes"); err != ErrChannelNotFound {
+		t.Errorf("Get after Delete: got %v, want %v", err, ErrChannelNotFound)
+	}
+	if cs.HasData() {
+		t.Error("deleted channel content still reported")
+	}
+}
+
+func TestChannelsShareMemoryBudget(t *testing.T) {
+	global, err := secure.NewMemoryTracker(64 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	memory, err := global.Child(secure.MinMemoryLimit)
+	if err != nil {
+		t.Fatalf("Child: %v", err)
+	}
+	cs := NewChannelStore(nil, memory, 0, 0, 0, 2*secure.MinMemoryLimit)
+	t.Cleanup(cs.Close)
+
+	first, err := cs.Create("first", false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	second, err := cs.Create("second", false, 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// SetText shreds its input, so each write gets its own copy
+	half := func() []byte { return bytes.Repeat([]byte{'a'}, secure.MinMemoryLimit/2+1) }
+	if _, err := first.Clipboard.SetText(half(), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := second.Clipboard.SetText(half(), AnyVersion, EntryOptions{}); err == nil {
+		t.Error("channels exceeded their shared memory budget")
+	}
+
+	// Deleting a channel returns its memory to the budget and the global tracker
+	if err := cs.Delete("first"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if n := global.Allocated(); n != 0 {
+		t.Errorf("global tracker holds %d bytes after the channel was deleted, want 0", n)
+	}
+
+	// A writer still holding the deleted channel can no longer store content
+	if _, err := first.Clipboard.SetText(half(), AnyVersion, EntryOptions{}); err != ErrClipboardClosed {
+		t.Errorf("SetText on a deleted channel: got %v, want %v", err, ErrClipboardClosed)
+	}
+	if n := global.Allocated(); n != 0 {
+		t.Errorf("global tracker holds %d bytes after writing to a deleted channel, want 0", n)
+	}
+	if _, err := second.Clipboard.SetText(half(), AnyVersion, EntryOptions{}); err != nil {
+		t.Errorf("SetText after freeing the budget: %v", err)
+	}
+}
+
+func TestChannelDeleteIsPublished(t *testing.T) {
+	cs := NewChannelStore(nil, nil, 0, 0, 0, 0)
+	t.Cleanup(cs.Close)
+	bus := NewEventBus()
+	cs.SetEventBus(bus)
+
+	if _, err := cs.Create("notes", false, 0); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	if err := cs.Delete("notes"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// Publishing is synchronous, so the event is already buffered
+	for {
+		select {
+		case event := <-events:
+			if event.Type != EventChannelDeleted {
+				continue
+			}
+			if event.Channel != "notes" {
+				t.Errorf("channel.deleted for %q, want %q", event.Channel, "notes")
+			}
+			return
+		default:
+			t.Fatal("no channel.deleted event published")
+		}
+	}
+}
@@ -5,7 +5,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/fileez/fileez/internal/crypto"
 	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
 )
 
 var (
@@ -13,6 +15,24 @@ var (
 	ErrClipboardEmpty = errors.New("clipboard is empty")
 	// ErrClipboardExpired indicates the clipboard content has expired.
 	ErrClipboardExpired = errors.New("clipboard content expired")
+	// ErrClipboardEntryNotFound indicates the history entry does not exist.
+	ErrClipboardEntryNotFound = errors.New("clipboard entry not found")
+	// ErrClipboardClosed indicates the clipboard was closed, e.g. its channel was deleted.
+	ErrClipboardClosed = errors.New("clipboard closed")
+	// ErrRepresentationNotFound indicates the entry has no representation of the requested MIME type.
+	ErrRepresentationNotFound = errors.New("clipboard representation not found")
+	// ErrPinLimit indicates pinned entries would no longer fit within the history limits.
+	ErrPinLimit = errors.New("pinned clipboard entries exceed the history limits")
+)
+
+const (
+	// clipboardExpiryInterval is the longest time between expiry checks.
+	clipboardExpiryInterval = 1 * time.Minute
+
+	// DefaultClipboardHistorySize is the default number of history entries kept.
+	DefaultClipboardHistorySize = 20
+	// DefaultClipboardHistoryMaxBytes is the default total size of history entries (16MB).
+	DefaultClipboardHistoryMaxBytes = 16 * 1024 * 1024
 )
 
 // ClipboardType represents the type of clipboard content.
@@ -25,31 +45,86 @@ const (
 	ClipboardTypeImage
 )
 
+// String returns the API name of the clipboard type.
+func (t ClipboardType) String() string {
+	if t == ClipboardTypeImage {
+		return "image"
+	}
+	return "text"
+}
+
+// Representation is one MIME flavour of a clipboard text entry
+// (text/plain, text/html, text/uri-list, file references).
+type Representation struct {
+	MimeType string
+	Data     []byte
+}
+
+// clipboardRepresentation is an additional flavour of a text entry.
+type clipboardRepresentation struct {
+	mimeType string
+	data     *secure.FortifiedBuffer
+}
+
 // ClipboardEntry represents a single clipboard entry.
 type ClipboardEntry struct {
 	mu sync.RWMutex
 
+	// Identifier within the clipboard history
+	id string
+
+	// Store version at which the entry was added
+	version uint64
+
 	// Content (either plaintext or encrypted)
 	data      *secure.FortifiedBuffer // Plaintext when unlocked (with memory obfuscation)
 	encrypted []byte                  // Ciphertext when locked
 
+	// Additional representations of a text entry, each in its own buffer.
+	// data always holds the text/plain representation.
+	representations []*clipboardRepresentation
+
 	// Metadata
 	contentType ClipboardType
 	mimeType    string // For images: "image/png", "image/jpeg", etc.
-	size        int
+	size        int    // Total size of all representations
 	createdAt   time.Time
 	expiresAt   time.Time
+	ttl         time.Duration // Lifetime requested for the entry
+
+	// Pinned entries are never evicted from the history ring and never expire
+	pinned bool
+}
+
+// expired returns whether the entry has outlived its TTL (never, while pinned).
+// Caller must hold entry.mu (or the exclusive store lock).
+func (e *ClipboardEntry) expired(now time.Time) bool {
+	return !e.pinned && now.After(e.expiresAt)
+}
+
+// EntryOptions controls the lifetime of a new clipboard entry.
+type EntryOptions struct {
+	TTL    time.Duration // Zero uses the store's default expiry
+	Pinned bool          // Exempt from expiry and history eviction (not from deletion)
 }
 
 // ClipboardStore manages secure clipboard storage.
+// Text and image entries share a bounded history ring (oldest first).
+// The newest entry of each type is the "current" clipboard content.
 type ClipboardStore struct {
 	mu sync.RWMutex
 
-	text  *ClipboardEntry
-	image *ClipboardEntry
+	history []*ClipboardEntry
+
+	// Versions: version increases on every change; slotVersions records the
+	// version at which the current text/image last changed (ETag source)
+	version      uint64
+	slotVersions map[ClipboardType]uint64
 
 	// Configuration
-	expiry time.Duration
+	expiry          time.Duration
+	historySize     int
+	historyMaxBytes int64
 
 	// Session manager for encryption key
 	session *SessionManager
@@ -57,21 +132,41 @@ type ClipboardStore struct {
 	// Memory tracker
 	memory *secure.MemoryTracker
 
-	// Shutdown signal
-	done chan struct{}
+	// Change notifications (channel is "" for the default clipboard)
+	events  *EventBus
+	channel string
+
+	// Wakes the expiry loop early when an entry is due sooner
+	wake chan struct{}
+
+	// Shutdown signal; closed is set under mu so no write lands after Close
+	done   chan struct{}
+	closed bool
 }
 
 // NewClipboardStore creates a new clipboard store.
-func NewClipboardStore(session *SessionManager, memory *secure.MemoryTracker, expiry time.Duration) *ClipboardStore {
+// historySize and historyMaxBytes bound the history ring by entry count and
+// total content size; zero values select the defaults.
+func NewClipboardStore(session *SessionManager, memory *secure.MemoryTracker, expiry time.Duration, historySize int, historyMaxBytes int64) *ClipboardStore {
 	if expiry == 0 {
 		expiry = 1 * time.Hour
 	}
+	if historySize <= 0 {
+		historySize = DefaultClipboardHistorySize
+	}
+	if historyMaxBytes <= 0 {
+		historyMaxBytes = DefaultClipboardHistoryMaxBytes
+	}
 
 	store := &ClipboardStore{
-		expiry:  expiry,
-		session: session,
-		memory:  memory,
-		done:    make(chan struct{}),
+		expiry:          expiry,
+		historySize:     historySize,
+		historyMaxBytes: historyMaxBytes,
+		slotVersions:    make(map[ClipboardType]uint64),
+		session:         session,
+		memory:          memory,
+		wake:            make(chan struct{}, 1),
+		done:            make(chan struct{}),
 	}
 
 	// Start expiry checker
@@ -80,17 +175,72 @@ func NewClipboardStore(session *SessionManager, memory *secure.MemoryTracker, ex
 	return store
 }
 
+// SetEventBus sets the bus that receives change events for this clipboard.
+// channel names the clipboard channel in events ("" for the default clipboard).
+func (cs *ClipboardStore) SetEventBus(events *EventBus, channel string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.events = events
+	cs.channel = channel
+}
+
+// publish sends a clipboard event for an entry type.
+// Caller must hold cs.mu.
+func (cs *ClipboardStore) publish(eventType EventType, contentType ClipboardType, id string) {
+	cs.events.Publish(Event{
+		Type:    eventType,
+		Kind:    contentType.String(),
+		Channel: cs.channel,
+		ID:      id,
+	})
+}
+
+// Version returns the store version, which changes whenever any history entry changes.
+func (cs *ClipboardStore) Version() uint64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.version
+}
+
+// TextVersion returns the version of the current text.
+// Read it before the content so a concurrent update yields a stale (safe) version.
+func (cs *ClipboardStore) TextVersion() uint64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.slotVersions[ClipboardTypeText]
+}
+
+// ImageVersion returns the version of the current image.
+func (cs *ClipboardStore) ImageVersion() uint64 {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.slotVersions[ClipboardTypeImage]
+}
+
+// bumpVersion advances the store version and marks the given slots changed.
+// Caller must hold cs.mu exclusively.
+func (cs *ClipboardStore) bumpVersion(changed ...ClipboardType) uint64 {
+	cs.version++
+	for _, contentType := range changed {
+		cs.slotVersions[contentType] = cs.version
+	}
+	return cs.version
+}
+
 // SetText stores text content in the clipboard (plaintext in SecureBuffer).
+// The new entry becomes the current text; older entries stay in the history.
 // E2EE: This is only called when session is unlocked. When locked, encrypted
 // blobs are stored via SetEncryptedText.
+// ifVersion makes the update conditional on the current text version
+// (AnyVersion to skip the check). Returns the new text version.
+// opts sets the entry's TTL and pin flag.
 // WARNING: The content slice is always shredded after this call, even on error.
 // Caller should not reuse the slice.
-func (cs *ClipboardStore) SetText(content []byte) error {
+func (cs *ClipboardStore) SetText(content []byte, ifVersion uint64, opts EntryOptions) (uint64, error) {
 	// Always shred input when done, regardless of success/failure
 	defer secure.Shred(content)
 
-	contentLen := len(content)
-
 	// Pre-create the new entry BEFORE acquiring lock to minimize lock hold time
 	now := time.Now()
 
@@ -98,7 +248,7 @@ func (cs *ClipboardStore) SetText(content []byte) error {
 	// Uses scatter + obfuscation + tripwire for memory protection
 	buf, err := secure.NewFortifiedBuffer(content)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	newEntry := &ClipboardEntry{
@@ -106,37 +256,87 @@ func (cs *ClipboardStore) SetText(content []byte) error {
 		contentType: ClipboardTypeText,
 		size:        buf.Size(),
 		createdAt:   now,
-		expiresAt:   now.Add(cs.expiry),
 	}
 
-	// Now acquire lock briefly to swap entries
-	cs.mu.Lock()
+	return cs.addEntry(newEntry, ifVersion, opts)
+}
 
-	// Check memory limit
-	if cs.memory != nil {
-		if err := cs.memory.Allocate(int64(contentLen)); err != nil {
-			cs.mu.Unlock()
-			// Clean up the new entry we created
-			if newEntry.data != nil {
-				newEntry.data.Destroy()
-			}
-			return err
+// SetRepresentations stores a text entry carrying several MIME representations.
+// The text/plain representation is required and becomes the entry's text; the
+// others are kept alongside it, each in its own FortifiedBuffer.
+// Representations must already be validated (see validate.Representation).
+// ifVersion makes the update conditional on the current text version
+// (AnyVersion to skip the check). Returns the new text version.
+// opts sets the entry's TTL and pin flag.
+// WARNING: All representation data is always shredded after this call, even on error.
+func (cs *ClipboardStore) SetRepresentations(reps []Representation, ifVersion uint64, opts EntryOptions) (uint64, error) {
+	// Always shred input when done, regardless of success/failure
+	defer func() {
+		for _, rep := range reps {
+			secure.Shred(rep.Data)
 		}
+	}()
+
+	now := time.Now()
+	newEntry := &ClipboardEntry{
+		contentType: ClipboardTypeText,
+		createdAt:   now,
 	}
 
-	// Swap entries - grab old entry for deferred shredding
-	oldEntry := cs.text
-	cs.text = newEntry
+	for _, rep := range reps {
+		if rep.MimeType == validate.MIMETextPlain && newEntry.data != nil {
+			discardEntry(newEntry)
+			return 0, validate.ErrRepresentationDuplicate
+		}
+
+		buf, err := secure.NewFortifiedBuffer(rep.Data)
+		if err != nil {
+			discardEntry(newEntry)
+			return 0, err
+		}
+		newEntry.size += buf.Size()
 
-	cs.mu.Unlock()
+		if rep.MimeType == validate.MIMETextPlain {
+			newEntry.data = buf
+			continue
+		}
+		newEntry.representations = append(newEntry.representations, &clipboardRepresentation{
+			mimeType: rep.MimeType,
+			data:     buf,
+		})
+	}
 
-	// Shred old entry OUTSIDE the lock to avoid blocking other operations
-	// This is safe because we've already removed it from the store
-	if oldEntry != nil {
-		cs.shredEntryAsync(oldEntry)
+	if newEntry.data == nil {
+		discardEntry(newEntry)
+		return 0, validate.ErrRepresentationMissingText
 	}
 
-	return nil
+	return cs.addEntry(newEntry, ifVersion, opts)
+}
+
+// GetTextRepresentation retrieves one representation of the current text.
+// text/plain is the text itself.
+// E2EE: Representations are part of the encrypted blob when locked.
+func (cs *ClipboardStore) GetTextRepresentation(mimeType string) ([]byte, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	text := cs.current(ClipboardTypeText)
+	if text == nil {
+		return nil, ErrClipboardEmpty
+	}
+
+	text.mu.RLock()
+	defer text.mu.RUnlock()
+
+	if text.expired(time.Now()) {
+		return nil, ErrClipboardExpired
+	}
+	if text.data == nil {
+		return nil, ErrClipboardEmpty
+	}
+
+	return copyRepresentation(text, mimeType)
 }
 
 // GetText retrieves text content from the clipboard (plaintext from SecureBuffer).
@@ -146,50 +346,42 @@ func (cs *ClipboardStore) GetText() ([]byte, error) {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	if cs.text == nil {
+	text := cs.current(ClipboardTypeText)
+	if text == nil {
 		return nil, ErrClipboardEmpty
 	}
 
 	// Lock the entry to prevent concurrent shredding
-	cs.text.mu.RLock()
-	defer cs.text.mu.RUnlock()
+	text.mu.RLock()
+	defer text.mu.RUnlock()
 
 	// Check expiry
-	if time.Now().After(cs.text.expiresAt) {
+	if text.expired(time.Now()) {
 		return nil, ErrClipboardExpired
 	}
 
 	// Return copy of plaintext from SecureBuffer
-	if cs.text.data == nil {
+	if text.data == nil {
 		// No plaintext data - might be encrypted (locked state)
 		return nil, ErrClipboardEmpty
 	}
 
-	var result []byte
-	err := cs.text.data.Use(func(d []byte) error {
-		result = make([]byte, len(d))
-		copy(result, d)
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	return result, nil
+	return copyEntryData(text)
 }
 
 // SetImage stores image content in the clipboard (plaintext in SecureBuffer).
+// The new entry becomes the current image; older entries stay in the history.
 // E2EE: This is only called when session is unlocked. When locked, encrypted
 // blobs are stored via SetEncryptedImage.
+// ifVersion makes the update conditional on the current image version
+// (AnyVersion to skip the check). Returns the new image version.
+// opts sets the entry's TTL and pin flag.
 // WARNING: The content slice is always shredded after this call, even on error.
 // Caller should not reuse the slice.
-func (cs *ClipboardStore) SetImage(content []byte, mimeType string) error {
+func (cs *ClipboardStore) SetImage(content []byte, mimeType string, ifVersion uint64, opts EntryOptions) (uint64, error) {
 	// Always shred input when done, regardless of success/failure
 	defer secure.Shred(content)
 
-	contentLen := len(content)
-
 	// Pre-create the new entry BEFORE acquiring lock to minimize lock hold time
 	now := time.Now()
 
@@ -197,7 +389,7 @@ func (cs *ClipboardStore) SetImage(content []byte, mimeType string) error {
 	// Uses scatter + obfuscation + tripwire for memory protection
 	buf, err := secure.NewFortifiedBuffer(content)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
 	newEntry := &ClipboardEntry{
@@ -206,36 +398,9 @@ func (cs *ClipboardStore) SetImage(content []byte, mimeType string) error {
 		mimeType:    mimeType,
 		size:        buf.Size(),
 		createdAt:   now,
-		expiresAt:   now.Add(cs.expiry),
-	}
-
-	// Now acquire lock briefly to swap entries
-	cs.mu.Lock()
-
-	// Check memory limit
-	if cs.memory != nil {
-		if err := cs.memory.Allocate(int64(contentLen)); err != nil {
-			cs.mu.Unlock()
-			// Clean up the new entry we created
-			if newEntry.data != nil {
-				newEntry.data.Destroy()
-			}
-			return err
-		}
 	}
 
-	// Swap entries - grab old entry for deferred shredding
-	oldEntry := cs.image
-	cs.image = newEntry
-
-	cs.mu.Unlock()
-
-	// Shred old entry OUTSIDE the lock to avoid blocking other operations
-	if oldEntry != nil {
-		cs.shredEntryAsync(oldEntry)
-	}
-
-	return nil
+	return cs.addEntry(newEntry, ifVersion, opts)
 }
 
 // GetImage retrieves image content from the clipboard (plaintext from SecureBuffer).
@@ -245,34 +410,29 @@ func (cs *ClipboardStore) GetImage() ([]byte, string, error) {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	if cs.image == nil {
+	image := cs.current(ClipboardTypeImage)
+	if image == nil {
 		return nil, "", ErrClipboardEmpty
 	}
 
 	// Lock the entry to prevent concurrent shredding
-	cs.image.mu.RLock()
-	defer cs.image.mu.RUnlock()
+	image.mu.RLock()
+	defer image.mu.RUnlock()
 
 	// Check expiry
-	if time.Now().After(cs.image.expiresAt) {
+	if image.expired(time.Now()) {
 		return nil, "", ErrClipboardExpired
 	}
 
-	mimeType := cs.image.mimeType
+	mimeType := image.mimeType
 
 	// Return copy of plaintext from SecureBuffer
-	if cs.image.data == nil {
+	if image.data == nil {
 		// No plaintext data - might be encrypted (locked state)
 		return nil, "", ErrClipboardEmpty
 	}
 
-	var result []byte
-	err := cs.image.data.Use(func(d []byte) error {
-		result = make([]byte, len(d))
-		copy(result, d)
-		return nil
-	})
-
+	result, err := copyEntryData(image)
 	if err != nil {
 		return nil, "", err
 	}
@@ -280,117 +440,613 @@ func (cs *ClipboardStore) GetImage() ([]byte, string, error) {
 	return result, mimeType, nil
 }
 
-// DeleteText shreds and removes text clipboard content.
-func (cs *ClipboardStore) DeleteText() {
-	cs.mu.Lock()
-	defer cs.mu.Unlock()
+// DeleteText shreds and removes the current text and all older text entries.
+// Otherwise an older entry would resurface as the current text.
+// ifVersion makes the delete conditional (AnyVersion to skip the check).
+// Returns the new text version.
+func (cs *ClipboardStore) DeleteText(ifVersion uint64) (uint64, error) {
+	return cs.deleteType(ClipboardTypeText, ifVersion)
+}
 
-	if cs.text != nil {
-		cs.shredEntry(cs.text)
-		cs.text = nil
-	}
+// DeleteImage shreds and removes the current image and all older image entries.
+// ifVersion makes the delete conditional (AnyVersion to skip the check).
+// Returns the new image version.
+func (cs *ClipboardStore) DeleteImage(ifVersion uint64) (uint64, error) {
+	return cs.deleteType(ClipboardTypeImage, ifVersion)
 }
 
-// DeleteImage shreds and removes image clipboard content.
-func (cs *ClipboardStore) DeleteImage() {
+// deleteType shreds every history entry of the given type.
+func (cs *ClipboardStore) deleteType(contentType ClipboardType, ifVersion uint64) (uint64, error) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	if cs.image != nil {
-		cs.shredEntry(cs.image)
-		cs.image = nil
+	if ifVersion != AnyVersion && ifVersion != cs.slotVersions[contentType] {
+		return cs.slotVersions[contentType], ErrVersionMismatch
 	}
-}
 
-// HasText returns whether there is text content.
-func (cs *ClipboardStore) HasText() bool {
-	cs.mu.RLock()
-	defer cs.mu.RUnlock()
+	deleted := false
+	kept := cs.history[:0]
+	for _, entry := range cs.history {
+		if entry.contentType == contentType {
+			cs.shredEntry(entry)
+			deleted = true
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	cs.truncateHistory(kept)
 
-	if cs.text == nil {
-		return false
+	if deleted {
+		cs.bumpVersion(contentType)
+		cs.publish(EventClipboardDeleted, contentType, "")
 	}
 
-	cs.text.mu.RLock()
-	defer cs.text.mu.RUnlock()
+	return cs.slotVersions[contentType], nil
+}
 
-	return !time.Now().After(cs.text.expiresAt)
+// HasText returns whether there is text content.
+func (cs *ClipboardStore) HasText() bool {
+	return cs.hasContent(ClipboardTypeText)
 }
 
 // HasImage returns whether there is image content.
 func (cs *ClipboardStore) HasImage() bool {
+	return cs.hasContent(ClipboardTypeImage)
+}
+
+// hasContent returns whether the current entry of the given type is live.
+func (cs *ClipboardStore) hasContent(contentType ClipboardType) bool {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	if cs.image == nil {
+	entry := cs.current(contentType)
+	if entry == nil {
 		return false
 	}
 
-	cs.image.mu.RLock()
-	defer cs.image.mu.RUnlock()
+	entry.mu.RLock()
+	defer entry.mu.RUnlock()
 
-	return !time.Now().After(cs.image.expiresAt)
+	return !entry.expired(time.Now())
 }
 
-// TextInfo returns metadata about text content without returning the content.
+// ClipboardInfo contains clipboard entry metadata without the content.
 type ClipboardInfo struct {
-	HasContent bool      `json:"has_content"`
-	Size       int       `json:"size,omitempty"`
-	MimeType   string    `json:"mime_type,omitempty"`
-	CreatedAt  time.Time `json:"created_at,omitempty"`
-	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	ID              string    `json:"id,omitempty"`
+	Kind            string    `json:"kind,omitempty"`
+	HasContent      bool      `json:"has_content"`
+	Encrypted       bool      `json:"encrypted,omitempty"`
+	Pinned          bool      `json:"pinned,omitempty"`
+	Version         uint64    `json:"version,omitempty"` // Entry version in history; slot version for current text/image
+	Size            int       `json:"size,omitempty"`
+	MimeType        string    `json:"mime_type,omitempty"`
+	Representations []string  `json:"representations,omitempty"` // Available MIME types of a text entry
+	CreatedAt       time.Time `json:"created_at,omitempty"`
+	ExpiresAt       time.Time `json:"expires_at,omitempty"`
+	ExpiresIn       int64     `json:"expires_in,omitempty"` // Seconds until expiry (omitted while pinned)
 }
 
 // TextInfo returns information about text clipboard.
 func (cs *ClipboardStore) TextInfo() ClipboardInfo {
+	return cs.currentInfo(ClipboardTypeText)
+}
+
+// ImageInfo returns information about image clipboard.
+func (cs *ClipboardStore) ImageInfo() ClipboardInfo {
+	return cs.currentInfo(ClipboardTypeImage)
+}
+
+// currentInfo returns information about the current entry of the given type.
+func (cs *ClipboardStore) currentInfo(contentType ClipboardType) ClipboardInfo {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	if cs.text == nil {
+	entry := cs.current(contentType)
+	if entry == nil {
 		return ClipboardInfo{HasContent: false}
 	}
 
-	cs.text.mu.RLock()
-	defer cs.text.mu.RUnlock()
+	entry.mu.RLock()
+	defer entry.mu.RUnlock()
 
-	if time.Now().After(cs.text.expiresAt) {
+	if entry.expired(time.Now()) {
 		return ClipboardInfo{HasContent: false}
 	}
 
-	return ClipboardInfo{
-		HasContent: true,
-		Size:       cs.text.size,
-		MimeType:   "text/plain",
-		CreatedAt:  cs.text.createdAt,
-		ExpiresAt:  cs.text.expiresAt,
-	}
+	info := entryInfo(entry)
+	info.Version = cs.slotVersions[contentType]
+	return info
 }
 
-// ImageInfo returns information about image clipboard.
-func (cs *ClipboardStore) ImageInfo() ClipboardInfo {
+// History returns metadata for all live history entries (newest first).
+func (cs *ClipboardStore) History() []ClipboardInfo {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	if cs.image == nil {
-		return ClipboardInfo{HasContent: false}
+	now := time.Now()
+	entries := make([]ClipboardInfo, 0, len(cs.history))
+
+	for i := len(cs.history) - 1; i >= 0; i-- {
+		entry := cs.history[i]
+		entry.mu.RLock()
+		if !entry.expired(now) {
+			entries = append(entries, entryInfo(entry))
+		}
+		entry.mu.RUnlock()
 	}
 
-	cs.image.mu.RLock()
-	defer cs.image.mu.RUnlock()
+	return entries
+}
 
-	if time.Now().After(cs.image.expiresAt) {
-		return ClipboardInfo{HasContent: false}
+// GetEntry retrieves a plaintext history entry by ID.
+// E2EE: When locked, encrypted entries are retrieved via GetEncryptedEntry.
+func (cs *ClipboardStore) GetEntry(id string) (ClipboardInfo, []byte, error) {
+	entry, err := cs.lookupEntry(id)
+	if err != nil {
+		return ClipboardInfo{}, nil, err
+	}
+	defer cs.mu.RUnlock()
+	defer entry.mu.RUnlock()
+
+	if entry.data == nil {
+		// No plaintext data - might be encrypted (locked state)
+		return ClipboardInfo{}, nil, ErrClipboardEntryNotFound
+	}
+
+	content, err := copyEntryData(entry)
+	if err != nil {
+		return ClipboardInfo{}, nil, err
+	}
+
+	return entryInfo(entry), content, nil
+}
+
+// GetEntryRepresentation retrieves one representation of a plaintext text history entry.
+func (cs *ClipboardStore) GetEntryRepresentation(id string, mimeType string) (ClipboardInfo, []byte, error) {
+	entry, err := cs.lookupEntry(id)
+	if err != nil {
+		return ClipboardInfo{}, nil, err
+	}
+	defer cs.mu.RUnlock()
+	defer entry.mu.RUnlock()
+
+	if entry.data == nil {
+		return ClipboardInfo{}, nil, ErrClipboardEntryNotFound
+	}
+	if entry.contentType != ClipboardTypeText {
+		return ClipboardInfo{}, nil, ErrRepresentationNotFound
+	}
+
+	content, err := copyRepresentation(entry, mimeType)
+	if err != nil {
+		return ClipboardInfo{}, nil, err
+	}
+
+	return entryInfo(entry), content, nil
+}
+
+// EntryType returns the content type of a live history entry.
+func (cs *ClipboardStore) EntryType(id string) (ClipboardType, error) {
+	entry, err := cs.lookupEntry(id)
+	if err != nil {
+		return 0, err
+	}
+	defer cs.mu.RUnlock()
+	defer entry.mu.RUnlock()
+
+	return entry.contentType, nil
+}
+
+// GetEncryptedEntry returns the encrypted blob of a history entry by ID.
+func (cs *ClipboardStore) GetEncryptedEntry(id string) (ClipboardInfo, []byte, error) {
+	entry, err := cs.lookupEntry(id)
+	if err != nil {
+		return ClipboardInfo{}, nil, err
+	}
+	defer cs.mu.RUnlock()
+	defer entry.mu.RUnlock()
+
+	if entry.encrypted == nil {
+		return ClipboardInfo{}, nil, ErrClipboardEntryNotFound
+	}
+
+	// Return a copy
+	result := make([]byte, len(entry.encrypted))
+	copy(result, entry.encrypted)
+	return entryInfo(entry), result, nil
+}
+
+// lookupEntry finds a live history entry by ID.
+// On success both the store and entry read locks are held; the caller must release them.
+func (cs *ClipboardStore) lookupEntry(id string) (*ClipboardEntry, error) {
+	id, err := validate.ClipboardEntryID(id)
+	if err != nil {
+		return nil, ErrClipboardEntryNotFound
+	}
+
+	cs.mu.RLock()
+
+	index := cs.indexOf(id)
+	if index < 0 {
+		cs.mu.RUnlock()
+		return nil, ErrClipboardEntryNotFound
+	}
+
+	entry := cs.history[index]
+	entry.mu.RLock()
+
+	if entry.expired(time.Now()) {
+		entry.mu.RUnlock()
+		cs.mu.RUnlock()
+		return nil, ErrClipboardExpired
+	}
+
+	return entry, nil
+}
+
+// PinEntry sets whether a history entry is protected from eviction and expiry.
+// Returns ErrPinLimit if the pinned entries would exceed the history limits.
+func (cs *ClipboardStore) PinEntry(id string, pinned bool) (ClipboardInfo, error) {
+	id, err := validate.ClipboardEntryID(id)
+	if err != nil {
+		return ClipboardInfo{}, ErrClipboardEntryNotFound
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	index := cs.indexOf(id)
+	if index < 0 {
+		return ClipboardInfo{}, ErrClipboardEntryNotFound
+	}
+
+	entry := cs.history[index]
+	if entry.expired(time.Now()) {
+		return ClipboardInfo{}, ErrClipboardExpired
+	}
+	if pinned && !entry.pinned && !cs.pinFitsLocked(entry.size) {
+		return ClipboardInfo{}, ErrPinLimit
+	}
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.pinned != pinned {
+		entry.pinned = pinned
+		if !pinned {
+			// The entry's TTL starts over when it is unpinned
+			entry.expiresAt = time.Now().Add(entry.ttl)
+			cs.wakeExpiry()
+		}
+		// The current entry's pin and expiry are part of its slot, so its ETag changes
+		if cs.current(entry.contentType) == entry {
+			cs.bumpVersion(entry.contentType)
+		} else {
+			cs.bumpVersion()
+		}
+	}
+
+	return entryInfo(entry), nil
+}
+
+// DeleteEntry shreds and removes a single history entry.
+// ifVersion makes the delete conditional on the entry version (AnyVersion to skip the check).
+// Returns the entry version, so a caller can report it on ErrVersionMismatch.
+func (cs *ClipboardStore) DeleteEntry(id string, ifVersion uint64) (uint64, error) {
+	id, err := validate.ClipboardEntryID(id)
+	if err != nil {
+		return 0, ErrClipboardEntryNotFound
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	index := cs.indexOf(id)
+	if index < 0 {
+		return 0, ErrClipboardEntryNotFound
+	}
+
+	version := cs.history[index].version
+	if ifVersion != AnyVersion && ifVersion != version {
+		return version, ErrVersionMismatch
+	}
+
+	// Removing the current entry changes the slot
+	isCurrent := cs.current(cs.history[index].contentType) == cs.history[index]
+
+	entry := cs.removeAt(index)
+	cs.shredEntry(entry)
+	if isCurrent {
+		cs.bumpVersion(entry.contentType)
+	} else {
+		cs.bumpVersion()
+	}
+	cs.publish(EventClipboardDeleted, entry.contentType, entry.id)
+
+	return version, nil
+}
+
+// addEntry assigns an ID and lifetime to a new entry, appends it to the
+// history and evicts the oldest unpinned entries that no longer fit.
+// ifVersion makes the add conditional on the slot version (AnyVersion to skip the check).
+// The new entry is destroyed if it cannot be stored; a pinned entry is
+// rejected with ErrPinLimit if the pinned entries would exceed the limits.
+// Returns the new slot version (or the current one on ErrVersionMismatch).
+func (cs *ClipboardStore) addEntry(newEntry *ClipboardEntry, ifVersion uint64, opts EntryOptions) (uint64, error) {
+	id, err := crypto.GenerateFileID()
+	if err != nil {
+		discardEntry(newEntry)
+		return 0, err
+	}
+	newEntry.id = id
+
+	newEntry.ttl = cs.expiry
+	if opts.TTL > 0 {
+		newEntry.ttl = opts.TTL
+	}
+	newEntry.expiresAt = newEntry.createdAt.Add(newEntry.ttl)
+	newEntry.pinned = opts.Pinned
+
+	// Now acquire lock briefly to append the entry
+	cs.mu.Lock()
+
+	if cs.closed {
+		cs.mu.Unlock()
+		discardEntry(newEntry)
+		return 0, ErrClipboardClosed
+	}
+
+	if current := cs.slotVersions[newEntry.contentType]; ifVersion != AnyVersion && ifVersion != current {
+		cs.mu.Unlock()
+		discardEntry(newEntry)
+		return current, ErrVersionMismatch
+	}
+
+	if newEntry.pinned && !cs.pinFitsLocked(newEntry.size) {
+		cs.mu.Unlock()
+		discardEntry(newEntry)
+		return 0, ErrPinLimit
+	}
+
+	// Check memory limit
+	if cs.memory != nil {
+		if err := cs.memory.Allocate(int64(newEntry.size)); err != nil {
+			cs.mu.Unlock()
+			// Clean up the new entry we created
+			discardEntry(newEntry)
+			return 0, err
+		}
+	}
+
+	version := cs.bumpVersion(newEntry.contentType)
+	newEntry.version = version
+	cs.history = append(cs.history, newEntry)
+	evicted := cs.evictLocked()
+	cs.publish(EventClipboardSet, newEntry.contentType, newEntry.id)
+
+	cs.mu.Unlock()
+
+	if !newEntry.pinned && newEntry.ttl < clipboardExpiryInterval {
+		cs.wakeExpiry()
+	}
+
+	// Shred evicted entries OUTSIDE the lock to avoid blocking other operations
+	// This is safe because we've already removed them from the store
+	for _, entry := range evicted {
+		cs.shredEntryAsync(entry)
+	}
+
+	return version, nil
+}
+
+// evictLocked removes the oldest unpinned entries until the history fits
+// within the configured count and byte limits. The current text and image
+// are never evicted, so a burst of one type cannot drop the other.
+// Returns the removed entries for shredding.
+// Caller must hold cs.mu exclusively.
+func (cs *ClipboardStore) evictLocked() []*ClipboardEntry {
+	var total int64
+	for _, entry := range cs.history {
+		total += int64(entry.size)
+	}
+
+	var evicted []*ClipboardEntry
+	for len(cs.history) > cs.historySize || total > cs.historyMaxBytes {
+		text, image := cs.current(ClipboardTypeText), cs.current(ClipboardTypeImage)
+		index := -1
+		for i, entry := range cs.history {
+			if !entry.pinned && entry != text && entry != image {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			// Only pinned and current entries left - pinFitsLocked keeps the
+			// pinned ones within the limits, so this overshoots by the current
+			// text and image at most
+			break
+		}
+
+		entry := cs.removeAt(index)
+		total -= int64(entry.size)
+		evicted = append(evicted, entry)
+	}
+
+	return evicted
+}
+
+// pinFitsLocked returns whether one more pinned entry of size bytes keeps the
+// pinned entries within the history count and byte limits. Pinned entries
+// are never evicted, so without this bound they could grow without limit.
+// Caller must hold cs.mu.
+func (cs *ClipboardStore) pinFitsLocked(size int) bool {
+	count, total := 1, int64(size)
+	for _, entry := range cs.history {
+		if entry.pinned {
+			count++
+			total += int64(entry.size)
+		}
+	}
+	return count <= cs.historySize && total <= cs.historyMaxBytes
+}
+
+// current returns the newest history entry of the given type, or nil.
+// Caller must hold cs.mu.
+func (cs *ClipboardStore) current(contentType ClipboardType) *ClipboardEntry {
+	for i := len(cs.history) - 1; i >= 0; i-- {
+		if cs.history[i].contentType == contentType {
+			return cs.history[i]
+		}
+	}
+	return nil
+}
+
+// indexOf returns the history index of the entry with the given ID, or -1.
+// Caller must hold cs.mu.
+func (cs *ClipboardStore) indexOf(id string) int {
+	for i, entry := range cs.history {
+		if entry.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeAt removes and returns the history entry at index.
+// Caller must hold cs.mu exclusively.
+func (cs *ClipboardStore) removeAt(index int) *ClipboardEntry {
+	entry := cs.history[index]
+	cs.truncateHistory(append(cs.history[:index], cs.history[index+1:]...))
+	return entry
+}
+
+// truncateHistory replaces the history with kept, clearing the dropped tail
+// so removed entries are not retained by the backing array.
+// Caller must hold cs.mu exclusively.
+func (cs *ClipboardStore) truncateHistory(kept []*ClipboardEntry) {
+	for i := len(kept); i < len(cs.history); i++ {
+		cs.history[i] = nil
+	}
+	cs.history = kept
+}
+
+// entryInfo builds the metadata for an entry.
+// Caller must hold entry.mu.
+func entryInfo(entry *ClipboardEntry) ClipboardInfo {
+	mimeType := entry.mimeType
+	var representations []string
+	if entry.contentType == ClipboardTypeText {
+		mimeType = validate.MIMETextPlain
+		if entry.data != nil {
+			representations = append(representations, validate.MIMETextPlain)
+			for _, rep := range entry.representations {
+				representations = append(representations, rep.mimeType)
+			}
+		}
 	}
 
 	return ClipboardInfo{
+		ID:         entry.id,
+		Kind:       entry.contentType.String(),
 		HasContent: true,
-		Size:       cs.image.size,
-		MimeType:   cs.image.mimeType,
-		CreatedAt:  cs.image.createdAt,
-		ExpiresAt:  cs.image.expiresAt,
+		Encrypted:  entry.encrypted != nil,
+		Pinned:     entry.pinned,
+		Version:    entry.version,
+		Size:       entry.size,
+		MimeType:   mimeType,
+		CreatedAt:  entry.createdAt,
+		ExpiresAt:  entry.expiresAt,
+
+		Representations: representations,
+		ExpiresIn:       expiresIn(entry),
 	}
 }
 
+// expiresIn returns the whole seconds (rounded up) until an entry expires,
+// or 0 for pinned entries.
+// Caller must hold entry.mu.
+func expiresIn(entry *ClipboardEntry) int64 {
+	if entry.pinned {
+		return 0
+	}
+
+	remaining := time.Until(entry.expiresAt)
+	if remaining <= 0 {
+		return 0
+	}
+	return int64((remaining + time.Second - 1) / time.Second)
+}
+
+// copyEntryData returns a copy of the entry's plaintext.
+// Caller must hold entry.mu and ensure entry.data is set.
+func copyEntryData(entry *ClipboardEntry) ([]byte, error) {
+	var result []byte
+	err := entry.data.Use(func(d []byte) error {
+		result = make([]byte, len(d))
+		copy(result, d)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// copyRepresentation returns a copy of one representation of a text entry.
+// Caller must hold entry.mu and ensure entry.data is set.
+func copyRepresentation(entry *ClipboardEntry, mimeType string) ([]byte, error) {
+	if mimeType == validate.MIMETextPlain {
+		return copyEntryData(entry)
+	}
+
+	for _, rep := range entry.representations {
+		if rep.mimeType != mimeType {
+			continue
+		}
+
+		var result []byte
+		err := rep.data.Use(func(d []byte) error {
+			result = make([]byte, len(d))
+			copy(result, d)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return nil, ErrRepresentationNotFound
+}
+
+// shredContent destroys all content of an entry.
+// Caller must hold entry.mu exclusively (or own the entry).
+func shredContent(entry *ClipboardEntry) {
+	if entry.data != nil {
+		secure.ShredFortifiedBuffer(entry.data)
+		entry.data = nil
+	}
+
+	for _, rep := range entry.representations {
+		secure.ShredFortifiedBuffer(rep.data)
+		rep.data = nil
+	}
+	entry.representations = nil
+
+	if entry.encrypted != nil {
+		secure.Shred(entry.encrypted)
+		entry.encrypted = nil
+	}
+}
+
+// discardEntry destroys the content of an entry that was never added to the
+// store (and therefore never allocated against the memory tracker).
+func discardEntry(entry *ClipboardEntry) {
+	shredContent(entry)
+}
+
 // shredEntry securely destroys a clipboard entry (synchronous).
 // Should only be called when you need to ensure shredding completes before returning.
 func (cs *ClipboardStore) shredEntry(entry *ClipboardEntry) {
@@ -407,15 +1063,7 @@ func (cs *ClipboardStore) shredEntry(entry *ClipboardEntry) {
 	}
 
 	// Shred data (FortifiedBuffer handles its own secure destruction)
-	if entry.data != nil {
-		secure.ShredFortifiedBuffer(entry.data)
-		entry.data = nil
-	}
-
-	if entry.encrypted != nil {
-		secure.Shred(entry.encrypted)
-		entry.encrypted = nil
-	}
+	shredContent(entry)
 }
 
 // shredEntryAsync securely destroys a clipboard entry asynchronously.
@@ -436,37 +1084,40 @@ func (cs *ClipboardStore) shredEntryAsync(entry *ClipboardEntry) {
 		}
 
 		// Shred data (FortifiedBuffer handles its own secure destruction)
-		if entry.data != nil {
-			secure.ShredFortifiedBuffer(entry.data)
-			entry.data = nil
-		}
-
-		if entry.encrypted != nil {
-			secure.Shred(entry.encrypted)
-			entry.encrypted = nil
-		}
+		shredContent(entry)
 	}()
 }
 
-// ShredAll securely destroys all clipboard content.
+// ShredAll securely destroys all clipboard content, including history.
 func (cs *ClipboardStore) ShredAll() {
+	cs.ShredTypes(ClipboardTypeText, ClipboardTypeImage)
+}
+
+// ShredTypes securely destroys all content of the given types, including history.
+func (cs *ClipboardStore) ShredTypes(types ...ClipboardType) {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	if cs.text != nil {
-		cs.shredEntry(cs.text)
-		cs.text = nil
-	}
-
-	if cs.image != nil {
-		cs.shredEntry(cs.image)
-		cs.image = nil
+	kept := cs.history[:0]
+	for _, entry := range cs.history {
+		if hasClipboardType(types, entry.contentType) {
+			cs.shredEntry(entry)
+			continue
+		}
+		kept = append(kept, entry)
 	}
+	cs.truncateHistory(kept)
+	cs.bumpVersion(types...)
 }
 
-// Close stops the expiry loop and shreds all content.
+// Close stops the expiry loop and shreds all content. Later writes fail
+// with ErrClipboardClosed.
 // Should be called on application shutdown.
 func (cs *ClipboardStore) Close() {
+	cs.mu.Lock()
+	cs.closed = true
+	cs.mu.Unlock()
+
 	// Signal goroutine to stop
 	close(cs.done)
 
@@ -475,137 +1126,169 @@ func (cs *ClipboardStore) Close() {
 }
 
 // expiryLoop periodically checks for expired content.
+// It runs at least once a minute, and when the next entry is due sooner
+// (short per-entry TTLs) it runs at that time instead.
 func (cs *ClipboardStore) expiryLoop() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+	delay := clipboardExpiryInterval
 
 	for {
+		timer := time.NewTimer(delay)
 		select {
-		case <-ticker.C:
-			cs.cleanupExpired()
+		case <-timer.C:
+		case <-cs.wake:
+			timer.Stop()
 		case <-cs.done:
+			timer.Stop()
 			return
 		}
+
+		delay = cs.cleanupExpired()
+	}
+}
+
+// wakeExpiry makes the expiry loop recompute when the next entry is due.
+func (cs *ClipboardStore) wakeExpiry() {
+	select {
+	case cs.wake <- struct{}{}:
+	default:
 	}
 }
 
 // cleanupExpired removes expired content.
-func (cs *ClipboardStore) cleanupExpired() {
+// Returns the time until the next entry expires (at most clipboardExpiryInterval).
+func (cs *ClipboardStore) cleanupExpired() time.Duration {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
 	now := time.Now()
+	next := clipboardExpiryInterval
 
 	// Since we hold the exclusive store lock, we can safely read entry fields
 	// without acquiring entry locks (no other writers can run)
-	if cs.text != nil && now.After(cs.text.expiresAt) {
-		cs.shredEntry(cs.text)
-		cs.text = nil
+	var changed []ClipboardType
+	kept := cs.history[:0]
+	for _, entry := range cs.history {
+		if entry.expired(now) {
+			cs.shredEntry(entry)
+			changed = append(changed, entry.contentType)
+			cs.publish(EventClipboardExpired, entry.contentType, entry.id)
+			continue
+		}
+		if until := entry.expiresAt.Sub(now); !entry.pinned && until < next {
+			next = until
+		}
+		kept = append(kept, entry)
 	}
+	cs.truncateHistory(kept)
 
-	if cs.image != nil && now.After(cs.image.expiresAt) {
-		cs.shredEntry(cs.image)
-		cs.image = nil
+	if len(changed) > 0 {
+		cs.bumpVersion(changed...)
 	}
+
+	// Never spin on an entry that is just about to expire
+	if next < 100*time.Millisecond {
+		next = 100 * time.Millisecond
+	}
+	return next
 }
 
 // SetEncryptedText stores an already-encrypted text blob from the client.
+// The blob becomes the current text; older entries stay in the history.
 // Used during E2EE lock operation - server cannot decrypt this data.
-func (cs *ClipboardStore) SetEncryptedText(encrypted []byte) {
+// ifVersion makes the update conditional (AnyVersion to skip the check).
+// opts sets the entry's TTL and pin flag.
+// The blob must be a crypto envelope for clipboard text.
+func (cs *ClipboardStore) SetEncryptedText(encrypted []byte, ifVersion uint64, opts EntryOptions) (uint64, error) {
 	if len(encrypted) == 0 {
-		return
+		return cs.TextVersion(), nil
 	}
-
-	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
-	// Shred any existing text
-	if cs.text != nil {
-		cs.shredEntry(cs.text)
+	if err := crypto.CheckEnvelope(encrypted, crypto.EnvelopeClipboardText, ""); err != nil {
+		return cs.TextVersion(), err
 	}
 
 	// Store encrypted blob (server cannot decrypt)
-	cs.text = &ClipboardEntry{
+	now := time.Now()
+	entry := &ClipboardEntry{
 		encrypted:   make([]byte, len(encrypted)),
 		contentType: ClipboardTypeText,
 		size:        len(encrypted),
-		createdAt:   time.Now(),
-		expiresAt:   time.Now().Add(cs.expiry),
+		createdAt:   now,
 	}
-	copy(cs.text.encrypted, encrypted)
+	copy(entry.encrypted, encrypted)
+
+	return cs.addEntry(entry, ifVersion, opts)
 }
 
 // GetEncryptedText returns the encrypted text blob for client-side decryption.
 // Returns nil if no encrypted text is stored.
 func (cs *ClipboardStore) GetEncryptedText() []byte {
-	cs.mu.RLock()
-	defer cs.mu.RUnlock()
-
-	if cs.text == nil {
-		return nil
-	}
-
-	cs.text.mu.RLock()
-	defer cs.text.mu.RUnlock()
-
-	if cs.text.encrypted == nil {
-		return nil
-	}
-
-	// Return a copy
-	result := make([]byte, len(cs.text.encrypted))
-	copy(result, cs.text.encrypted)
-	return result
+	encrypted, _, _ := cs.currentEncrypted(ClipboardTypeText)
+	return encrypted
 }
 
 // SetEncryptedImage stores an already-encrypted image blob from the client.
+// The blob becomes the current image; older entries stay in the history.
 // Used during E2EE lock operation - server cannot decrypt this data.
-func (cs *ClipboardStore) SetEncryptedImage(encrypted []byte, mimeType string) {
+// ifVersion makes the update conditional (AnyVersion to skip the check).
+// opts sets the entry's TTL and pin flag.
+// The blob must be a crypto envelope for a clipboard image.
+func (cs *ClipboardStore) SetEncryptedImage(encrypted []byte, mimeType string, ifVersion uint64, opts EntryOptions) (uint64, error) {
 	if len(encrypted) == 0 {
-		return
+		return cs.ImageVersion(), nil
 	}
-
-	cs.mu.Lock()
-	defer cs.mu.Unlock()
-
-	// Shred any existing image
-	if cs.image != nil {
-		cs.shredEntry(cs.image)
+	if err := crypto.CheckEnvelope(encrypted, crypto.EnvelopeClipboardImage, ""); err != nil {
+		return cs.ImageVersion(), err
 	}
 
 	// Store encrypted blob (server cannot decrypt)
-	cs.image = &ClipboardEntry{
+	now := time.Now()
+	entry := &ClipboardEntry{
 		encrypted:   make([]byte, len(encrypted)),
 		contentType: ClipboardTypeImage,
 		mimeType:    mimeType,
 		size:        len(encrypted),
-		createdAt:   time.Now(),
-		expiresAt:   time.Now().Add(cs.expiry),
+		createdAt:   now,
 	}
-	copy(cs.image.encrypted, encrypted)
+	copy(entry.encrypted, encrypted)
+
+	return cs.addEntry(entry, ifVersion, opts)
 }
 
 // GetEncryptedImage returns the encrypted image blob and mime type for client-side decryption.
 // Returns nil if no encrypted image is stored.
 func (cs *ClipboardStore) GetEncryptedImage() ([]byte, string) {
+	encrypted, mimeType, _ := cs.currentEncrypted(ClipboardTypeImage)
+	return encrypted, mimeType
+}
+
+// GetEncryptedCurrent returns the current encrypted text or image blob with
+// its mime type and slot version, read together.
+// Returns nil if the current entry is not encrypted.
+func (cs *ClipboardStore) GetEncryptedCurrent(contentType ClipboardType) ([]byte, string, uint64) {
+	return cs.currentEncrypted(contentType)
+}
+
+// currentEncrypted returns a copy of the current entry's encrypted blob, mime type and slot version.
+func (cs *ClipboardStore) currentEncrypted(contentType ClipboardType) ([]byte, string, uint64) {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	if cs.image == nil {
-		return nil, ""
+	entry := cs.current(contentType)
+	if entry == nil {
+		return nil, "", 0
 	}
 
-	cs.image.mu.RLock()
-	defer cs.image.mu.RUnlock()
+	entry.mu.RLock()
+	defer entry.mu.RUnlock()
 
-	if cs.image.encrypted == nil {
-		return nil, ""
+	if entry.encrypted == nil {
+		return nil, "", 0
 	}
 
 	// Return a copy
-	result := make([]byte, len(cs.image.encrypted))
-	copy(result, cs.image.encrypted)
-	return result, cs.image.mimeType
+	result := make([]byte, len(entry.encrypted))
+	copy(result, entry.encrypted)
+	return result, entry.mimeType, cs.slotVersions[contentType]
 }
 
 // ClearEncryptedData shreds all encrypted blobs.
@@ -615,33 +1298,23 @@ func (cs *ClipboardStore) ClearEncryptedData() {
 	defer cs.mu.Unlock()
 
 	// Only clear encrypted data, not plaintext SecureBuffer data
-	if cs.text != nil {
-		cs.text.mu.Lock()
-		if cs.text.encrypted != nil {
-			secure.Shred(cs.text.encrypted)
-			cs.text.encrypted = nil
+	kept := cs.history[:0]
+	for _, entry := range cs.history {
+		entry.mu.Lock()
+		if entry.encrypted != nil {
+			secure.Shred(entry.encrypted)
+			entry.encrypted = nil
 		}
 		// If no plaintext data either, remove the entry
-		if cs.text.data == nil {
-			cs.text.mu.Unlock()
-			cs.text = nil
-		} else {
-			cs.text.mu.Unlock()
-		}
-	}
+		hasData := entry.data != nil
+		entry.mu.Unlock()
 
-	if cs.image != nil {
-		cs.image.mu.Lock()
-		if cs.image.encrypted != nil {
-			secure.Shred(cs.image.encrypted)
-			cs.image.encrypted = nil
-		}
-		// If no plaintext data either, remove the entry
-		if cs.image.data == nil {
-			cs.image.mu.Unlock()
-			cs.image = nil
-		} else {
-			cs.image.mu.Unlock()
+		if hasData {
+			kept = append(kept, entry)
+		} else if cs.memory != nil {
+			cs.memory.Free(int64(entry.size))
 		}
 	}
+	cs.truncateHistory(kept)
+	cs.bumpVersion(ClipboardTypeText, ClipboardTypeImage)
 }
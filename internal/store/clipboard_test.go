@@ -0,0 +1,360 @@
+package store
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+func TestPinnedEntriesStayWithinHistoryLimits(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 2, 0)
+	t.Cleanup(cs.Close)
+
+	pinned := EntryOptions{Pinned: true}
+	for _, text := range []string{"one", "two"} {
+		if _, err := cs.SetText([]byte(text), AnyVersion, pinned); err != nil {
+			t.Fatalf("SetText: %v", err)
+		}
+	}
+	if _, err := cs.SetText([]byte("three"), AnyVersion, pinned); err != ErrPinLimit {
+		t.Fatalf("pinned entry over the history size: got %v, want %v", err, ErrPinLimit)
+	}
+
+	// Unpinned entries are still accepted, and cannot be pinned
+	if _, err := cs.SetText([]byte("three"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := cs.PinEntry(cs.TextInfo().ID, true); err != ErrPinLimit {
+		t.Errorf("pin over the history size: got %v, want %v", err, ErrPinLimit)
+	}
+	if n := len(cs.History()); n != 3 {
+		t.Errorf("history has %d entries, want 3", n)
+	}
+}
+
+func TestPinnedEntriesStayWithinHistoryBytes(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 10, 8)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("12345"), AnyVersion, EntryOptions{Pinned: true}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := cs.SetText([]byte("6789"), AnyVersion, EntryOptions{Pinned: true}); err != ErrPinLimit {
+		t.Errorf("pinned entry over the history bytes: got %v, want %v", err, ErrPinLimit)
+	}
+}
+
+func TestClipboardHistoryEvictsOldestEntries(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 3, 0)
+	t.Cleanup(cs.Close)
+
+	for _, text := range []string{"one", "two", "three", "four"} {
+		if _, err := cs.SetText([]byte(text), AnyVersion, EntryOptions{}); err != nil {
+			t.Fatalf("SetText(%q): %v", text, err)
+		}
+	}
+
+	history := cs.History()
+	if len(history) != 3 {
+		t.Fatalf("history has %d entries, want 3", len(history))
+	}
+	for i, want := range []string{"four", "three", "two"} {
+		_, content, err := cs.GetEntry(history[i].ID)
+		if err != nil {
+			t.Fatalf("GetEntry: %v", err)
+		}
+		if string(content) != want {
+			t.Errorf("history[%d] = %q, want %q", i, content, want)
+		}
+	}
+
+	current, err := cs.GetText()
+	if err != nil {
+		t.Fatalf("GetText: %v", err)
+	}
+	if string(current) != "four" {
+		t.Errorf("current text = %q, want %q", current, "four")
+	}
+}
+
+func TestClipboardHistoryEvictsByBytes(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 10, 8)
+	t.Cleanup(cs.Close)
+
+	for _, text := range []string{"1234", "5678", "90"} {
+		if _, err := cs.SetText([]byte(text), AnyVersion, EntryOptions{}); err != nil {
+			t.Fatalf("SetText(%q): %v", text, err)
+		}
+	}
+
+	history := cs.History()
+	if len(history) != 2 {
+		t.Fatalf("history has %d entries, want 2", len(history))
+	}
+	total := 0
+	for _, info := range history {
+		total += info.Size
+	}
+	if total > 8 {
+		t.Errorf("history holds %d bytes, want at most 8", total)
+	}
+}
+
+func TestClipboardHistoryKeepsCurrentEntryOfEachType(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 3, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("text"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	for _, image := range []string{"png1", "png2", "png3", "png4"} {
+		if _, err := cs.SetImage([]byte(image), "image/png", AnyVersion, EntryOptions{}); err != nil {
+			t.Fatalf("SetImage(%q): %v", image, err)
+		}
+	}
+
+	// A burst of images evicts older images, never the current text
+	text, err := cs.GetText()
+	if err != nil {
+		t.Fatalf("GetText: %v", err)
+	}
+	if string(text) != "text" {
+		t.Errorf("current text = %q, want %q", text, "text")
+	}
+	image, _, err := cs.GetImage()
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if string(image) != "png4" {
+		t.Errorf("current image = %q, want %q", image, "png4")
+	}
+	if n := len(cs.History()); n != 3 {
+		t.Errorf("history has %d entries, want 3", n)
+	}
+}
+
+func TestClipboardDeleteTextKeepsImages(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("old"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := cs.SetImage([]byte("png"), "image/png", AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetImage: %v", err)
+	}
+	if _, err := cs.SetText([]byte("new"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+
+	if _, err := cs.DeleteText(AnyVersion); err != nil {
+		t.Fatalf("DeleteText: %v", err)
+	}
+
+	// The older text must not resurface as the current text
+	if cs.HasText() {
+		t.Error("text left after DeleteText")
+	}
+	if !cs.HasImage() {
+		t.Error("image removed by DeleteText")
+	}
+	if n := len(cs.History()); n != 1 {
+		t.Errorf("history has %d entries, want 1", n)
+	}
+}
+
+func TestClipboardConditionalWrites(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	first, err := cs.SetText([]byte("one"), AnyVersion, EntryOptions{})
+	if err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	second, err := cs.SetText([]byte("two"), first, EntryOptions{})
+	if err != nil {
+		t.Fatalf("SetText with the current version: %v", err)
+	}
+
+	// A writer still holding the first version loses
+	current, err := cs.SetText([]byte("stale"), first, EntryOptions{})
+	if err != ErrVersionMismatch {
+		t.Fatalf("SetText with a stale version: got %v, want %v", err, ErrVersionMismatch)
+	}
+	if current != second {
+		t.Errorf("mismatch reported version %d, want %d", current, second)
+	}
+	if _, err := cs.DeleteText(first); err != ErrVersionMismatch {
+		t.Errorf("DeleteText with a stale version: got %v, want %v", err, ErrVersionMismatch)
+	}
+
+	// Image writes do not change the text version
+	if _, err := cs.SetImage([]byte("png"), "image/png", AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetImage: %v", err)
+	}
+	if v := cs.TextVersion(); v != second {
+		t.Errorf("text version = %d after an image write, want %d", v, second)
+	}
+
+	text, err := cs.GetText()
+	if err != nil {
+		t.Fatalf("GetText: %v", err)
+	}
+	if string(text) != "two" {
+		t.Errorf("text = %q, want %q", text, "two")
+	}
+}
+
+func TestClipboardEntryTTL(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, time.Hour, 0, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("kept"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := cs.SetText([]byte("short"), AnyVersion, EntryOptions{TTL: time.Millisecond}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := cs.SetText([]byte("pinned"), AnyVersion, EntryOptions{TTL: time.Millisecond, Pinned: true}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	cs.cleanupExpired()
+
+	var texts []string
+	for _, info := range cs.History() {
+		_, content, err := cs.GetEntry(info.ID)
+		if err != nil {
+			t.Fatalf("GetEntry: %v", err)
+		}
+		texts = append(texts, string(content))
+	}
+	if want := []string{"pinned", "kept"}; !slices.Equal(texts, want) {
+		t.Errorf("history after expiry = %q, want %q", texts, want)
+	}
+
+	// Unpinning starts the entry's TTL over
+	info := cs.TextInfo()
+	if !info.Pinned || info.ExpiresIn != 0 {
+		t.Errorf("pinned entry: pinned = %v, expires in %ds", info.Pinned, info.ExpiresIn)
+	}
+	if _, err := cs.PinEntry(info.ID, false); err != nil {
+		t.Fatalf("PinEntry: %v", err)
+	}
+	if !cs.HasText() {
+		t.Error("entry expired as soon as it was unpinned")
+	}
+	time.Sleep(5 * time.Millisecond)
+	cs.cleanupExpired()
+
+	text, err := cs.GetText()
+	if err != nil {
+		t.Fatalf("GetText: %v", err)
+	}
+	if string(text) != "kept" {
+		t.Errorf("current text = %q, want %q", text, "kept")
+	}
+}
+
+func TestPinnedEntriesSurviveEviction(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 2, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("pinned"), AnyVersion, EntryOptions{Pinned: true}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	for _, text := range []string{"two", "three", "four"} {
+		if _, err := cs.SetText([]byte(text), AnyVersion, EntryOptions{}); err != nil {
+			t.Fatalf("SetText(%q): %v", text, err)
+		}
+	}
+
+	history := cs.History()
+	if len(history) != 2 {
+		t.Fatalf("history has %d entries, want 2", len(history))
+	}
+	if oldest := history[len(history)-1]; !oldest.Pinned {
+		t.Error("pinned entry was evicted")
+	}
+}
+
+func TestPinEntryChangesCurrentVersion(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, time.Hour, 0, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("old"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	old := cs.TextInfo().ID
+	if _, err := cs.SetText([]byte("current"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+
+	// Pinning the current text changes its version, so cached copies go stale
+	version := cs.TextVersion()
+	if _, err := cs.PinEntry(cs.TextInfo().ID, true); err != nil {
+		t.Fatalf("PinEntry: %v", err)
+	}
+	if cs.TextVersion() == version {
+		t.Error("pinning the current text kept its version")
+	}
+
+	// An older entry is not part of the current text
+	version = cs.TextVersion()
+	if _, err := cs.PinEntry(old, true); err != nil {
+		t.Fatalf("PinEntry: %v", err)
+	}
+	if cs.TextVersion() != version {
+		t.Error("pinning an older entry changed the current text version")
+	}
+}
+
+func TestPinEntryRejectsExpiredEntry(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, time.Hour, 0, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("short"), AnyVersion, EntryOptions{TTL: time.Millisecond}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	id := cs.TextInfo().ID
+	time.Sleep(5 * time.Millisecond)
+
+	// Not cleaned up yet, but pinning must not bring it back
+	if _, err := cs.PinEntry(id, true); err != ErrClipboardExpired {
+		t.Errorf("pin an expired entry: got %v, want %v", err, ErrClipboardExpired)
+	}
+}
+
+func TestEncryptedClipboardWritesRequireEnvelopes(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	image := testEnvelope(t, crypto.EnvelopeHeader{Type: crypto.EnvelopeClipboardImage}, []byte("png"))
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"raw ciphertext", bytes.Repeat([]byte{1}, 64), crypto.ErrInvalidEnvelope},
+		{"image envelope as text", image, crypto.ErrEnvelopeMismatch},
+	}
+	for _, tt := range tests {
+		if _, err := cs.SetEncryptedText(tt.data, AnyVersion, EntryOptions{}); err != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if cs.HasText() {
+		t.Error("rejected blob was stored")
+	}
+
+	if _, err := cs.SetEncryptedText(clipboardEnvelope(t, "text"), AnyVersion, EntryOptions{}); err != nil {
+		t.Errorf("text envelope: %v", err)
+	}
+	if _, err := cs.SetEncryptedImage(image, "image/png", AnyVersion, EntryOptions{}); err != nil {
+		t.Errorf("image envelope: %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+var (
+	// ErrDeviceNotFound indicates no device with that ID holds a token.
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrTooManyDevices indicates the device limit has been reached.
+	ErrTooManyDevices = errors.New("too many devices")
+)
+
+// MaxDevices is the maximum number of device tokens per sealed session.
+const MaxDevices = 32
+
+// TokenPolicy limits how long device tokens stay valid.
+// Zero durations disable the corresponding limit.
+type TokenPolicy struct {
+	Lifetime    time.Duration // Absolute lifetime of a token from when it was issued
+	IdleTimeout time.Duration // A token unused for this long expires
+	AutoLock    time.Duration // With no activity from any device for this long, all tokens are invalidated
+}
+
+// device is a device holding a token for the sealed session.
+// Only the SHA-256 of the token is kept.
+type device struct {
+	id        string
+	name      string
+	createdAt time.Time
+	issuedAt  time.Time // When the current token was issued (rotation resets it)
+	lastSeen  time.Time
+}
+
+// DeviceInfo contains device metadata for API responses.
+type DeviceInfo struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastSeen  time.Time  `json:"last_seen"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Absolute token expiry (TOKEN_LIFETIME)
+	Current   bool       `json:"current,omitempty"`    // The device making the request
+}
+
+// SetTokenPolicy sets the lifetime limits for device tokens.
+func (sm *SessionManager) SetTokenPolicy(policy TokenPolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.policy = policy
+}
+
+// IssueDeviceToken issues a new token for a device of the sealed session.
+// If previousToken is still valid, the token is rotated: the old token stops
+// working and the device keeps its ID (and its name unless a new one is given).
+// The token is returned only here; the session keeps its hash.
+func (sm *SessionManager) IssueDeviceToken(name, previousToken string) (string, DeviceInfo, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return "", DeviceInfo{}, ErrSessionNotLocked
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	if !sm.session.locked {
+		return "", DeviceInfo{}, ErrSessionNotLocked
+	}
+
+	now := time.Now()
+	sm.sweepDevices(now)
+
+	var previous *device
+	if previousToken != "" {
+		hash := sha256.Sum256([]byte(previousToken))
+		if previous = sm.session.devices[hash]; previous != nil {
+			delete(sm.session.devices, hash)
+			if strings.TrimSpace(name) == "" {
+				name = previous.name
+			}
+		}
+	}
+
+	token, d, err := sm.session.issueDeviceToken(name, now)
+	if err != nil {
+		return "", DeviceInfo{}, err
+	}
+	if previous != nil {
+		d.id = previous.id
+		d.createdAt = previous.createdAt
+	}
+
+	return token, sm.deviceInfo(d), nil
+}
+
+// issueDeviceToken adds a device and returns its token.
+// Caller must hold s.mu.
+func (s *Session) issueDeviceToken(name string, now time.Time) (string, *device, error) {
+	if len(s.devices) >= MaxDevices {
+		return "", nil, ErrTooManyDevices
+	}
+
+	token, err := crypto.GenerateSessionTokenString()
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := crypto.GenerateFileID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	d := &device{
+		id:        id,
+		name:      validate.DeviceName(name),
+		createdAt: now,
+		issuedAt:  now,
+		lastSeen:  now,
+	}
+
+	if s.devices == nil {
+		s.devices = make(map[[sha256.Size]byte]*device)
+	}
+	s.devices[sha256.Sum256([]byte(token))] = d
+	s.lastActivity = now
+
+	return token, d, nil
+}
+
+// ValidateToken reports whether token belongs to a device of the session
+// and records the request as activity of that device.
+func (sm *SessionManager) ValidateToken(token string) bool {
+	_, ok := sm.lookupDevice(token, true)
+	return ok
+}
+
+// CheckToken reports whether token belongs to a device of the session
+// without counting as activity. Used to re-check long-lived streams.
+func (sm *SessionManager) CheckToken(token string) bool {
+	_, ok := sm.lookupDevice(token, false)
+	return ok
+}
+
+// DeviceForToken returns the ID of the device holding token
+// and records the request as activity of that device.
+func (sm *SessionManager) DeviceForToken(token string) (string, bool) {
+	return sm.lookupDevice(token, true)
+}
+
+// lookupDevice finds the device holding token, expiring tokens first.
+func (sm *SessionManager) lookupDevice(token string, touch bool) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	hash := sha256.Sum256([]byte(token))
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return "", false
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	now := time.Now()
+	sm.sweepDevices(now)
+
+	d, exists := sm.session.devices[hash]
+	if !exists {
+		return "", false
+	}
+
+	if touch {
+		d.lastSeen = now
+		sm.session.lastActivity = now
+	}
+	return d.id, true
+}
+
+// sweepDevices drops tokens that have expired under the token policy.
+// After AutoLock without activity from any device, every token is dropped.
+// Caller must hold sm.mu (read) and sm.session.mu (write).
+func (sm *SessionManager) sweepDevices(now time.Time) {
+	s := sm.session
+	if len(s.devices) == 0 {
+		return
+	}
+
+	if sm.policy.AutoLock > 0 && now.Sub(s.lastActivity) > sm.policy.AutoLock {
+		s.clearDevices()
+		sm.publish(Event{Type: EventSessionAutoLocked})
+		return
+	}
+
+	for hash, d := range s.devices {
+		expired := (sm.policy.Lifetime > 0 && now.Sub(d.issuedAt) > sm.policy.Lifetime) ||
+			(sm.policy.IdleTimeout > 0 && now.Sub(d.lastSeen) > sm.policy.IdleTimeout)
+		if expired {
+			delete(s.devices, hash)
+			sm.publish(Event{Type: EventSessionDeviceExpired, ID: d.id})
+		}
+	}
+}
+
+// ListDevices returns the devices holding valid tokens, oldest first.
+func (sm *SessionManager) ListDevices() []DeviceInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	devices := []DeviceInfo{}
+	if sm.session == nil {
+		return devices
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	sm.sweepDevices(time.Now())
+
+	for _, d := range sm.session.devices {
+		devices = append(devices, sm.deviceInfo(d))
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.Before(devices[j].CreatedAt)
+	})
+
+	return devices
+}
+
+// RevokeDevice invalidates the token of the device with the given ID.
+func (sm *SessionManager) RevokeDevice(id string) error {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return ErrDeviceNotFound
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	for hash, d := range sm.session.devices {
+		if d.id == id {
+			delete(sm.session.devices, hash)
+			sm.publish(Event{Type: EventSessionDeviceRevoked, ID: id})
+			return nil
+		}
+	}
+
+	return ErrDeviceNotFound
+}
+
+// clearDevices invalidates all device tokens.
+// Caller must hold s.mu.
+func (s *Session) clearDevices() {
+	s.devices = nil
+}
+
+// deviceInfo returns the device metadata.
+// Caller must hold sm.mu.
+func (sm *SessionManager) deviceInfo(d *device) DeviceInfo {
+	info := DeviceInfo{
+		ID:        d.id,
+		Name:      d.name,
+		CreatedAt: d.createdAt,
+		LastSeen:  d.lastSeen,
+	}
+	if sm.policy.Lifetime > 0 {
+		expiresAt := d.issuedAt.Add(sm.policy.Lifetime)
+		info.ExpiresAt = &expiresAt
+	}
+	return info
+}
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+// sealedSession returns a session sealed with policy and the first device's token.
+func sealedSession(t *testing.T, policy TokenPolicy) (*SessionManager, string) {
+	t.Helper()
+
+	sm := NewSessionManager()
+	sm.SetTokenPolicy(policy)
+	t.Cleanup(sm.Destroy)
+
+	token, _, err := sm.Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "laptop", "", nil)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	return sm, token
+}
+
+func TestDeviceTokensRotateAndRevoke(t *testing.T) {
+	sm, laptop := sealedSession(t, TokenPolicy{})
+
+	phone, phoneInfo, err := sm.IssueDeviceToken("phone", "")
+	if err != nil {
+		t.Fatalf("IssueDeviceToken: %v", err)
+	}
+	if devices := sm.ListDevices(); len(devices) != 2 || devices[0].Name != "laptop" || devices[1].Name != "phone" {
+		t.Fatalf("devices = %+v, want laptop and phone", devices)
+	}
+
+	// Rotation keeps the device but retires its old token
+	rotated, rotatedInfo, err := sm.IssueDeviceToken("", phone)
+	if err != nil {
+		t.Fatalf("IssueDeviceToken: %v", err)
+	}
+	if rotatedInfo.ID != phoneInfo.ID || rotatedInfo.Name != "phone" {
+		t.Errorf("rotated device = %s %q, want %s %q", rotatedInfo.ID, rotatedInfo.Name, phoneInfo.ID, "phone")
+	}
+	if sm.ValidateToken(phone) {
+		t.Error("token still valid after rotation")
+	}
+	if !sm.ValidateToken(rotated) {
+		t.Error("rotated token rejected")
+	}
+
+	if err := sm.RevokeDevice(phoneInfo.ID); err != nil {
+		t.Fatalf("RevokeDevice: %v", err)
+	}
+	if sm.ValidateToken(rotated) {
+		t.Error("revoked device's token still valid")
+	}
+	if !sm.ValidateToken(laptop) {
+		t.Error("revoking one device invalidated another")
+	}
+	if err := sm.RevokeDevice(phoneInfo.ID); err != ErrDeviceNotFound {
+		t.Errorf("revoking twice: got %v, want %v", err, ErrDeviceNotFound)
+	}
+
+	// Unlocking invalidates every token
+	if err := sm.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if sm.ValidateToken(laptop) {
+		t.Error("token valid after unlock")
+	}
+	if _, _, err := sm.IssueDeviceToken("tablet", ""); err != ErrSessionNotLocked {
+		t.Errorf("issue while unlocked: got %v, want %v", err, ErrSessionNotLocked)
+	}
+}
+
+func TestDeviceTokenLimit(t *testing.T) {
+	sm, _ := sealedSession(t, TokenPolicy{})
+
+	for i := 1; i < MaxDevices; i++ {
+		if _, _, err := sm.IssueDeviceToken("device", ""); err != nil {
+			t.Fatalf("IssueDeviceToken %d: %v", i, err)
+		}
+	}
+	if _, _, err := sm.IssueDeviceToken("device", ""); err != ErrTooManyDevices {
+		t.Errorf("device over the limit: got %v, want %v", err, ErrTooManyDevices)
+	}
+}
+
+// ageDevices moves the issue and use times of every device, and the
+// session's last activity, back by d.
+func ageDevices(sm *SessionManager, d time.Duration) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	for _, device := range sm.session.devices {
+		device.issuedAt = device.issuedAt.Add(-d)
+		device.lastSeen = device.lastSeen.Add(-d)
+	}
+	sm.session.lastActivity = sm.session.lastActivity.Add(-d)
+}
+
+func TestDeviceTokenLifetime(t *testing.T) {
+	sm, token := sealedSession(t, TokenPolicy{Lifetime: time.Hour})
+
+	if devices := sm.ListDevices(); len(devices) != 1 || devices[0].ExpiresAt == nil {
+		t.Fatalf("devices = %+v, want one with an expiry", devices)
+	}
+
+	// Use does not extend the lifetime; rotation does
+	ageDevices(sm, 50*time.Minute)
+	if !sm.ValidateToken(token) {
+		t.Fatal("token expired before its lifetime")
+	}
+	rotated, _, err := sm.IssueDeviceToken("", token)
+	if err != nil {
+		t.Fatalf("IssueDeviceToken: %v", err)
+	}
+	ageDevices(sm, 50*time.Minute)
+	if !sm.ValidateToken(rotated) {
+		t.Error("rotated token expired with the lifetime of the old one")
+	}
+	ageDevices(sm, 20*time.Minute)
+	if sm.ValidateToken(rotated) {
+		t.Error("token valid past its lifetime")
+	}
+	if !sm.IsLocked() {
+		t.Error("token expiry unlocked the session")
+	}
+}
+
+func TestDeviceTokenIdleTimeout(t *testing.T) {
+	sm, laptop := sealedSession(t, TokenPolicy{IdleTimeout: time.Hour})
+	phone, _, err := sm.IssueDeviceToken("phone", "")
+	if err != nil {
+		t.Fatalf("IssueDeviceToken: %v", err)
+	}
+
+	ageDevices(sm, 40*time.Minute)
+	if !sm.ValidateToken(laptop) {
+		t.Fatal("used token expired")
+	}
+	ageDevices(sm, 40*time.Minute)
+
+	// The laptop was used 40 minutes ago, the phone 80
+	if !sm.ValidateToken(laptop) {
+		t.Error("token expired within the idle timeout")
+	}
+	if sm.ValidateToken(phone) {
+		t.Error("idle token still valid")
+	}
+
+	// Checks do not count as use
+	ageDevices(sm, 40*time.Minute)
+	if !sm.CheckToken(laptop) {
+		t.Fatal("token expired within the idle timeout")
+	}
+	ageDevices(sm, 40*time.Minute)
+	if sm.CheckToken(laptop) {
+		t.Error("CheckToken kept an idle token alive")
+	}
+}
+
+func TestSessionAutoLock(t *testing.T) {
+	sm, laptop := sealedSession(t, TokenPolicy{AutoLock: time.Hour})
+	phone, _, err := sm.IssueDeviceToken("phone", "")
+	if err != nil {
+		t.Fatalf("IssueDeviceToken: %v", err)
+	}
+
+	// Activity from any device keeps every token valid
+	ageDevices(sm, 50*time.Minute)
+	if !sm.ValidateToken(laptop) {
+		t.Fatal("token expired before auto-lock")
+	}
+	ageDevices(sm, 50*time.Minute)
+	if !sm.ValidateToken(phone) {
+		t.Fatal("auto-lock ignored activity from another device")
+	}
+
+	ageDevices(sm, 70*time.Minute)
+	if sm.ValidateToken(laptop) || sm.ValidateToken(phone) {
+		t.Error("tokens still valid after auto-lock")
+	}
+	if !sm.IsLocked() {
+		t.Error("auto-lock unsealed the session")
+	}
+}
@@ -0,0 +1,626 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+var (
+	// ErrRevisionTooOld indicates the operations since a revision have been compacted away.
+	// The client must reload the document.
+	ErrRevisionTooOld = errors.New("revision too old")
+	// ErrInvalidRevision indicates a revision newer than the document.
+	ErrInvalidRevision = errors.New("invalid revision")
+	// ErrRevisionConflict indicates a sealed operation was not based on the latest revision.
+	// Sealed operations cannot be transformed by the server; the client must rebase.
+	ErrRevisionConflict = errors.New("revision conflict")
+	// ErrDocumentTooLarge indicates the edited document would exceed the size limit.
+	ErrDocumentTooLarge = errors.New("document too large")
+	// ErrDocumentSealed indicates a plaintext operation on a sealed document.
+	ErrDocumentSealed = errors.New("document is sealed")
+	// ErrDocumentNotSealed indicates an encrypted operation on an unsealed document.
+	ErrDocumentNotSealed = errors.New("document is not sealed")
+)
+
+// DefaultDocumentHistory is the default number of operations kept for catching up.
+const DefaultDocumentHistory = 256
+
+// DocumentOp is an operation in the document log.
+type DocumentOp struct {
+	Revision  uint64         // Revision produced by this operation
+	Op        *TextOperation // Plaintext mode
+	Encrypted []byte         // Sealed mode (opaque to the server)
+}
+
+// documentOp is a logged operation held in secure memory.
+type documentOp struct {
+	revision  uint64
+	data      *secure.FortifiedBuffer // JSON-encoded TextOperation (plaintext mode)
+	encrypted []byte                  // Ciphertext (sealed mode)
+	size      int
+}
+
+// DocumentStore holds the shared Wormhole document.
+//
+// Unsealed, the server merges concurrent edits with operational transform:
+// clients send operations based on the revision they last saw, and the server
+// transforms them against everything that happened since.
+//
+// Sealed, the server cannot read operations, so it only orders them: an
+// encrypted operation is accepted only if it is based on the latest revision,
+// and clients fetch, decrypt and rebase their pending edits themselves.
+type DocumentStore struct {
+	mu sync.Mutex
+
+	// Plaintext document (unsealed)
+	text   *secure.FortifiedBuffer
+	length int // Unicode code points
+
+	// Encrypted snapshot (sealed) taken at snapshotRevision
+	snapshot         []byte
+	snapshotRevision uint64
+	sealed           bool
+
+	// Operation log: operations base+1 .. revision
+	revision uint64
+	base     uint64
+	log      []*documentOp
+
+	updatedAt time.Time
+
+	// Configuration
+	maxOps  int
+	expiry  time.Duration
+	maxSize int
+
+	// Memory tracker
+	memory *secure.MemoryTracker
+
+	// Change notifications
+	events *EventBus
+
+	// Shutdown signal
+	done chan struct{}
+}
+
+// NewDocumentStore creates a new document store.
+// maxOps bounds the operation log; expiry shreds the document after that long without edits.
+func NewDocumentStore(memory *secure.MemoryTracker, maxOps int, expiry time.Duration) *DocumentStore {
+	if maxOps <= 0 {
+		maxOps = DefaultDocumentHistory
+	}
+	if expiry == 0 {
+		expiry = 1 * time.Hour
+	}
+
+	store := &DocumentStore{
+		maxOps:  maxOps,
+		expiry:  expiry,
+		maxSize: validate.MaxClipboardSize,
+		memory:  memory,
+		done:    make(chan struct{}),
+	}
+
+	// Start expiry checker
+	go store.expiryLoop()
+
+	return store
+}
+
+// SetEventBus sets the bus that receives document change events.
+func (ds *DocumentStore) SetEventBus(events *EventBus) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	ds.events = events
+}
+
+// Revision returns the current document revision.
+func (ds *DocumentStore) Revision() uint64 {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.revision
+}
+
+// Get returns a copy of the plaintext document and its revision.
+func (ds *DocumentStore) Get() ([]byte, uint64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.sealed {
+		return nil, 0, ErrDocumentSealed
+	}
+
+	content, err := ds.textBytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	return content, ds.revision, nil
+}
+
+// GetEncrypted returns the encrypted snapshot, its revision and the current revision.
+// Clients decrypt the snapshot and replay Ops(snapshotRevision) on top.
+func (ds *DocumentStore) GetEncrypted() ([]byte, uint64, uint64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if !ds.sealed {
+		return nil, 0, 0, ErrDocumentNotSealed
+	}
+
+	var snapshot []byte
+	if ds.snapshot != nil {
+		snapshot = make([]byte, len(ds.snapshot))
+		copy(snapshot, ds.snapshot)
+	}
+	return snapshot, ds.snapshotRevision, ds.revision, nil
+}
+
+// EncryptedSnapshotInfo returns the size and revision of the encrypted
+// snapshot without copying it.
+func (ds *DocumentStore) EncryptedSnapshotInfo() (int, uint64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if !ds.sealed {
+		return 0, 0, ErrDocumentNotSealed
+	}
+	return len(ds.snapshot), ds.snapshotRevision, nil
+}
+
+// Apply merges a plaintext operation based on revision into the document.
+// Returns the new revision and the operation as applied (transformed against
+// concurrent operations), which the sender uses to acknowledge its edit.
+func (ds *DocumentStore) Apply(revision uint64, op TextOperation) (uint64, TextOperation, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.sealed {
+		return 0, TextOperation{}, ErrDocumentSealed
+	}
+	if err := ds.checkRevision(revision); err != nil {
+		return ds.revision, TextOperation{}, err
+	}
+
+	// Transform against every operation the client has not seen
+	for _, logged := range ds.log {
+		if logged.revision <= revision {
+			continue
+		}
+		concurrent, err := decodeDocumentOp(logged)
+		if err != nil {
+			return 0, TextOperation{}, err
+		}
+		if op, _, err = TransformOperations(op, concurrent); err != nil {
+			return 0, TextOperation{}, err
+		}
+	}
+
+	if op.BaseLength() != ds.length {
+		return 0, TextOperation{}, ErrOperationLength
+	}
+
+	encodedOp, err := json.Marshal(op)
+	if err != nil {
+		return 0, TextOperation{}, err
+	}
+	defer secure.Shred(encodedOp)
+
+	opBuf, err := secure.NewFortifiedBuffer(encodedOp)
+	if err != nil {
+		return 0, TextOperation{}, err
+	}
+	if ds.memory != nil {
+		if err := ds.memory.Allocate(int64(opBuf.Size())); err != nil {
+			secure.ShredFortifiedBuffer(opBuf)
+			return 0, TextOperation{}, ErrStorageFull
+		}
+	}
+	entry := &documentOp{data: opBuf, size: opBuf.Size()}
+
+	if err := ds.applyText(op); err != nil {
+		ds.shredOp(entry)
+		return 0, TextOperation{}, err
+	}
+
+	ds.appendOp(entry)
+
+	return ds.revision, op, nil
+}
+
+// AppendEncrypted appends an encrypted operation based on revision.
+// Returns ErrRevisionConflict (with the current revision) unless revision is
+// the latest: the server cannot transform sealed operations.
+func (ds *DocumentStore) AppendEncrypted(revision uint64, encrypted []byte) (uint64, error) {
+	if len(encrypted) == 0 {
+		return 0, ErrInvalidOperation
+	}
+	if len(encrypted) > ds.maxSize {
+		return 0, ErrDocumentTooLarge
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if !ds.sealed {
+		return 0, ErrDocumentNotSealed
+	}
+	if revision != ds.revision {
+		return ds.revision, ErrRevisionConflict
+	}
+
+	entry := &documentOp{
+		encrypted: make([]byte, len(encrypted)),
+		size:      len(encrypted),
+	}
+	copy(entry.encrypted, encrypted)
+
+	if ds.memory != nil {
+		if err := ds.memory.Allocate(int64(entry.size)); err != nil {
+			secure.Shred(entry.encrypted)
+			return 0, ErrStorageFull
+		}
+	}
+
+	ds.appendOp(entry)
+	return ds.revision, nil
+}
+
+// SetEncryptedSnapshot replaces the encrypted snapshot with one taken at revision.
+// Logged operations up to revision are dropped, compacting the log.
+func (ds *DocumentStore) SetEncryptedSnapshot(revision uint64, encrypted []byte) error {
+	if len(encrypted) > ds.maxSize {
+		return ErrDocumentTooLarge
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if !ds.sealed {
+		return ErrDocumentNotSealed
+	}
+	if err := ds.checkRevision(revision); err != nil {
+		return err
+	}
+	if revision < ds.snapshotRevision {
+		return ErrRevisionTooOld
+	}
+
+	if ds.memory != nil {
+		if err := ds.memory.Allocate(int64(len(encrypted))); err != nil {
+			return ErrStorageFull
+		}
+	}
+	ds.clearSnapshot()
+	ds.snapshot = make([]byte, len(encrypted))
+	copy(ds.snapshot, encrypted)
+	ds.snapshotRevision = revision
+
+	// Operations up to the snapshot are no longer needed
+	ds.trimLog(func(op *documentOp) bool { return op.revision <= revision })
+
+	return nil
+}
+
+// Ops returns the logged operations after revision since.
+func (ds *DocumentStore) Ops(since uint64) ([]DocumentOp, uint64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if err := ds.checkRevision(since); err != nil {
+		return nil, ds.revision, err
+	}
+
+	ops := make([]DocumentOp, 0, len(ds.log))
+	for _, logged := range ds.log {
+		if logged.revision <= since {
+			continue
+		}
+
+		if logged.encrypted != nil {
+			encrypted := make([]byte, len(logged.encrypted))
+			copy(encrypted, logged.encrypted)
+			ops = append(ops, DocumentOp{Revision: logged.revision, Encrypted: encrypted})
+			continue
+		}
+
+		op, err := decodeDocumentOp(logged)
+		if err != nil {
+			return nil, ds.revision, err
+		}
+		ops = append(ops, DocumentOp{Revision: logged.revision, Op: &op})
+	}
+
+	return ops, ds.revision, nil
+}
+
+// Seal switches the document to sealed mode.
+// The plaintext and its log are shredded; encrypted (optional) becomes the
+// snapshot at the current revision. The document is sealed even if the
+// snapshot cannot be stored.
+func (ds *DocumentStore) Seal(encrypted []byte) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.shredLocked()
+	ds.sealed = true
+	ds.snapshotRevision = ds.revision
+
+	if len(encrypted) == 0 {
+		return nil
+	}
+	if len(encrypted) > ds.maxSize {
+		return ErrDocumentTooLarge
+	}
+	if ds.memory != nil {
+		if err := ds.memory.Allocate(int64(len(encrypted))); err != nil {
+			return ErrStorageFull
+		}
+	}
+
+	ds.snapshot = make([]byte, len(encrypted))
+	copy(ds.snapshot, encrypted)
+	return nil
+}
+
+// HasData returns whether the document holds any content.
+func (ds *DocumentStore) HasData() bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	return ds.length > 0 || ds.snapshot != nil || len(ds.log) > 0
+}
+
+// ShredAll securely destroys the document and its log and returns to
+// unsealed mode. The revision keeps increasing so clients notice and reload.
+func (ds *DocumentStore) ShredAll() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	ds.shredLocked()
+	ds.sealed = false
+	ds.updatedAt = time.Time{}
+	ds.revision++
+	ds.base = ds.revision
+	ds.snapshotRevision = ds.revision
+	ds.events.Publish(Event{Type: EventDocumentDeleted, Revision: ds.revision})
+}
+
+// Close stops the expiry loop and shreds the document.
+// Should be called on application shutdown.
+func (ds *DocumentStore) Close() {
+	close(ds.done)
+	ds.ShredAll()
+}
+
+// checkRevision verifies that the log can serve operations after revision.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) checkRevision(revision uint64) error {
+	if revision > ds.revision {
+		return ErrInvalidRevision
+	}
+	if revision < ds.base {
+		return ErrRevisionTooOld
+	}
+	return nil
+}
+
+// applyText applies op to the plaintext document.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) applyText(op TextOperation) error {
+	current, err := ds.textBytes()
+	if err != nil {
+		return err
+	}
+	doc := []rune(string(current))
+	secure.Shred(current)
+	defer shredRunes(doc)
+
+	updated, err := op.Apply(doc)
+	if err != nil {
+		return err
+	}
+	defer shredRunes(updated)
+
+	content := []byte(string(updated))
+	defer secure.Shred(content)
+
+	if len(content) > ds.maxSize {
+		return ErrDocumentTooLarge
+	}
+	if !utf8.Valid(content) {
+		return ErrInvalidOperation
+	}
+
+	oldSize := 0
+	if ds.text != nil {
+		oldSize = ds.text.Size()
+	}
+	if ds.memory != nil {
+		if err := ds.memory.Allocate(int64(len(content))); err != nil {
+			return ErrStorageFull
+		}
+	}
+
+	var buf *secure.FortifiedBuffer
+	if len(content) > 0 {
+		buf, err = secure.NewFortifiedBuffer(content)
+		if err != nil {
+			if ds.memory != nil {
+				ds.memory.Free(int64(len(content)))
+			}
+			return err
+		}
+	}
+
+	if ds.text != nil {
+		secure.ShredFortifiedBuffer(ds.text)
+	}
+	if ds.memory != nil {
+		ds.memory.Free(int64(oldSize))
+	}
+
+	ds.text = buf
+	ds.length = len(updated)
+	return nil
+}
+
+// appendOp appends an entry at the next revision and trims the log.
+// Memory for the entry must already be allocated.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) appendOp(entry *documentOp) {
+	ds.revision++
+	entry.revision = ds.revision
+	ds.log = append(ds.log, entry)
+
+	if over := len(ds.log) - ds.maxOps; over > 0 {
+		dropped := 0
+		ds.trimLog(func(*documentOp) bool {
+			dropped++
+			return dropped <= over
+		})
+	}
+
+	ds.publishChange()
+}
+
+// publishChange records an edit and notifies subscribers.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) publishChange() {
+	ds.updatedAt = time.Now()
+	ds.events.Publish(Event{Type: EventDocumentChanged, Revision: ds.revision})
+}
+
+// trimLog shreds leading log entries while drop returns true.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) trimLog(drop func(*documentOp) bool) {
+	i := 0
+	for ; i < len(ds.log) && drop(ds.log[i]); i++ {
+		ds.base = ds.log[i].revision
+		ds.shredOp(ds.log[i])
+		ds.log[i] = nil
+	}
+	ds.log = ds.log[i:]
+}
+
+// textBytes returns a copy of the plaintext document.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) textBytes() ([]byte, error) {
+	if ds.text == nil {
+		return []byte{}, nil
+	}
+
+	var result []byte
+	err := ds.text.Use(func(d []byte) error {
+		result = make([]byte, len(d))
+		copy(result, d)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// shredOp securely destroys a logged operation.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) shredOp(op *documentOp) {
+	if ds.memory != nil {
+		ds.memory.Free(int64(op.size))
+	}
+	if op.data != nil {
+		secure.ShredFortifiedBuffer(op.data)
+		op.data = nil
+	}
+	if op.encrypted != nil {
+		secure.Shred(op.encrypted)
+		op.encrypted = nil
+	}
+}
+
+// clearSnapshot shreds the encrypted snapshot.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) clearSnapshot() {
+	if ds.snapshot == nil {
+		return
+	}
+	if ds.memory != nil {
+		ds.memory.Free(int64(len(ds.snapshot)))
+	}
+	secure.Shred(ds.snapshot)
+	ds.snapshot = nil
+}
+
+// shredLocked destroys the document content, snapshot and log.
+// Caller must hold ds.mu.
+func (ds *DocumentStore) shredLocked() {
+	if ds.text != nil {
+		if ds.memory != nil {
+			ds.memory.Free(int64(ds.text.Size()))
+		}
+		secure.ShredFortifiedBuffer(ds.text)
+		ds.text = nil
+	}
+	ds.length = 0
+
+	ds.clearSnapshot()
+	ds.trimLog(func(*documentOp) bool { return true })
+	ds.base = ds.revision
+}
+
+// expiryLoop periodically shreds the document once it has been idle too long.
+func (ds *DocumentStore) expiryLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ds.cleanupExpired()
+		case <-ds.done:
+			return
+		}
+	}
+}
+
+// cleanupExpired shreds the document if it has not been edited within the expiry.
+// The sealed state is kept so sealed clients can start a new document.
+func (ds *DocumentStore) cleanupExpired() {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if ds.updatedAt.IsZero() || time.Since(ds.updatedAt) <= ds.expiry {
+		return
+	}
+
+	ds.shredLocked()
+	ds.revision++
+	ds.base = ds.revision
+	ds.snapshotRevision = ds.revision
+	ds.updatedAt = time.Time{}
+	ds.events.Publish(Event{Type: EventDocumentExpired, Revision: ds.revision})
+}
+
+// decodeDocumentOp decodes a logged plaintext operation.
+func decodeDocumentOp(logged *documentOp) (TextOperation, error) {
+	if logged.data == nil {
+		return TextOperation{}, ErrInvalidOperation
+	}
+
+	var op TextOperation
+	err := logged.data.Use(func(d []byte) error {
+		return json.Unmarshal(d, &op)
+	})
+	return op, err
+}
+
+// shredRunes zeroes a rune slice holding document text.
+func shredRunes(r []rune) {
+	for i := range r {
+		r[i] = 0
+	}
+}
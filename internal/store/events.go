@@ -0,0 +1,159 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of change an Event describes.
+type EventType string
+
+const (
+	// EventClipboardSet is published when a clipboard entry is stored.
+	EventClipboardSet EventType = "clipboard.set"
+	// EventClipboardDeleted is published when clipboard content is shredded on request.
+	EventClipboardDeleted EventType = "clipboard.deleted"
+	// EventClipboardExpired is published when clipboard content expires.
+	EventClipboardExpired EventType = "clipboard.expired"
+	// EventChannelDeleted is published when a clipboard channel is deleted.
+	EventChannelDeleted EventType = "channel.deleted"
+	// EventFileAdded is published when a file is stored.
+	EventFileAdded EventType = "file.added"
+	// EventFileDeleted is published when a file is shredded on request.
+	EventFileDeleted EventType = "file.deleted"
+	// EventFileExpired is published when a file expires.
+	EventFileExpired EventType = "file.expired"
+	// EventDocumentChanged is published when the shared document is edited.
+	EventDocumentChanged EventType = "document.changed"
+	// EventDocumentDeleted is published when the shared document is shredded on request.
+	EventDocumentDeleted EventType = "document.deleted"
+	// EventDocumentExpired is published when the shared document expires.
+	EventDocumentExpired EventType = "document.expired"
+	// EventSessionLocked is published when the session is sealed.
+	EventSessionLocked EventType = "session.locked"
+	// EventSessionUnlocked is published when the session is unsealed.
+	EventSessionUnlocked EventType = "session.unlocked"
+	// EventSessionForceUnlocked is published when the session is force-unlocked (data shredded).
+	EventSessionForceUnlocked EventType = "session.force_unlocked"
+	// EventSessionDeviceRevoked is published when a device token is revoked.
+	EventSessionDeviceRevoked EventType = "session.device_revoked"
+	// EventSessionDeviceExpired is published when a device token reaches its lifetime or idle limit.
+	EventSessionDeviceExpired EventType = "session.device_expired"
+	// EventSessionAutoLocked is published when inactivity invalidates all device tokens.
+	EventSessionAutoLocked EventType = "session.auto_locked"
+	// EventSessionRekeyed is published when the session password is changed and its data re-encrypted.
+	EventSessionRekeyed EventType = "session.rekeyed"
+	// EventSessionKeySlotsChanged is published when a key slot is added or removed.
+	EventSessionKeySlotsChanged EventType = "session.key_slots_changed"
+)
+
+// DefaultEventBuffer is the number of events buffered per subscriber.
+const DefaultEventBuffer = 64
+
+// Event is a change notification.
+// SECURITY: Events carry metadata only - never content, names or sizes.
+type Event struct {
+	Seq      uint64    `json:"seq"`
+	Type     EventType `json:"type"`
+	Kind     string    `json:"kind,omitempty"`     // Clipboard: "text" or "image"
+	Channel  string    `json:"channel,omitempty"`  // Clipboard channel name ("" = default clipboard)
+	ID       string    `json:"id,omitempty"`       // File ID, clipboard entry ID or device ID
+	Revision uint64    `json:"revision,omitempty"` // Document revision
+	Scope    LockScope `json:"scope,omitempty"`    // Lock scope of a session event ("" = whole session)
+	Time     time.Time `json:"time"`
+}
+
+// EventBus fans out store change events to subscribers.
+// Publishing never blocks: a subscriber that falls behind by more than its
+// buffer is dropped (its channel is closed) and must resubscribe and resync.
+type EventBus struct {
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	buffer      int
+	seq         uint64
+	closed      bool
+}
+
+// NewEventBus creates a new event bus.
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subscribers: make(map[chan Event]struct{}),
+		buffer:      DefaultEventBuffer,
+	}
+}
+
+// Subscribe registers a new subscriber.
+// Returns the event channel and a function that unsubscribes; the channel is
+// closed on unsubscribe or when the subscriber is dropped for falling behind.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, b.buffer)
+
+	b.mu.Lock()
+	if b.closed {
+		close(ch)
+	} else {
+		b.subscribers[ch] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.subscribers[ch]; ok {
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish sends an event to all subscribers.
+// Safe to call on a nil bus (no-op), so stores work without one.
+func (b *EventBus) Publish(event Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event.Seq = b.seq
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber is too slow - drop it rather than block the store
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// SubscriberCount returns the number of active subscribers.
+func (b *EventBus) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// Close ends all subscriptions and rejects new ones.
+// Should be called on shutdown so long-lived streams return promptly.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
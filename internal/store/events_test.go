@@ -0,0 +1,74 @@
+package store
+
+import "testing"
+
+func TestEventBusFansOutToAllSubscribers(t *testing.T) {
+	bus := NewEventBus()
+	first, unsubscribeFirst := bus.Subscribe()
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := bus.Subscribe()
+	defer unsubscribeSecond()
+
+	bus.Publish(Event{Type: EventClipboardSet, Kind: "text"})
+	bus.Publish(Event{Type: EventFileAdded, ID: "0000000000000001"})
+
+	for name, events := range map[string]<-chan Event{"first": first, "second": second} {
+		for i, want := range []EventType{EventClipboardSet, EventFileAdded} {
+			event := <-events
+			if event.Type != want || event.Seq != uint64(i+1) {
+				t.Errorf("%s subscriber event %d: got %s #%d, want %s #%d", name, i, event.Type, event.Seq, want, i+1)
+			}
+			if event.Time.IsZero() {
+				t.Errorf("%s subscriber event %d has no time", name, i)
+			}
+		}
+	}
+}
+
+func TestEventBusDropsSlowSubscribers(t *testing.T) {
+	bus := NewEventBus()
+	slow, unsubscribeSlow := bus.Subscribe()
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := bus.Subscribe()
+	defer unsubscribeFast()
+
+	// The slow subscriber never reads; one event past its buffer drops it
+	for i := 0; i <= DefaultEventBuffer; i++ {
+		bus.Publish(Event{Type: EventClipboardSet})
+		<-fast
+	}
+
+	if n := bus.SubscriberCount(); n != 1 {
+		t.Errorf("%d subscribers after the slow one fell behind, want 1", n)
+	}
+	received := 0
+	for range slow {
+		received++
+	}
+	if received != DefaultEventBuffer {
+		t.Errorf("dropped subscriber received %d buffered events, want %d", received, DefaultEventBuffer)
+	}
+
+	// The publisher never blocked, and the fast subscriber keeps receiving
+	bus.Publish(Event{Type: EventFileAdded})
+	if event := <-fast; event.Type != EventFileAdded {
+		t.Errorf("fast subscriber got %s, want %s", event.Type, EventFileAdded)
+	}
+}
+
+func TestEventBusCloseEndsSubscriptions(t *testing.T) {
+	bus := NewEventBus()
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	bus.Close()
+	if _, ok := <-events; ok {
+		t.Error("subscription still open after Close")
+	}
+
+	late, unsubscribeLate := bus.Subscribe()
+	defer unsubscribeLate()
+	if _, ok := <-late; ok {
+		t.Error("subscription accepted after Close")
+	}
+}
@@ -28,13 +28,15 @@ type StoredFile struct {
 	mu sync.RWMutex
 
 	// Identifiers
-	ID string
+	ID      string
+	Version uint64 // Store version at which the file was added (ETag source)
 
 	// Content (either plaintext or encrypted)
-	data      *secure.FortifiedBuffer // Plaintext when unlocked (with memory obfuscation)
-	encrypted []byte                  // Ciphertext when locked
+	data              *secure.FortifiedBuffer // Plaintext when unlocked (with memory obfuscation)
+	encrypted         []byte                  // Ciphertext when locked
+	encryptedMetadata []byte                  // Client-encrypted name, MIME type and size when locked
 
-	// Metadata
+	// Metadata (when locked: no name or MIME type, and Size is the padded size bucket)
 	Filename  string
 	MimeType  string
 	Size      int64
@@ -48,6 +50,9 @@ type FileStore struct {
 
 	files map[string]*StoredFile
 
+	// Increases whenever the file list changes
+	version uint64
+
 	// Configuration
 	maxFileSize int64
 	expiry      time.Duration
@@ -58,6 +63,9 @@ type FileStore struct {
 	// Memory tracker
 	memory *secure.MemoryTracker
 
+	// Change notifications
+	events *EventBus
+
 	// Shutdown signal
 	done chan struct{}
 }
@@ -86,9 +94,23 @@ func NewFileStore(session *SessionManager, memory *secure.MemoryTracker, maxFile
 	return store
 }
 
+// SetEventBus sets the bus that receives file change events.
+func (fs *FileStore) SetEventBus(events *EventBus) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.events = events
+}
+
+// Version returns the store version, which changes whenever a file is added or removed.
+func (fs *FileStore) Version() uint64 {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.version
+}
+
 // Store stores a file and returns its ID (plaintext in SecureBuffer).
 // E2EE: This is only called when session is unlocked. When locked, encrypted
-// blobs are stored via SetEncryptedFiles.
+// blobs are stored via BeginSeal and AddEncryptedFile.
 // WARNING: The content slice is always shredded after this call, even on error.
 // Caller should not reuse the slice.
 func (fs *FileStore) Store(filename string, mimeType string, content []byte) (string, error) {
@@ -149,7 +171,10 @@ func (fs *FileStore) Store(filename string, mimeType string, content []byte) (st
 	}
 
 	fs.mu.Lock()
+	fs.version++
+	file.Version = fs.version
 	fs.files[id] = file
+	fs.events.Publish(Event{Type: EventFileAdded, ID: id})
 	fs.mu.Unlock()
 
 	return id, nil
@@ -226,25 +251,33 @@ func (fs *FileStore) GetMetadata(id string) (*StoredFile, error) {
 }
 
 // Delete securely shreds and removes a file.
-func (fs *FileStore) Delete(id string) error {
+// ifVersion makes the delete conditional on the file version (AnyVersion to skip the check).
+// Returns the file version, so a caller can report it on ErrVersionMismatch.
+func (fs *FileStore) Delete(id string, ifVersion uint64) (uint64, error) {
 	id, err := validate.FileID(id)
 	if err != nil {
-		return ErrFileNotFound
+		return 0, ErrFileNotFound
 	}
 
 	fs.mu.Lock()
 	file, exists := fs.files[id]
 	if !exists {
 		fs.mu.Unlock()
-		return ErrFileNotFound
+		return 0, ErrFileNotFound
+	}
+	if ifVersion != AnyVersion && ifVersion != file.Version {
+		fs.mu.Unlock()
+		return file.Version, ErrVersionMismatch
 	}
 	delete(fs.files, id)
+	fs.version++
+	fs.events.Publish(Event{Type: EventFileDeleted, ID: id})
 	fs.mu.Unlock()
 
 	// Shred file data
 	fs.shredFile(file)
 
-	return nil
+	return file.Version, nil
 }
 
 // List returns metadata for all stored files.
@@ -260,6 +293,7 @@ func (fs *FileStore) List() []FileInfo {
 		if !now.After(file.ExpiresAt) {
 			files = append(files, FileInfo{
 				ID:        file.ID,
+				Version:   file.Version,
 				Filename:  file.Filename,
 				MimeType:  file.MimeType,
 				Size:      file.Size,
@@ -281,6 +315,7 @@ func (fs *FileStore) List() []FileInfo {
 // FileInfo contains file metadata for API responses.
 type FileInfo struct {
 	ID        string    `json:"id"`
+	Version   uint64    `json:"version"`
 	Filename  string    `json:"filename"`
 	MimeType  string    `json:"mime_type"`
 	Size      int64     `json:"size"`
@@ -319,6 +354,10 @@ func (fs *FileStore) shredFile(file *StoredFile) {
 		secure.Shred(file.encrypted)
 		file.encrypted = nil
 	}
+	if file.encryptedMetadata != nil {
+		secure.Shred(file.encryptedMetadata)
+		file.encryptedMetadata = nil
+	}
 }
 
 // ShredAll securely destroys all stored files.
@@ -332,6 +371,7 @@ func (fs *FileStore) ShredAll() int {
 		fs.shredFile(file)
 		delete(fs.files, id)
 	}
+	fs.version++
 
 	return count
 }
@@ -383,6 +423,8 @@ func (fs *FileStore) cleanupExpired() {
 		if file, exists := fs.files[id]; exists {
 			fs.shredFile(file)
 			delete(fs.files, id)
+			fs.version++
+			fs.events.Publish(Event{Type: EventFileExpired, ID: id})
 		}
 	}
 }
@@ -413,93 +455,183 @@ func (fs *FileStore) Stats() FileStoreStats {
 	}
 }
 
-// EncryptedFileInfo contains file metadata and encrypted data for E2EE.
+// MaxEncryptedMetadataSize bounds a file's encrypted metadata blob.
+const MaxEncryptedMetadataSize = 4096
+
+// EncryptedFileInfo contains encrypted file metadata and data for E2EE.
+// The server never sees the name, MIME type or exact size.
 type EncryptedFileInfo struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	MimeType    string `json:"mimetype"`
-	Size        int64  `json:"size"`
-	EncryptedB64 string `json:"encrypted_b64"`
+	ID                   string `json:"id"`
+	EncryptedMetadataB64 string `json:"encryptedMetadata_b64"` // Name, MIME type and size, encrypted by the client
+	SizeBucket           int64  `json:"sizeBucket,omitempty"`  // Padded size, set by the server
+	EncryptedB64         string `json:"encrypted_b64"`
 }
 
-// SetEncryptedFiles stores already-encrypted file blobs from the client.
-// Used during E2EE lock operation - server cannot decrypt this data.
-// Shreds all existing files first.
-func (fs *FileStore) SetEncryptedFiles(files []EncryptedFileInfo) {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+// decodeEncryptedFile decodes the content and metadata of f, which must be
+// crypto envelopes bound to f.ID, and returns the content's size bucket.
+// f.ID must be a normalized file ID, or validate.ErrInvalidFileID is returned.
+func decodeEncryptedFile(f EncryptedFileInfo) ([]byte, []byte, int64, error) {
+	if id, err := validate.FileID(f.ID); err != nil || id != f.ID {
+		return nil, nil, 0, validate.ErrInvalidFileID
+	}
 
-	// Shred all existing files
-	for id, file := range fs.files {
-		fs.shredFile(file)
-		delete(fs.files, id)
+	encrypted, err := base64.StdEncoding.DecodeString(f.EncryptedB64)
+	if err != nil {
+		return nil, nil, 0, crypto.ErrInvalidEnvelope
+	}
+	envelope, err := crypto.ParseEnvelope(encrypted)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if envelope.Type != crypto.EnvelopeFile || envelope.ID != f.ID {
+		return nil, nil, 0, crypto.ErrEnvelopeMismatch
 	}
 
-	// Store encrypted blobs
-	now := time.Now()
-	for _, f := range files {
-		encrypted, err := base64.StdEncoding.DecodeString(f.EncryptedB64)
-		if err != nil {
-			continue
-		}
+	metadata, err := base64.StdEncoding.DecodeString(f.EncryptedMetadataB64)
+	if err != nil || len(metadata) > MaxEncryptedMetadataSize {
+		return nil, nil, 0, crypto.ErrInvalidEnvelope
+	}
+	if err := crypto.CheckEnvelope(metadata, crypto.EnvelopeFileMetadata, f.ID); err != nil {
+		return nil, nil, 0, err
+	}
 
-		fs.files[f.ID] = &StoredFile{
-			ID:        f.ID,
-			encrypted: encrypted,
-			Filename:  f.Name,
-			MimeType:  f.MimeType,
-			Size:      f.Size,
-			CreatedAt: now,
-			ExpiresAt: now.Add(fs.expiry),
-		}
+	return encrypted, metadata, crypto.SizeBucket(int64(envelope.PlaintextSize())), nil
+}
+
+// newEncryptedFile returns a stored sealed file without plaintext metadata.
+func (fs *FileStore) newEncryptedFile(id string, encrypted, metadata []byte, bucket int64, now time.Time) *StoredFile {
+	return &StoredFile{
+		ID:                id,
+		Version:           fs.version,
+		encrypted:         encrypted,
+		encryptedMetadata: metadata,
+		Size:              bucket,
+		CreatedAt:         now,
+		ExpiresAt:         now.Add(fs.expiry),
+	}
+}
+
+// EncryptedMetadata returns a copy of the file's encrypted metadata blob.
+// Returns nil for plaintext files.
+func (f *StoredFile) EncryptedMetadata() []byte {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.encryptedMetadata == nil {
+		return nil
 	}
+	metadata := make([]byte, len(f.encryptedMetadata))
+	copy(metadata, f.encryptedMetadata)
+	return metadata
 }
 
 // AddEncryptedFile adds a single encrypted file to the store.
 // Used for E2EE uploads when session is locked - client encrypts locally.
-func (fs *FileStore) AddEncryptedFile(f EncryptedFileInfo) {
+// The content and metadata must be crypto envelopes bound to f.ID.
+// The padded size counts against the same size and memory limits as
+// plaintext uploads. Returns the file version, or ErrDuplicateFile if a file
+// with the ID is already stored.
+func (fs *FileStore) AddEncryptedFile(f EncryptedFileInfo) (uint64, error) {
+	encrypted, metadata, bucket, err := decodeEncryptedFile(f)
+	if err != nil {
+		return 0, err
+	}
+	discard := func(err error) (uint64, error) {
+		secure.Shred(encrypted)
+		secure.Shred(metadata)
+		return 0, err
+	}
+
+	if bucket > crypto.SizeBucket(fs.maxFileSize) {
+		return discard(ErrFileTooLarge)
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	encrypted, err := base64.StdEncoding.DecodeString(f.EncryptedB64)
-	if err != nil {
-		return
+	if _, exists := fs.files[f.ID]; exists {
+		return discard(ErrDuplicateFile)
 	}
-
-	now := time.Now()
-	fs.files[f.ID] = &StoredFile{
-		ID:        f.ID,
-		encrypted: encrypted,
-		Filename:  f.Name,
-		MimeType:  f.MimeType,
-		Size:      f.Size,
-		CreatedAt: now,
-		ExpiresAt: now.Add(fs.expiry),
+	if fs.memory != nil {
+		if err := fs.memory.Allocate(bucket); err != nil {
+			return discard(ErrStorageFull)
+		}
 	}
+
+	fs.version++
+	fs.files[f.ID] = fs.newEncryptedFile(f.ID, encrypted, metadata, bucket, time.Now())
+	fs.events.Publish(Event{Type: EventFileAdded, ID: f.ID})
+
+	return fs.version, nil
+}
+
+// SealedFileInfo describes a sealed file without its content.
+// The content is fetched separately with GetEncryptedFile.
+type SealedFileInfo struct {
+	ID                   string `json:"id"`
+	EncryptedMetadataB64 string `json:"encryptedMetadata_b64"` // Name, MIME type and size, encrypted by the client
+	SizeBucket           int64  `json:"sizeBucket"`            // Padded size
+	Version              uint64 `json:"version"`
 }
 
-// GetEncryptedFiles returns all encrypted file blobs for client-side decryption.
-func (fs *FileStore) GetEncryptedFiles() []EncryptedFileInfo {
+// SealedFiles returns the sealed files, oldest first, without their content.
+func (fs *FileStore) SealedFiles() []SealedFileInfo {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	var result []EncryptedFileInfo
+	now := time.Now()
+	var sealed []*StoredFile
 	for _, file := range fs.files {
 		file.mu.RLock()
-		if file.encrypted != nil {
-			result = append(result, EncryptedFileInfo{
-				ID:          file.ID,
-				Name:        file.Filename,
-				MimeType:    file.MimeType,
-				Size:        file.Size,
-				EncryptedB64: base64.StdEncoding.EncodeToString(file.encrypted),
-			})
+		if file.encrypted != nil && !now.After(file.ExpiresAt) {
+			sealed = append(sealed, file)
 		}
 		file.mu.RUnlock()
 	}
+	sort.Slice(sealed, func(i, j int) bool {
+		return sealed[i].CreatedAt.Before(sealed[j].CreatedAt)
+	})
+
+	result := make([]SealedFileInfo, 0, len(sealed))
+	for _, file := range sealed {
+		file.mu.RLock()
+		result = append(result, SealedFileInfo{
+			ID:                   file.ID,
+			EncryptedMetadataB64: base64.StdEncoding.EncodeToString(file.encryptedMetadata),
+			SizeBucket:           file.Size,
+			Version:              file.Version,
+		})
+		file.mu.RUnlock()
+	}
 	return result
 }
 
+// GetEncryptedFile returns a copy of one sealed file's ciphertext and its version.
+// Returns ErrFileNotFound if the file has no ciphertext.
+func (fs *FileStore) GetEncryptedFile(id string) ([]byte, uint64, error) {
+	fs.mu.RLock()
+	file, exists := fs.files[id]
+	fs.mu.RUnlock()
+
+	if !exists {
+		return nil, 0, ErrFileNotFound
+	}
+
+	file.mu.RLock()
+	defer file.mu.RUnlock()
+
+	if time.Now().After(file.ExpiresAt) {
+		return nil, 0, ErrFileExpired
+	}
+	if file.encrypted == nil {
+		return nil, 0, ErrFileNotFound
+	}
+
+	encrypted := make([]byte, len(file.encrypted))
+	copy(encrypted, file.encrypted)
+	return encrypted, file.Version, nil
+}
+
 // ClearEncryptedData shreds all encrypted file blobs.
 // Called after client successfully decrypts and re-uploads plaintext data.
 func (fs *FileStore) ClearEncryptedData() {
@@ -512,10 +644,18 @@ func (fs *FileStore) ClearEncryptedData() {
 			secure.Shred(file.encrypted)
 			file.encrypted = nil
 		}
+		if file.encryptedMetadata != nil {
+			secure.Shred(file.encryptedMetadata)
+			file.encryptedMetadata = nil
+		}
 		// If no plaintext data either, remove the file
 		if file.data == nil {
+			if fs.memory != nil {
+				fs.memory.Free(file.Size)
+			}
 			file.mu.Unlock()
 			delete(fs.files, id)
+			fs.version++
 		} else {
 			file.mu.Unlock()
 		}
@@ -0,0 +1,156 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// encryptedFile returns an encrypted upload of size bytes under id.
+func encryptedFile(t *testing.T, id string, size int) EncryptedFileInfo {
+	t.Helper()
+
+	content := testEnvelope(t, crypto.EnvelopeHeader{Type: crypto.EnvelopeFile, ID: id}, make([]byte, size))
+	metadata := testEnvelope(t, crypto.EnvelopeHeader{Type: crypto.EnvelopeFileMetadata, ID: id}, []byte(`{"name":"a.txt"}`))
+	return EncryptedFileInfo{
+		ID:                   id,
+		EncryptedB64:         base64.StdEncoding.EncodeToString(content),
+		EncryptedMetadataB64: base64.StdEncoding.EncodeToString(metadata),
+	}
+}
+
+func TestFileDeleteChecksVersion(t *testing.T) {
+	fs := NewFileStore(nil, nil, 0, time.Hour)
+	t.Cleanup(fs.Close)
+
+	id, err := fs.Store("a.txt", "text/plain", []byte("content"))
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	file, _, err := fs.Get(id)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	current, err := fs.Delete(id, file.Version+1)
+	if err != ErrVersionMismatch {
+		t.Fatalf("Delete with a wrong version: got %v, want %v", err, ErrVersionMismatch)
+	}
+	if current != file.Version {
+		t.Errorf("mismatch reported version %d, want %d", current, file.Version)
+	}
+	if _, err := fs.Delete(id, file.Version); err != nil {
+		t.Errorf("Delete with the current version: %v", err)
+	}
+}
+
+func TestEncryptedFilesAreBoundToTheirID(t *testing.T) {
+	fs := NewFileStore(nil, nil, 0, time.Hour)
+	t.Cleanup(fs.Close)
+
+	moved := encryptedFile(t, "0000000000000001", 256)
+	moved.ID = "0000000000000002"
+	if _, err := fs.AddEncryptedFile(moved); err != crypto.ErrEnvelopeMismatch {
+		t.Errorf("envelope of another file: got %v, want %v", err, crypto.ErrEnvelopeMismatch)
+	}
+}
+
+func TestAddEncryptedFileRejectsDuplicateID(t *testing.T) {
+	fs := NewFileStore(nil, nil, 0, time.Hour)
+	t.Cleanup(fs.Close)
+
+	original := encryptedFile(t, "0000000000000001", 256)
+	if _, err := fs.AddEncryptedFile(original); err != nil {
+		t.Fatalf("AddEncryptedFile: %v", err)
+	}
+	if _, err := fs.AddEncryptedFile(encryptedFile(t, "0000000000000001", 512)); err != ErrDuplicateFile {
+		t.Fatalf("upload with a stored ID: got %v, want %v", err, ErrDuplicateFile)
+	}
+
+	encrypted, _, err := fs.GetEncryptedFile("0000000000000001")
+	if err != nil {
+		t.Fatalf("GetEncryptedFile: %v", err)
+	}
+	if want, _ := base64.StdEncoding.DecodeString(original.EncryptedB64); !bytes.Equal(encrypted, want) {
+		t.Error("stored file was overwritten")
+	}
+}
+
+func TestAddEncryptedFileAppliesLimits(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(1 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	fs := NewFileStore(nil, memory, 1<<20, time.Hour)
+	t.Cleanup(fs.Close)
+
+	if _, err := fs.AddEncryptedFile(encryptedFile(t, "00000000000000ff", 2<<20)); err != ErrFileTooLarge {
+		t.Errorf("file over the size limit: got %v, want %v", err, ErrFileTooLarge)
+	}
+
+	if _, err := fs.AddEncryptedFile(encryptedFile(t, "0000000000000001", 600<<10)); err != nil {
+		t.Fatalf("AddEncryptedFile: %v", err)
+	}
+	if _, err := fs.AddEncryptedFile(encryptedFile(t, "0000000000000002", 600<<10)); err != ErrStorageFull {
+		t.Errorf("file over the memory limit: got %v, want %v", err, ErrStorageFull)
+	}
+	if fs.Count() != 1 {
+		t.Errorf("%d files stored, want 1", fs.Count())
+	}
+}
+
+func TestBeginSealAppliesMemoryLimit(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(1 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	fs := NewFileStore(nil, memory, 1<<20, time.Hour)
+	t.Cleanup(fs.Close)
+
+	if _, err := fs.BeginSeal([]EncryptedFileInfo{encryptedFile(t, "00000000000000ff", 2<<20)}); err != ErrFileTooLarge {
+		t.Errorf("file over the size limit: got %v, want %v", err, ErrFileTooLarge)
+	}
+
+	files := []EncryptedFileInfo{
+		encryptedFile(t, "0000000000000001", 600<<10),
+		encryptedFile(t, "0000000000000002", 600<<10),
+	}
+	if _, err := fs.BeginSeal(files); err != ErrStorageFull {
+		t.Errorf("seal over the memory limit: got %v, want %v", err, ErrStorageFull)
+	}
+	if allocated := memory.Allocated(); allocated != 0 {
+		t.Errorf("%d bytes allocated after a rejected seal, want 0", allocated)
+	}
+
+	tx, err := fs.BeginSeal(files[:1])
+	if err != nil {
+		t.Fatalf("BeginSeal: %v", err)
+	}
+	tx.Commit()
+	tx.Shred()
+	if fs.ShredAll(); memory.Allocated() != 0 {
+		t.Errorf("%d bytes allocated after shredding the sealed files, want 0", memory.Allocated())
+	}
+}
+
+func TestEncryptedFilesRequireValidID(t *testing.T) {
+	fs := NewFileStore(nil, nil, 0, time.Hour)
+	t.Cleanup(fs.Close)
+
+	for _, id := range []string{"file-1", "00000000000000AB", "0000000000000001/../x"} {
+		if _, err := fs.AddEncryptedFile(encryptedFile(t, id, 16)); err != validate.ErrInvalidFileID {
+			t.Errorf("upload with ID %q: got %v, want %v", id, err, validate.ErrInvalidFileID)
+		}
+		if _, err := fs.BeginSeal([]EncryptedFileInfo{encryptedFile(t, id, 16)}); err != validate.ErrInvalidFileID {
+			t.Errorf("seal with ID %q: got %v, want %v", id, err, validate.ErrInvalidFileID)
+		}
+	}
+	if fs.Count() != 0 {
+		t.Errorf("%d files stored, want 0", fs.Count())
+	}
+}
@@ -0,0 +1,351 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+// Key slot kinds.
+const (
+	// KeySlotPassword is a slot whose key is derived from a password.
+	KeySlotPassword = "password"
+	// KeySlotDevice is a slot whose key is a secret kept by one device.
+	KeySlotDevice = "device"
+)
+
+const (
+	// MaxKeySlots is the maximum number of key slots per sealed session.
+	MaxKeySlots = 16
+	// MaxWrappedKeySize bounds a wrapped content key (the key plus cipher overhead).
+	MaxWrappedKeySize = 512
+)
+
+var (
+	// ErrKeySlotsNotEnabled indicates the session was not sealed with key slots.
+	ErrKeySlotsNotEnabled = errors.New("session was not sealed with key slots")
+	// ErrKeySlotNotFound indicates no key slot with that ID exists.
+	ErrKeySlotNotFound = errors.New("key slot not found")
+	// ErrTooManyKeySlots indicates the key slot limit has been reached.
+	ErrTooManyKeySlots = errors.New("too many key slots")
+	// ErrLastKeySlot indicates the only remaining key slot cannot be removed.
+	ErrLastKeySlot = errors.New("cannot remove the last key slot")
+	// ErrInvalidKeySlot indicates a key slot with missing or malformed fields.
+	ErrInvalidKeySlot = errors.New("invalid key slot")
+)
+
+// KeySlot is a new key slot sent by the client.
+// The data is encrypted under a random content key; the slot's key (derived
+// from a password or held by a device) wraps that content key. The server
+// stores the wrapped key and the SHA-256 of the slot key, and can unwrap neither.
+type KeySlot struct {
+	Label      string
+	Kind       string // KeySlotPassword or KeySlotDevice
+	Salt       []byte
+	KDF        crypto.KDFParams
+	KeyHash    []byte // SHA-256 of the slot key, for verification
+	WrappedKey []byte // Content key encrypted with the slot key
+}
+
+// KeySlotInfo contains key slot metadata for API responses.
+// It is public: clients need the salt and KDF to derive a slot key.
+type KeySlotInfo struct {
+	ID        string           `json:"id"`
+	Label     string           `json:"label"`
+	Kind      string           `json:"kind"`
+	Salt      []byte           `json:"salt_b64"`
+	KDF       crypto.KDFParams `json:"kdf"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// KeySlotMatch is the key slot that verified an unlock, with its wrapped content key.
+type KeySlotMatch struct {
+	Slot       KeySlotInfo
+	WrappedKey []byte
+}
+
+// keySlot is a stored key slot.
+type keySlot struct {
+	id         string
+	label      string
+	kind       string
+	salt       []byte
+	kdf        crypto.KDFParams
+	keyHash    []byte
+	wrappedKey []byte
+	createdAt  time.Time
+}
+
+// newKeySlot validates k and returns a stored copy with a new ID.
+func newKeySlot(k KeySlot, now time.Time) (*keySlot, error) {
+	if k.Kind == "" {
+		k.Kind = KeySlotPassword
+	}
+	if k.Kind != KeySlotPassword && k.Kind != KeySlotDevice {
+		return nil, ErrInvalidKeySlot
+	}
+	if len(k.KeyHash) != 32 || len(k.Salt) < 16 || len(k.WrappedKey) == 0 || len(k.WrappedKey) > MaxWrappedKeySize {
+		return nil, ErrInvalidKeySlot
+	}
+	if err := k.KDF.Validate(); err != nil {
+		return nil, ErrInvalidKeySlot
+	}
+
+	id, err := crypto.GenerateFileID()
+	if err != nil {
+		return nil, err
+	}
+
+	slot := &keySlot{
+		id:         id,
+		label:      validate.DeviceName(k.Label),
+		kind:       k.Kind,
+		salt:       make([]byte, len(k.Salt)),
+		kdf:        k.KDF,
+		keyHash:    make([]byte, len(k.KeyHash)),
+		wrappedKey: make([]byte, len(k.WrappedKey)),
+		createdAt:  now,
+	}
+	copy(slot.salt, k.Salt)
+	copy(slot.keyHash, k.KeyHash)
+	copy(slot.wrappedKey, k.WrappedKey)
+	return slot, nil
+}
+
+// info returns the slot metadata.
+func (k *keySlot) info() KeySlotInfo {
+	salt := make([]byte, len(k.salt))
+	copy(salt, k.salt)
+	return KeySlotInfo{
+		ID:        k.id,
+		Label:     k.label,
+		Kind:      k.kind,
+		Salt:      salt,
+		KDF:       k.kdf,
+		CreatedAt: k.createdAt,
+	}
+}
+
+// shred wipes the slot's secrets.
+func (k *keySlot) shred() {
+	secure.Shred(k.keyHash)
+	secure.Shred(k.wrappedKey)
+	secure.Shred(k.salt)
+}
+
+// LockWithKeySlots locks the session with E2EE, verified by key slots.
+// Each slot wraps the same content key; any of them unlocks the session.
+// recoveryCode and seal are as for Lock.
+// Returns the token of the locking device.
+func (sm *SessionManager) LockWithKeySlots(slots []KeySlot, deviceName, recoveryCode string, seal func() error) (string, DeviceInfo, error) {
+	if len(slots) == 0 {
+		return "", DeviceInfo{}, ErrInvalidKeySlot
+	}
+	if len(slots) > MaxKeySlots {
+		return "", DeviceInfo{}, ErrTooManyKeySlots
+	}
+
+	now := time.Now()
+	stored := make([]*keySlot, 0, len(slots))
+	for _, k := range slots {
+		slot, err := newKeySlot(k, now)
+		if err != nil {
+			for _, s := range stored {
+				s.shred()
+			}
+			return "", DeviceInfo{}, err
+		}
+		stored = append(stored, slot)
+	}
+
+	token, device, err := sm.lock(credentials{slots: stored}, deviceName, recoveryCode, seal)
+	if err != nil {
+		for _, s := range stored {
+			s.shred()
+		}
+	}
+	return token, device, err
+}
+
+// KeySlots returns the key slots of the sealed session in the order they were added.
+// Returns nil if the session is not sealed with key slots.
+func (sm *SessionManager) KeySlots() []KeySlotInfo {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return nil
+	}
+
+	sm.session.mu.RLock()
+	defer sm.session.mu.RUnlock()
+
+	if !sm.session.locked || sm.session.slots == nil {
+		return nil
+	}
+	return sm.session.keySlotInfos()
+}
+
+// MatchKeyHash verifies keyHash like VerifyKeyHash. For a key slot seal it
+// also returns the matching slot and its wrapped content key (nil otherwise).
+// slotID restricts the check to one slot ("" = any slot).
+func (sm *SessionManager) MatchKeyHash(keyHash []byte, slotID string) (*KeySlotMatch, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return nil, ErrSessionNotLocked
+	}
+
+	sm.session.mu.RLock()
+	defer sm.session.mu.RUnlock()
+
+	slot, err := sm.session.matchKeyHash(keyHash, slotID)
+	if err != nil || slot == nil {
+		return nil, err
+	}
+
+	wrapped := make([]byte, len(slot.wrappedKey))
+	copy(wrapped, slot.wrappedKey)
+	return &KeySlotMatch{Slot: slot.info(), WrappedKey: wrapped}, nil
+}
+
+// AddKeySlot adds a key slot wrapping the content key, after keyHash proves
+// an existing slot. The content stays as it is.
+func (sm *SessionManager) AddKeySlot(keyHash []byte, k KeySlot) (KeySlotInfo, error) {
+	slot, err := newKeySlot(k, time.Now())
+	if err != nil {
+		return KeySlotInfo{}, err
+	}
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		slot.shred()
+		return KeySlotInfo{}, ErrSessionNotLocked
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	s := sm.session
+	if err := s.requireKeySlots(keyHash); err != nil {
+		slot.shred()
+		return KeySlotInfo{}, err
+	}
+	if len(s.slots) >= MaxKeySlots {
+		slot.shred()
+		return KeySlotInfo{}, ErrTooManyKeySlots
+	}
+
+	s.slots = append(s.slots, slot)
+	sm.publish(Event{Type: EventSessionKeySlotsChanged, ID: slot.id})
+
+	return slot.info(), nil
+}
+
+// RemoveKeySlot removes the key slot with the given ID, after keyHash proves
+// an existing slot (which may be the one removed). The last slot is kept.
+func (sm *SessionManager) RemoveKeySlot(keyHash []byte, id string) error {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return ErrSessionNotLocked
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	s := sm.session
+	if err := s.requireKeySlots(keyHash); err != nil {
+		return err
+	}
+
+	for i, slot := range s.slots {
+		if slot.id != id {
+			continue
+		}
+		if len(s.slots) == 1 {
+			return ErrLastKeySlot
+		}
+		s.slots = append(s.slots[:i], s.slots[i+1:]...)
+		slot.shred()
+		sm.publish(Event{Type: EventSessionKeySlotsChanged, ID: id})
+		return nil
+	}
+
+	return ErrKeySlotNotFound
+}
+
+// requireKeySlots checks that the session is a key slot seal and keyHash matches a slot.
+// Caller must hold s.mu.
+func (s *Session) requireKeySlots(keyHash []byte) error {
+	if s.locked && s.slots == nil {
+		return ErrKeySlotsNotEnabled
+	}
+	_, err := s.matchKeyHash(keyHash, "")
+	return err
+}
+
+// matchKeyHash checks keyHash against the seal's key hash or key slots.
+// Returns the matching slot for key slot seals, nil otherwise.
+// Caller must hold s.mu.
+func (s *Session) matchKeyHash(keyHash []byte, slotID string) (*keySlot, error) {
+	if !s.locked {
+		return nil, ErrSessionNotLocked
+	}
+
+	// Sealed with an SRP verifier - the key hash is never accepted
+	if s.verifier != nil {
+		return nil, ErrPAKERequired
+	}
+
+	if s.slots != nil {
+		// Compare against every slot so timing does not reveal which one matched
+		var match *keySlot
+		found := slotID == ""
+		for _, slot := range s.slots {
+			if slotID != "" && slot.id != slotID {
+				continue
+			}
+			found = true
+			if crypto.ConstantTimeCompare(slot.keyHash, keyHash) && match == nil {
+				match = slot
+			}
+		}
+		if !found {
+			return nil, ErrKeySlotNotFound
+		}
+		if match == nil {
+			return nil, ErrInvalidPassword
+		}
+		return match, nil
+	}
+
+	// Verify keyHash exists (defensive check)
+	if s.keyHash == nil {
+		return nil, errors.New("session keyHash is nil")
+	}
+
+	// Constant-time comparison to prevent timing attacks
+	if !crypto.ConstantTimeCompare(s.keyHash, keyHash) {
+		return nil, ErrInvalidPassword
+	}
+
+	return nil, nil
+}
+
+// keySlotInfos returns the slot metadata in the order the slots were added.
+// Caller must hold s.mu.
+func (s *Session) keySlotInfos() []KeySlotInfo {
+	infos := make([]KeySlotInfo, 0, len(s.slots))
+	for _, slot := range s.slots {
+		infos = append(infos, slot.info())
+	}
+	return infos
+}
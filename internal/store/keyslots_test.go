@@ -0,0 +1,115 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+// testKeySlot returns a key slot whose key hash, salt and wrapped key are filled with b.
+func testKeySlot(b byte) KeySlot {
+	return KeySlot{
+		Label:      "slot",
+		Salt:       bytes.Repeat([]byte{b}, 16),
+		KDF:        crypto.LegacyKDFParams(),
+		KeyHash:    bytes.Repeat([]byte{b}, 32),
+		WrappedKey: bytes.Repeat([]byte{b}, 48),
+	}
+}
+
+func TestKeySlotsUnlockWithAnySlot(t *testing.T) {
+	sm := NewSessionManager()
+	t.Cleanup(sm.Destroy)
+
+	if _, _, err := sm.LockWithKeySlots([]KeySlot{testKeySlot(1), testKeySlot(2)}, "test", "", nil); err != nil {
+		t.Fatalf("LockWithKeySlots: %v", err)
+	}
+	slots := sm.KeySlots()
+	if len(slots) != 2 {
+		t.Fatalf("%d key slots, want 2", len(slots))
+	}
+
+	// Each slot's key returns that slot's wrapped content key
+	for i, b := range []byte{1, 2} {
+		match, err := sm.MatchKeyHash(bytes.Repeat([]byte{b}, 32), "")
+		if err != nil {
+			t.Fatalf("MatchKeyHash slot %d: %v", i, err)
+		}
+		if match.Slot.ID != slots[i].ID || !bytes.Equal(match.WrappedKey, bytes.Repeat([]byte{b}, 48)) {
+			t.Errorf("slot %d key matched slot %s", i, match.Slot.ID)
+		}
+	}
+
+	if _, err := sm.MatchKeyHash(bytes.Repeat([]byte{3}, 32), ""); err != ErrInvalidPassword {
+		t.Errorf("unknown key: got %v, want %v", err, ErrInvalidPassword)
+	}
+	if _, err := sm.MatchKeyHash(bytes.Repeat([]byte{1}, 32), slots[1].ID); err != ErrInvalidPassword {
+		t.Errorf("key of another slot: got %v, want %v", err, ErrInvalidPassword)
+	}
+	if _, err := sm.MatchKeyHash(bytes.Repeat([]byte{1}, 32), "0000000000000000"); err != ErrKeySlotNotFound {
+		t.Errorf("unknown slot ID: got %v, want %v", err, ErrKeySlotNotFound)
+	}
+}
+
+func TestKeySlotsAddAndRemove(t *testing.T) {
+	sm := NewSessionManager()
+	t.Cleanup(sm.Destroy)
+
+	if _, _, err := sm.LockWithKeySlots([]KeySlot{testKeySlot(1)}, "test", "", nil); err != nil {
+		t.Fatalf("LockWithKeySlots: %v", err)
+	}
+	first := bytes.Repeat([]byte{1}, 32)
+
+	if _, err := sm.AddKeySlot(bytes.Repeat([]byte{9}, 32), testKeySlot(2)); err != ErrInvalidPassword {
+		t.Errorf("add without a valid key: got %v, want %v", err, ErrInvalidPassword)
+	}
+	invalid := testKeySlot(2)
+	invalid.KeyHash = invalid.KeyHash[:16]
+	if _, err := sm.AddKeySlot(first, invalid); err != ErrInvalidKeySlot {
+		t.Errorf("add a malformed slot: got %v, want %v", err, ErrInvalidKeySlot)
+	}
+
+	added, err := sm.AddKeySlot(first, testKeySlot(2))
+	if err != nil {
+		t.Fatalf("AddKeySlot: %v", err)
+	}
+
+	// The new slot can remove the first one, but not itself as the last
+	original := sm.KeySlots()[0].ID
+	second := bytes.Repeat([]byte{2}, 32)
+	if err := sm.RemoveKeySlot(second, original); err != nil {
+		t.Fatalf("RemoveKeySlot: %v", err)
+	}
+	if err := sm.VerifyKeyHash(first); err != ErrInvalidPassword {
+		t.Errorf("key of a removed slot: got %v, want %v", err, ErrInvalidPassword)
+	}
+	if err := sm.RemoveKeySlot(second, added.ID); err != ErrLastKeySlot {
+		t.Errorf("remove the last slot: got %v, want %v", err, ErrLastKeySlot)
+	}
+
+	for i := len(sm.KeySlots()); i < MaxKeySlots; i++ {
+		if _, err := sm.AddKeySlot(second, testKeySlot(byte(10+i))); err != nil {
+			t.Fatalf("AddKeySlot %d: %v", i, err)
+		}
+	}
+	if _, err := sm.AddKeySlot(second, testKeySlot(99)); err != ErrTooManyKeySlots {
+		t.Errorf("slot over the limit: got %v, want %v", err, ErrTooManyKeySlots)
+	}
+}
+
+func TestKeySlotsRequireKeySlotSeal(t *testing.T) {
+	sm := NewSessionManager()
+	t.Cleanup(sm.Destroy)
+
+	keyHash := bytes.Repeat([]byte{1}, 32)
+	if _, _, err := sm.Lock(keyHash, bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if _, err := sm.AddKeySlot(keyHash, testKeySlot(2)); err != ErrKeySlotsNotEnabled {
+		t.Errorf("add to a key hash seal: got %v, want %v", err, ErrKeySlotsNotEnabled)
+	}
+	if slots := sm.KeySlots(); slots != nil {
+		t.Errorf("key hash seal lists %d key slots", len(slots))
+	}
+}
@@ -0,0 +1,457 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+var (
+	// ErrNoteNotFound indicates the note does not exist, was already read or has expired.
+	// Retrieval deliberately does not distinguish these cases.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrTooManyNotes indicates the unread note limit has been reached.
+	ErrTooManyNotes = errors.New("too many notes")
+	// ErrInvalidNoteExpiry indicates the requested note expiry is out of range.
+	ErrInvalidNoteExpiry = errors.New("note expiry out of range")
+)
+
+const (
+	// DefaultMaxNotes is the default maximum number of unread notes.
+	DefaultMaxNotes = 100
+	// tombstonesPerNote bounds the tombstones kept relative to the note limit.
+	tombstonesPerNote = 10
+	// MinNoteExpiry is the shortest lifetime a note may have.
+	MinNoteExpiry = 1 * time.Minute
+)
+
+// NoteState describes what happened to a note.
+type NoteState string
+
+const (
+	// NoteUnread means the note is waiting to be retrieved.
+	NoteUnread NoteState = "unread"
+	// NoteRead means the note was retrieved and shredded.
+	NoteRead NoteState = "read"
+	// NoteExpired means the note expired unread and was shredded.
+	NoteExpired NoteState = "expired"
+	// NoteBurned means the creator shredded the note before it was read.
+	NoteBurned NoteState = "burned"
+)
+
+// NoteTokens are the secrets handed to a note's creator.
+// Only their hashes are kept, so they cannot be recovered later.
+type NoteTokens struct {
+	Token       string    // Retrieval token for the recipient's link
+	StatusToken string    // Lets the creator check (or burn) the note
+	ExpiresAt   time.Time // When the unread note is shredded
+}
+
+// NoteStatus is what a creator can learn about a note. Never includes content.
+type NoteStatus struct {
+	State     NoteState  `json:"state"`
+	Encrypted bool       `json:"encrypted"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"` // When it was read, expired or burned
+}
+
+// secretNote is a view-once note.
+// After it is closed the content is gone and only a tombstone remains,
+// so the creator can still see what happened to it.
+type secretNote struct {
+	tokenHash [sha256.Size]byte
+
+	// Content (only one is set, and neither once closed)
+	data      *secure.FortifiedBuffer // Plaintext mode
+	encrypted []byte                  // Client-encrypted (key never reaches the server)
+	size      int
+	sealed    bool // Client-encrypted, kept for the status after shredding
+
+	state     NoteState
+	createdAt time.Time
+	expiresAt time.Time
+	closedAt  time.Time
+}
+
+// SecretNoteStore holds view-once notes shared by link.
+//
+// Notes are addressed by the SHA-256 of a random retrieval token, so the
+// server never keeps a usable link. Retrieval removes the note and shreds it
+// in one step under the store lock: two concurrent requests cannot both read it.
+// A separate status token lets the creator see whether the note was read.
+type SecretNoteStore struct {
+	mu sync.Mutex
+
+	// Unread notes by retrieval token hash
+	notes map[[sha256.Size]byte]*secretNote
+	// All notes, including tombstones, by status token hash
+	status map[[sha256.Size]byte]*secretNote
+
+	// Configuration
+	maxNotes int
+	expiry   time.Duration // Default and longest note lifetime; also how long tombstones are kept
+
+	// Memory tracker
+	memory *secure.MemoryTracker
+
+	// Shutdown signal
+	done chan struct{}
+}
+
+// NewSecretNoteStore creates a new secret note store.
+// expiry is the default and longest lifetime of a note.
+func NewSecretNoteStore(memory *secure.MemoryTracker, maxNotes int, expiry time.Duration) *SecretNoteStore {
+	if maxNotes <= 0 {
+		maxNotes = DefaultMaxNotes
+	}
+	if expiry < MinNoteExpiry {
+		expiry = 24 * time.Hour
+	}
+
+	store := &SecretNoteStore{
+		notes:    make(map[[sha256.Size]byte]*secretNote),
+		status:   make(map[[sha256.Size]byte]*secretNote),
+		maxNotes: maxNotes,
+		expiry:   expiry,
+		memory:   memory,
+		done:     make(chan struct{}),
+	}
+
+	// Start expiry checker
+	go store.expiryLoop()
+
+	return store
+}
+
+// MaxExpiry returns the longest lifetime a note may have.
+func (ns *SecretNoteStore) MaxExpiry() time.Duration {
+	return ns.expiry
+}
+
+// Create stores a plaintext note. The source data is wiped.
+// A zero expiry uses the store default.
+func (ns *SecretNoteStore) Create(data []byte, expiry time.Duration) (NoteTokens, error) {
+	if len(data) > validate.MaxClipboardSize {
+		secure.Shred(data)
+		return NoteTokens{}, validate.ErrClipboardTooLarge
+	}
+
+	size := len(data)
+	fortified, err := secure.NewFortifiedBuffer(data)
+	if err != nil {
+		return NoteTokens{}, err
+	}
+
+	note := &secretNote{data: fortified, size: size}
+	tokens, err := ns.add(note, expiry)
+	if err != nil {
+		secure.ShredFortifiedBuffer(fortified)
+		return NoteTokens{}, err
+	}
+	return tokens, nil
+}
+
+// CreateEncrypted stores a client-encrypted note.
+// The store takes ownership of encrypted. A zero expiry uses the store default.
+func (ns *SecretNoteStore) CreateEncrypted(encrypted []byte, expiry time.Duration) (NoteTokens, error) {
+	if len(encrypted) == 0 {
+		return NoteTokens{}, validate.ErrEmptyInput
+	}
+	// Allow for the nonce and authentication tag around the plaintext
+	if len(encrypted) > validate.MaxClipboardSize+1024 {
+		secure.Shred(encrypted)
+		return NoteTokens{}, validate.ErrClipboardTooLarge
+	}
+
+	note := &secretNote{encrypted: encrypted, size: len(encrypted), sealed: true}
+	tokens, err := ns.add(note, expiry)
+	if err != nil {
+		secure.Shred(encrypted)
+		return NoteTokens{}, err
+	}
+	return tokens, nil
+}
+
+// add generates the note's tokens and stores it.
+// On error the caller still owns the note content.
+func (ns *SecretNoteStore) add(note *secretNote, expiry time.Duration) (NoteTokens, error) {
+	if expiry == 0 {
+		expiry = ns.expiry
+	} else if expiry < MinNoteExpiry || expiry > ns.expiry {
+		return NoteTokens{}, ErrInvalidNoteExpiry
+	}
+
+	token, err := crypto.GenerateSessionTokenString()
+	if err != nil {
+		return NoteTokens{}, err
+	}
+	statusToken, err := crypto.GenerateSessionTokenString()
+	if err != nil {
+		return NoteTokens{}, err
+	}
+
+	now := time.Now()
+	note.tokenHash = sha256.Sum256([]byte(token))
+	note.state = NoteUnread
+	note.createdAt = now
+	note.expiresAt = now.Add(expiry)
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	if len(ns.notes) >= ns.maxNotes {
+		return NoteTokens{}, ErrTooManyNotes
+	}
+	if len(ns.status) >= ns.maxNotes*tombstonesPerNote {
+		ns.dropOldestTombstone()
+	}
+
+	if ns.memory != nil {
+		if err := ns.memory.Allocate(int64(note.size)); err != nil {
+			return NoteTokens{}, err
+		}
+	}
+
+	ns.notes[note.tokenHash] = note
+	ns.status[sha256.Sum256([]byte(statusToken))] = note
+
+	return NoteTokens{
+		Token:       token,
+		StatusToken: statusToken,
+		ExpiresAt:   note.expiresAt,
+	}, nil
+}
+
+// Open retrieves a note and shreds it. A note can be opened only once.
+// Returns the content and whether it is client-encrypted; the caller must
+// shred the returned slice.
+func (ns *SecretNoteStore) Open(token string) ([]byte, bool, error) {
+	hash := sha256.Sum256([]byte(token))
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	note, exists := ns.notes[hash]
+	if !exists {
+		return nil, false, ErrNoteNotFound
+	}
+
+	now := time.Now()
+	if now.After(note.expiresAt) {
+		ns.closeNote(note, NoteExpired, now)
+		return nil, false, ErrNoteNotFound
+	}
+
+	var content []byte
+	encrypted := note.encrypted != nil
+	if encrypted {
+		// Hand the ciphertext over instead of copying it
+		content = note.encrypted
+		note.encrypted = nil
+	} else {
+		err := note.data.Use(func(d []byte) error {
+			content = make([]byte, len(d))
+			copy(content, d)
+			return nil
+		})
+		if err != nil {
+			// Still burn it: a note must never be readable twice
+			ns.closeNote(note, NoteBurned, now)
+			return nil, false, err
+		}
+	}
+
+	ns.closeNote(note, NoteRead, now)
+	return content, encrypted, nil
+}
+
+// Status returns what happened to the note with the given status token.
+func (ns *SecretNoteStore) Status(statusToken string) (NoteStatus, error) {
+	hash := sha256.Sum256([]byte(statusToken))
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	note, exists := ns.status[hash]
+	if !exists {
+		return NoteStatus{}, ErrNoteNotFound
+	}
+
+	// Report expiry even if the loop has not run yet
+	now := time.Now()
+	if note.state == NoteUnread && now.After(note.expiresAt) {
+		ns.closeNote(note, NoteExpired, now)
+	}
+
+	return noteStatus(note), nil
+}
+
+// Burn shreds an unread note on behalf of its creator.
+// The tombstone is kept; burning a closed note just returns its status.
+func (ns *SecretNoteStore) Burn(statusToken string) (NoteStatus, error) {
+	hash := sha256.Sum256([]byte(statusToken))
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	note, exists := ns.status[hash]
+	if !exists {
+		return NoteStatus{}, ErrNoteNotFound
+	}
+
+	if note.state == NoteUnread {
+		ns.closeNote(note, NoteBurned, time.Now())
+	}
+
+	return noteStatus(note), nil
+}
+
+// Count returns the number of unread notes.
+func (ns *SecretNoteStore) Count() int {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	return len(ns.notes)
+}
+
+// HasData returns whether any unread note is stored.
+func (ns *SecretNoteStore) HasData() bool {
+	return ns.Count() > 0
+}
+
+// ShredPlaintext shreds unread notes the server could read.
+// Client-encrypted notes are kept. Used when the session is sealed.
+func (ns *SecretNoteStore) ShredPlaintext() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	for _, note := range ns.notes {
+		if note.data != nil {
+			ns.closeNote(note, NoteBurned, now)
+		}
+	}
+}
+
+// ShredAll securely destroys all notes and tombstones.
+func (ns *SecretNoteStore) ShredAll() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	for _, note := range ns.notes {
+		ns.closeNote(note, NoteBurned, now)
+	}
+	ns.status = make(map[[sha256.Size]byte]*secretNote)
+}
+
+// Close stops the expiry loop and shreds all notes.
+// Should be called on application shutdown.
+func (ns *SecretNoteStore) Close() {
+	ns.mu.Lock()
+	select {
+	case <-ns.done:
+	default:
+		close(ns.done)
+	}
+	ns.mu.Unlock()
+
+	ns.ShredAll()
+}
+
+// closeNote shreds a note's content and leaves a tombstone.
+// Caller must hold ns.mu.
+func (ns *SecretNoteStore) closeNote(note *secretNote, state NoteState, now time.Time) {
+	delete(ns.notes, note.tokenHash)
+
+	if ns.memory != nil {
+		ns.memory.Free(int64(note.size))
+	}
+	if note.data != nil {
+		secure.ShredFortifiedBuffer(note.data)
+		note.data = nil
+	}
+	if note.encrypted != nil {
+		secure.Shred(note.encrypted)
+		note.encrypted = nil
+	}
+
+	note.size = 0
+	note.state = state
+	note.closedAt = now
+}
+
+// dropOldestTombstone forgets the longest-closed note.
+// Caller must hold ns.mu.
+func (ns *SecretNoteStore) dropOldestTombstone() {
+	var oldest [sha256.Size]byte
+	var oldestAt time.Time
+	found := false
+	for hash, note := range ns.status {
+		if note.state == NoteUnread {
+			continue
+		}
+		if !found || note.closedAt.Before(oldestAt) {
+			oldest, oldestAt, found = hash, note.closedAt, true
+		}
+	}
+	if found {
+		delete(ns.status, oldest)
+	}
+}
+
+// noteStatus builds the creator-visible status of a note.
+// Caller must hold ns.mu.
+func noteStatus(note *secretNote) NoteStatus {
+	status := NoteStatus{
+		State:     note.state,
+		Encrypted: note.sealed,
+		CreatedAt: note.createdAt,
+		ExpiresAt: note.expiresAt,
+	}
+	if note.state != NoteUnread {
+		closedAt := note.closedAt
+		status.ClosedAt = &closedAt
+	}
+	return status
+}
+
+// expiryLoop periodically shreds expired notes and old tombstones.
+func (ns *SecretNoteStore) expiryLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ns.cleanupExpired()
+		case <-ns.done:
+			return
+		}
+	}
+}
+
+// cleanupExpired shreds expired notes and drops tombstones older than the
+// store expiry.
+func (ns *SecretNoteStore) cleanupExpired() {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+
+	now := time.Now()
+	for _, note := range ns.notes {
+		if now.After(note.expiresAt) {
+			ns.closeNote(note, NoteExpired, now)
+		}
+	}
+
+	for hash, note := range ns.status {
+		if note.state != NoteUnread && now.Sub(note.closedAt) > ns.expiry {
+			delete(ns.status, hash)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"crypto/sha256"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/secure"
+)
+
+func TestSecretNoteOpensOnce(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(secure.MinMemoryLimit)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	ns := NewSecretNoteStore(memory, 0, 0)
+	t.Cleanup(ns.Close)
+
+	tokens, err := ns.Create([]byte("secret"), 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// Concurrent readers: exactly one gets the note
+	var mu sync.Mutex
+	var opened []string
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content, _, err := ns.Open(tokens.Token)
+			if err == nil {
+				mu.Lock()
+				opened = append(opened, string(content))
+				mu.Unlock()
+			} else if err != ErrNoteNotFound {
+				t.Errorf("Open: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(opened) != 1 || opened[0] != "secret" {
+		t.Fatalf("notes opened = %q, want one %q", opened, "secret")
+	}
+	if n := memory.Allocated(); n != 0 {
+		t.Errorf("%d bytes still accounted after the note was read, want 0", n)
+	}
+
+	status, err := ns.Status(tokens.StatusToken)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if status.State != NoteRead || status.ClosedAt == nil {
+		t.Errorf("status = %s (closed at %v), want %s with a close time", status.State, status.ClosedAt, NoteRead)
+	}
+
+	// The tokens are not interchangeable
+	if _, err := ns.Status(tokens.Token); err != ErrNoteNotFound {
+		t.Errorf("status with the retrieval token: got %v, want %v", err, ErrNoteNotFound)
+	}
+}
+
+func TestSecretNoteBurnAndExpiry(t *testing.T) {
+	ns := NewSecretNoteStore(nil, 0, time.Hour)
+	t.Cleanup(ns.Close)
+
+	if _, err := ns.Create([]byte("too late"), 2*time.Hour); err != ErrInvalidNoteExpiry {
+		t.Errorf("expiry above the store expiry: got %v, want %v", err, ErrInvalidNoteExpiry)
+	}
+
+	burned, err := ns.Create([]byte("burn me"), 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if status, err := ns.Burn(burned.StatusToken); err != nil || status.State != NoteBurned {
+		t.Errorf("Burn: got %v, %v, want %s", status.State, err, NoteBurned)
+	}
+	if _, _, err := ns.Open(burned.Token); err != ErrNoteNotFound {
+		t.Errorf("Open after Burn: got %v, want %v", err, ErrNoteNotFound)
+	}
+
+	expired, err := ns.Create([]byte("expire me"), MinNoteExpiry)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	ns.mu.Lock()
+	ns.notes[sha256.Sum256([]byte(expired.Token))].expiresAt = time.Now().Add(-time.Second)
+	ns.mu.Unlock()
+
+	if _, _, err := ns.Open(expired.Token); err != ErrNoteNotFound {
+		t.Errorf("Open after expiry: got %v, want %v", err, ErrNoteNotFound)
+	}
+	if status, err := ns.Status(expired.StatusToken); err != nil || status.State != NoteExpired {
+		t.Errorf("Status after expiry: got %v, %v, want %s", status.State, err, NoteExpired)
+	}
+	if ns.HasData() {
+		t.Error("unread notes left after burn and expiry")
+	}
+}
+
+func TestSecretNoteLimit(t *testing.T) {
+	ns := NewSecretNoteStore(nil, 1, 0)
+	t.Cleanup(ns.Close)
+
+	tokens, err := ns.Create([]byte("first"), 0)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := ns.Create([]byte("second"), 0); err != ErrTooManyNotes {
+		t.Errorf("note over the limit: got %v, want %v", err, ErrTooManyNotes)
+	}
+
+	// Reading a note frees its place
+	if _, _, err := ns.Open(tokens.Token); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := ns.Create([]byte("second"), 0); err != nil {
+		t.Errorf("Create after a note was read: %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/fileez/fileez/internal/validate"
+)
+
+var (
+	// ErrInvalidOperation indicates a malformed text operation.
+	ErrInvalidOperation = errors.New("invalid operation")
+	// ErrOperationLength indicates an operation does not fit the document it is applied to.
+	ErrOperationLength = errors.New("operation length does not match document")
+)
+
+// maxOperationLength bounds the characters an operation retains or deletes.
+// No document is longer, and it keeps the lengths far from overflowing.
+const maxOperationLength = validate.MaxClipboardSize
+
+// opComponent is one step of a TextOperation.
+// Exactly one of: n > 0 (retain n), n < 0 (delete -n), insert != "".
+type opComponent struct {
+	n      int
+	insert string
+}
+
+// TextOperation is an operational-transform edit of a text document.
+// It uses the ot.js wire format: a JSON array where a positive number retains
+// that many characters, a negative number deletes that many, and a string is
+// inserted. The operation must cover the whole document. Lengths count
+// Unicode code points.
+type TextOperation struct {
+	ops []opComponent
+}
+
+// retain advances over n characters.
+func (o *TextOperation) retain(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := len(o.ops) - 1; last >= 0 && o.ops[last].insert == "" && o.ops[last].n > 0 {
+		o.ops[last].n += n
+		return
+	}
+	o.ops = append(o.ops, opComponent{n: n})
+}
+
+// insertText inserts s at the current position.
+// Inserts are kept before an adjacent delete so equivalent operations
+// have a single canonical form.
+func (o *TextOperation) insertText(s string) {
+	if s == "" {
+		return
+	}
+	last := len(o.ops) - 1
+	if last >= 0 && o.ops[last].insert != "" {
+		o.ops[last].insert += s
+		return
+	}
+	if last >= 0 && o.ops[last].n < 0 {
+		if last > 0 && o.ops[last-1].insert != "" {
+			o.ops[last-1].insert += s
+			return
+		}
+		o.ops = append(o.ops, o.ops[last])
+		o.ops[last] = opComponent{insert: s}
+		return
+	}
+	o.ops = append(o.ops, opComponent{insert: s})
+}
+
+// deleteText removes n characters at the current position.
+func (o *TextOperation) deleteText(n int) {
+	if n <= 0 {
+		return
+	}
+	if last := len(o.ops) - 1; last >= 0 && o.ops[last].insert == "" && o.ops[last].n < 0 {
+		o.ops[last].n -= n
+		return
+	}
+	o.ops = append(o.ops, opComponent{n: -n})
+}
+
+// BaseLength returns the document length the operation applies to.
+func (o TextOperation) BaseLength() int {
+	length := 0
+	for _, c := range o.ops {
+		if c.n > 0 {
+			length += c.n
+		} else if c.n < 0 {
+			length -= c.n
+		}
+	}
+	return length
+}
+
+// TargetLength returns the document length after the operation.
+func (o TextOperation) TargetLength() int {
+	length := 0
+	for _, c := range o.ops {
+		if c.insert != "" {
+			length += utf8.RuneCountInString(c.insert)
+		} else if c.n > 0 {
+			length += c.n
+		}
+	}
+	return length
+}
+
+// IsNoop returns whether the operation leaves every document unchanged.
+func (o TextOperation) IsNoop() bool {
+	return len(o.ops) == 0 || (len(o.ops) == 1 && o.ops[0].n > 0)
+}
+
+// Apply applies the operation to a document.
+// The input is not modified.
+func (o TextOperation) Apply(doc []rune) ([]rune, error) {
+	if o.BaseLength() != len(doc) {
+		return nil, ErrOperationLength
+	}
+
+	result := make([]rune, 0, o.TargetLength())
+	pos := 0
+	for _, c := range o.ops {
+		switch {
+		case c.insert != "":
+			result = append(result, []rune(c.insert)...)
+		case c.n > 0:
+			if c.n > len(doc)-pos {
+				return nil, ErrOperationLength
+			}
+			result = append(result, doc[pos:pos+c.n]...)
+			pos += c.n
+		default:
+			if -c.n > len(doc)-pos {
+				return nil, ErrOperationLength
+			}
+			pos -= c.n
+		}
+	}
+
+	return result, nil
+}
+
+// TransformOperations transforms two concurrent operations a and b (both
+// based on the same document) into a' and b' such that applying a then b'
+// gives the same result as applying b then a'. When both insert at the same
+// position, a's insert is placed first.
+func TransformOperations(a, b TextOperation) (TextOperation, TextOperation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return TextOperation{}, TextOperation{}, ErrOperationLength
+	}
+
+	var aPrime, bPrime TextOperation
+	ops1, ops2 := a.ops, b.ops
+	i1, i2 := 0, 0
+
+	next := func(ops []opComponent, i *int) (opComponent, bool) {
+		if *i >= len(ops) {
+			return opComponent{}, false
+		}
+		c := ops[*i]
+		*i++
+		return c, true
+	}
+
+	o1, ok1 := next(ops1, &i1)
+	o2, ok2 := next(ops2, &i2)
+
+	for ok1 || ok2 {
+		if ok1 && o1.insert != "" {
+			aPrime.insertText(o1.insert)
+			bPrime.retain(utf8.RuneCountInString(o1.insert))
+			o1, ok1 = next(ops1, &i1)
+			continue
+		}
+		if ok2 && o2.insert != "" {
+			aPrime.retain(utf8.RuneCountInString(o2.insert))
+			bPrime.insertText(o2.insert)
+			o2, ok2 = next(ops2, &i2)
+			continue
+		}
+		if !ok1 || !ok2 {
+			return TextOperation{}, TextOperation{}, ErrOperationLength
+		}
+
+		switch {
+		case o1.n > 0 && o2.n > 0:
+			// Both retain
+			var minLength int
+			switch {
+			case o1.n > o2.n:
+				minLength = o2.n
+				o1.n -= o2.n
+				o2, ok2 = next(ops2, &i2)
+			case o1.n == o2.n:
+				minLength = o2.n
+				o1, ok1 = next(ops1, &i1)
+				o2, ok2 = next(ops2, &i2)
+			default:
+				minLength = o1.n
+				o2.n -= o1.n
+				o1, ok1 = next(ops1, &i1)
+			}
+			aPrime.retain(minLength)
+			bPrime.retain(minLength)
+
+		case o1.n < 0 && o2.n < 0:
+			// Both delete the same text - nothing left to do for either
+			switch {
+			case -o1.n > -o2.n:
+				o1.n -= o2.n
+				o2, ok2 = next(ops2, &i2)
+			case o1.n == o2.n:
+				o1, ok1 = next(ops1, &i1)
+				o2, ok2 = next(ops2, &i2)
+			default:
+				o2.n -= o1.n
+				o1, ok1 = next(ops1, &i1)
+			}
+
+		case o1.n < 0 && o2.n > 0:
+			// a deletes what b retains
+			var minLength int
+			switch {
+			case -o1.n > o2.n:
+				minLength = o2.n
+				o1.n += o2.n
+				o2, ok2 = next(ops2, &i2)
+			case -o1.n == o2.n:
+				minLength = o2.n
+				o1, ok1 = next(ops1, &i1)
+				o2, ok2 = next(ops2, &i2)
+			default:
+				minLength = -o1.n
+				o2.n += o1.n
+				o1, ok1 = next(ops1, &i1)
+			}
+			aPrime.deleteText(minLength)
+
+		default:
+			// a retains what b deletes
+			var minLength int
+			switch {
+			case o1.n > -o2.n:
+				minLength = -o2.n
+				o1.n += o2.n
+				o2, ok2 = next(ops2, &i2)
+			case o1.n == -o2.n:
+				minLength = o1.n
+				o1, ok1 = next(ops1, &i1)
+				o2, ok2 = next(ops2, &i2)
+			default:
+				minLength = o1.n
+				o2.n += o1.n
+				o1, ok1 = next(ops1, &i1)
+			}
+			bPrime.deleteText(minLength)
+		}
+	}
+
+	return aPrime, bPrime, nil
+}
+
+// MarshalJSON encodes the operation in the ot.js array format.
+func (o TextOperation) MarshalJSON() ([]byte, error) {
+	items := make([]interface{}, len(o.ops))
+	for i, c := range o.ops {
+		if c.insert != "" {
+			items[i] = c.insert
+		} else {
+			items[i] = c.n
+		}
+	}
+	return json.Marshal(items)
+}
+
+// UnmarshalJSON decodes an operation in the ot.js array format.
+// Adjacent components are normalised; zero-length components and operations
+// retaining or deleting more than maxOperationLength characters are rejected.
+func (o *TextOperation) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return ErrInvalidOperation
+	}
+
+	var op TextOperation
+	base := 0
+	for _, item := range items {
+		var n int
+		if err := json.Unmarshal(item, &n); err == nil {
+			if n == 0 || n < -maxOperationLength || n > maxOperationLength {
+				return ErrInvalidOperation
+			}
+			count := n
+			if count < 0 {
+				count = -count
+			}
+			// Checked against the bound before adding, so base cannot overflow
+			if count > maxOperationLength-base {
+				return ErrInvalidOperation
+			}
+			base += count
+
+			if n > 0 {
+				op.retain(n)
+			} else {
+				op.deleteText(-n)
+			}
+			continue
+		}
+
+		var s string
+		if err := json.Unmarshal(item, &s); err != nil || s == "" {
+			return ErrInvalidOperation
+		}
+		op.insertText(s)
+	}
+
+	*o = op
+	return nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// textOp decodes an operation in the ot.js array format.
+func textOp(t *testing.T, s string) TextOperation {
+	t.Helper()
+
+	var op TextOperation
+	if err := json.Unmarshal([]byte(s), &op); err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return op
+}
+
+// mustJSON encodes v as JSON.
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	return string(data)
+}
+
+func TestTransformOperationsConverge(t *testing.T) {
+	tests := []struct {
+		doc  string
+		a, b string
+		want string
+	}{
+		{"hello", `[5, " world"]`, `["oh, ", 5]`, "oh, hello world"},
+		{"hello", `[1, -4]`, `[5, "!"]`, "h!"},
+		{"hello", `[-5]`, `[2, -3]`, ""},
+		{"hello", `[2, "X", 3]`, `[2, "Y", 3]`, "heXYllo"}, // a's insert goes first
+		{"héllo", `[1, -1, "e", 3]`, `[5, "ö"]`, "helloö"},
+	}
+
+	for _, tt := range tests {
+		a, b := textOp(t, tt.a), textOp(t, tt.b)
+		aPrime, bPrime, err := TransformOperations(a, b)
+		if err != nil {
+			t.Fatalf("TransformOperations(%s, %s): %v", tt.a, tt.b, err)
+		}
+
+		doc := []rune(tt.doc)
+		afterA, err := a.Apply(doc)
+		if err != nil {
+			t.Fatalf("Apply(%s): %v", tt.a, err)
+		}
+		ab, err := bPrime.Apply(afterA)
+		if err != nil {
+			t.Fatalf("Apply(b'): %v", err)
+		}
+		afterB, err := b.Apply(doc)
+		if err != nil {
+			t.Fatalf("Apply(%s): %v", tt.b, err)
+		}
+		ba, err := aPrime.Apply(afterB)
+		if err != nil {
+			t.Fatalf("Apply(a'): %v", err)
+		}
+
+		if string(ab) != string(ba) {
+			t.Errorf("%s / %s on %q diverged: %q vs %q", tt.a, tt.b, tt.doc, string(ab), string(ba))
+		}
+		if string(ab) != tt.want {
+			t.Errorf("%s / %s on %q: got %q, want %q", tt.a, tt.b, tt.doc, string(ab), tt.want)
+		}
+	}
+}
+
+func TestTransformOperationsRejectsDifferentBases(t *testing.T) {
+	if _, _, err := TransformOperations(textOp(t, `[5]`), textOp(t, `[4]`)); err != ErrOperationLength {
+		t.Errorf("operations on different documents: got %v, want %v", err, ErrOperationLength)
+	}
+}
+
+func TestTextOperationJSON(t *testing.T) {
+	huge := `4611686018427387904`
+	for _, invalid := range []string{`[0]`, `[""]`, `[true]`, `{}`, `[` + huge + `]`, `[-` + huge + `]`} {
+		var op TextOperation
+		if err := json.Unmarshal([]byte(invalid), &op); err == nil {
+			t.Errorf("decode %s: accepted", invalid)
+		}
+	}
+
+	// Adjacent components are merged and inserts kept before deletes
+	encoded, err := json.Marshal(textOp(t, `[1, 2, -1, "a", "b"]`))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if want := `[3,"ab",-1]`; string(encoded) != want {
+		t.Errorf("encoded %s, want %s", encoded, want)
+	}
+}
+
+func TestDocumentApplyTransformsConcurrentEdits(t *testing.T) {
+	ds := NewDocumentStore(nil, 0, 0)
+	t.Cleanup(ds.Close)
+
+	base, _, err := ds.Apply(0, textOp(t, `["hello"]`))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	// Two clients edit revision base without seeing each other
+	if _, _, err := ds.Apply(base, textOp(t, `[5, " world"]`)); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	_, applied, err := ds.Apply(base, textOp(t, `["oh, ", 5]`))
+	if err != nil {
+		t.Fatalf("Apply of a concurrent edit: %v", err)
+	}
+	if want := `["oh, ",11]`; mustJSON(t, applied) != want {
+		t.Errorf("applied %s, want %s", mustJSON(t, applied), want)
+	}
+
+	text, _, err := ds.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(text) != "oh, hello world" {
+		t.Errorf("document = %q, want %q", text, "oh, hello world")
+	}
+
+	if _, _, err := ds.Apply(base+2, textOp(t, `[3]`)); err != ErrOperationLength {
+		t.Errorf("operation of the wrong length: got %v, want %v", err, ErrOperationLength)
+	}
+}
+
+func TestDocumentApplyRejectsOverflowingOperation(t *testing.T) {
+	ds := NewDocumentStore(nil, 0, 0)
+	t.Cleanup(ds.Close)
+
+	revision, _, err := ds.Apply(0, textOp(t, `["hello"]`))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	// The retains add up to 2^64 + 5, which wraps around to the document length
+	huge := `4611686018427387904`
+	encoded := `[` + huge + `,"x",` + huge + `,` + huge + `,` + huge + `,5]`
+	var op TextOperation
+	if err := json.Unmarshal([]byte(encoded), &op); err != ErrInvalidOperation {
+		t.Errorf("decode %s: got %v, want %v", encoded, err, ErrInvalidOperation)
+	}
+
+	// Built directly the operation still must not reach past the document
+	const n = 1 << 62
+	op = TextOperation{ops: []opComponent{{n: n}, {insert: "x"}, {n: n}, {n: n}, {n: n + 5}}}
+	if _, _, err := ds.Apply(revision, op); err != ErrOperationLength {
+		t.Errorf("Apply: got %v, want %v", err, ErrOperationLength)
+	}
+
+	text, _, err := ds.Get()
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if string(text) != "hello" {
+		t.Errorf("document = %q, want %q", text, "hello")
+	}
+}
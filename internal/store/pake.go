@@ -0,0 +1,278 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+)
+
+// Auth modes of a sealed session.
+const (
+	// AuthModeKeyHash verifies a SHA-256 key hash sent by the client.
+	AuthModeKeyHash = "keyhash"
+	// AuthModeSRP verifies an SRP-6a handshake; the server keeps only a verifier.
+	AuthModeSRP = "srp"
+	// AuthModeKeySlots verifies the key hash of one of several key slots.
+	AuthModeKeySlots = "keyslots"
+)
+
+const (
+	// SRPHandshakeExpiry is how long a started SRP handshake may wait for the client proof.
+	SRPHandshakeExpiry = 1 * time.Minute
+	// MaxSRPHandshakes is the maximum number of pending SRP handshakes per session.
+	MaxSRPHandshakes = 64
+	// MaxSRPHandshakesPerClient is the maximum number of pending SRP handshakes per client IP.
+	MaxSRPHandshakesPerClient = 4
+)
+
+var (
+	// ErrPAKERequired indicates the session was sealed with a verifier and must be unlocked with SRP.
+	ErrPAKERequired = errors.New("session requires SRP unlock")
+	// ErrPAKENotEnabled indicates the session was sealed with a key hash, not an SRP verifier.
+	ErrPAKENotEnabled = errors.New("session was not sealed with an SRP verifier")
+	// ErrHandshakeNotFound indicates the SRP handshake does not exist, was used or has expired.
+	ErrHandshakeNotFound = errors.New("handshake not found")
+	// ErrTooManyHandshakes indicates the pending handshake limit has been reached.
+	ErrTooManyHandshakes = errors.New("too many pending handshakes")
+	// ErrVerifierChanged indicates the session was re-keyed while a handshake was starting.
+	ErrVerifierChanged = errors.New("SRP verifier changed")
+)
+
+// srpHandshake is a started SRP unlock waiting for the client proof.
+type srpHandshake struct {
+	server    *crypto.SRPServer
+	client    string // IP that started the handshake
+	expiresAt time.Time
+}
+
+// LockWithVerifier locks the session with E2EE, verified by SRP.
+// Stores the salt and SRP verifier; neither allows unlocking without the password.
+// recoveryCode and seal are as for Lock.
+// Returns the token of the locking device.
+func (sm *SessionManager) LockWithVerifier(salt, verifier []byte, kdf crypto.KDFParams, deviceName, recoveryCode string, seal func() error) (string, DeviceInfo, error) {
+	if err := crypto.CheckSRPVerifier(verifier); err != nil {
+		return "", DeviceInfo{}, err
+	}
+	return sm.lock(credentials{verifier: verifier, salt: salt, kdf: kdf}, deviceName, recoveryCode, seal)
+}
+
+// AuthMode returns how the sealed session verifies the password,
+// or "" if the session is not locked.
+func (sm *SessionManager) AuthMode() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return ""
+	}
+
+	sm.session.mu.RLock()
+	defer sm.session.mu.RUnlock()
+
+	switch {
+	case !sm.session.locked:
+		return ""
+	case sm.session.verifier != nil:
+		return AuthModeSRP
+	case sm.session.slots != nil:
+		return AuthModeKeySlots
+	default:
+		return AuthModeKeyHash
+	}
+}
+
+// BeginSRP starts an SRP unlock for the client's public value A.
+// client identifies the requester (its IP): each client may have only
+// MaxSRPHandshakesPerClient handshakes pending, and handshakes it abandons
+// count as failed unlocks with the session's unlock guard.
+// Returns the handshake ID, the salt and the server's public value B.
+func (sm *SessionManager) BeginSRP(clientPublic []byte, client string) (string, []byte, []byte, error) {
+	s := sm.GetSession()
+	if s == nil {
+		return "", nil, nil, ErrSessionNotLocked
+	}
+
+	// Copy the verifier and check the limits without holding the session
+	// exclusively; the modexp for B runs without any session lock
+	s.mu.RLock()
+	if !s.locked {
+		s.mu.RUnlock()
+		return "", nil, nil, ErrSessionNotLocked
+	}
+	if s.verifier == nil {
+		s.mu.RUnlock()
+		return "", nil, nil, ErrPAKENotEnabled
+	}
+	if !s.handshakeAllowed(client, time.Now()) {
+		s.mu.RUnlock()
+		return "", nil, nil, ErrTooManyHandshakes
+	}
+	salt := make([]byte, len(s.salt))
+	copy(salt, s.salt)
+	verifier := make([]byte, len(s.verifier))
+	copy(verifier, s.verifier)
+	s.mu.RUnlock()
+	defer secure.Shred(verifier)
+
+	server, err := crypto.NewSRPServer(salt, verifier, clientPublic)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	id, err := crypto.GenerateSessionTokenString()
+	if err != nil {
+		server.Destroy()
+		return "", nil, nil, err
+	}
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The session may have been unsealed or re-keyed meanwhile
+	if sm.session != s || !s.locked {
+		server.Destroy()
+		return "", nil, nil, ErrSessionNotLocked
+	}
+	if !bytes.Equal(s.verifier, verifier) {
+		server.Destroy()
+		return "", nil, nil, ErrVerifierChanged
+	}
+
+	now := time.Now()
+	s.expireHandshakes(now)
+	if !s.handshakeAllowed(client, now) {
+		server.Destroy()
+		return "", nil, nil, ErrTooManyHandshakes
+	}
+
+	if s.handshakes == nil {
+		s.handshakes = make(map[string]*srpHandshake)
+	}
+	s.handshakes[id] = &srpHandshake{
+		server:    server,
+		client:    client,
+		expiresAt: now.Add(SRPHandshakeExpiry),
+	}
+
+	return id, salt, server.PublicKey(), nil
+}
+
+// handshakeAllowed reports whether client may start another handshake:
+// below both the per-session and the per-client limit of pending handshakes.
+// Handshakes that have expired by now are not counted.
+// Caller must hold s.mu (read or write).
+func (s *Session) handshakeAllowed(client string, now time.Time) bool {
+	pending, mine := 0, 0
+	for _, hs := range s.handshakes {
+		if now.After(hs.expiresAt) {
+			continue
+		}
+		pending++
+		if hs.client == client {
+			mine++
+		}
+	}
+	return pending < MaxSRPHandshakes && mine < MaxSRPHandshakesPerClient
+}
+
+// FinishSRP checks the client proof M1 of a started handshake.
+// Each handshake allows a single proof. Returns the server proof M2 on success
+// and ErrInvalidPassword if the proof does not match.
+func (sm *SessionManager) FinishSRP(id string, clientProof []byte) ([]byte, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return nil, ErrSessionNotLocked
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	return sm.session.finishSRP(id, clientProof)
+}
+
+// finishSRP consumes the handshake and checks the client proof.
+// Caller must hold s.mu.
+func (s *Session) finishSRP(id string, clientProof []byte) ([]byte, error) {
+	if !s.locked {
+		return nil, ErrSessionNotLocked
+	}
+
+	hs, exists := s.handshakes[id]
+	if !exists {
+		return nil, ErrHandshakeNotFound
+	}
+	delete(s.handshakes, id)
+	defer hs.server.Destroy()
+
+	if time.Now().After(hs.expiresAt) {
+		s.abandoned(hs)
+		return nil, ErrHandshakeNotFound
+	}
+
+	serverProof, err := hs.server.Verify(clientProof)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	return serverProof, nil
+}
+
+// expireHandshakes drops handshakes that were never finished.
+// Caller must hold s.mu.
+func (s *Session) expireHandshakes(now time.Time) {
+	for id, hs := range s.handshakes {
+		if now.After(hs.expiresAt) {
+			hs.server.Destroy()
+			delete(s.handshakes, id)
+			s.abandoned(hs)
+		}
+	}
+}
+
+// abandoned counts a handshake that expired without a proof as a failed
+// unlock of the client that started it, so holding handshakes open is not free.
+// Caller must hold s.mu.
+func (s *Session) abandoned(hs *srpHandshake) {
+	if s.guard != nil {
+		s.guard.AbandonedHandshake(hs.client)
+	}
+}
+
+// clearVerification shreds the key hash, verifier or key slots, salt and pending handshakes.
+// The recovery code is kept: it does not depend on the password.
+// Caller must hold s.mu.
+func (s *Session) clearVerification() {
+	if s.keyHash != nil {
+		secure.Shred(s.keyHash)
+		s.keyHash = nil
+	}
+
+	if s.verifier != nil {
+		secure.Shred(s.verifier)
+		s.verifier = nil
+	}
+
+	if s.salt != nil {
+		secure.Shred(s.salt)
+		s.salt = nil
+	}
+	s.kdf = crypto.KDFParams{}
+
+	for _, slot := range s.slots {
+		slot.shred()
+	}
+	s.slots = nil
+
+	for id, hs := range s.handshakes {
+		hs.server.Destroy()
+		delete(s.handshakes, id)
+	}
+}
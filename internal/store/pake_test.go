@@ -0,0 +1,91 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+// srpSession returns a session sealed with an SRP verifier.
+func srpSession(t *testing.T, guard *UnlockGuard) (*SessionManager, []byte) {
+	t.Helper()
+
+	sm := NewSessionManager()
+	sm.SetUnlockGuard(guard)
+	t.Cleanup(sm.Destroy)
+
+	salt := bytes.Repeat([]byte{3}, 16)
+	password := bytes.Repeat([]byte{4}, 32)
+	if _, _, err := sm.LockWithVerifier(salt, crypto.SRPVerifier(salt, password), crypto.LegacyKDFParams(), "test", "", nil); err != nil {
+		t.Fatalf("LockWithVerifier: %v", err)
+	}
+	return sm, password
+}
+
+// beginSRP starts a handshake from client with a fresh client value.
+func beginSRP(t *testing.T, sm *SessionManager, password []byte, client string) (string, error) {
+	t.Helper()
+
+	c, err := crypto.NewSRPClient(password)
+	if err != nil {
+		t.Fatalf("NewSRPClient: %v", err)
+	}
+	defer c.Destroy()
+
+	id, _, _, err := sm.BeginSRP(c.PublicKey(), client)
+	return id, err
+}
+
+func TestBeginSRPLimitsPendingHandshakesPerClient(t *testing.T) {
+	guard := NewUnlockGuard(UnlockGuardConfig{})
+	t.Cleanup(guard.Close)
+	sm, password := srpSession(t, guard)
+
+	for i := 0; i < MaxSRPHandshakesPerClient; i++ {
+		if _, err := beginSRP(t, sm, password, "203.0.113.7"); err != nil {
+			t.Fatalf("handshake %d: %v", i, err)
+		}
+	}
+	if _, err := beginSRP(t, sm, password, "203.0.113.7"); err != ErrTooManyHandshakes {
+		t.Errorf("handshake over the limit: got %v, want %v", err, ErrTooManyHandshakes)
+	}
+
+	// Other clients are not blocked
+	if _, err := beginSRP(t, sm, password, "198.51.100.1"); err != nil {
+		t.Errorf("other client: %v", err)
+	}
+}
+
+func TestAbandonedSRPHandshakesCountAsFailures(t *testing.T) {
+	guard := NewUnlockGuard(UnlockGuardConfig{BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(guard.Close)
+	sm, password := srpSession(t, guard)
+
+	if _, err := beginSRP(t, sm, password, "203.0.113.7"); err != nil {
+		t.Fatalf("BeginSRP: %v", err)
+	}
+
+	// Let the handshake expire without a proof
+	s := sm.GetSession()
+	s.mu.Lock()
+	for _, hs := range s.handshakes {
+		hs.expiresAt = time.Now().Add(-time.Second)
+	}
+	s.mu.Unlock()
+
+	if _, err := beginSRP(t, sm, password, "198.51.100.1"); err != nil {
+		t.Fatalf("BeginSRP: %v", err)
+	}
+
+	if _, ok := guard.Allow("203.0.113.7"); ok {
+		t.Error("client that abandoned a handshake is not backed off")
+	}
+	if _, ok := guard.Allow("198.51.100.1"); !ok {
+		t.Error("other client is backed off")
+	}
+	if stats := guard.Stats(); stats.AbandonedHandshakes != 1 || stats.FailedAttempts != 0 {
+		t.Errorf("stats = %+v, want 1 abandoned handshake and no failed attempts", stats)
+	}
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"crypto/sha256"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+// hashRecoveryCode returns the SHA-256 of the normalized recovery code.
+// The session keeps only this hash; the code is shown once when sealing.
+func hashRecoveryCode(code string) []byte {
+	hash := sha256.Sum256([]byte(crypto.NormalizeRecoveryCode(code)))
+	return hash[:]
+}
+
+// CheckRecoveryCode reports whether code is the recovery code of the sealed session.
+func (sm *SessionManager) CheckRecoveryCode(code string) bool {
+	if code == "" {
+		return false
+	}
+	hash := hashRecoveryCode(code)
+
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if sm.session == nil {
+		return false
+	}
+
+	sm.session.mu.RLock()
+	defer sm.session.mu.RUnlock()
+
+	if !sm.session.locked || sm.session.recoveryHash == nil {
+		return false
+	}
+
+	// Constant-time comparison to prevent timing attacks
+	return crypto.ConstantTimeCompare(sm.session.recoveryHash, hash)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+func TestRecoveryCodeIsStoredWithTheSeal(t *testing.T) {
+	sm := NewSessionManager()
+	t.Cleanup(sm.Destroy)
+
+	code, err := crypto.GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode: %v", err)
+	}
+	keyHash, salt := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16)
+
+	// A rejected seal leaves neither a seal nor a recovery code
+	rejected := errors.New("rejected")
+	if _, _, err := sm.Lock(keyHash, salt, crypto.LegacyKDFParams(), "test", code, func() error { return rejected }); err != rejected {
+		t.Fatalf("Lock with a failing seal: got %v, want %v", err, rejected)
+	}
+	if sm.IsLocked() || sm.CheckRecoveryCode(code) {
+		t.Fatal("failed seal left the session locked or the recovery code valid")
+	}
+
+	if _, _, err := sm.Lock(keyHash, salt, crypto.LegacyKDFParams(), "test", code, nil); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if !sm.CheckRecoveryCode(code) {
+		t.Error("recovery code of the seal was not accepted")
+	}
+	if sm.CheckRecoveryCode("0000-0000-0000-0000") {
+		t.Error("wrong recovery code was accepted")
+	}
+}
+
+func TestRecoveryCodeEndsWithTheSeal(t *testing.T) {
+	sm := NewSessionManager()
+	t.Cleanup(sm.Destroy)
+
+	code, err := crypto.GenerateRecoveryCode()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCode: %v", err)
+	}
+	keyHash, salt := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16)
+	if _, _, err := sm.Lock(keyHash, salt, crypto.LegacyKDFParams(), "test", code, nil); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if err := sm.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if sm.CheckRecoveryCode(code) {
+		t.Error("recovery code accepted while unlocked")
+	}
+
+	// A new seal without a recovery code must not accept the old one
+	if _, _, err := sm.Lock(keyHash, salt, crypto.LegacyKDFParams(), "test", "", nil); err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if sm.CheckRecoveryCode(code) {
+		t.Error("recovery code of an earlier seal accepted")
+	}
+}
@@ -0,0 +1,463 @@
+package store
+
+import (
+	"crypto/sha256"
+	"errors"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+)
+
+var (
+	// ErrRekeyIncomplete indicates a re-key does not cover exactly the sealed data.
+	ErrRekeyIncomplete = errors.New("re-key does not cover all sealed data")
+	// ErrRekeyKeySlots indicates a re-key of a seal with several key slots,
+	// which cannot re-wrap the content key for the other slots.
+	ErrRekeyKeySlots = errors.New("re-key would drop the other key slots")
+)
+
+// RekeyParams describes a password change of the sealed session.
+// The current password is proven with KeyHash (keyhash seals) or with a
+// started SRP handshake (SRP seals); the new password with NewKeyHash or
+// NewVerifier.
+type RekeyParams struct {
+	// Proof of the current password
+	KeyHash     []byte
+	HandshakeID string
+	ClientProof []byte // SRP M1 for HandshakeID
+
+	// New credentials
+	NewKeyHash  []byte
+	NewVerifier []byte
+	NewSalt     []byte
+	NewKDF      crypto.KDFParams
+
+	// Device performing the re-key; it keeps access under the new password
+	DeviceName    string
+	PreviousToken string
+}
+
+// RekeyResult contains the outcome of a successful re-key.
+type RekeyResult struct {
+	Token       string     // New token of the re-keying device
+	Device      DeviceInfo // The re-keying device
+	ServerProof []byte     // SRP M2, if the current password was proven with SRP
+}
+
+// RekeyTx is one store's staged part of a re-key.
+// It holds the store's lock from Begin until Commit or Abort.
+type RekeyTx interface {
+	Commit()
+	Abort()
+}
+
+// Rekey changes the password of the sealed session in one transaction.
+// It verifies the current password, then calls swap while holding the session
+// exclusively; swap replaces the encrypted data and may reject it. Only if
+// swap succeeds are the credentials replaced. All other device tokens are
+// revoked, since those devices hold the old key; the re-keying device gets a
+// new token. A seal with more than one key slot returns ErrRekeyKeySlots.
+func (sm *SessionManager) Rekey(p RekeyParams, swap func() error) (RekeyResult, error) {
+	if p.NewVerifier != nil {
+		if err := crypto.CheckSRPVerifier(p.NewVerifier); err != nil {
+			return RekeyResult{}, err
+		}
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if sm.session == nil {
+		return RekeyResult{}, ErrSessionNotLocked
+	}
+
+	sm.session.mu.Lock()
+	defer sm.session.mu.Unlock()
+
+	s := sm.session
+	if !s.locked {
+		return RekeyResult{}, ErrSessionNotLocked
+	}
+
+	// SECURITY: Prove the current password before touching anything
+	var result RekeyResult
+	if s.verifier != nil {
+		if p.HandshakeID == "" {
+			return RekeyResult{}, ErrPAKERequired
+		}
+		serverProof, err := s.finishSRP(p.HandshakeID, p.ClientProof)
+		if err != nil {
+			return RekeyResult{}, err
+		}
+		result.ServerProof = serverProof
+	} else if p.HandshakeID != "" {
+		return RekeyResult{}, ErrPAKENotEnabled
+	} else if _, err := s.matchKeyHash(p.KeyHash, ""); err != nil {
+		return RekeyResult{}, err
+	}
+
+	// The new credentials replace every key slot; the others must be removed first
+	if len(s.slots) > 1 {
+		return RekeyResult{}, ErrRekeyKeySlots
+	}
+
+	if err := swap(); err != nil {
+		return RekeyResult{}, err
+	}
+
+	// Keep the re-keying device, drop every other token
+	now := time.Now()
+	var previous *device
+	if p.PreviousToken != "" {
+		previous = s.devices[sha256.Sum256([]byte(p.PreviousToken))]
+	}
+	name := p.DeviceName
+	if previous != nil && name == "" {
+		name = previous.name
+	}
+	s.clearDevices()
+
+	// Replace the credentials; pending handshakes were against the old verifier
+	// and the single key slot wrapped the old content key
+	s.setCredentials(credentials{
+		keyHash:  p.NewKeyHash,
+		verifier: p.NewVerifier,
+		salt:     p.NewSalt,
+		kdf:      p.NewKDF,
+	})
+
+	sm.publish(Event{Type: EventSessionRekeyed})
+
+	token, d, err := s.issueDeviceToken(name, now)
+	if err != nil {
+		// The data is already re-keyed; the device must unlock with the new password
+		return result, nil
+	}
+	if previous != nil {
+		d.id = previous.id
+		d.createdAt = previous.createdAt
+	}
+	result.Token = token
+	result.Device = sm.deviceInfo(d)
+
+	return result, nil
+}
+
+// fileRekeyTx replaces the ciphertext and encrypted metadata of every sealed file.
+type fileRekeyTx struct {
+	fs    *FileStore
+	files map[string]stagedFile
+	size  int64
+}
+
+// stagedFile is a re-encrypted or sealed file staged for commit.
+type stagedFile struct {
+	encrypted []byte
+	metadata  []byte
+	bucket    int64
+}
+
+// BeginRekey stages re-encrypted content and metadata for the sealed files.
+// files must list exactly the files currently holding ciphertext. Returns
+// ErrRekeyIncomplete otherwise, an envelope error if content or metadata
+// is not a crypto envelope for its file, or ErrStorageFull if the new
+// ciphertext does not fit next to the old until Commit.
+func (fs *FileStore) BeginRekey(files []EncryptedFileInfo) (RekeyTx, error) {
+	tx := &fileRekeyTx{fs: fs, files: make(map[string]stagedFile, len(files))}
+	for _, f := range files {
+		if _, duplicate := tx.files[f.ID]; duplicate {
+			tx.shred()
+			return nil, ErrRekeyIncomplete
+		}
+		encrypted, metadata, bucket, err := decodeEncryptedFile(f)
+		if err != nil {
+			tx.shred()
+			return nil, err
+		}
+		tx.files[f.ID] = stagedFile{encrypted: encrypted, metadata: metadata, bucket: bucket}
+		tx.size += bucket
+	}
+
+	fs.mu.Lock()
+
+	sealed := 0
+	complete := true
+	for id, file := range fs.files {
+		file.mu.RLock()
+		hasCiphertext := file.encrypted != nil
+		file.mu.RUnlock()
+		if !hasCiphertext {
+			continue
+		}
+		sealed++
+		if _, exists := tx.files[id]; !exists {
+			complete = false
+		}
+	}
+	if !complete || sealed != len(tx.files) {
+		fs.mu.Unlock()
+		tx.shred()
+		return nil, ErrRekeyIncomplete
+	}
+
+	if fs.memory != nil {
+		if err := fs.memory.Allocate(tx.size); err != nil {
+			fs.mu.Unlock()
+			tx.shred()
+			return nil, ErrStorageFull
+		}
+	}
+
+	return tx, nil
+}
+
+// Commit swaps in the new ciphertext and metadata and shreds the old,
+// freeing the old padded sizes.
+func (tx *fileRekeyTx) Commit() {
+	fs := tx.fs
+	defer fs.mu.Unlock()
+
+	fs.version++
+	for id, staged := range tx.files {
+		file := fs.files[id]
+		file.mu.Lock()
+		if fs.memory != nil {
+			fs.memory.Free(file.Size)
+		}
+		secure.Shred(file.encrypted)
+		secure.Shred(file.encryptedMetadata)
+		file.encrypted = staged.encrypted
+		file.encryptedMetadata = staged.metadata
+		file.Size = staged.bucket
+		file.Version = fs.version
+		file.mu.Unlock()
+	}
+}
+
+// Abort releases the store and shreds the staged ciphertext.
+func (tx *fileRekeyTx) Abort() {
+	if tx.fs.memory != nil {
+		tx.fs.memory.Free(tx.size)
+	}
+	tx.fs.mu.Unlock()
+	tx.shred()
+}
+
+func (tx *fileRekeyTx) shred() {
+	for _, staged := range tx.files {
+		secure.Shred(staged.encrypted)
+		secure.Shred(staged.metadata)
+	}
+}
+
+// clipboardRekeyTx replaces the ciphertext of the encrypted clipboard
+// entries of some types, keeping each entry's ID, pin and lifetime.
+type clipboardRekeyTx struct {
+	cs       *ClipboardStore
+	staged   map[*ClipboardEntry][]byte // New ciphertext of each entry
+	mimeType string                     // New MIME type of the current image ("" = unchanged)
+	image    *ClipboardEntry            // Current image
+	expired  []*ClipboardEntry          // Expired encrypted entries, removed on commit
+	size     int64
+}
+
+// BeginRekey stages re-encrypted clipboard entries of types: text and image
+// replace the current text and image, and history replaces older entries by
+// ID. Every live encrypted entry of types must be replaced exactly once, or
+// ErrRekeyIncomplete is returned, so a password change never drops history.
+// All blobs must be crypto envelopes for their entry's type.
+func (cs *ClipboardStore) BeginRekey(types []ClipboardType, text, image []byte, mimeType string, history map[string][]byte) (RekeyTx, error) {
+	current := map[ClipboardType][]byte{ClipboardTypeText: text, ClipboardTypeImage: image}
+	for contentType, blob := range current {
+		if len(blob) == 0 {
+			continue
+		}
+		if err := crypto.CheckEnvelope(blob, clipboardEnvelopeType(contentType), ""); err != nil {
+			return nil, err
+		}
+	}
+
+	cs.mu.Lock()
+
+	tx := &clipboardRekeyTx{cs: cs, staged: make(map[*ClipboardEntry][]byte), mimeType: mimeType}
+	abort := func(err error) (RekeyTx, error) {
+		cs.mu.Unlock()
+		tx.shred()
+		return nil, err
+	}
+
+	now := time.Now()
+	consumed := 0
+	for _, entry := range cs.history {
+		if !hasClipboardType(types, entry.contentType) {
+			continue
+		}
+		entry.mu.RLock()
+		encrypted, expired := entry.encrypted != nil, entry.expired(now)
+		entry.mu.RUnlock()
+		if !encrypted {
+			continue
+		}
+		if expired {
+			tx.expired = append(tx.expired, entry)
+			continue
+		}
+
+		var blob []byte
+		if entry == cs.current(entry.contentType) {
+			blob = current[entry.contentType]
+			delete(current, entry.contentType)
+			if entry.contentType == ClipboardTypeImage {
+				tx.image = entry
+			}
+		} else if blob = history[entry.id]; blob != nil {
+			consumed++
+			if err := crypto.CheckEnvelope(blob, clipboardEnvelopeType(entry.contentType), ""); err != nil {
+				return abort(err)
+			}
+		}
+		if len(blob) == 0 {
+			return abort(ErrRekeyIncomplete)
+		}
+
+		staged := make([]byte, len(blob))
+		copy(staged, blob)
+		tx.staged[entry] = staged
+		tx.size += int64(len(staged))
+	}
+
+	// Nothing may be left over: blobs for entries that are not sealed
+	if consumed != len(history) || len(current[ClipboardTypeText]) > 0 || len(current[ClipboardTypeImage]) > 0 {
+		return abort(ErrRekeyIncomplete)
+	}
+
+	if cs.memory != nil {
+		if err := cs.memory.Allocate(tx.size); err != nil {
+			return abort(ErrStorageFull)
+		}
+	}
+
+	return tx, nil
+}
+
+// clipboardEnvelopeType returns the envelope type of clipboard entries of contentType.
+func clipboardEnvelopeType(contentType ClipboardType) crypto.EnvelopeType {
+	if contentType == ClipboardTypeImage {
+		return crypto.EnvelopeClipboardImage
+	}
+	return crypto.EnvelopeClipboardText
+}
+
+// Commit swaps in the new ciphertext and shreds the old.
+// Expired encrypted entries were not re-encrypted and are removed.
+func (tx *clipboardRekeyTx) Commit() {
+	cs := tx.cs
+
+	for entry, encrypted := range tx.staged {
+		entry.mu.Lock()
+		if cs.memory != nil {
+			cs.memory.Free(int64(entry.size))
+		}
+		secure.Shred(entry.encrypted)
+		entry.encrypted = encrypted
+		entry.size = len(encrypted)
+		if entry == tx.image && tx.mimeType != "" {
+			entry.mimeType = tx.mimeType
+		}
+		if entry == cs.current(entry.contentType) {
+			entry.version = cs.bumpVersion(entry.contentType)
+		} else {
+			entry.version = cs.bumpVersion()
+		}
+		entry.mu.Unlock()
+		cs.publish(EventClipboardSet, entry.contentType, entry.id)
+	}
+	tx.staged = nil
+
+	for _, entry := range tx.expired {
+		if index := cs.indexOf(entry.id); index >= 0 {
+			cs.removeAt(index)
+		}
+	}
+
+	cs.mu.Unlock()
+
+	for _, entry := range tx.expired {
+		cs.shredEntryAsync(entry)
+	}
+}
+
+// Abort releases the store and shreds the staged ciphertext.
+func (tx *clipboardRekeyTx) Abort() {
+	if tx.cs.memory != nil {
+		tx.cs.memory.Free(tx.size)
+	}
+	tx.cs.mu.Unlock()
+	tx.shred()
+}
+
+func (tx *clipboardRekeyTx) shred() {
+	for _, encrypted := range tx.staged {
+		secure.Shred(encrypted)
+	}
+}
+
+// documentRekeyTx replaces the encrypted document snapshot and log.
+type documentRekeyTx struct {
+	ds        *DocumentStore
+	encrypted []byte
+}
+
+// BeginRekey stages a snapshot re-encrypted at revision.
+// revision must be the current revision, since logged operations after an
+// older one are encrypted with the old key; otherwise ErrRevisionConflict.
+func (ds *DocumentStore) BeginRekey(revision uint64, encrypted []byte) (RekeyTx, error) {
+	if len(encrypted) > ds.maxSize {
+		return nil, ErrDocumentTooLarge
+	}
+
+	ds.mu.Lock()
+
+	if !ds.sealed {
+		ds.mu.Unlock()
+		return nil, ErrDocumentNotSealed
+	}
+	if revision != ds.revision {
+		ds.mu.Unlock()
+		return nil, ErrRevisionConflict
+	}
+
+	tx := &documentRekeyTx{ds: ds}
+	if len(encrypted) > 0 {
+		if ds.memory != nil {
+			if err := ds.memory.Allocate(int64(len(encrypted))); err != nil {
+				ds.mu.Unlock()
+				return nil, ErrStorageFull
+			}
+		}
+		tx.encrypted = make([]byte, len(encrypted))
+		copy(tx.encrypted, encrypted)
+	}
+
+	return tx, nil
+}
+
+// Commit shreds the old snapshot and log and installs the new snapshot.
+func (tx *documentRekeyTx) Commit() {
+	ds := tx.ds
+	defer ds.mu.Unlock()
+
+	ds.shredLocked()
+	ds.snapshot = tx.encrypted
+	ds.snapshotRevision = ds.revision
+}
+
+// Abort releases the store and shreds the staged snapshot.
+func (tx *documentRekeyTx) Abort() {
+	if tx.encrypted != nil && tx.ds.memory != nil {
+		tx.ds.memory.Free(int64(len(tx.encrypted)))
+	}
+	tx.ds.mu.Unlock()
+	secure.Shred(tx.encrypted)
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+)
+
+// clipboardEnvelope encrypts text into a clipboard text envelope.
+func clipboardEnvelope(t *testing.T, text string) []byte {
+	t.Helper()
+	return testEnvelope(t, crypto.EnvelopeHeader{Type: crypto.EnvelopeClipboardText}, []byte(text))
+}
+
+// testEnvelope encrypts plaintext into an envelope for the item h under a fixed key.
+func testEnvelope(t *testing.T, h crypto.EnvelopeHeader, plaintext []byte) []byte {
+	t.Helper()
+
+	key, err := secure.NewSecureKey(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("NewSecureKey: %v", err)
+	}
+	defer key.Destroy()
+
+	envelope, err := crypto.SealEnvelope(key, h, plaintext)
+	if err != nil {
+		t.Fatalf("SealEnvelope: %v", err)
+	}
+	return envelope
+}
+
+func TestClipboardRekeyReplacesHistory(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	for _, text := range []string{"old", "current"} {
+		if _, err := cs.SetEncryptedText(clipboardEnvelope(t, text), AnyVersion, EntryOptions{}); err != nil {
+			t.Fatalf("SetEncryptedText: %v", err)
+		}
+	}
+	older := cs.History()[1].ID // Newest first
+	types := []ClipboardType{ClipboardTypeText}
+
+	// The older entry has no replacement
+	if _, err := cs.BeginRekey(types, clipboardEnvelope(t, "current'"), nil, "", nil); err != ErrRekeyIncomplete {
+		t.Fatalf("rekey without history: got %v, want %v", err, ErrRekeyIncomplete)
+	}
+
+	// An entry that does not exist
+	history := map[string][]byte{older: clipboardEnvelope(t, "old'"), "missing": clipboardEnvelope(t, "x")}
+	if _, err := cs.BeginRekey(types, clipboardEnvelope(t, "current'"), nil, "", history); err != ErrRekeyIncomplete {
+		t.Fatalf("rekey with an unknown entry: got %v, want %v", err, ErrRekeyIncomplete)
+	}
+
+	replacement := clipboardEnvelope(t, "old'")
+	tx, err := cs.BeginRekey(types, clipboardEnvelope(t, "current'"), nil, "", map[string][]byte{older: replacement})
+	if err != nil {
+		t.Fatalf("BeginRekey: %v", err)
+	}
+	tx.Commit()
+
+	if n := len(cs.History()); n != 2 {
+		t.Fatalf("history has %d entries after rekey, want 2", n)
+	}
+	_, encrypted, err := cs.GetEncryptedEntry(older)
+	if err != nil {
+		t.Fatalf("GetEncryptedEntry: %v", err)
+	}
+	if !bytes.Equal(encrypted, replacement) {
+		t.Error("older entry was not re-encrypted")
+	}
+}
+
+func TestRekeyRejectsSeveralKeySlots(t *testing.T) {
+	sm := NewSessionManager()
+	t.Cleanup(sm.Destroy)
+
+	slot := func(b byte) KeySlot {
+		return KeySlot{
+			Label:      "slot",
+			Salt:       bytes.Repeat([]byte{b}, 16),
+			KDF:        crypto.LegacyKDFParams(),
+			KeyHash:    bytes.Repeat([]byte{b}, 32),
+			WrappedKey: bytes.Repeat([]byte{b}, 48),
+		}
+	}
+	if _, _, err := sm.LockWithKeySlots([]KeySlot{slot(1), slot(2)}, "test", "", nil); err != nil {
+		t.Fatalf("LockWithKeySlots: %v", err)
+	}
+
+	swapped := false
+	_, err := sm.Rekey(RekeyParams{
+		KeyHash:    bytes.Repeat([]byte{1}, 32),
+		NewKeyHash: bytes.Repeat([]byte{9}, 32),
+		NewSalt:    bytes.Repeat([]byte{9}, 16),
+		NewKDF:     crypto.LegacyKDFParams(),
+	}, func() error {
+		swapped = true
+		return nil
+	})
+	if err != ErrRekeyKeySlots {
+		t.Fatalf("Rekey: got %v, want %v", err, ErrRekeyKeySlots)
+	}
+	if swapped {
+		t.Error("data was swapped although the re-key was rejected")
+	}
+	if n := len(sm.KeySlots()); n != 2 {
+		t.Errorf("%d key slots after rejected re-key, want 2", n)
+	}
+}
+
+func TestFileRekeyKeepsMemoryAccounting(t *testing.T) {
+	memory, err := secure.NewMemoryTracker(1 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	fs := NewFileStore(nil, memory, 1<<20, time.Hour)
+	t.Cleanup(fs.Close)
+
+	if _, err := fs.AddEncryptedFile(encryptedFile(t, "0000000000000001", 100<<10)); err != nil {
+		t.Fatalf("AddEncryptedFile: %v", err)
+	}
+	before := memory.Allocated()
+
+	// Old and new ciphertext must fit side by side until commit
+	if _, err := fs.BeginRekey([]EncryptedFileInfo{encryptedFile(t, "0000000000000001", 1000<<10)}); err != ErrStorageFull {
+		t.Fatalf("rekey over the memory limit: got %v, want %v", err, ErrStorageFull)
+	}
+	if memory.Allocated() != before {
+		t.Errorf("%d bytes allocated after a rejected rekey, want %d", memory.Allocated(), before)
+	}
+
+	tx, err := fs.BeginRekey([]EncryptedFileInfo{encryptedFile(t, "0000000000000001", 300<<10)})
+	if err != nil {
+		t.Fatalf("BeginRekey: %v", err)
+	}
+	tx.Commit()
+	if want := fs.Stats().TotalSize; memory.Allocated() != want {
+		t.Errorf("%d bytes allocated after rekey, want %d", memory.Allocated(), want)
+	}
+
+	fs.ShredAll()
+	if memory.Allocated() != 0 {
+		t.Errorf("%d bytes allocated after shredding, want 0", memory.Allocated())
+	}
+}
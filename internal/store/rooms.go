@@ -0,0 +1,336 @@
+package store
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+	"github.com/fileez/fileez/internal/validate"
+)
+
+var (
+	// ErrRoomNotFound indicates the room does not exist or has expired.
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrTooManyRooms indicates the room limit has been reached.
+	ErrTooManyRooms = errors.New("too many rooms")
+)
+
+const (
+	// DefaultMaxRooms is the default maximum number of rooms.
+	DefaultMaxRooms = 32
+	// DefaultRoomExpiry is how long a room may sit idle before it is shredded.
+	DefaultRoomExpiry = 24 * time.Hour
+	// DefaultRoomMemoryShare is the share of the global memory limit all rooms
+	// together may use without an explicit per-room budget. Rooms need no
+	// credentials to create, so they must not starve the default session.
+	DefaultRoomMemoryShare = 2 // 1/2
+)
+
+// RoomConfig holds the settings used for the stores of every room.
+type RoomConfig struct {
+	MaxMemory                int64 // Memory budget per room, drawn from the global tracker (0 = DefaultRoomMemoryShare)
+	MaxFileSize              int64
+	FileExpiry               time.Duration
+	ClipboardExpiry          time.Duration
+	ClipboardHistory         int
+	ClipboardHistoryMaxBytes int64
+	MaxChannels              int
+	ChannelMaxMemory         int64 // Drawn from the room budget
+	DocumentHistory          int
+	TokenPolicy              TokenPolicy
+	UnlockGuard              UnlockGuardConfig
+}
+
+// Room is an isolated session with its own stores, lock state and token.
+// Nothing in a room is visible from the default session or other rooms.
+type Room struct {
+	Code      string
+	CreatedAt time.Time
+
+	Session   *SessionManager
+	Files     *FileStore
+	Clipboard *ClipboardStore
+	Channels  *ChannelStore
+	Document  *DocumentStore
+	Events    *EventBus
+	Memory    *secure.MemoryTracker
+	Guard     *UnlockGuard
+
+	// Unix nanoseconds of the last request
+	lastActive atomic.Int64
+}
+
+// RoomInfo contains room metadata for API responses.
+type RoomInfo struct {
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at"`
+	Locked    bool      `json:"locked"`
+}
+
+// RoomManager creates and expires rooms.
+// Rooms are only reachable by their code; they cannot be listed.
+type RoomManager struct {
+	mu sync.Mutex
+
+	rooms map[string]*Room
+
+	// Configuration
+	config   RoomConfig
+	maxRooms int
+	expiry   time.Duration
+
+	// Global memory tracker every room budget draws from
+	memory *secure.MemoryTracker
+
+	// Called after a room is removed
+	onRemove func(code string)
+
+	// Shutdown signal
+	done chan struct{}
+}
+
+// NewRoomManager creates a new room manager.
+// expiry is how long a room may go without requests before it is shredded.
+func NewRoomManager(memory *secure.MemoryTracker, config RoomConfig, maxRooms int, expiry time.Duration) *RoomManager {
+	if maxRooms <= 0 {
+		maxRooms = DefaultMaxRooms
+	}
+	if expiry == 0 {
+		expiry = DefaultRoomExpiry
+	}
+	if config.MaxMemory <= 0 {
+		config.MaxMemory = max(memory.Limit()/int64(DefaultRoomMemoryShare*maxRooms), secure.MinMemoryLimit)
+	}
+
+	rm := &RoomManager{
+		rooms:    make(map[string]*Room),
+		config:   config,
+		maxRooms: maxRooms,
+		expiry:   expiry,
+		memory:   memory,
+		done:     make(chan struct{}),
+	}
+
+	// Start expiry checker
+	go rm.expiryLoop()
+
+	return rm
+}
+
+// OnRemove sets a function called with the code of every room that is
+// deleted or expires, after its data has been shredded.
+func (rm *RoomManager) OnRemove(fn func(code string)) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	rm.onRemove = fn
+}
+
+// Create creates a new room with a random code.
+func (rm *RoomManager) Create() (*Room, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if len(rm.rooms) >= rm.maxRooms {
+		return nil, ErrTooManyRooms
+	}
+
+	var code string
+	for {
+		var err error
+		code, err = crypto.GenerateRoomCode()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := rm.rooms[code]; !exists {
+			break
+		}
+	}
+
+	memory, err := rm.memory.Child(rm.config.MaxMemory)
+	if err != nil {
+		return nil, err
+	}
+	channelMemory, err := memory.Child(rm.config.ChannelMaxMemory)
+	if err != nil {
+		memory.Reset()
+		return nil, err
+	}
+
+	cfg := rm.config
+	session := NewSessionManager()
+	events := NewEventBus()
+	session.SetEventBus(events)
+	session.SetTokenPolicy(cfg.TokenPolicy)
+
+	room := &Room{
+		Code:      code,
+		CreatedAt: time.Now(),
+		Session:   session,
+		Files:     NewFileStore(session, memory, cfg.MaxFileSize, cfg.FileExpiry),
+		Clipboard: NewClipboardStore(session, memory, cfg.ClipboardExpiry, cfg.ClipboardHistory, cfg.ClipboardHistoryMaxBytes),
+		Channels:  NewChannelStore(session, channelMemory, cfg.MaxChannels, cfg.ClipboardExpiry, cfg.ClipboardHistory, cfg.ClipboardHistoryMaxBytes),
+		Document:  NewDocumentStore(memory, cfg.DocumentHistory, cfg.ClipboardExpiry),
+		Events:    events,
+		Memory:    memory,
+		Guard:     NewUnlockGuard(cfg.UnlockGuard),
+	}
+	session.SetUnlockGuard(room.Guard)
+	room.Files.SetEventBus(events)
+	room.Clipboard.SetEventBus(events, "")
+	room.Channels.SetEventBus(events)
+	room.Document.SetEventBus(events)
+	room.Touch()
+
+	rm.rooms[code] = room
+
+	return room, nil
+}
+
+// Get returns the room with the given code and marks it active.
+func (rm *RoomManager) Get(code string) (*Room, error) {
+	code, err := validate.RoomCode(code)
+	if err != nil {
+		return nil, ErrRoomNotFound
+	}
+
+	rm.mu.Lock()
+	room, exists := rm.rooms[code]
+	rm.mu.Unlock()
+
+	if !exists {
+		return nil, ErrRoomNotFound
+	}
+
+	room.Touch()
+	return room, nil
+}
+
+// Delete shreds a room's data and removes it.
+func (rm *RoomManager) Delete(code string) error {
+	code, err := validate.RoomCode(code)
+	if err != nil {
+		return ErrRoomNotFound
+	}
+
+	rm.mu.Lock()
+	room, exists := rm.rooms[code]
+	if !exists {
+		rm.mu.Unlock()
+		return ErrRoomNotFound
+	}
+	delete(rm.rooms, code)
+	onRemove := rm.onRemove
+	rm.mu.Unlock()
+
+	room.close()
+	if onRemove != nil {
+		onRemove(code)
+	}
+
+	return nil
+}
+
+// Count returns the number of rooms.
+func (rm *RoomManager) Count() int {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	return len(rm.rooms)
+}
+
+// Close shreds and removes all rooms.
+// Should be called on application shutdown.
+func (rm *RoomManager) Close() {
+	rm.mu.Lock()
+	select {
+	case <-rm.done:
+	default:
+		close(rm.done)
+	}
+	rooms := rm.rooms
+	rm.rooms = make(map[string]*Room)
+	rm.mu.Unlock()
+
+	for _, room := range rooms {
+		room.close()
+	}
+}
+
+// CloseEvents ends the event streams of all rooms, keeping their data.
+// Called on shutdown so open streams don't hold up the HTTP server.
+func (rm *RoomManager) CloseEvents() {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	for _, room := range rm.rooms {
+		room.Events.Close()
+	}
+}
+
+// Touch marks the room as active.
+func (r *Room) Touch() {
+	r.lastActive.Store(time.Now().UnixNano())
+}
+
+// Info returns metadata about the room.
+func (r *Room) Info() RoomInfo {
+	return RoomInfo{
+		Code:      r.Code,
+		CreatedAt: r.CreatedAt,
+		Locked:    r.Session.IsLocked(),
+	}
+}
+
+// close stops the room's stores, shreds all data and ends its event streams.
+func (r *Room) close() {
+	r.Files.Close()
+	r.Clipboard.Close()
+	r.Channels.Close()
+	r.Document.Close()
+	r.Session.Destroy()
+	r.Events.Close()
+	r.Guard.Close()
+
+	// Return anything still accounted to the global tracker
+	r.Memory.Reset()
+}
+
+// expiryLoop periodically removes idle rooms.
+func (rm *RoomManager) expiryLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			rm.cleanupExpired()
+		case <-rm.done:
+			return
+		}
+	}
+}
+
+// cleanupExpired shreds rooms that have had no requests within the expiry.
+func (rm *RoomManager) cleanupExpired() {
+	cutoff := time.Now().Add(-rm.expiry).UnixNano()
+
+	rm.mu.Lock()
+	var expired []*Room
+	for code, room := range rm.rooms {
+		if room.lastActive.Load() < cutoff {
+			expired = append(expired, room)
+			delete(rm.rooms, code)
+		}
+	}
+	onRemove := rm.onRemove
+	rm.mu.Unlock()
+
+	for _, room := range expired {
+		room.close()
+		if onRemove != nil {
+			onRemove(room.Code)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/fileez/fileez/internal/secure"
+)
+
+func TestRoomMemoryDefaultsToShareOfGlobalLimit(t *testing.T) {
+	global, err := secure.NewMemoryTracker(64 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	rm := NewRoomManager(global, RoomConfig{}, 4, 0)
+	t.Cleanup(rm.Close)
+
+	room, err := rm.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if limit, want := room.Memory.Limit(), int64(64<<20)/(DefaultRoomMemoryShare*4); limit != want {
+		t.Errorf("room memory limit = %d, want %d", limit, want)
+	}
+}
+
+func TestRoomCreateFailureLeavesGlobalMemoryUntouched(t *testing.T) {
+	global, err := secure.NewMemoryTracker(64 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	// A channel budget below the minimum fails after the room budget was created
+	rm := NewRoomManager(global, RoomConfig{MaxMemory: 8 << 20, ChannelMaxMemory: 1}, 4, 0)
+	t.Cleanup(rm.Close)
+
+	if _, err := rm.Create(); err == nil {
+		t.Fatal("Create succeeded with an invalid channel budget")
+	}
+	if n := rm.Count(); n != 0 {
+		t.Errorf("%d rooms after failed create, want 0", n)
+	}
+	if allocated := global.Allocated(); allocated != 0 {
+		t.Errorf("global tracker has %d bytes allocated, want 0", allocated)
+	}
+}
+
+func TestRoomCloseEventsEndsStreams(t *testing.T) {
+	global, err := secure.NewMemoryTracker(64 << 20)
+	if err != nil {
+		t.Fatalf("NewMemoryTracker: %v", err)
+	}
+	rm := NewRoomManager(global, RoomConfig{}, 4, 0)
+	t.Cleanup(rm.Close)
+
+	room, err := rm.Create()
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	events, unsubscribe := room.Events.Subscribe()
+	defer unsubscribe()
+
+	rm.CloseEvents()
+
+	if _, open := <-events; open {
+		t.Error("room event stream still open after CloseEvents")
+	}
+	if _, err := rm.Get(room.Code); err != nil {
+		t.Errorf("room removed by CloseEvents: %v", err)
+	}
+}
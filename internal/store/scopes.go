@@ -0,0 +1,188 @@
+package store
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrScopeConflict indicates a seal that would overlap another: the whole
+// session cannot be sealed while a lock scope is, nor a scope while the
+// whole session is.
+var ErrScopeConflict = errors.New("seal conflicts with a sealed lock scope")
+
+// LockScope is a part of the session that can be sealed on its own, with
+// its own credentials and device tokens.
+type LockScope string
+
+// Lock scopes.
+const (
+	// ScopeSession is the whole session, sealed by /api/lock.
+	ScopeSession LockScope = ""
+	// ScopeFiles covers the shared files.
+	ScopeFiles LockScope = "files"
+	// ScopeClipboardText covers clipboard text, the clipboard channels and
+	// the shared document.
+	ScopeClipboardText LockScope = "clipboard-text"
+	// ScopeClipboardImage covers the clipboard image.
+	ScopeClipboardImage LockScope = "clipboard-image"
+)
+
+// AllLockScopes lists the scopes that can be sealed on their own.
+var AllLockScopes = []LockScope{ScopeFiles, ScopeClipboardText, ScopeClipboardImage}
+
+// ClipboardTypes returns the clipboard entry types the scope covers.
+func (s LockScope) ClipboardTypes() []ClipboardType {
+	switch s {
+	case ScopeSession:
+		return []ClipboardType{ClipboardTypeText, ClipboardTypeImage}
+	case ScopeClipboardText:
+		return []ClipboardType{ClipboardTypeText}
+	case ScopeClipboardImage:
+		return []ClipboardType{ClipboardTypeImage}
+	}
+	return nil
+}
+
+// Covers reports whether the scope covers clipboard entries of contentType.
+func (s LockScope) Covers(contentType ClipboardType) bool {
+	return hasClipboardType(s.ClipboardTypes(), contentType)
+}
+
+// hasClipboardType reports whether types contains contentType.
+func hasClipboardType(types []ClipboardType, contentType ClipboardType) bool {
+	for _, t := range types {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// LockState is the seal guarding some data. Handlers ask it whether their
+// data is sealed, and middleware which device tokens may access it.
+// *SessionManager is the whole session, *ScopeLock one lock scope.
+type LockState interface {
+	IsLocked() bool
+	ValidateToken(token string) bool // Counts as activity of the token's device
+	CheckToken(token string) bool    // Does not count as activity
+}
+
+// LockScopes holds a session and unlock guard per lock scope, next to the
+// whole session. Each scope is sealed, unlocked and force-unlocked on its
+// own; sealing the whole session and sealing scopes exclude each other.
+type LockScopes struct {
+	// Held while sealing, so the conflict check and the seal are atomic
+	mu sync.Mutex
+
+	session *SessionManager
+	scopes  map[LockScope]*SessionManager
+	guards  map[LockScope]*UnlockGuard
+}
+
+// NewLockScopes creates an unsealed session and an unlock guard for each
+// scope of session. Scope sessions publish their events to events.
+func NewLockScopes(session *SessionManager, policy TokenPolicy, guard UnlockGuardConfig, events *EventBus) *LockScopes {
+	ls := &LockScopes{
+		session: session,
+		scopes:  make(map[LockScope]*SessionManager, len(AllLockScopes)),
+		guards:  make(map[LockScope]*UnlockGuard, len(AllLockScopes)),
+	}
+	for _, scope := range AllLockScopes {
+		sm := NewSessionManager()
+		sm.scope = scope
+		sm.SetEventBus(events)
+		sm.SetTokenPolicy(policy)
+		ls.scopes[scope] = sm
+		ls.guards[scope] = NewUnlockGuard(guard)
+		sm.SetUnlockGuard(ls.guards[scope])
+	}
+	return ls
+}
+
+// Session returns the session that seals scope.
+func (ls *LockScopes) Session(scope LockScope) *SessionManager {
+	if scope == ScopeSession {
+		return ls.session
+	}
+	return ls.scopes[scope]
+}
+
+// Guard returns the failed unlock tracking of scope.
+func (ls *LockScopes) Guard(scope LockScope) *UnlockGuard {
+	return ls.guards[scope]
+}
+
+// Lock returns the seal guarding the data of scope.
+func (ls *LockScopes) Lock(scope LockScope) *ScopeLock {
+	return &ScopeLock{session: ls.session, scope: ls.scopes[scope]}
+}
+
+// Seal calls seal, which seals scope (ScopeSession for the whole session),
+// unless that would overlap a sealed scope.
+// Returns ErrScopeConflict or the error of seal.
+func (ls *LockScopes) Seal(scope LockScope, seal func() error) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	if scope == ScopeSession {
+		for _, sm := range ls.scopes {
+			if sm.IsLocked() {
+				return ErrScopeConflict
+			}
+		}
+	} else if ls.session.IsLocked() {
+		return ErrScopeConflict
+	}
+
+	return seal()
+}
+
+// Sealed returns the scopes that are sealed on their own.
+func (ls *LockScopes) Sealed() []LockScope {
+	var sealed []LockScope
+	for _, scope := range AllLockScopes {
+		if ls.scopes[scope].IsLocked() {
+			sealed = append(sealed, scope)
+		}
+	}
+	return sealed
+}
+
+// Destroy securely wipes the session of every scope and stops the guards.
+func (ls *LockScopes) Destroy() {
+	for scope, sm := range ls.scopes {
+		sm.Destroy()
+		ls.guards[scope].Close()
+	}
+}
+
+// ScopeLock is the seal guarding the data of one lock scope: the data is
+// sealed while the whole session or the scope is. Only one of them can be
+// sealed at a time, and the tokens of that one are accepted.
+type ScopeLock struct {
+	session *SessionManager
+	scope   *SessionManager
+}
+
+// current returns the session whose seal applies.
+func (l *ScopeLock) current() *SessionManager {
+	if l.session.IsLocked() {
+		return l.session
+	}
+	return l.scope
+}
+
+// IsLocked returns whether the scope's data is sealed.
+func (l *ScopeLock) IsLocked() bool {
+	return l.current().IsLocked()
+}
+
+// ValidateToken checks a device token against the seal that applies.
+func (l *ScopeLock) ValidateToken(token string) bool {
+	return l.current().ValidateToken(token)
+}
+
+// CheckToken is like ValidateToken but does not count as activity.
+func (l *ScopeLock) CheckToken(token string) bool {
+	return l.current().CheckToken(token)
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/fileez/fileez/internal/crypto"
+)
+
+// sealScope seals the session of scope through ls and returns its device token.
+func sealScope(t *testing.T, ls *LockScopes, scope LockScope) (string, error) {
+	t.Helper()
+
+	var token string
+	err := ls.Seal(scope, func() error {
+		var err error
+		token, _, err = ls.Session(scope).Lock(bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{1}, 16), crypto.LegacyKDFParams(), "test", "", nil)
+		return err
+	})
+	return token, err
+}
+
+func TestLockScopesSealIndependently(t *testing.T) {
+	session := NewSessionManager()
+	t.Cleanup(session.Destroy)
+	ls := NewLockScopes(session, TokenPolicy{}, UnlockGuardConfig{}, nil)
+	t.Cleanup(ls.Destroy)
+
+	token, err := sealScope(t, ls, ScopeFiles)
+	if err != nil {
+		t.Fatalf("seal files: %v", err)
+	}
+
+	if !ls.Lock(ScopeFiles).IsLocked() {
+		t.Error("files not sealed")
+	}
+	if ls.Lock(ScopeClipboardText).IsLocked() || ls.Lock(ScopeClipboardImage).IsLocked() || session.IsLocked() {
+		t.Error("sealing files sealed other data")
+	}
+	if sealed := ls.Sealed(); !slices.Equal(sealed, []LockScope{ScopeFiles}) {
+		t.Errorf("sealed scopes = %v, want [files]", sealed)
+	}
+
+	// The scope's token opens only that scope
+	if !ls.Lock(ScopeFiles).ValidateToken(token) {
+		t.Error("files token rejected for files")
+	}
+	if _, err := sealScope(t, ls, ScopeClipboardText); err != nil {
+		t.Fatalf("seal clipboard text: %v", err)
+	}
+	if ls.Lock(ScopeClipboardText).ValidateToken(token) {
+		t.Error("files token accepted for clipboard text")
+	}
+
+	// The whole session cannot be sealed over the scopes
+	if _, err := sealScope(t, ls, ScopeSession); err != ErrScopeConflict {
+		t.Errorf("seal the session over sealed scopes: got %v, want %v", err, ErrScopeConflict)
+	}
+	if session.IsLocked() {
+		t.Error("session sealed despite the conflict")
+	}
+}
+
+func TestSessionSealCoversEveryScope(t *testing.T) {
+	session := NewSessionManager()
+	t.Cleanup(session.Destroy)
+	ls := NewLockScopes(session, TokenPolicy{}, UnlockGuardConfig{}, nil)
+	t.Cleanup(ls.Destroy)
+
+	token, err := sealScope(t, ls, ScopeSession)
+	if err != nil {
+		t.Fatalf("seal session: %v", err)
+	}
+
+	for _, scope := range AllLockScopes {
+		lock := ls.Lock(scope)
+		if !lock.IsLocked() || !lock.ValidateToken(token) {
+			t.Errorf("%s: locked = %v, session token accepted = %v, want both", scope, lock.IsLocked(), lock.ValidateToken(token))
+		}
+	}
+	if _, err := sealScope(t, ls, ScopeClipboardImage); err != ErrScopeConflict {
+		t.Errorf("seal a scope of a sealed session: got %v, want %v", err, ErrScopeConflict)
+	}
+	if sealed := ls.Sealed(); len(sealed) != 0 {
+		t.Errorf("sealed scopes = %v, want none", sealed)
+	}
+}
+
+func TestClipboardSealKeepsOtherTypes(t *testing.T) {
+	cs := NewClipboardStore(nil, nil, 0, 0, 0)
+	t.Cleanup(cs.Close)
+
+	if _, err := cs.SetText([]byte("text"), AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetText: %v", err)
+	}
+	if _, err := cs.SetImage([]byte("png"), "image/png", AnyVersion, EntryOptions{}); err != nil {
+		t.Fatalf("SetImage: %v", err)
+	}
+	imageVersion := cs.ImageVersion()
+
+	// Seal only the clipboard text scope
+	tx, err := cs.BeginSeal(ScopeClipboardText.ClipboardTypes(), clipboardEnvelope(t, "text"), nil, "")
+	if err != nil {
+		t.Fatalf("BeginSeal: %v", err)
+	}
+	tx.Commit()
+	tx.Shred()
+
+	if _, err := cs.GetText(); err == nil {
+		t.Error("plaintext text left after sealing the text scope")
+	}
+	if cs.GetEncryptedText() == nil {
+		t.Error("sealed text not stored")
+	}
+	image, mimeType, err := cs.GetImage()
+	if err != nil {
+		t.Fatalf("GetImage: %v", err)
+	}
+	if string(image) != "png" || mimeType != "image/png" {
+		t.Errorf("image = %q (%s), want the unsealed image", image, mimeType)
+	}
+	if v := cs.ImageVersion(); v != imageVersion {
+		t.Errorf("image version = %d after sealing text, want %d", v, imageVersion)
+	}
+}
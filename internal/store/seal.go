@@ -0,0 +1,323 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/fileez/fileez/internal/crypto"
+	"github.com/fileez/fileez/internal/secure"
+)
+
+// ErrDuplicateFile indicates a seal lists the same file ID twice, or an
+// encrypted upload reuses the ID of a stored file.
+var ErrDuplicateFile = errors.New("duplicate file ID")
+
+// SealTx is one store's staged part of a lock.
+// It holds the store's lock from Begin until Commit or Abort. Commit swaps
+// the staged ciphertext in and detaches the plaintext it replaces; Shred
+// destroys that plaintext once the session is sealed.
+type SealTx interface {
+	Commit()
+	Abort()
+	Shred()
+}
+
+// fileSealTx replaces all files with the sealed files.
+type fileSealTx struct {
+	fs       *FileStore
+	files    map[string]stagedFile
+	size     int64
+	replaced []*StoredFile
+}
+
+// BeginSeal stages the encrypted files replacing every stored file.
+// The padded sizes count against the same size and memory limits as
+// uploads. Returns an envelope error if a file's content or metadata is not
+// a crypto envelope for the file, ErrDuplicateFile, ErrFileTooLarge or
+// ErrStorageFull.
+func (fs *FileStore) BeginSeal(files []EncryptedFileInfo) (SealTx, error) {
+	tx := &fileSealTx{fs: fs, files: make(map[string]stagedFile, len(files))}
+	for _, f := range files {
+		if _, duplicate := tx.files[f.ID]; duplicate {
+			tx.shredStaged()
+			return nil, ErrDuplicateFile
+		}
+		encrypted, metadata, bucket, err := decodeEncryptedFile(f)
+		if err != nil {
+			tx.shredStaged()
+			return nil, err
+		}
+		tx.files[f.ID] = stagedFile{encrypted: encrypted, metadata: metadata, bucket: bucket}
+		if bucket > crypto.SizeBucket(fs.maxFileSize) {
+			tx.shredStaged()
+			return nil, ErrFileTooLarge
+		}
+		tx.size += bucket
+	}
+
+	fs.mu.Lock()
+
+	if fs.memory != nil {
+		if err := fs.memory.Allocate(tx.size); err != nil {
+			fs.mu.Unlock()
+			tx.shredStaged()
+			return nil, ErrStorageFull
+		}
+	}
+
+	return tx, nil
+}
+
+// Commit removes the stored files and adds the sealed files.
+func (tx *fileSealTx) Commit() {
+	fs := tx.fs
+	defer fs.mu.Unlock()
+
+	for id, file := range fs.files {
+		tx.replaced = append(tx.replaced, file)
+		delete(fs.files, id)
+	}
+
+	fs.version++
+	now := time.Now()
+	for id, staged := range tx.files {
+		fs.files[id] = fs.newEncryptedFile(id, staged.encrypted, staged.metadata, staged.bucket, now)
+	}
+}
+
+// Abort releases the store and shreds the staged files.
+func (tx *fileSealTx) Abort() {
+	if tx.fs.memory != nil {
+		tx.fs.memory.Free(tx.size)
+	}
+	tx.fs.mu.Unlock()
+	tx.shredStaged()
+}
+
+// Shred destroys the files removed by Commit.
+func (tx *fileSealTx) Shred() {
+	for _, file := range tx.replaced {
+		tx.fs.shredFile(file)
+	}
+	tx.replaced = nil
+}
+
+func (tx *fileSealTx) shredStaged() {
+	for _, staged := range tx.files {
+		secure.Shred(staged.encrypted)
+		secure.Shred(staged.metadata)
+	}
+}
+
+// clipboardSealTx replaces the history entries of some types with the
+// sealed entries.
+type clipboardSealTx struct {
+	cs       *ClipboardStore
+	types    []ClipboardType
+	entries  []*ClipboardEntry
+	size     int64
+	replaced []*ClipboardEntry
+}
+
+// BeginSeal stages the encrypted current text and image replacing every
+// history entry of types; entries of other types are kept. Both must be
+// crypto envelopes for their type, and empty unless their type is in types.
+func (cs *ClipboardStore) BeginSeal(types []ClipboardType, text, image []byte, mimeType string) (SealTx, error) {
+	entries, size, err := cs.stageEncrypted(text, image, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	tx := &clipboardSealTx{cs: cs, types: types, entries: entries, size: size}
+
+	cs.mu.Lock()
+
+	if cs.memory != nil {
+		if err := cs.memory.Allocate(tx.size); err != nil {
+			cs.mu.Unlock()
+			discardEntries(tx.entries)
+			return nil, ErrStorageFull
+		}
+	}
+
+	return tx, nil
+}
+
+// Commit removes the entries of the sealed types and adds the sealed entries.
+func (tx *clipboardSealTx) Commit() {
+	cs := tx.cs
+
+	kept := cs.history[:0]
+	for _, entry := range cs.history {
+		if hasClipboardType(tx.types, entry.contentType) {
+			tx.replaced = append(tx.replaced, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	cs.truncateHistory(kept)
+	cs.bumpVersion(tx.types...)
+
+	now := time.Now()
+	for _, entry := range tx.entries {
+		entry.expiresAt = now.Add(entry.ttl)
+		entry.version = cs.bumpVersion(entry.contentType)
+		cs.history = append(cs.history, entry)
+		cs.publish(EventClipboardSet, entry.contentType, entry.id)
+	}
+
+	cs.mu.Unlock()
+
+	cs.wakeExpiry()
+}
+
+// Abort releases the store and shreds the staged entries.
+func (tx *clipboardSealTx) Abort() {
+	if tx.cs.memory != nil {
+		tx.cs.memory.Free(tx.size)
+	}
+	tx.cs.mu.Unlock()
+	discardEntries(tx.entries)
+}
+
+// Shred destroys the entries removed by Commit.
+func (tx *clipboardSealTx) Shred() {
+	for _, entry := range tx.replaced {
+		tx.cs.shredEntry(entry)
+	}
+	tx.replaced = nil
+}
+
+// stageEncrypted builds new entries for an encrypted current text and image,
+// which must be crypto envelopes for their type. Empty blobs are skipped.
+// Returns the entries and their total size.
+func (cs *ClipboardStore) stageEncrypted(text, image []byte, mimeType string) ([]*ClipboardEntry, int64, error) {
+	var entries []*ClipboardEntry
+	var size int64
+	now := time.Now()
+	for _, blob := range []struct {
+		encrypted    []byte
+		contentType  ClipboardType
+		envelopeType crypto.EnvelopeType
+		mimeType     string
+	}{
+		{text, ClipboardTypeText, crypto.EnvelopeClipboardText, ""},
+		{image, ClipboardTypeImage, crypto.EnvelopeClipboardImage, mimeType},
+	} {
+		if len(blob.encrypted) == 0 {
+			continue
+		}
+		if err := crypto.CheckEnvelope(blob.encrypted, blob.envelopeType, ""); err != nil {
+			discardEntries(entries)
+			return nil, 0, err
+		}
+		id, err := crypto.GenerateFileID()
+		if err != nil {
+			discardEntries(entries)
+			return nil, 0, err
+		}
+		entry := &ClipboardEntry{
+			id:          id,
+			encrypted:   make([]byte, len(blob.encrypted)),
+			contentType: blob.contentType,
+			mimeType:    blob.mimeType,
+			size:        len(blob.encrypted),
+			createdAt:   now,
+			ttl:         cs.expiry,
+		}
+		copy(entry.encrypted, blob.encrypted)
+		entries = append(entries, entry)
+		size += int64(entry.size)
+	}
+
+	return entries, size, nil
+}
+
+// discardEntries shreds entries that never entered the store.
+func discardEntries(entries []*ClipboardEntry) {
+	for _, entry := range entries {
+		discardEntry(entry)
+	}
+}
+
+// documentSealTx replaces the document with an encrypted snapshot.
+type documentSealTx struct {
+	ds        *DocumentStore
+	encrypted []byte
+
+	// Replaced by Commit
+	text     *secure.FortifiedBuffer
+	snapshot []byte
+	log      []*documentOp
+}
+
+// BeginSeal stages the encrypted snapshot replacing the document and its log.
+// encrypted may be empty for a sealed document without content.
+func (ds *DocumentStore) BeginSeal(encrypted []byte) (SealTx, error) {
+	if len(encrypted) > ds.maxSize {
+		return nil, ErrDocumentTooLarge
+	}
+
+	ds.mu.Lock()
+
+	tx := &documentSealTx{ds: ds}
+	if len(encrypted) > 0 {
+		if ds.memory != nil {
+			if err := ds.memory.Allocate(int64(len(encrypted))); err != nil {
+				ds.mu.Unlock()
+				return nil, ErrStorageFull
+			}
+		}
+		tx.encrypted = make([]byte, len(encrypted))
+		copy(tx.encrypted, encrypted)
+	}
+
+	return tx, nil
+}
+
+// Commit seals the document with the staged snapshot.
+func (tx *documentSealTx) Commit() {
+	ds := tx.ds
+	defer ds.mu.Unlock()
+
+	tx.text, ds.text = ds.text, nil
+	tx.snapshot, ds.snapshot = ds.snapshot, nil
+	tx.log, ds.log = ds.log, nil
+	ds.length = 0
+	ds.base = ds.revision
+
+	ds.sealed = true
+	ds.snapshot = tx.encrypted
+	ds.snapshotRevision = ds.revision
+}
+
+// Abort releases the store and shreds the staged snapshot.
+func (tx *documentSealTx) Abort() {
+	if tx.encrypted != nil && tx.ds.memory != nil {
+		tx.ds.memory.Free(int64(len(tx.encrypted)))
+	}
+	tx.ds.mu.Unlock()
+	secure.Shred(tx.encrypted)
+}
+
+// Shred destroys the document content, snapshot and log replaced by Commit.
+func (tx *documentSealTx) Shred() {
+	ds := tx.ds
+	if tx.text != nil {
+		if ds.memory != nil {
+			ds.memory.Free(int64(tx.text.Size()))
+		}
+		secure.ShredFortifiedBuffer(tx.text)
+		tx.text = nil
+	}
+	if tx.snapshot != nil {
+		if ds.memory != nil {
+			ds.memory.Free(int64(len(tx.snapshot)))
+		}
+		secure.Shred(tx.snapshot)
+		tx.snapshot = nil
+	}
+	for _, op := range tx.log {
+		ds.shredOp(op)
+	}
+	tx.log = nil
+}
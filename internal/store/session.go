@@ -2,12 +2,12 @@
 package store
 
 import (
+	"crypto/sha256"
 	"errors"
 	"sync"
 	"time"
 
 	"github.com/fileez/fileez/internal/crypto"
-	"github.com/fileez/fileez/internal/secure"
 )
 
 var (
@@ -27,7 +27,6 @@ type Session struct {
 	mu sync.RWMutex
 
 	// Session state
-	token     string
 	locked    bool
 	createdAt time.Time
 	lockedAt  time.Time
@@ -37,6 +36,26 @@ type Session struct {
 	// salt: PBKDF2 salt (sent to client for key derivation on unlock)
 	keyHash []byte
 	salt    []byte
+	// How clients derive the key from the password and salt
+	kdf crypto.KDFParams
+
+	// SRP verifier, used instead of keyHash when sealed with LockWithVerifier,
+	// and the handshakes started against it
+	verifier   []byte
+	handshakes map[string]*srpHandshake
+	// Charged for handshakes that are never finished (nil = none)
+	guard *UnlockGuard
+
+	// Key slots, used instead of keyHash when sealed with LockWithKeySlots
+	slots []*keySlot
+
+	// SHA-256 of the recovery code that authorizes a force-unlock
+	recoveryHash []byte
+
+	// Devices allowed to access sealed data, by SHA-256 of their token
+	devices map[[sha256.Size]byte]*device
+	// Last request from any device (for auto-lock)
+	lastActivity time.Time
 
 	// Callbacks for lock/unlock events
 	onLock   func()
@@ -47,6 +66,18 @@ type Session struct {
 type SessionManager struct {
 	mu      sync.RWMutex
 	session *Session
+
+	// Device token lifetimes
+	policy TokenPolicy
+
+	// Change notifications
+	events *EventBus
+
+	// Lock scope this session seals ("" = the whole session)
+	scope LockScope
+
+	// Failed unlock tracking, charged for abandoned SRP handshakes
+	guard *UnlockGuard
 }
 
 // NewSessionManager creates a new session manager.
@@ -54,25 +85,25 @@ func NewSessionManager() *SessionManager {
 	return &SessionManager{}
 }
 
-// CreateSession creates a new unlocked session.
-// Returns the session token.
-func (sm *SessionManager) CreateSession() (string, error) {
+// SetEventBus sets the bus that receives lock state events.
+func (sm *SessionManager) SetEventBus(events *EventBus) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
+	sm.events = events
+}
 
-	// Generate session token
-	token, err := crypto.GenerateSessionTokenString()
-	if err != nil {
-		return "", err
-	}
-
-	sm.session = &Session{
-		token:     token,
-		locked:    false,
-		createdAt: time.Now(),
-	}
+// SetUnlockGuard sets the failed unlock tracking that is charged for SRP
+// handshakes started but never finished.
+func (sm *SessionManager) SetUnlockGuard(guard *UnlockGuard) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.guard = guard
+}
 
-	return token, nil
+// publish sends a session event, tagged with the session's lock scope.
+func (sm *SessionManager) publish(event Event) {
+	event.Scope = sm.scope
+	sm.events.Publish(event)
 }
 
 // GetSession returns the current session if it exists.
@@ -98,18 +129,56 @@ func (sm *SessionManager) IsLocked() bool {
 
 // Lock locks the session with E2EE.
 // Stores keyHash and salt from client for verification (server cannot derive key).
-func (sm *SessionManager) Lock(keyHash, salt []byte) error {
+// recoveryCode authorizes a force-unlock of the seal ("" = none).
+// seal swaps in the encrypted data (see lock); it may be nil.
+// Returns the token of the locking device.
+func (sm *SessionManager) Lock(keyHash, salt []byte, kdf crypto.KDFParams, deviceName, recoveryCode string, seal func() error) (string, DeviceInfo, error) {
+	return sm.lock(credentials{keyHash: keyHash, salt: salt, kdf: kdf}, deviceName, recoveryCode, seal)
+}
+
+// credentials is what a seal verifies passwords against:
+// a keyHash, an SRP verifier or key slots.
+type credentials struct {
+	keyHash  []byte
+	verifier []byte
+	salt     []byte
+	kdf      crypto.KDFParams
+	slots    []*keySlot
+}
+
+// setCredentials replaces the seal's credentials with copies of c.
+// Key slots are taken over as they are.
+// Caller must hold s.mu.
+func (s *Session) setCredentials(c credentials) {
+	s.clearVerification()
+	if c.verifier != nil {
+		s.verifier = make([]byte, len(c.verifier))
+		copy(s.verifier, c.verifier)
+	}
+	if c.keyHash != nil {
+		s.keyHash = make([]byte, len(c.keyHash))
+		copy(s.keyHash, c.keyHash)
+	}
+	if c.salt != nil {
+		s.salt = make([]byte, len(c.salt))
+		copy(s.salt, c.salt)
+	}
+	s.kdf = c.kdf
+	s.slots = c.slots
+}
+
+// lock seals the session with a keyHash, an SRP verifier or key slots.
+// seal is called while holding the session exclusively, once nothing else
+// can fail; it replaces the data with its encrypted version and may reject
+// it. Only if seal succeeds is the session locked, together with the hash of
+// recoveryCode, so a seal never ends up without its recovery code.
+func (sm *SessionManager) lock(c credentials, deviceName, recoveryCode string, seal func() error) (string, DeviceInfo, error) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	if sm.session == nil {
 		// Create a new session if none exists
-		token, err := crypto.GenerateSessionTokenString()
-		if err != nil {
-			return err
-		}
 		sm.session = &Session{
-			token:     token,
 			createdAt: time.Now(),
 		}
 	}
@@ -118,15 +187,31 @@ func (sm *SessionManager) Lock(keyHash, salt []byte) error {
 	defer sm.session.mu.Unlock()
 
 	if sm.session.locked {
-		return ErrSessionLocked
+		return "", DeviceInfo{}, ErrSessionLocked
 	}
+	sm.session.guard = sm.guard
 
-	// Store keyHash and salt for verification (cannot derive key from these)
-	sm.session.keyHash = make([]byte, len(keyHash))
-	copy(sm.session.keyHash, keyHash)
+	// The locking device is the first device of the sealed session
+	sm.session.clearDevices()
+	token, device, err := sm.session.issueDeviceToken(deviceName, time.Now())
+	if err != nil {
+		return "", DeviceInfo{}, err
+	}
+
+	if seal != nil {
+		if err := seal(); err != nil {
+			sm.session.clearDevices()
+			return "", DeviceInfo{}, err
+		}
+	}
 
-	sm.session.salt = make([]byte, len(salt))
-	copy(sm.session.salt, salt)
+	// Store keyHash, verifier or key slots and salt for verification (cannot derive key from these)
+	// A recovery code of an earlier seal no longer applies
+	sm.session.setCredentials(c)
+	sm.session.recoveryHash = nil
+	if recoveryCode != "" {
+		sm.session.recoveryHash = hashRecoveryCode(recoveryCode)
+	}
 
 	sm.session.locked = true
 	sm.session.lockedAt = time.Now()
@@ -135,37 +220,17 @@ func (sm *SessionManager) Lock(keyHash, salt []byte) error {
 		sm.session.onLock()
 	}
 
-	return nil
+	sm.publish(Event{Type: EventSessionLocked})
+
+	return token, sm.deviceInfo(device), nil
 }
 
 // VerifyKeyHash checks if the provided keyHash matches using constant-time comparison.
+// For a key slot seal, any slot matches.
 // Returns nil if keyHash is correct, ErrInvalidPassword if wrong.
 func (sm *SessionManager) VerifyKeyHash(keyHash []byte) error {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
-
-	if sm.session == nil {
-		return ErrSessionNotLocked
-	}
-
-	sm.session.mu.RLock()
-	defer sm.session.mu.RUnlock()
-
-	if !sm.session.locked {
-		return ErrSessionNotLocked
-	}
-
-	// Verify keyHash exists (defensive check)
-	if sm.session.keyHash == nil {
-		return errors.New("session keyHash is nil")
-	}
-
-	// Constant-time comparison to prevent timing attacks
-	if !crypto.ConstantTimeCompare(sm.session.keyHash, keyHash) {
-		return ErrInvalidPassword
-	}
-
-	return nil
+	_, err := sm.MatchKeyHash(keyHash, "")
+	return err
 }
 
 // Unlock unlocks the session after keyHash verification.
@@ -185,24 +250,20 @@ func (sm *SessionManager) Unlock() error {
 		return ErrSessionNotLocked
 	}
 
-	// Clear keyHash and salt
-	if sm.session.keyHash != nil {
-		secure.Shred(sm.session.keyHash)
-		sm.session.keyHash = nil
-	}
-
-	if sm.session.salt != nil {
-		secure.Shred(sm.session.salt)
-		sm.session.salt = nil
-	}
+	// Clear keyHash or verifier, salt and recovery code
+	sm.session.clearVerification()
+	sm.session.recoveryHash = nil
 
 	sm.session.locked = false
 	sm.session.lockedAt = time.Time{}
+	sm.session.clearDevices()
 
 	if sm.session.onUnlock != nil {
 		sm.session.onUnlock()
 	}
 
+	sm.publish(Event{Type: EventSessionUnlocked})
+
 	return nil
 }
 
@@ -228,20 +289,15 @@ func (sm *SessionManager) ForceUnlock(shredCallback func()) error {
 		shredCallback()
 	}
 
-	// Clear keyHash
-	if sm.session.keyHash != nil {
-		secure.Shred(sm.session.keyHash)
-		sm.session.keyHash = nil
-	}
-
-	// Clear salt
-	if sm.session.salt != nil {
-		secure.Shred(sm.session.salt)
-		sm.session.salt = nil
-	}
+	// Clear keyHash or verifier, salt and recovery code
+	sm.session.clearVerification()
+	sm.session.recoveryHash = nil
 
 	sm.session.locked = false
 	sm.session.lockedAt = time.Time{}
+	sm.session.clearDevices()
+
+	sm.publish(Event{Type: EventSessionForceUnlocked})
 
 	return nil
 }
@@ -269,19 +325,23 @@ func (sm *SessionManager) GetSalt() []byte {
 	return result
 }
 
-// GetToken returns the session token.
-func (sm *SessionManager) GetToken() string {
+// GetKDF returns the KDF descriptor recorded when the session was sealed.
+// The second value is false if the session is not locked.
+func (sm *SessionManager) GetKDF() (crypto.KDFParams, bool) {
 	sm.mu.RLock()
 	defer sm.mu.RUnlock()
 
 	if sm.session == nil {
-		return ""
+		return crypto.KDFParams{}, false
 	}
 
 	sm.session.mu.RLock()
 	defer sm.session.mu.RUnlock()
 
-	return sm.session.token
+	if !sm.session.locked {
+		return crypto.KDFParams{}, false
+	}
+	return sm.session.kdf, true
 }
 
 // Status returns the current session status.
@@ -348,13 +408,8 @@ func (sm *SessionManager) Destroy() {
 	sm.session.mu.Lock()
 	defer sm.session.mu.Unlock()
 
-	if sm.session.keyHash != nil {
-		secure.Shred(sm.session.keyHash)
-	}
-
-	if sm.session.salt != nil {
-		secure.Shred(sm.session.salt)
-	}
-
+	sm.session.clearVerification()
+	sm.session.recoveryHash = nil
+	sm.session.clearDevices()
 	sm.session = nil
 }
@@ -0,0 +1,345 @@
+package store
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultUnlockMaxFailures is the number of failed unlocks from one IP before it is locked out.
+	DefaultUnlockMaxFailures = 5
+	// DefaultUnlockGlobalLimit is the number of failed unlocks from all IPs
+	// together before every IP is delayed.
+	DefaultUnlockGlobalLimit = 50
+	// DefaultUnlockLockout is how long an IP is locked out after too many failures.
+	DefaultUnlockLockout = 15 * time.Minute
+	// DefaultUnlockBackoffBase is the delay after the first failed unlock.
+	DefaultUnlockBackoffBase = 1 * time.Second
+	// DefaultUnlockBackoffMax caps the exponential backoff delay.
+	DefaultUnlockBackoffMax = 1 * time.Minute
+
+	// maxTrackedIPs bounds the per-IP records; beyond it only the global backoff applies.
+	maxTrackedIPs = 10000
+)
+
+// UnlockGuardConfig configures brute-force protection for unlocking.
+type UnlockGuardConfig struct {
+	MaxFailures int           // Failures from one IP before it is locked out
+	GlobalLimit int           // Failures from all IPs together before every IP is delayed
+	Lockout     time.Duration // How long a locked-out IP must wait
+	BackoffBase time.Duration // Delay after the first failure, doubled for each further one
+	BackoffMax  time.Duration // Longest backoff delay
+	WipeAfter   int           // Shred the session after this many failures in total (0 = never)
+}
+
+// UnlockGuardStats contains failed-unlock counters for the health endpoint.
+type UnlockGuardStats struct {
+	FailedAttempts      int64 `json:"failed_attempts"`      // Failures since the session was sealed
+	ConsecutiveFailures int   `json:"consecutive_failures"` // Recent failures from all IPs (see GlobalLimit)
+	TrackedIPs          int   `json:"tracked_ips"`
+	LockedOutIPs        int   `json:"locked_out_ips"`
+	GlobalBackoff       bool  `json:"global_backoff"` // All IPs are currently delayed
+	WipeAfter           int   `json:"wipe_after,omitempty"`
+	Throttled           int64 `json:"throttled"`             // Attempts rejected while delayed or locked out
+	RejectedForceUnlock int64 `json:"rejected_force_unlock"` // Force-unlocks without valid authorization
+	AbandonedHandshakes int64 `json:"abandoned_handshakes"`  // SRP handshakes that expired without a proof
+}
+
+// unlockRecord tracks failed unlocks from one source.
+type unlockRecord struct {
+	failures    int
+	lastFailure time.Time
+	retryAt     time.Time
+	pending     int // Attempts admitted by Allow and not yet released
+}
+
+// UnlockGuard tracks failed unlock attempts per IP and globally.
+// Each failure doubles the delay before the next attempt is accepted, and an
+// IP reaching MaxFailures is locked out. The global backoff starts once all IPs
+// together pass GlobalLimit, so rotating addresses does not help an attacker.
+// It trades availability for brute-force resistance: past GlobalLimit, every
+// client waits, including those that know the password.
+type UnlockGuard struct {
+	mu sync.Mutex
+
+	config UnlockGuardConfig
+	ips    map[string]*unlockRecord
+	global unlockRecord
+
+	// Counters since the session was sealed
+	total     int64
+	throttled int64
+	recovery  int64 // Rejected force-unlocks
+	abandoned int64 // SRP handshakes that expired without a proof
+
+	// Shutdown signal
+	done chan struct{}
+}
+
+// NewUnlockGuard creates a new unlock guard.
+func NewUnlockGuard(config UnlockGuardConfig) *UnlockGuard {
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = DefaultUnlockMaxFailures
+	}
+	if config.GlobalLimit <= 0 {
+		config.GlobalLimit = DefaultUnlockGlobalLimit
+	}
+	if config.Lockout <= 0 {
+		config.Lockout = DefaultUnlockLockout
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = DefaultUnlockBackoffBase
+	}
+	if config.BackoffMax < config.BackoffBase {
+		config.BackoffMax = DefaultUnlockBackoffMax
+	}
+	if config.WipeAfter < 0 {
+		config.WipeAfter = 0
+	}
+
+	g := &UnlockGuard{
+		config: config,
+		ips:    make(map[string]*unlockRecord),
+		done:   make(chan struct{}),
+	}
+
+	// Start cleanup of stale IP records
+	go g.cleanupLoop()
+
+	return g
+}
+
+// Allow reports whether an unlock attempt from ip may proceed now.
+// If not, it returns how long the client has to wait.
+// An admitted attempt is reserved until the caller calls Release: only one
+// attempt per IP (and, past GlobalLimit, one in total) is in flight at a
+// time, so a parallel burst cannot get ahead of the backoff its failures add.
+func (g *UnlockGuard) Allow(ip string) (time.Duration, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	wait := g.global.retryAt.Sub(now)
+	over := g.global.failures - g.config.GlobalLimit
+	if over >= 0 && g.global.pending > 0 {
+		if d := g.backoff(over + 1); d > wait {
+			wait = d
+		}
+	}
+	rec, exists := g.ips[ip]
+	if exists {
+		if d := rec.retryAt.Sub(now); d > wait {
+			wait = d
+		}
+		if rec.pending > 0 {
+			if d := g.backoff(rec.failures + 1); d > wait {
+				wait = d
+			}
+		}
+	}
+
+	if wait > 0 {
+		g.throttled++
+		return wait, false
+	}
+
+	if !exists && len(g.ips) < maxTrackedIPs {
+		rec = &unlockRecord{}
+		g.ips[ip] = rec
+	}
+	if rec != nil {
+		rec.pending++
+	}
+	g.global.pending++
+	return 0, true
+}
+
+// Release ends an attempt admitted by Allow, after its Success or Failure
+// has been recorded (or without either, if it was rejected before checking
+// the password).
+func (g *UnlockGuard) Release(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if rec, exists := g.ips[ip]; exists && rec.pending > 0 {
+		rec.pending--
+	}
+	if g.global.pending > 0 {
+		g.global.pending--
+	}
+}
+
+// Failure records a failed unlock from ip.
+// It reports whether the total number of failures has reached WipeAfter,
+// in which case the caller must shred the session.
+func (g *UnlockGuard) Failure(ip string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.total++
+	g.fail(ip)
+
+	return g.config.WipeAfter > 0 && g.total >= int64(g.config.WipeAfter)
+}
+
+// ForceUnlockFailure records a force-unlock from ip without valid authorization.
+// It backs off ip like a failed unlock, but never counts toward GlobalLimit,
+// so nobody can lock the owner out of unlocking, nor toward WipeAfter, so
+// guessing cannot be used to shred the session.
+func (g *UnlockGuard) ForceUnlockFailure(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.recovery++
+	g.failIP(ip, time.Now())
+}
+
+// AbandonedHandshake records an SRP handshake from ip that expired without a
+// proof. It backs off ip like a failed unlock, but never counts toward
+// GlobalLimit or WipeAfter: starting handshakes needs no password, so
+// anyone could otherwise delay every client or shred the session.
+func (g *UnlockGuard) AbandonedHandshake(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.abandoned++
+	g.failIP(ip, time.Now())
+}
+
+// fail applies the per-IP and global backoff for a failure from ip.
+// Caller must hold g.mu.
+func (g *UnlockGuard) fail(ip string) {
+	now := time.Now()
+	g.failIP(ip, now)
+
+	g.global.failures++
+	g.global.lastFailure = now
+	if over := g.global.failures - g.config.GlobalLimit; over > 0 {
+		g.global.retryAt = now.Add(g.backoff(over))
+	}
+}
+
+// failIP applies the per-IP backoff for a failure from ip.
+// Caller must hold g.mu.
+func (g *UnlockGuard) failIP(ip string, now time.Time) {
+	rec, exists := g.ips[ip]
+	if !exists && len(g.ips) < maxTrackedIPs {
+		rec = &unlockRecord{}
+		g.ips[ip] = rec
+	}
+	if rec != nil {
+		rec.failures++
+		rec.lastFailure = now
+		if rec.failures >= g.config.MaxFailures {
+			rec.retryAt = now.Add(g.config.Lockout)
+		} else {
+			rec.retryAt = now.Add(g.backoff(rec.failures))
+		}
+	}
+}
+
+// Success clears the backoff for ip. The global backoff is left alone, so a
+// client that knows the password cannot reset it for everyone else; it ends
+// once no failure has been seen for a lockout period. The total failure
+// count is kept until the session is sealed again.
+func (g *UnlockGuard) Success(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.ips, ip)
+}
+
+// Reset clears all counters. Called when the session is sealed or shredded.
+func (g *UnlockGuard) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.ips = make(map[string]*unlockRecord)
+	g.global = unlockRecord{}
+	g.total = 0
+	g.throttled = 0
+	g.recovery = 0
+	g.abandoned = 0
+}
+
+// Stats returns the failed-unlock counters.
+func (g *UnlockGuard) Stats() UnlockGuardStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	lockedOut := 0
+	for _, rec := range g.ips {
+		if rec.failures >= g.config.MaxFailures && rec.retryAt.After(now) {
+			lockedOut++
+		}
+	}
+
+	return UnlockGuardStats{
+		FailedAttempts:      g.total,
+		ConsecutiveFailures: g.global.failures,
+		TrackedIPs:          len(g.ips),
+		LockedOutIPs:        lockedOut,
+		GlobalBackoff:       g.global.retryAt.After(now),
+		WipeAfter:           g.config.WipeAfter,
+		Throttled:           g.throttled,
+		RejectedForceUnlock: g.recovery,
+		AbandonedHandshakes: g.abandoned,
+	}
+}
+
+// Close stops the cleanup goroutine.
+func (g *UnlockGuard) Close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	select {
+	case <-g.done:
+	default:
+		close(g.done)
+	}
+}
+
+// backoff returns the delay after the given number of failures.
+// Caller must hold g.mu.
+func (g *UnlockGuard) backoff(failures int) time.Duration {
+	delay := g.config.BackoffBase
+	for i := 1; i < failures && delay < g.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > g.config.BackoffMax {
+		delay = g.config.BackoffMax
+	}
+	return delay
+}
+
+// cleanupLoop periodically removes stale IP records.
+func (g *UnlockGuard) cleanupLoop() {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.cleanup()
+		case <-g.done:
+			return
+		}
+	}
+}
+
+// cleanup forgets backoff state that has not seen a failure for a lockout period.
+func (g *UnlockGuard) cleanup() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for ip, rec := range g.ips {
+		if rec.pending == 0 && now.After(rec.retryAt) && now.Sub(rec.lastFailure) > g.config.Lockout {
+			delete(g.ips, ip)
+		}
+	}
+	if g.global.failures > 0 && now.After(g.global.retryAt) && now.Sub(g.global.lastFailure) > g.config.Lockout {
+		g.global = unlockRecord{pending: g.global.pending}
+	}
+}
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestUnlockSuccessKeepsGlobalBackoff(t *testing.T) {
+	g := NewUnlockGuard(UnlockGuardConfig{MaxFailures: 5, GlobalLimit: 3, BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(g.Close)
+
+	// One failure from each of several IPs stays below the per-IP limit
+	for i := 0; i < 4; i++ {
+		g.Failure(fmt.Sprintf("198.51.100.%d", i))
+	}
+	if !g.Stats().GlobalBackoff {
+		t.Fatal("no global backoff after passing the global limit")
+	}
+
+	g.Success("203.0.113.7")
+	if _, ok := g.Allow("203.0.113.8"); ok {
+		t.Error("a success from another IP lifted the global backoff")
+	}
+}
+
+func TestUnlockGlobalLimitIsSeparateFromPerIPLimit(t *testing.T) {
+	g := NewUnlockGuard(UnlockGuardConfig{MaxFailures: 2, GlobalLimit: 10, BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(g.Close)
+
+	g.Failure("198.51.100.1")
+	g.Failure("198.51.100.1")
+	g.Failure("198.51.100.2")
+
+	if stats := g.Stats(); stats.LockedOutIPs != 1 || stats.GlobalBackoff {
+		t.Errorf("locked out %d IPs, global backoff %v; want 1 IP and no global backoff", stats.LockedOutIPs, stats.GlobalBackoff)
+	}
+	if _, ok := g.Allow("203.0.113.7"); !ok {
+		t.Error("an IP without failures was delayed below the global limit")
+	}
+}
+
+func TestUnlockAllowsOneAttemptPerIPAtATime(t *testing.T) {
+	g := NewUnlockGuard(UnlockGuardConfig{MaxFailures: 5, GlobalLimit: 10, BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(g.Close)
+
+	// A parallel burst from one IP gets a single attempt in before the backoff
+	if _, ok := g.Allow("198.51.100.1"); !ok {
+		t.Fatal("first attempt was delayed")
+	}
+	if wait, ok := g.Allow("198.51.100.1"); ok || wait <= 0 {
+		t.Errorf("second attempt in flight: allowed %v, wait %v", ok, wait)
+	}
+	if _, ok := g.Allow("198.51.100.2"); !ok {
+		t.Error("an attempt from another IP was delayed")
+	}
+
+	// The failure of the admitted attempt delays the next one
+	g.Failure("198.51.100.1")
+	g.Release("198.51.100.1")
+	if _, ok := g.Allow("198.51.100.1"); ok {
+		t.Error("attempt allowed right after a failure")
+	}
+
+	// An attempt released without a verdict frees the slot
+	g.Release("198.51.100.2")
+	if _, ok := g.Allow("198.51.100.2"); !ok {
+		t.Error("attempt delayed after the previous one was released")
+	}
+}
+
+func TestUnlockAllowsOneAttemptAtATimePastGlobalLimit(t *testing.T) {
+	g := NewUnlockGuard(UnlockGuardConfig{MaxFailures: 5, GlobalLimit: 2, BackoffBase: time.Millisecond, BackoffMax: time.Millisecond})
+	t.Cleanup(g.Close)
+
+	for i := 0; i < 2; i++ {
+		g.Failure(fmt.Sprintf("198.51.100.%d", i))
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Rotating addresses does not allow a parallel burst either
+	if _, ok := g.Allow("203.0.113.1"); !ok {
+		t.Fatal("attempt delayed after the backoff ended")
+	}
+	if _, ok := g.Allow("203.0.113.2"); ok {
+		t.Error("second attempt in flight past the global limit was allowed")
+	}
+	g.Release("203.0.113.1")
+	if _, ok := g.Allow("203.0.113.2"); !ok {
+		t.Error("attempt delayed after the previous one was released")
+	}
+}
+
+func TestAbandonedHandshakesSkipGlobalBackoff(t *testing.T) {
+	g := NewUnlockGuard(UnlockGuardConfig{MaxFailures: 5, GlobalLimit: 2, BackoffBase: time.Minute, BackoffMax: time.Minute})
+	t.Cleanup(g.Close)
+
+	// Starting handshakes needs no password, so they must not delay everyone
+	for i := 0; i < 5; i++ {
+		g.AbandonedHandshake(fmt.Sprintf("198.51.100.%d", i))
+	}
+	if stats := g.Stats(); stats.GlobalBackoff || stats.ConsecutiveFailures != 0 {
+		t.Errorf("global backoff %v after %d failures, want none", stats.GlobalBackoff, stats.ConsecutiveFailures)
+	}
+	if _, ok := g.Allow("203.0.113.7"); !ok {
+		t.Error("an IP without failures was delayed by abandoned handshakes")
+	}
+	if _, ok := g.Allow("198.51.100.1"); ok {
+		t.Error("IP that abandoned a handshake is not backed off")
+	}
+}
+
+func TestRejectedForceUnlocksSkipGlobalBackoff(t *testing.T) {
+	g := NewUnlockGuard(UnlockGuardConfig{MaxFailures: 5, GlobalLimit: 2, BackoffBase: time.Minute, BackoffMax: time.Minute, WipeAfter: 3})
+	t.Cleanup(g.Close)
+
+	// Guessing recovery codes must not lock the owner out of unlocking
+	for i := 0; i < 5; i++ {
+		g.ForceUnlockFailure(fmt.Sprintf("198.51.100.%d", i))
+	}
+	if stats := g.Stats(); stats.GlobalBackoff || stats.FailedAttempts != 0 || stats.RejectedForceUnlock != 5 {
+		t.Errorf("stats = %+v, want 5 rejected force-unlocks and no global backoff", stats)
+	}
+	if _, ok := g.Allow("203.0.113.7"); !ok {
+		t.Error("an IP without failures was delayed by rejected force-unlocks")
+	}
+	if _, ok := g.Allow("198.51.100.1"); ok {
+		t.Error("IP with a rejected force-unlock is not backed off")
+	}
+}
@@ -0,0 +1,9 @@
+package store
+
+import "errors"
+
+// ErrVersionMismatch indicates a conditional update was based on a stale version.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// AnyVersion disables the version check in conditional updates.
+const AnyVersion uint64 = 0
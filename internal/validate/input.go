@@ -7,31 +7,70 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
 	// FileIDLength is the expected length of a file ID in hex characters.
 	FileIDLength = 16
+	// ClipboardEntryIDLength is the expected length of a clipboard history entry ID in hex characters.
+	ClipboardEntryIDLength = 16
+	// DeviceIDLength is the expected length of a device ID in hex characters.
+	DeviceIDLength = 16
+	// RoomCodeLength is the expected length of a room code in hex characters.
+	RoomCodeLength = 16
 	// SessionTokenLength is the expected length of a session token in hex characters.
 	SessionTokenLength = 64
 	// MaxClipboardSize is the maximum size of clipboard content (1MB).
 	MaxClipboardSize = 1 * 1024 * 1024
+	// MaxClipboardImageSize is the maximum size of a clipboard image (5MB).
+	MaxClipboardImageSize = 5 * 1024 * 1024
 	// MaxFilenameLength is the maximum allowed filename length.
 	MaxFilenameLength = 255
+	// MaxChannelNameLength is the maximum length of a clipboard channel name.
+	MaxChannelNameLength = 32
+	// MaxDeviceNameLength is the maximum length of a device name in characters.
+	MaxDeviceNameLength = 64
+	// DefaultDeviceName is used when a client does not name its device.
+	DefaultDeviceName = "Unnamed device"
 )
 
 var (
 	// ErrInvalidFileID indicates an invalid file ID format.
 	ErrInvalidFileID = errors.New("invalid file ID: must be 16 hex characters")
+	// ErrInvalidClipboardEntryID indicates an invalid clipboard history entry ID format.
+	ErrInvalidClipboardEntryID = errors.New("invalid clipboard entry ID: must be 16 hex characters")
+	// ErrInvalidDeviceID indicates an invalid device ID format.
+	ErrInvalidDeviceID = errors.New("invalid device ID: must be 16 hex characters")
+	// ErrInvalidRoomCode indicates an invalid room code format.
+	ErrInvalidRoomCode = errors.New("invalid room code: must be 16 hex characters")
 	// ErrInvalidSessionToken indicates an invalid session token format.
 	ErrInvalidSessionToken = errors.New("invalid session token: must be 64 hex characters")
 	// ErrClipboardTooLarge indicates the clipboard content exceeds the size limit.
 	ErrClipboardTooLarge = errors.New("clipboard content too large")
 	// ErrEmptyInput indicates empty input where content is required.
 	ErrEmptyInput = errors.New("input cannot be empty")
+	// ErrInvalidChannelName indicates an invalid clipboard channel name.
+	ErrInvalidChannelName = errors.New("invalid channel name: use 1-32 of a-z, 0-9, '-' or '_'")
+	// ErrReservedChannelName indicates a channel name that collides with an API route.
+	ErrReservedChannelName = errors.New("channel name is reserved")
+	// ErrTTLOutOfRange indicates a requested TTL outside the configured bounds.
+	ErrTTLOutOfRange = errors.New("ttl_seconds is outside the allowed range")
 
 	// hexPattern matches valid hex strings
 	hexPattern = regexp.MustCompile(`^[a-fA-F0-9]+$`)
+
+	// channelNamePattern matches valid (lowercase) channel names
+	channelNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+	// reservedChannelNames are path segments used by /api/clipboard/* routes
+	reservedChannelNames = map[string]bool{
+		"history": true,
+		"live":    true,
+		"raw":     true,
+	}
 )
 
 // FileID validates and normalizes a file ID.
@@ -56,6 +95,46 @@ func FileID(id string) (string, error) {
 	return strings.ToLower(id), nil
 }
 
+// ClipboardEntryID validates and normalizes a clipboard history entry ID.
+// Entry IDs must be exactly 16 hex characters (64 bits).
+// Returns the lowercase normalized ID or an error.
+func ClipboardEntryID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+
+	if len(id) != ClipboardEntryIDLength || !hexPattern.MatchString(id) {
+		return "", ErrInvalidClipboardEntryID
+	}
+
+	return strings.ToLower(id), nil
+}
+
+// DeviceID validates and normalizes a device ID.
+// Device IDs must be exactly 16 hex characters (64 bits).
+// Returns the lowercase normalized ID or an error.
+func DeviceID(id string) (string, error) {
+	id = strings.TrimSpace(id)
+
+	if len(id) != DeviceIDLength || !hexPattern.MatchString(id) {
+		return "", ErrInvalidDeviceID
+	}
+
+	return strings.ToLower(id), nil
+}
+
+// RoomCode validates and normalizes a room code.
+// Room codes are 16 hex characters (64 bits); dashes and spaces people add
+// when typing a code are ignored.
+// Returns the lowercase normalized code or an error.
+func RoomCode(code string) (string, error) {
+	code = strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code))
+
+	if len(code) != RoomCodeLength || !hexPattern.MatchString(code) {
+		return "", ErrInvalidRoomCode
+	}
+
+	return strings.ToLower(code), nil
+}
+
 // SessionToken validates a session token.
 // Session tokens must be exactly 64 hex characters (256 bits).
 // Returns the lowercase normalized token or an error.
@@ -78,6 +157,45 @@ func SessionToken(token string) (string, error) {
 	return strings.ToLower(token), nil
 }
 
+// DeviceName normalizes a user-supplied device name.
+// Control characters are dropped and the name is cut to MaxDeviceNameLength
+// characters; an empty name becomes DefaultDeviceName.
+func DeviceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == utf8.RuneError {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if runes := []rune(name); len(runes) > MaxDeviceNameLength {
+		name = strings.TrimSpace(string(runes[:MaxDeviceNameLength]))
+	}
+	if name == "" {
+		return DefaultDeviceName
+	}
+	return name
+}
+
+// ChannelName validates and normalizes a clipboard channel name.
+// Names are case-insensitive, 1-32 characters of a-z, 0-9, '-' and '_',
+// and must not collide with fixed /api/clipboard/* routes.
+// Returns the lowercase normalized name or an error.
+func ChannelName(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	if len(name) == 0 || len(name) > MaxChannelNameLength || !channelNamePattern.MatchString(name) {
+		return "", ErrInvalidChannelName
+	}
+
+	if reservedChannelNames[name] {
+		return "", ErrReservedChannelName
+	}
+
+	return name, nil
+}
+
 // ClipboardContent validates clipboard text content.
 // Content must not exceed MaxClipboardSize.
 // Returns the content (trimmed of leading/trailing whitespace) or an error.
@@ -117,3 +235,22 @@ func MaxLength(s string, maxLen int) error {
 	}
 	return nil
 }
+
+// TTL validates a requested lifetime in seconds against the configured bounds.
+// Zero means no TTL was requested and returns 0 (use the default).
+func TTL(seconds int64, min, max time.Duration) (time.Duration, error) {
+	if seconds == 0 {
+		return 0, nil
+	}
+
+	if seconds < 0 || seconds > int64(max/time.Second) {
+		return 0, ErrTTLOutOfRange
+	}
+
+	ttl := time.Duration(seconds) * time.Second
+	if ttl < min || ttl > max {
+		return 0, ErrTTLOutOfRange
+	}
+
+	return ttl, nil
+}
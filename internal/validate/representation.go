@@ -0,0 +1,168 @@
+package validate
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Clipboard representation MIME types.
+const (
+	// MIMETextPlain is the plain text representation (required for text entries).
+	MIMETextPlain = "text/plain"
+	// MIMETextHTML is the HTML representation.
+	MIMETextHTML = "text/html"
+	// MIMEURIList is a list of URLs (RFC 2483).
+	MIMEURIList = "text/uri-list"
+	// MIMEFileRefs is a JSON array of shared file IDs.
+	MIMEFileRefs = "application/x-fileez-file-refs+json"
+)
+
+var (
+	// ErrRepresentationUnsupported indicates a MIME type that cannot be stored as a clipboard representation.
+	ErrRepresentationUnsupported = errors.New("unsupported clipboard representation")
+	// ErrRepresentationInvalid indicates representation content that does not match its MIME type.
+	ErrRepresentationInvalid = errors.New("invalid clipboard representation content")
+	// ErrRepresentationDuplicate indicates the same MIME type was given twice.
+	ErrRepresentationDuplicate = errors.New("duplicate clipboard representation")
+	// ErrRepresentationMissingText indicates a text entry without a text/plain representation.
+	ErrRepresentationMissingText = errors.New("text/plain representation is required")
+	// ErrURIScheme indicates a URI with a scheme that is not allowed in a URI list.
+	ErrURIScheme = errors.New("URI scheme not allowed")
+
+	// allowedURISchemes are the schemes accepted in text/uri-list content
+	allowedURISchemes = map[string]bool{
+		"http":   true,
+		"https":  true,
+		"ftp":    true,
+		"mailto": true,
+	}
+)
+
+// MaxFileRefs is the maximum number of file references in one representation.
+const MaxFileRefs = 100
+
+// RepresentationMIMEType normalizes a clipboard representation MIME type.
+// Parameters (such as charset) are dropped: text representations are always UTF-8.
+// Returns the bare lowercase type or an error if it is not supported.
+func RepresentationMIMEType(mimeType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return "", ErrRepresentationUnsupported
+	}
+
+	switch mediaType {
+	case MIMETextPlain, MIMETextHTML, MIMEURIList, MIMEFileRefs:
+		return mediaType, nil
+	default:
+		return "", ErrRepresentationUnsupported
+	}
+}
+
+// Representation validates the content of a clipboard representation.
+// mimeType must already be normalized by RepresentationMIMEType.
+// Returns the normalized content or an error.
+func Representation(mimeType string, content string) (string, error) {
+	if len(content) > MaxClipboardSize {
+		return "", ErrClipboardTooLarge
+	}
+	if !utf8.ValidString(content) {
+		return "", ErrRepresentationInvalid
+	}
+
+	switch mimeType {
+	case MIMETextPlain:
+		text, err := ClipboardContent(content)
+		if err != nil {
+			return "", err
+		}
+		return NonEmpty(text)
+	case MIMETextHTML:
+		if strings.TrimSpace(content) == "" {
+			return "", ErrEmptyInput
+		}
+		return content, nil
+	case MIMEURIList:
+		return URIList(content)
+	case MIMEFileRefs:
+		return FileRefs(content)
+	default:
+		return "", ErrRepresentationUnsupported
+	}
+}
+
+// URIList validates a text/uri-list (RFC 2483).
+// Comment lines starting with '#' are kept; every other line must be an
+// absolute URI with an allowed scheme (no javascript:, data:, file:, ...).
+// Returns the list with CRLF line endings.
+func URIList(content string) (string, error) {
+	lines := strings.FieldsFunc(content, func(r rune) bool {
+		return r == '\r' || r == '\n'
+	})
+
+	var kept []string
+	uris := 0
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			kept = append(kept, line)
+			continue
+		}
+
+		u, err := url.Parse(line)
+		if err != nil || !u.IsAbs() {
+			return "", ErrRepresentationInvalid
+		}
+		if !allowedURISchemes[strings.ToLower(u.Scheme)] {
+			return "", ErrURIScheme
+		}
+		kept = append(kept, line)
+		uris++
+	}
+
+	if uris == 0 {
+		return "", ErrEmptyInput
+	}
+
+	return strings.Join(kept, "\r\n") + "\r\n", nil
+}
+
+// FileRefs validates a JSON array of shared file IDs.
+// Returns the array re-encoded with normalized IDs.
+func FileRefs(content string) (string, error) {
+	var ids []string
+	if err := json.Unmarshal([]byte(content), &ids); err != nil {
+		return "", ErrRepresentationInvalid
+	}
+	if len(ids) == 0 {
+		return "", ErrEmptyInput
+	}
+	if len(ids) > MaxFileRefs {
+		return "", ErrRepresentationInvalid
+	}
+
+	seen := make(map[string]bool, len(ids))
+	for i, id := range ids {
+		normalized, err := FileID(id)
+		if err != nil {
+			return "", err
+		}
+		if seen[normalized] {
+			return "", ErrRepresentationDuplicate
+		}
+		seen[normalized] = true
+		ids[i] = normalized
+	}
+
+	encoded, err := json.Marshal(ids)
+	if err != nil {
+		return "", ErrRepresentationInvalid
+	}
+	return string(encoded), nil
+}
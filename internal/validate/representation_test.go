@@ -0,0 +1,62 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURIList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		err     error
+	}{
+		{"one URI", "https://example.com/a", "https://example.com/a\r\n", nil},
+		{"LF endings and comments", "# shared links\nhttps://example.com/a\n\nmailto:someone@example.com\n", "# shared links\r\nhttps://example.com/a\r\nmailto:someone@example.com\r\n", nil},
+		{"only comments", "# nothing here\n", "", ErrEmptyInput},
+		{"relative URI", "/etc/passwd", "", ErrRepresentationInvalid},
+		{"javascript scheme", "javascript:alert(1)", "", ErrURIScheme},
+		{"file scheme", "https://example.com\nfile:///etc/passwd", "", ErrURIScheme},
+		{"data scheme", "DATA:text/html,<script>", "", ErrURIScheme},
+	}
+
+	for _, tt := range tests {
+		got, err := URIList(tt.content)
+		if err != tt.err {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFileRefs(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		err     error
+	}{
+		{"normalized IDs", `[" 00000000000000AB ", "0000000000000001"]`, `["00000000000000ab","0000000000000001"]`, nil},
+		{"not JSON", `00000000000000ab`, "", ErrRepresentationInvalid},
+		{"not strings", `[1, 2]`, "", ErrRepresentationInvalid},
+		{"empty", `[]`, "", ErrEmptyInput},
+		{"invalid ID", `["../../etc/passwd"]`, "", ErrInvalidFileID},
+		{"duplicate", `["00000000000000ab", "00000000000000AB"]`, "", ErrRepresentationDuplicate},
+		{"too many", `[` + strings.Repeat(`"0000000000000001",`, MaxFileRefs) + `"0000000000000002"]`, "", ErrRepresentationInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := FileRefs(tt.content)
+		if err != tt.err {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}